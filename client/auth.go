@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// tokenExpirySkew is subtracted from token lifetimes so we refresh slightly before the server rejects them
+const tokenExpirySkew = 30 * time.Second
+
+// AuthProvider attaches credentials to an outgoing request.
+// Implementations must be safe for concurrent use.
+type AuthProvider interface {
+	Authenticate(req *resty.Request) error
+}
+
+// BasicAuth authenticates with a static username and password
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements AuthProvider
+func (b BasicAuth) Authenticate(req *resty.Request) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
+
+// tokenResponse is the standard OAuth2 token endpoint response
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+}
+
+// expiry returns the point in time after which the token should be considered stale
+func (t *tokenResponse) expiry() time.Time {
+	if t.ExpiresIn <= 0 {
+		// No lifetime given, treat it as short lived so we don't cache a revoked token forever
+		return time.Now().Add(5 * time.Minute)
+	}
+	return time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - tokenExpirySkew)
+}
+
+// requestToken posts a form to an OAuth2 token endpoint using client_secret_basic authentication
+func requestToken(ctx context.Context, httpClient *resty.Client, tokenURL, clientID, clientSecret string, form url.Values) (*tokenResponse, error) {
+	var tok tokenResponse
+	req := httpClient.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetFormDataFromValues(form).
+		SetResult(&tok)
+	if clientID != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+
+	resp, err := req.Post(tokenURL)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token response did not contain an access_token")
+	}
+	return &tok, nil
+}
+
+// clientAuthentication returns the basic auth credentials for a token request. With a client
+// certificate the certificate authenticates and client_id only identifies, in the form.
+func clientAuthentication(form url.Values, clientID, clientSecret string, mtls bool) (string, string) {
+	if mtls {
+		form.Set("client_id", clientID)
+		return "", ""
+	}
+	return clientID, clientSecret
+}
+
+// ClientCredentialsAuth authenticates with a bearer token obtained through the OAuth2
+// client_credentials grant, e.g. against an XSUAA service instance.
+// The token is cached and refreshed shortly before it expires.
+type ClientCredentialsAuth struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *resty.Client
+	mtls         bool
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewClientCredentialsAuth creates a client_credentials provider for the given token endpoint
+func NewClientCredentialsAuth(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentialsAuth {
+	return &ClientCredentialsAuth{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		httpClient:   resty.New().SetTimeout(30 * time.Second),
+	}
+}
+
+// Authenticate implements AuthProvider
+func (c *ClientCredentialsAuth) Authenticate(req *resty.Request) error {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.SetAuthToken(token)
+	return nil
+}
+
+// SetCertificate authenticates at the token endpoint with a client certificate
+// (tls_client_auth) instead of the client secret, e.g. for x509 XSUAA bindings
+func (c *ClientCredentialsAuth) SetCertificate(cert tls.Certificate) {
+	c.httpClient.SetCertificates(cert)
+	c.mtls = true
+}
+
+// Token returns a valid access token, fetching a new one if the cached token expired
+func (c *ClientCredentialsAuth) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+
+	clientID, clientSecret := clientAuthentication(form, c.clientID, c.clientSecret, c.mtls)
+	tok, err := requestToken(ctx, c.httpClient, c.tokenURL, clientID, clientSecret, form)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = tok.AccessToken
+	c.expiry = tok.expiry()
+	return c.token, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+)
+
+// AuthProviderFromConfig builds the AuthProvider described by cfg: an OAuth provider for the
+// configured grant type when a token URL is set, basic authentication otherwise. It returns
+// nil when cfg holds no credentials.
+func AuthProviderFromConfig(cfg *config.Config) (AuthProvider, error) {
+	if cfg.OAuthTokenURL == "" {
+		if cfg.SAPUsername == "" {
+			return nil, nil
+		}
+		return BasicAuth{Username: cfg.SAPUsername, Password: cfg.SAPPassword}, nil
+	}
+
+	var cert *tls.Certificate
+	if cfg.OAuthCertFile != "" {
+		c, err := tls.LoadX509KeyPair(cfg.OAuthCertFile, cfg.OAuthKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading OAuth client certificate: %w", err)
+		}
+		cert = &c
+	}
+
+	var grant string
+	switch cfg.OAuthGrantType {
+	case "", config.GrantClientCredentials:
+		auth := NewClientCredentialsAuth(cfg.OAuthTokenURL, cfg.OAuthClientID, cfg.OAuthClientSecret, cfg.OAuthScopes...)
+		if cert != nil {
+			auth.SetCertificate(*cert)
+		}
+		return auth, nil
+	case config.GrantJWTBearer:
+		grant = GrantJWTBearer
+	case config.GrantTokenExchange:
+		grant = GrantTokenExchange
+	case config.GrantSAML2Bearer:
+		grant = GrantSAML2Bearer
+	default:
+		return nil, fmt.Errorf("unsupported OAuth grant type %q", cfg.OAuthGrantType)
+	}
+
+	auth := NewTokenExchangeAuth(cfg.OAuthTokenURL, cfg.OAuthClientID, cfg.OAuthClientSecret, grant)
+	auth.Scopes = cfg.OAuthScopes
+	if cert != nil {
+		auth.SetCertificate(*cert)
+	}
+	return auth, nil
+}
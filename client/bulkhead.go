@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrBulkheadFull is returned when no slot frees up within the bulkhead's MaxWait
+var ErrBulkheadFull = errors.New("bulkhead full")
+
+// Bulkhead limits the number of concurrent requests, so that one heavy consumer cannot take
+// every connection to the gateway. Set it on the client to limit per host, or on a
+// Service (odata.Service.WithBulkhead) to isolate consumers sharing the client.
+type Bulkhead struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// NewBulkhead allows maxConcurrent requests in flight. Further requests wait for a slot up to
+// maxWait and then fail with ErrBulkheadFull; zero maxWait waits as long as the context allows.
+func NewBulkhead(maxConcurrent int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{slots: make(chan struct{}, max(maxConcurrent, 1)), maxWait: maxWait}
+}
+
+// Acquire takes a slot; call release when the request is done
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		t := time.NewTimer(b.maxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-timeout:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+// InFlight returns the number of slots in use
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// SetBulkhead limits the concurrent requests of the client; nil removes the limit
+func (s *SAPClient) SetBulkhead(b *Bulkhead) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bulkhead = b
+}
+
+// limiter is a Bulkhead, a Scheduler or the rate limit
+type limiter interface {
+	Acquire(ctx context.Context) (func(), error)
+}
+
+// acquire takes the slots of the request's and the client's bulkheads, the client's
+// scheduler and a rate limit token. The request's own bulkhead comes first so a saturated consumer waits there
+// instead of holding client-wide slots.
+func (s *SAPClient) acquire(ctx context.Context, r *Request) (func(), error) {
+	var limiters []limiter
+	if r.Bulkhead != nil {
+		limiters = append(limiters, r.Bulkhead)
+	}
+	s.mu.RLock()
+	if s.bulkhead != nil {
+		limiters = append(limiters, s.bulkhead)
+	}
+	if s.scheduler != nil {
+		limiters = append(limiters, s.scheduler)
+	}
+	if s.rateLimit != nil {
+		limiters = append(limiters, s.rateLimit) // last, so tokens are spent only by requests about to run
+	}
+	s.mu.RUnlock()
+
+	var releases []func()
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	for _, l := range limiters {
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+// releaseWhenDone frees the bulkhead slots now, or for streamed responses once the
+// caller closes the body, since the connection stays busy until then
+func releaseWhenDone(r *Request, resp *resty.Response, release func()) {
+	if r.Stream && resp != nil && resp.RawResponse != nil {
+		resp.RawResponse.Body = &releasingBody{ReadCloser: resp.RawResponse.Body, release: release}
+		return
+	}
+	release()
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	done    bool
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.done {
+		b.done = true
+		b.release()
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBulkheadAcquire(t *testing.T) {
+	b := NewBulkhead(2, 20*time.Millisecond)
+	r1, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.InFlight(); got != 2 {
+		t.Errorf("InFlight() = %d, want 2", got)
+	}
+
+	if _, err := b.Acquire(context.Background()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("third Acquire: err = %v, want ErrBulkheadFull", err)
+	}
+
+	full := NewBulkhead(1, 0) // waits as long as the context allows
+	if _, err := full.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := full.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with a cancelled context: err = %v, want context.Canceled", err)
+	}
+
+	r1()
+	r3, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire after a release: %v", err)
+	}
+	r2()
+	r3()
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() after releasing all = %d, want 0", got)
+	}
+}
+
+func TestBulkheadWaitsForSlot(t *testing.T) {
+	b := NewBulkhead(1, time.Second)
+	release, _ := b.Acquire(context.Background())
+	time.AfterFunc(10*time.Millisecond, release)
+	if _, err := b.Acquire(context.Background()); err != nil {
+		t.Errorf("Acquire waiting for a released slot: %v", err)
+	}
+}
+
+func TestClientBulkheads(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := NewSAPClient(srv.URL, "", "")
+	c.SetBulkhead(NewBulkhead(3, 0))
+	service := NewBulkhead(2, 0) // a consumer limited below the client
+
+	var wg sync.WaitGroup
+	for i := range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &Request{Method: http.MethodGet, URL: "/svc/ProductSet"}
+			if i%2 == 0 {
+				r.Bulkhead = service
+			}
+			if _, err := c.Do(context.Background(), r); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 3 {
+		t.Errorf("%d requests in flight, want at most 3", got)
+	}
+	if got := service.InFlight(); got != 0 {
+		t.Errorf("service bulkhead holds %d slots after all requests, want 0", got)
+	}
+}
+
+func TestBulkheadStreamHoldsSlot(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"d":{"results":[]}}`))
+	}))
+	defer srv.Close()
+
+	c := NewSAPClient(srv.URL, "", "")
+	b := NewBulkhead(1, 10*time.Millisecond)
+	c.SetBulkhead(b)
+
+	resp, err := c.Do(context.Background(), &Request{Method: http.MethodGet, URL: "/svc/ProductSet", Stream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.InFlight(); got != 1 {
+		t.Errorf("InFlight() while the stream is open = %d, want 1", got)
+	}
+	resp.RawBody().Close()
+	resp.RawBody().Close() // a second Close must not release another slot
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() after closing the stream = %d, want 0", got)
+	}
+}
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,26 +21,83 @@ const (
 type SAPClient struct {
 	client      *resty.Client
 	baseURL     string
+	readURL     string // read replica, see SetReadReplica
+	sapClient   string
+	language    string
 	csrfToken   string
 	csrfCookies []*http.Cookie
+	healthPath  string
+	slow        *slowLog
+	retry       *RetryPolicy
+	bulkhead    *Bulkhead
+	scheduler   *Scheduler
+	rateLimit   *rateLimiter
+	logger      *slog.Logger
 	mu          sync.RWMutex
+
+	beforeRequest []BeforeRequestHook
+	afterResponse []AfterResponseHook
+
+	// auth and conn are guarded separately from mu because RefreshCSRFToken holds mu
+	// while its own request passes through the authentication middleware.
+	auth   AuthProvider
+	conn   *connectivity
+	authMu sync.RWMutex
+
+	inflight inflight // requests in Do, see Close
 }
 
-// NewSAPClient initializes the Resty client with basic auth and defaults
+// NewSAPClient initializes the Resty client with basic auth and defaults.
+// It is shorthand for New(baseURL, WithBasicAuth(username, password)).
 func NewSAPClient(baseURL, username, password string) *SAPClient {
+	return New(baseURL, WithBasicAuth(username, password))
+}
+
+// New creates a client for the SAP system at baseURL, configured by opts
+func New(baseURL string, opts ...Option) *SAPClient {
 	r := resty.New()
 	r.SetBaseURL(baseURL)
-	r.SetBasicAuth(username, password)
 
 	// Set default timeouts and headers
 	r.SetTimeout(time.Second * 30)
 	r.SetHeader("Accept", "application/json")
 	r.SetHeader("Content-Type", "application/json")
 
-	return &SAPClient{
+	s := &SAPClient{
 		client:  r,
 		baseURL: baseURL,
 	}
+	r.OnBeforeRequest(s.authenticate)
+	r.OnBeforeRequest(s.applyConnectivity)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetAuthProvider replaces the credentials used for every subsequent request
+func (s *SAPClient) SetAuthProvider(p AuthProvider) {
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	s.auth = p
+}
+
+// authenticate is the resty middleware applying the configured AuthProvider
+func (s *SAPClient) authenticate(_ *resty.Client, req *resty.Request) error {
+	s.authMu.RLock()
+	p := s.auth
+	s.authMu.RUnlock()
+	if e, ok := req.Context().Value(endpointKey{}).(*endpoint); ok {
+		p = e.auth
+	}
+
+	if p == nil {
+		return nil
+	}
+	if err := p.Authenticate(req); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	return nil
 }
 
 // SetDebug enables resty debug mode
@@ -45,6 +105,13 @@ func (s *SAPClient) SetDebug(debug bool) {
 	s.client.SetDebug(debug)
 }
 
+// Language returns the logon language sent as sap-language, "" if none is configured
+func (s *SAPClient) Language() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.language
+}
+
 // GetClient returns the underlying resty client if direct access is needed
 func (s *SAPClient) GetClient() *resty.Client {
 	return s.client
@@ -53,23 +120,117 @@ func (s *SAPClient) GetClient() *resty.Client {
 // executeRequest wraps the resty request execution with CSRF handling.
 // It takes a function meant to build and execute the request.
 func (s *SAPClient) ExecuteRequest(method, url string, body interface{}, queryParams map[string]string) (*resty.Response, error) {
+	return s.ExecuteRequestContext(context.Background(), method, url, body, queryParams)
+}
+
+// ExecuteRequestContext is like ExecuteRequest but binds the request (and any CSRF refresh) to ctx
+func (s *SAPClient) ExecuteRequestContext(ctx context.Context, method, url string, body interface{}, queryParams map[string]string) (*resty.Response, error) {
+	return s.Do(ctx, &Request{
+		Method:      method,
+		URL:         url,
+		Body:        body,
+		QueryParams: queryParams,
+	})
+}
+
+// Request describes a single call for Do. Only Method and URL are required.
+type Request struct {
+	Method      string
+	URL         string
+	Body        interface{}
+	QueryParams map[string]string
+	// Headers override the client defaults, e.g. a multipart Content-Type for $batch
+	Headers map[string]string
+	// Cookies are sent in addition to the cookies managed by the client, replacing those of
+	// the same name, e.g. the session cookies of the CSRF token
+	Cookies []*http.Cookie
+	// Stream leaves the response body unread; the caller must close resp.RawBody()
+	Stream bool
+	// Retryable lets the RetryPolicy repeat the request whatever its method, see WithRetryable
+	Retryable bool
+	// Bulkhead limits concurrency in addition to the client's bulkhead
+	Bulkhead *Bulkhead
+	// Stats, if set, is filled in while the request is executed
+	Stats *RequestStats
+	// CSRFURL is where a CSRF token is fetched for this request, e.g. the service root for a
+	// $batch request, which cannot be fetched from. If set, a token is fetched before a
+	// modifying request is sent without one, rather than after it was refused. Defaults to URL.
+	CSRFURL string
+}
+
+// RequestStats describes how a Request was executed
+type RequestStats struct {
+	// Attempts counts the HTTP requests sent, including retries and the repeat after a
+	// CSRF token refresh
+	Attempts      int
+	CSRFRefreshes int
+	// BytesSent is the size of the request bodies sent
+	BytesSent int64
+}
+
+// sent records an HTTP request of an attempt
+func (st *RequestStats) sent(resp *resty.Response) {
+	if st == nil {
+		return
+	}
+	st.Attempts++
+	if resp != nil && resp.Request != nil && resp.Request.RawRequest != nil && resp.Request.RawRequest.ContentLength > 0 {
+		st.BytesSent += resp.Request.RawRequest.ContentLength
+	}
+}
+
+// Do executes r with the same CSRF handling as ExecuteRequest
+func (s *SAPClient) Do(ctx context.Context, r *Request) (*resty.Response, error) {
+	done, err := s.inflight.begin()
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.pinEndpoint(ctx)
+	before, after := s.hooks()
+	r, err = runBeforeRequest(ctx, before, r)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	acquired, err := s.acquire(ctx, r)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	release := func() {
+		acquired()
+		done()
+	}
+
+	start := time.Now()
+	resp, err := s.doWithRetry(ctx, r)
+	if slow := s.slowLog(); slow != nil {
+		slow.logIfSlow(ctx, r, resp, err, time.Since(start))
+	}
+	releaseWhenDone(r, resp, release)
+	for _, h := range after {
+		h(ctx, r, resp, err)
+	}
+	return resp, err
+}
+
+func (s *SAPClient) do(ctx context.Context, r *Request) (*resty.Response, error) {
 	var resp *resty.Response
 	var err error
 
 	// 1. Try with existing token (if we have one, or just try if we don't know it's needed yet)
 	// For mutating requests, we check if we need to fetch first.
-	isMutating := isMutatingMethod(method)
+	isMutating := isMutatingMethod(r.Method)
 
 	// If we anticipate needing a token but don't have one, fetch it now to save a round trip failure.
 	// However, standard flow is: Try -> Fail -> Fetch -> Retry
 	// We'll optimistically try if we have a token, or if it's GET (doesn't need one usually).
 
-	req := s.buildRequest()
-	if body != nil {
-		req.SetBody(body)
-	}
-	if len(queryParams) > 0 {
-		req.SetQueryParams(queryParams)
+	url := s.requestURL(ctx, r)
+	csrfURL := r.CSRFURL
+	if csrfURL == "" {
+		csrfURL = url
 	}
 
 	// Attach current token if available
@@ -77,32 +238,52 @@ func (s *SAPClient) ExecuteRequest(method, url string, body interface{}, queryPa
 	token := s.csrfToken
 	s.mu.RUnlock()
 
+	// Requests with their own token URL, such as $batch, get a token up front, so the whole
+	// multipart body is sent once with one token and its session cookies
+	if token == "" && isMutating && r.CSRFURL != "" {
+		if err := s.refreshCSRFToken(ctx, csrfURL); err != nil {
+			return nil, fmt.Errorf("failed to fetch CSRF token: %w", err)
+		}
+		if r.Stats != nil {
+			r.Stats.CSRFRefreshes++
+		}
+		s.mu.RLock()
+		token = s.csrfToken
+		s.mu.RUnlock()
+	}
+
+	req := s.prepareRequest(ctx, r)
+
 	if token != "" {
 		req.SetHeader(CSRFHeader, token)
 	}
 
-	resp, err = req.Execute(method, url)
+	resp, err = req.Execute(r.Method, url)
+	r.Stats.sent(resp)
 	if err != nil {
 		return nil, err
 	}
+	if err := decodeResponse(resp, r.Stream); err != nil {
+		return nil, err
+	}
 
 	// 2. Check for CSRF error
 	// SAP usually returns 403 Forbidden with proper header indication, or sometimes generic 403.
 	// We detect need for refresh if 403 AND we tried a mutating method.
 	if isMutating && (resp.StatusCode() == http.StatusForbidden || resp.Header().Get(CSRFHeader) == "Required") {
 		// Log or Debug: "CSRF token invalid or missing, refreshing..."
-		if err := s.RefreshCSRFToken(url); err != nil {
+		if r.Stream {
+			resp.RawBody().Close()
+		}
+		if err := s.refreshCSRFToken(ctx, csrfURL); err != nil {
 			return nil, fmt.Errorf("failed to refresh CSRF token: %w", err)
 		}
+		if r.Stats != nil {
+			r.Stats.CSRFRefreshes++
+		}
 
 		// 3. Retry with new token
-		reqRetry := s.buildRequest()
-		if body != nil {
-			reqRetry.SetBody(body)
-		}
-		if len(queryParams) > 0 {
-			reqRetry.SetQueryParams(queryParams)
-		}
+		reqRetry := s.prepareRequest(ctx, r)
 
 		s.mu.RLock()
 		newToken := s.csrfToken
@@ -110,12 +291,52 @@ func (s *SAPClient) ExecuteRequest(method, url string, body interface{}, queryPa
 
 		reqRetry.SetHeader(CSRFHeader, newToken)
 
-		resp, err = reqRetry.Execute(method, url)
+		resp, err = reqRetry.Execute(r.Method, url)
+		r.Stats.sent(resp)
+		if err == nil {
+			err = decodeResponse(resp, r.Stream)
+		}
 	}
 
 	return resp, err
 }
 
+// prepareRequest builds a resty request from r, ready to execute
+func (s *SAPClient) prepareRequest(ctx context.Context, r *Request) *resty.Request {
+	req := s.buildRequest().SetContext(ctx).SetHeader("Accept-Encoding", AcceptEncoding)
+	if r.Body != nil {
+		req.SetBody(r.Body)
+	}
+	if len(r.QueryParams) > 0 {
+		req.SetQueryParams(r.QueryParams)
+	}
+	if len(r.Headers) > 0 {
+		req.SetHeaders(r.Headers)
+	}
+	if len(r.Cookies) > 0 {
+		req.Cookies = mergeCookies(req.Cookies, r.Cookies)
+	}
+	if r.Stream {
+		req.SetDoNotParseResponse(true)
+	}
+	if s.slowLogEnabled() {
+		req.SetHeader(StatisticsHeader, "true")
+	}
+	return req
+}
+
+// mergeCookies returns cookies with those of extra added, replacing the cookies of the same
+// name, so a session cookie such as SAP_SESSIONID is sent once
+func mergeCookies(cookies, extra []*http.Cookie) []*http.Cookie {
+	merged := make([]*http.Cookie, 0, len(cookies)+len(extra))
+	for _, c := range cookies {
+		if !slices.ContainsFunc(extra, func(e *http.Cookie) bool { return e.Name == c.Name }) {
+			merged = append(merged, c)
+		}
+	}
+	return append(merged, extra...)
+}
+
 // buildRequest creates a new request and attaches managed cookies
 func (s *SAPClient) buildRequest() *resty.Request {
 	s.mu.RLock()
@@ -129,18 +350,55 @@ func (s *SAPClient) buildRequest() *resty.Request {
 	if len(s.csrfCookies) > 0 {
 		req.SetCookies(s.csrfCookies)
 	}
+	s.setSystemParams(req)
 	return req
 }
 
+// setSystemParams adds the sap-client and sap-language query parameters; s.mu must be held
+func (s *SAPClient) setSystemParams(req *resty.Request) {
+	if s.sapClient != "" {
+		req.SetQueryParam("sap-client", s.sapClient)
+	}
+	if s.language != "" {
+		req.SetQueryParam("sap-language", s.language)
+	}
+}
+
+// resolveURL makes a relative URL absolute with the base URL the request of ctx started
+// with, or the current one. Resolving it here rather than through resty's base URL lets
+// ApplyConfig switch hosts while requests run.
+func (s *SAPClient) resolveURL(ctx context.Context, u string) string {
+	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	s.mu.RLock()
+	base := s.baseURL
+	s.mu.RUnlock()
+	if e, ok := ctx.Value(endpointKey{}).(*endpoint); ok {
+		base = e.baseURL
+	}
+	if !strings.HasPrefix(u, "/") {
+		u = "/" + u
+	}
+	return strings.TrimRight(base, "/") + u
+}
+
 // RefreshCSRFToken fetches a new token and updates the client state
 func (s *SAPClient) RefreshCSRFToken(fetchUrl string) error {
+	return s.refreshCSRFToken(context.Background(), fetchUrl)
+}
+
+func (s *SAPClient) refreshCSRFToken(ctx context.Context, fetchUrl string) error {
+	fetchUrl = s.resolveURL(ctx, fetchUrl)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Use HEAD or GET to valid endpoint. Service Root "/" is standard.
 	// We use the dynamically provided fetchUrl to fetch the token.
 	req := s.client.R().
+		SetContext(ctx).
 		SetHeader(CSRFHeader, CSRFValue)
+	s.setSystemParams(req)
 
 	resp, err := req.Head(fetchUrl) // Hit the dynamic URL
 	if err != nil {
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClientClosed is returned for requests started after Close
+var ErrClientClosed = errors.New("client closed")
+
+// inflight counts the requests being executed, so Close can wait for them
+type inflight struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// begin registers a request; the returned func marks it done
+func (f *inflight) begin() (func(), error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, ErrClientClosed
+	}
+	f.wg.Add(1)
+	var once sync.Once
+	return func() { once.Do(f.wg.Done) }, nil
+}
+
+// Close shuts the client down for a graceful stop, e.g. during a deployment. New requests
+// fail with ErrClientClosed at once; requests in flight, including streamed responses not
+// yet closed, are waited for until ctx is done. Then the CSRF token and its session
+// cookies are discarded, a cookie jar with a Save() error method, as persistent jars
+// have, is saved, and idle connections are closed. Close returns ctx.Err() if requests
+// were still running; calling it again waits again.
+func (s *SAPClient) Close(ctx context.Context) error {
+	s.inflight.mu.Lock()
+	s.inflight.closed = true
+	s.inflight.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.wg.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("closing client with requests in flight: %w", ctx.Err())
+	}
+
+	s.mu.Lock()
+	s.csrfToken = ""
+	s.csrfCookies = nil
+	s.mu.Unlock()
+
+	hc := s.client.GetClient()
+	if jar, ok := hc.Jar.(interface{ Save() error }); ok {
+		if saveErr := jar.Save(); saveErr != nil && err == nil {
+			err = fmt.Errorf("saving cookies: %w", saveErr)
+		}
+	}
+	hc.CloseIdleConnections()
+	return err
+}
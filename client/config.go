@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+)
+
+// NewSAPClientFromConfig validates cfg (without its profiles) and creates a client with
+// everything it configures: host, credentials, sap-client, language, debug logging,
+// connectivity and tuning
+func NewSAPClientFromConfig(cfg *config.Config) (*SAPClient, error) {
+	top := *cfg
+	top.Profiles = nil
+	if err := top.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts, err := OptionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg.SAPHost, opts...), nil
+}
+
+// OptionsFromConfig returns the options for New described by cfg: authentication
+// (see AuthProviderFromConfig), sap-client, language, debug logging, read replica, proxy and
+// TLS settings, timeout, retries, rate limit and concurrency limit
+func OptionsFromConfig(cfg *config.Config) ([]Option, error) {
+	opts := []Option{
+		WithSAPClient(cfg.SAPClient),
+		WithLanguage(cfg.Language),
+		WithDebug(cfg.Debug),
+	}
+
+	auth, err := AuthProviderFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		opts = append(opts, WithAuthProvider(auth))
+	}
+
+	if cfg.SAPReadHost != "" {
+		opts = append(opts, WithReadReplica(cfg.SAPReadHost))
+	}
+	if cfg.ProxyURL != "" {
+		opts = append(opts, WithProxy(cfg.ProxyURL))
+	}
+
+	tlsConfig, err := TLSConfigFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, WithTLSConfig(tlsConfig))
+	}
+
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(cfg.Timeout))
+	}
+	if cfg.RetryMax > 0 {
+		p := &RetryPolicy{MaxRetries: cfg.RetryMax, Backoff: cfg.RetryBackoff, MaxBackoff: cfg.RetryMaxBackoff}
+		if cfg.RetryBudget > 0 {
+			p.Budget = NewRetryBudget(cfg.RetryBudget, time.Minute, 10)
+		}
+		opts = append(opts, WithRetry(p))
+	}
+	if cfg.RateLimit > 0 {
+		opts = append(opts, WithRateLimit(cfg.RateLimit, cfg.RateBurst))
+	}
+	if cfg.MaxConcurrency > 0 {
+		opts = append(opts, WithBulkhead(NewBulkhead(cfg.MaxConcurrency, cfg.MaxConcurrencyWait)))
+	}
+	return opts, nil
+}
+
+// TLSConfigFromConfig builds the TLS configuration for the CA, client certificate and
+// skip-verify settings of cfg. It returns nil when none are set.
+func TLSConfigFromConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.CACertFile == "" && cfg.ClientCertFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ApplyConfig updates a running client after a configuration change, e.g. from envconfig.Watch.
+// Hosts and credentials are swapped together: requests already in flight drain against the
+// previous settings, retries and CSRF refreshes included, while later requests use the new
+// hosts, credentials, sap-client and language. The CSRF token and its session cookies are
+// discarded so the next modifying request fetches a token with the new credentials. Proxy,
+// TLS and tuning settings only take effect in a new client.
+func (s *SAPClient) ApplyConfig(cfg *config.Config) error {
+	auth, err := AuthProviderFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	s.auth = auth
+	s.baseURL = cfg.SAPHost
+	s.readURL = cfg.SAPReadHost
+	s.sapClient = cfg.SAPClient
+	s.language = cfg.Language
+	s.csrfToken = ""
+	s.csrfCookies = nil
+	return nil
+}
+
+type endpointKey struct{}
+
+// endpoint is the hosts and credentials a request started with
+type endpoint struct {
+	baseURL string
+	readURL string
+	auth    AuthProvider
+}
+
+// pinEndpoint binds the current hosts and credentials to the request of ctx, read in one
+// step so ApplyConfig cannot pair the host of one configuration with the credentials of another
+func (s *SAPClient) pinEndpoint(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(endpointKey{}).(*endpoint); ok {
+		return ctx
+	}
+	s.mu.RLock()
+	s.authMu.RLock()
+	e := &endpoint{baseURL: s.baseURL, readURL: s.readURL, auth: s.auth}
+	s.authMu.RUnlock()
+	s.mu.RUnlock()
+	return context.WithValue(ctx, endpointKey{}, e)
+}
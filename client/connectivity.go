@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/go-resty/resty/v2"
+)
+
+// Headers understood by the BTP connectivity proxy
+const (
+	ProxyAuthorizationHeader     = "Proxy-Authorization"
+	ConnectivityAuthHeader       = "SAP-Connectivity-Authentication"
+	ConnectivityLocationIDHeader = "SAP-Connectivity-SCC-Location_ID"
+)
+
+// ConnectivityConfig routes requests through the BTP connectivity proxy to an
+// on-premise system exposed by the Cloud Connector
+type ConnectivityConfig struct {
+	ProxyURL     string // e.g. http://connectivityproxy.internal.cf.eu10.hana.ondemand.com:20003
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// LocationID selects a Cloud Connector when several are attached to the subaccount
+	LocationID string
+	// PropagateUser forwards the user token from the request context (see ContextWithUserToken)
+	// so the Cloud Connector can perform principal propagation
+	PropagateUser bool
+}
+
+// ConnectivityFromBinding builds a ConnectivityConfig from a connectivity service binding
+func ConnectivityFromBinding(creds *config.ConnectivityCredentials) ConnectivityConfig {
+	return ConnectivityConfig{
+		ProxyURL:     creds.ProxyURL(),
+		TokenURL:     creds.TokenURL(),
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+	}
+}
+
+type connectivity struct {
+	cfg   ConnectivityConfig
+	proxy *ClientCredentialsAuth
+}
+
+// EnableConnectivity routes all requests through the connectivity proxy and
+// attaches the Proxy-Authorization and SAP-Connectivity-* headers it requires
+func (s *SAPClient) EnableConnectivity(cfg ConnectivityConfig) {
+	s.client.SetProxy(cfg.ProxyURL)
+
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	s.conn = &connectivity{
+		cfg:   cfg,
+		proxy: NewClientCredentialsAuth(cfg.TokenURL, cfg.ClientID, cfg.ClientSecret),
+	}
+}
+
+// applyConnectivity is the resty middleware adding the connectivity proxy headers
+func (s *SAPClient) applyConnectivity(_ *resty.Client, req *resty.Request) error {
+	s.authMu.RLock()
+	conn := s.conn
+	s.authMu.RUnlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	token, err := conn.proxy.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("connectivity proxy token: %w", err)
+	}
+	req.SetHeader(ProxyAuthorizationHeader, "Bearer "+token)
+
+	if conn.cfg.LocationID != "" {
+		req.SetHeader(ConnectivityLocationIDHeader, conn.cfg.LocationID)
+	}
+	if conn.cfg.PropagateUser {
+		userToken, ok := UserTokenFromContext(req.Context())
+		if !ok {
+			return ErrNoUserToken
+		}
+		req.SetHeader(ConnectivityAuthHeader, "Bearer "+userToken)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-resty/resty/v2"
+)
+
+// AcceptEncoding lists the content encodings the client negotiates and decodes. Sending it
+// explicitly turns off the transport's own gzip handling, so responses are decoded in one
+// place whether they are streamed or read by resty.
+const AcceptEncoding = "gzip, deflate, br"
+
+// decodeResponse replaces a compressed response body by its decoded content and drops the
+// Content-Encoding header, for gateways and reverse proxies compressing with deflate or br
+// as well as gzip
+func decodeResponse(resp *resty.Response, stream bool) error {
+	if resp == nil || resp.RawResponse == nil {
+		return nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header().Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	raw := resp.RawResponse
+	if stream {
+		body, err := decoder(encoding, raw.Body)
+		if err != nil {
+			return err
+		}
+		raw.Body = body
+	} else if encoding != "gzip" { // resty already decoded gzip
+		body, err := decoder(encoding, io.NopCloser(bytes.NewReader(resp.Body())))
+		if err != nil {
+			return err
+		}
+		decoded, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("decoding %s response: %w", encoding, err)
+		}
+		resp.SetBody(decoded)
+	}
+	raw.Header.Del("Content-Encoding")
+	raw.Header.Del("Content-Length")
+	raw.ContentLength = -1
+	raw.Uncompressed = true
+	return nil
+}
+
+// decoder returns a reader decoding body. The decompressor is created on the first read, so
+// an empty body, e.g. of a 204 or a HEAD request, is not an error.
+func decoder(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	var open func(r *bufio.Reader) (io.Reader, error)
+	switch encoding {
+	case "gzip", "x-gzip":
+		open = func(r *bufio.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = openDeflate
+	case "br":
+		open = func(r *bufio.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	return &lazyDecoder{body: body, open: open}, nil
+}
+
+// openDeflate accepts zlib-wrapped deflate as the specification demands and raw deflate as
+// some servers send it
+func openDeflate(r *bufio.Reader) (io.Reader, error) {
+	header, err := r.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+	return flate.NewReader(r), nil
+}
+
+type lazyDecoder struct {
+	body io.ReadCloser
+	open func(*bufio.Reader) (io.Reader, error)
+	r    io.Reader
+}
+
+func (d *lazyDecoder) Read(p []byte) (int, error) {
+	if d.r == nil {
+		br := bufio.NewReader(d.body)
+		if _, err := br.Peek(1); err != nil {
+			return 0, err // io.EOF for an empty body
+		}
+		r, err := d.open(br)
+		if err != nil {
+			return 0, err
+		}
+		d.r = r
+	}
+	return d.r.Read(p)
+}
+
+func (d *lazyDecoder) Close() error {
+	if c, ok := d.r.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
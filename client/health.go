@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthCheckPath is the standard ICF ping service, which requires a logon
+// and therefore verifies credentials as well as reachability
+const DefaultHealthCheckPath = "/sap/bc/ping"
+
+// CheckResult is the outcome of a single health check step
+type CheckResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMS  int64  `json:"latencyMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HealthReport aggregates check results in a shape suitable for readiness endpoints
+type HealthReport struct {
+	Healthy   bool          `json:"healthy"`
+	Checks    []CheckResult `json:"checks"`
+	CheckedAt time.Time     `json:"checkedAt"`
+}
+
+// NewHealthReport creates an empty, healthy report
+func NewHealthReport() *HealthReport {
+	return &HealthReport{Healthy: true, CheckedAt: time.Now()}
+}
+
+// Add records a check result and marks the report unhealthy if it failed
+func (r *HealthReport) Add(c CheckResult) {
+	r.Checks = append(r.Checks, c)
+	if !c.OK {
+		r.Healthy = false
+	}
+}
+
+// HTTPStatus maps the report to a probe status code (200 or 503)
+func (r *HealthReport) HTTPStatus() int {
+	if r.Healthy {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+// SetHealthCheckPath changes the endpoint used by HealthCheck (default DefaultHealthCheckPath)
+func (s *SAPClient) SetHealthCheckPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthPath = path
+}
+
+// HealthCheck verifies that the backend is reachable and accepts the configured credentials
+func (s *SAPClient) HealthCheck(ctx context.Context) *HealthReport {
+	s.mu.RLock()
+	path := s.healthPath
+	s.mu.RUnlock()
+	if path == "" {
+		path = DefaultHealthCheckPath
+	}
+
+	report := NewHealthReport()
+	reach, auth := s.Probe(ctx, path, nil)
+	report.Add(reach)
+	if reach.OK {
+		report.Add(auth)
+	}
+	return report
+}
+
+// Probe issues a GET to path and reports reachability and authentication separately.
+// Any HTTP response counts as reachable; only 401 counts as an authentication failure.
+func (s *SAPClient) Probe(ctx context.Context, path string, headers map[string]string) (reachable, authenticated CheckResult) {
+	return s.ProbeRequest(ctx, &Request{Method: http.MethodGet, URL: path, Headers: headers})
+}
+
+// ProbeRequest is Probe for a prepared request, e.g. one carrying the headers and query
+// parameters of a service
+func (s *SAPClient) ProbeRequest(ctx context.Context, r *Request) (reachable, authenticated CheckResult) {
+	reachable = CheckResult{Name: "reachability"}
+	authenticated = CheckResult{Name: "authentication"}
+
+	start := time.Now()
+	resp, err := s.Do(ctx, r)
+	latency := time.Since(start).Milliseconds()
+	reachable.LatencyMS, authenticated.LatencyMS = latency, latency
+
+	if err != nil {
+		reachable.Error = err.Error()
+		return reachable, authenticated
+	}
+	reachable.OK = true
+	reachable.StatusCode = resp.StatusCode()
+	authenticated.StatusCode = resp.StatusCode()
+
+	if resp.StatusCode() == http.StatusUnauthorized {
+		authenticated.Error = "credentials rejected"
+		return reachable, authenticated
+	}
+	authenticated.OK = true
+	return reachable, authenticated
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BeforeRequestHook is called before a request is sent. It may change r, e.g. add audit
+// headers; returning an error aborts the request with that error.
+type BeforeRequestHook func(ctx context.Context, r *Request) error
+
+// AfterResponseHook is called once a request completed, after retries. resp is nil when
+// err is set; the body of a streamed response has not been read yet.
+type AfterResponseHook func(ctx context.Context, r *Request, resp *resty.Response, err error)
+
+// OnBeforeRequest registers h to run before every request of Do, in registration order
+func (s *SAPClient) OnBeforeRequest(h BeforeRequestHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beforeRequest = append(s.beforeRequest, h)
+}
+
+// OnAfterResponse registers h to run after every request of Do, in registration order
+func (s *SAPClient) OnAfterResponse(h AfterResponseHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.afterResponse = append(s.afterResponse, h)
+}
+
+func (s *SAPClient) hooks() ([]BeforeRequestHook, []AfterResponseHook) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.beforeRequest, s.afterResponse
+}
+
+// runBeforeRequest applies the hooks to a copy of r, so the caller's request and its maps
+// are left untouched
+func runBeforeRequest(ctx context.Context, hooks []BeforeRequestHook, r *Request) (*Request, error) {
+	if len(hooks) == 0 {
+		return r, nil
+	}
+	r2 := *r
+	r2.Headers = cloneMap(r.Headers)
+	r2.QueryParams = cloneMap(r.QueryParams)
+	for _, h := range hooks {
+		if err := h(ctx, &r2); err != nil {
+			return nil, fmt.Errorf("before request hook: %w", err)
+		}
+	}
+	return &r2, nil
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// IASConfig configures authentication against SAP Identity Authentication Service (OIDC)
+type IASConfig struct {
+	Issuer       string // Tenant URL, e.g. https://tenant.accounts.ondemand.com
+	ClientID     string
+	ClientSecret string // Leave empty when authenticating with Certificate
+	// Certificate enables tls_client_auth (mTLS) instead of a client secret
+	Certificate *tls.Certificate
+	Scopes      []string
+	// Username/Password switch from client_credentials to the password grant for named users
+	Username string
+	Password string
+}
+
+// oidcConfiguration is the subset of the OIDC discovery document we need
+type oidcConfiguration struct {
+	Issuer        string `json:"issuer"`
+	TokenEndpoint string `json:"token_endpoint"`
+	// Some IAS tenants publish a separate endpoint for certificate based clients
+	MTLSEndpointAliases struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	} `json:"mtls_endpoint_aliases"`
+}
+
+// IASAuth authenticates with tokens issued by SAP IAS. The token endpoint is discovered
+// from the issuer's OpenID configuration on first use; tokens are cached and refreshed
+// with the refresh_token grant when the server issued one.
+type IASAuth struct {
+	cfg        IASConfig
+	httpClient *resty.Client
+
+	mu           sync.Mutex
+	tokenURL     string
+	token        string
+	refreshToken string
+	expiry       time.Time
+}
+
+// NewIASAuth creates an IAS provider. No network calls happen until the first request.
+func NewIASAuth(cfg IASConfig) *IASAuth {
+	r := resty.New().SetTimeout(30 * time.Second)
+	if cfg.Certificate != nil {
+		r.SetCertificates(*cfg.Certificate)
+	}
+	return &IASAuth{cfg: cfg, httpClient: r}
+}
+
+// Authenticate implements AuthProvider
+func (a *IASAuth) Authenticate(req *resty.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.SetAuthToken(token)
+	return nil
+}
+
+// Token returns a valid access token, refreshing or re-requesting it as needed
+func (a *IASAuth) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expiry) {
+		return a.token, nil
+	}
+
+	if a.tokenURL == "" {
+		if err := a.discover(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	// Prefer the refresh token, fall back to a full grant if it was revoked or expired
+	if a.refreshToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", a.refreshToken)
+		if err := a.requestToken(ctx, form); err == nil {
+			return a.token, nil
+		}
+		a.refreshToken = ""
+	}
+
+	form := url.Values{}
+	if a.cfg.Username != "" {
+		form.Set("grant_type", "password")
+		form.Set("username", a.cfg.Username)
+		form.Set("password", a.cfg.Password)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(a.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.cfg.Scopes, " "))
+	}
+	if err := a.requestToken(ctx, form); err != nil {
+		return "", err
+	}
+	return a.token, nil
+}
+
+// discover resolves the token endpoint from the issuer's OpenID configuration
+func (a *IASAuth) discover(ctx context.Context) error {
+	var oidc oidcConfiguration
+	endpoint := strings.TrimSuffix(a.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	resp, err := a.httpClient.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&oidc).
+		Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("oidc discovery failed with status %d", resp.StatusCode())
+	}
+
+	a.tokenURL = oidc.TokenEndpoint
+	if a.cfg.Certificate != nil && oidc.MTLSEndpointAliases.TokenEndpoint != "" {
+		a.tokenURL = oidc.MTLSEndpointAliases.TokenEndpoint
+	}
+	if a.tokenURL == "" {
+		return fmt.Errorf("oidc discovery document of %s has no token_endpoint", a.cfg.Issuer)
+	}
+	return nil
+}
+
+func (a *IASAuth) requestToken(ctx context.Context, form url.Values) error {
+	clientID, clientSecret := a.cfg.ClientID, a.cfg.ClientSecret
+	if a.cfg.Certificate != nil {
+		// tls_client_auth: the certificate authenticates, client_id only identifies
+		form.Set("client_id", clientID)
+		clientID, clientSecret = "", ""
+	}
+
+	tok, err := requestToken(ctx, a.httpClient, a.tokenURL, clientID, clientSecret, form)
+	if err != nil {
+		return err
+	}
+
+	a.token = tok.AccessToken
+	a.expiry = tok.expiry()
+	if tok.RefreshToken != "" {
+		a.refreshToken = tok.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures a client created with New
+type Option func(*SAPClient)
+
+// WithBasicAuth authenticates with a username and password
+func WithBasicAuth(username, password string) Option {
+	return func(s *SAPClient) {
+		s.auth = BasicAuth{Username: username, Password: password}
+	}
+}
+
+// WithAuthProvider authenticates with p, e.g. ClientCredentialsAuth
+func WithAuthProvider(p AuthProvider) Option {
+	return func(s *SAPClient) {
+		s.auth = p
+	}
+}
+
+// WithTimeout sets the overall timeout of a single request (default 30s)
+func WithTimeout(d time.Duration) Option {
+	return func(s *SAPClient) {
+		s.client.SetTimeout(d)
+	}
+}
+
+// WithTransport replaces the HTTP transport, e.g. for proxies, custom TLS or test doubles
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *SAPClient) {
+		s.client.SetTransport(rt)
+	}
+}
+
+// WithReadReplica sends GET and HEAD requests to baseURL (see SetReadReplica)
+func WithReadReplica(baseURL string) Option {
+	return func(s *SAPClient) {
+		s.readURL = baseURL
+	}
+}
+
+// WithProxy sends all requests through the HTTP proxy at proxyURL
+func WithProxy(proxyURL string) Option {
+	return func(s *SAPClient) {
+		s.client.SetProxy(proxyURL)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration, e.g. a private CA or a client certificate
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *SAPClient) {
+		s.client.SetTLSClientConfig(cfg)
+	}
+}
+
+// WithLogger routes the client's diagnostics (resty warnings, debug output and the
+// slow request log) to logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *SAPClient) {
+		s.logger = logger
+		s.client.SetLogger(restyLogger{logger})
+	}
+}
+
+// WithRetry enables retries of transient failures, see RetryPolicy
+func WithRetry(p *RetryPolicy) Option {
+	return func(s *SAPClient) {
+		s.retry = p
+	}
+}
+
+// WithRateLimit caps the request rate, see SAPClient.SetRateLimit
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(s *SAPClient) {
+		s.SetRateLimit(perSecond, burst)
+	}
+}
+
+// WithBulkhead limits the concurrent requests of the client
+func WithBulkhead(b *Bulkhead) Option {
+	return func(s *SAPClient) {
+		s.bulkhead = b
+	}
+}
+
+// WithScheduler routes requests through a priority scheduler
+func WithScheduler(sched *Scheduler) Option {
+	return func(s *SAPClient) {
+		s.scheduler = sched
+	}
+}
+
+// WithSlowRequestLog logs requests slower than threshold, see SAPClient.SetSlowRequestLog
+func WithSlowRequestLog(threshold time.Duration) Option {
+	return func(s *SAPClient) {
+		s.SetSlowRequestLog(threshold, nil)
+	}
+}
+
+// WithSAPClient selects the SAP client (mandant) with the sap-client query parameter
+func WithSAPClient(sapClient string) Option {
+	return func(s *SAPClient) {
+		s.sapClient = sapClient
+	}
+}
+
+// WithLanguage selects the logon language with the sap-language query parameter, e.g. "EN"
+func WithLanguage(language string) Option {
+	return func(s *SAPClient) {
+		s.language = language
+	}
+}
+
+// WithHeader sends a header with every request
+func WithHeader(name, value string) Option {
+	return func(s *SAPClient) {
+		s.client.SetHeader(name, value)
+	}
+}
+
+// WithDebug logs every request and response
+func WithDebug(debug bool) Option {
+	return func(s *SAPClient) {
+		s.client.SetDebug(debug)
+	}
+}
+
+// WithBeforeRequest registers a hook run before every request, see SAPClient.OnBeforeRequest
+func WithBeforeRequest(h BeforeRequestHook) Option {
+	return func(s *SAPClient) {
+		s.beforeRequest = append(s.beforeRequest, h)
+	}
+}
+
+// WithAfterResponse registers a hook run after every request, see SAPClient.OnAfterResponse
+func WithAfterResponse(h AfterResponseHook) Option {
+	return func(s *SAPClient) {
+		s.afterResponse = append(s.afterResponse, h)
+	}
+}
+
+// restyLogger adapts slog to resty's logger interface
+type restyLogger struct {
+	logger *slog.Logger
+}
+
+func (l restyLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, v...))
+}
+
+func (l restyLogger) Warnf(format string, v ...interface{}) {
+	l.logger.Warn(fmt.Sprintf(format, v...))
+}
+
+func (l restyLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// SetRateLimit caps the client at perSecond requests with bursts of up to burst requests.
+// Requests over the limit wait for their turn (bounded by their context) instead of failing.
+// A perSecond of zero or less removes the limit.
+func (s *SAPClient) SetRateLimit(perSecond float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if perSecond <= 0 {
+		s.rateLimit = nil
+		return
+	}
+	s.rateLimit = &rateLimiter{rate.NewLimiter(rate.Limit(perSecond), max(burst, 1))}
+}
+
+// rateLimiter adapts rate.Limiter to the limiter chain of acquire
+type rateLimiter struct {
+	*rate.Limiter
+}
+
+func (l *rateLimiter) Acquire(ctx context.Context) (func(), error) {
+	if err := l.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type primaryKey struct{}
+
+// ReadFromPrimary makes the requests of ctx read from the primary host even if the client has
+// a read replica, e.g. for a read that must see a write made just before
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// SetReadReplica sends GET and HEAD requests to baseURL, e.g. an HA read instance or a caching
+// proxy, while writes and the CSRF handshake stay on the primary host; "" removes it
+func (s *SAPClient) SetReadReplica(baseURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readURL = baseURL
+}
+
+// requestURL returns the absolute URL r is sent to
+func (s *SAPClient) requestURL(ctx context.Context, r *Request) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return s.resolveURL(ctx, r.URL)
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return s.resolveURL(ctx, r.URL)
+	}
+	s.mu.RLock()
+	base := s.readURL
+	s.mu.RUnlock()
+	if e, ok := ctx.Value(endpointKey{}).(*endpoint); ok {
+		base = e.readURL
+	}
+	if base == "" || strings.HasPrefix(r.URL, "http://") || strings.HasPrefix(r.URL, "https://") {
+		return s.resolveURL(ctx, r.URL)
+	}
+	u := r.URL
+	if !strings.HasPrefix(u, "/") {
+		u = "/" + u
+	}
+	return strings.TrimRight(base, "/") + u
+}
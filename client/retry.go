@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrRetryBudgetExhausted is matched (errors.Is) by a RetryBudgetError
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudgetError is returned instead of retrying when the RetryBudget is used up.
+// It carries the outcome of the last attempt.
+type RetryBudgetError struct {
+	Method     string
+	URL        string
+	StatusCode int   // status of the last attempt, 0 if it failed without a response
+	Err        error // error of the last attempt, if any
+}
+
+func (e *RetryBudgetError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s: %v (%v)", e.Method, e.URL, ErrRetryBudgetExhausted, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v (status %d)", e.Method, e.URL, ErrRetryBudgetExhausted, e.StatusCode)
+}
+
+func (e *RetryBudgetError) Is(target error) bool { return target == ErrRetryBudgetExhausted }
+
+func (e *RetryBudgetError) Unwrap() error { return e.Err }
+
+// RetryPolicy retries requests that failed with a transient error
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// Backoff is the initial delay, doubled per retry with full jitter (default 200ms)
+	Backoff time.Duration
+	// MaxBackoff caps the delay, including one requested by Retry-After (default 10s)
+	MaxBackoff time.Duration
+	// Statuses that are retried (default 429, 502, 503, 504). Transport errors are always retried.
+	Statuses []int
+	// Only GET, HEAD, OPTIONS and requests marked retryable (Request.Retryable or
+	// WithRetryable) are retried: a PUT or DELETE whose response was lost may have been
+	// applied, and repeating it can undo a change made in between.
+	// RetryNonIdempotent retries every method, which may then be applied twice.
+	RetryNonIdempotent bool
+	// Budget limits retries across all requests of the client; nil means unlimited
+	Budget *RetryBudget
+}
+
+var defaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// SetRetryPolicy enables retries of transient failures; nil disables them
+func (s *SAPClient) SetRetryPolicy(p *RetryPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retry = p
+}
+
+func (s *SAPClient) retryPolicy() *RetryPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retry
+}
+
+// doWithRetry runs do under the retry policy
+func (s *SAPClient) doWithRetry(ctx context.Context, r *Request) (*resty.Response, error) {
+	p := s.retryPolicy()
+	if p == nil {
+		return s.do(ctx, r)
+	}
+	if p.Budget != nil {
+		p.Budget.recordRequest()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := s.do(ctx, r)
+		if !p.shouldRetry(ctx, r, resp, err) || ctx.Err() != nil || attempt >= p.MaxRetries {
+			return resp, err
+		}
+
+		if p.Budget != nil && !p.Budget.tryRetry() {
+			budgetErr := &RetryBudgetError{Method: r.Method, URL: r.URL, Err: err}
+			if resp != nil {
+				budgetErr.StatusCode = resp.StatusCode()
+			}
+			closeStream(r, resp)
+			return nil, budgetErr
+		}
+
+		delay := p.delay(attempt, resp)
+		closeStream(r, resp)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (p *RetryPolicy) shouldRetry(ctx context.Context, r *Request, resp *resty.Response, err error) bool {
+	if !p.RetryNonIdempotent && !retryable(ctx, r) {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+	return slices.Contains(statuses, resp.StatusCode())
+}
+
+// delay returns the wait before retry number attempt+1, honouring Retry-After in seconds
+func (p *RetryPolicy) delay(attempt int, resp *resty.Response) time.Duration {
+	base, ceiling := p.Backoff, p.MaxBackoff
+	if base <= 0 {
+		base = 200 * time.Millisecond
+	}
+	if ceiling <= 0 {
+		ceiling = 10 * time.Second
+	}
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header().Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, ceiling)
+		}
+	}
+	d := ceiling
+	if attempt < 30 { // beyond that the shift overflows
+		d = min(base<<attempt, ceiling)
+	}
+	return rand.N(d) + 1
+}
+
+type retryableKey struct{}
+
+// WithRetryable marks the requests made with ctx as safe to repeat, so the RetryPolicy
+// retries them whatever their method, e.g. a DELETE or a PUT sent via odata.Service.WithContext
+// that the caller knows to be idempotent
+func WithRetryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableKey{}, true)
+}
+
+// retryable reports whether r may be repeated without the RetryNonIdempotent policy
+func retryable(ctx context.Context, r *Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	marked, _ := ctx.Value(retryableKey{}).(bool)
+	return r.Retryable || marked
+}
+
+// closeStream releases the unread body of a streamed response that is being discarded
+func closeStream(r *Request, resp *resty.Response) {
+	if r.Stream && resp != nil && resp.RawBody() != nil {
+		resp.RawBody().Close()
+	}
+}
+
+const budgetSlots = 10
+
+// RetryBudget caps retries at a fraction of the requests seen in a sliding window, so that
+// retrying during a backend incident cannot multiply the load on the gateway. It is shared
+// by all requests of the clients it is configured on.
+type RetryBudget struct {
+	ratio      float64
+	minRetries int
+	slot       time.Duration
+
+	mu    sync.Mutex
+	slots [budgetSlots]budgetSlot
+}
+
+type budgetSlot struct {
+	index    int64
+	requests int
+	retries  int
+}
+
+// NewRetryBudget allows retries for up to ratio (e.g. 0.1 for 10%) of the requests made in
+// the last window, but at least minRetries per window so low-traffic clients can still retry
+func NewRetryBudget(ratio float64, window time.Duration, minRetries int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, minRetries: minRetries, slot: max(window/budgetSlots, time.Millisecond)}
+}
+
+// current returns the slot for now, resetting it if it belongs to an expired window
+func (b *RetryBudget) current() (*budgetSlot, int64) {
+	idx := time.Now().UnixNano() / int64(b.slot)
+	s := &b.slots[idx%budgetSlots]
+	if s.index != idx {
+		*s = budgetSlot{index: idx}
+	}
+	return s, idx
+}
+
+func (b *RetryBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, _ := b.current()
+	s.requests++
+}
+
+// tryRetry reserves a retry if the window still allows one
+func (b *RetryBudget) tryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur, idx := b.current()
+	requests, retries := 0, 0
+	for i := range b.slots {
+		if s := &b.slots[i]; s.index > idx-budgetSlots {
+			requests += s.requests
+			retries += s.retries
+		}
+	}
+	if float64(retries+1) > max(float64(b.minRetries), b.ratio*float64(requests)) {
+		return false
+	}
+	cur.retries++
+	return true
+}
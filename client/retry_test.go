@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// failingServer answers the first failures requests with status, then with 200
+func failingServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		retryable  bool
+		policy     RetryPolicy
+		failures   int32
+		status     int
+		wantCalls  int32
+		wantStatus int
+	}{
+		{"retries until success", http.MethodGet, false, RetryPolicy{MaxRetries: 3}, 2, http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"gives up after MaxRetries", http.MethodGet, false, RetryPolicy{MaxRetries: 2}, 5, http.StatusBadGateway, 3, http.StatusBadGateway},
+		{"does not retry other statuses", http.MethodGet, false, RetryPolicy{MaxRetries: 3}, 1, http.StatusInternalServerError, 1, http.StatusInternalServerError},
+		{"custom statuses", http.MethodGet, false, RetryPolicy{MaxRetries: 3, Statuses: []int{http.StatusInternalServerError}}, 1, http.StatusInternalServerError, 2, http.StatusOK},
+		{"does not retry POST", http.MethodPost, false, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"retries POST if asked to", http.MethodPost, false, RetryPolicy{MaxRetries: 3, RetryNonIdempotent: true}, 1, http.StatusServiceUnavailable, 2, http.StatusOK},
+		{"does not retry PUT", http.MethodPut, false, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"does not retry DELETE", http.MethodDelete, false, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"retries a retryable DELETE", http.MethodDelete, true, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 2, http.StatusOK},
+		{"retries a retryable POST", http.MethodPost, true, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 2, http.StatusOK},
+		{"retries HEAD", http.MethodHead, false, RetryPolicy{MaxRetries: 3}, 1, http.StatusServiceUnavailable, 2, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := failingServer(t, tt.failures, tt.status)
+			c := NewSAPClient(srv.URL, "", "")
+			tt.policy.Backoff = time.Millisecond
+			c.SetRetryPolicy(&tt.policy)
+
+			resp, err := c.Do(context.Background(), &Request{Method: tt.method, URL: "/svc/ProductSet", Retryable: tt.retryable})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode() != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode(), tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryable(t *testing.T) {
+	srv, calls := failingServer(t, 1, http.StatusServiceUnavailable)
+	c := NewSAPClient(srv.URL, "", "")
+	c.SetRetryPolicy(&RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond})
+
+	resp, err := c.Do(WithRetryable(context.Background()), &Request{Method: http.MethodPut, URL: "/svc/ProductSet('1')"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode(), calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	c := NewSAPClient(srv.URL, "", "")
+	// the 1s of Retry-After is capped by MaxBackoff, but waited in full up to it
+	c.SetRetryPolicy(&RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond, MaxBackoff: 100 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := c.Do(context.Background(), &Request{Method: http.MethodGet, URL: "/svc/ProductSet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode(), calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("retried after %v, want the 100ms Retry-After allows", elapsed)
+	}
+}
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	srv, calls := failingServer(t, 100, http.StatusServiceUnavailable)
+	c := NewSAPClient(srv.URL, "", "")
+	// no share of the traffic, but one retry per window
+	c.SetRetryPolicy(&RetryPolicy{MaxRetries: 5, Backoff: time.Millisecond, Budget: NewRetryBudget(0, time.Minute, 1)})
+
+	_, err := c.Do(context.Background(), &Request{Method: http.MethodGet, URL: "/svc/ProductSet"})
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("err = %v, want ErrRetryBudgetExhausted", err)
+	}
+	var budgetErr *RetryBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.StatusCode != http.StatusServiceUnavailable || budgetErr.Method != http.MethodGet {
+		t.Errorf("err = %#v, want a RetryBudgetError for GET with status 503", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server saw %d requests, want 2 (one retry allowed)", got)
+	}
+
+	// the budget is shared: the next request may not retry at all
+	_, err = c.Do(context.Background(), &Request{Method: http.MethodGet, URL: "/svc/ProductSet"})
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("second request: err = %v, want ErrRetryBudgetExhausted", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+}
+
+func TestRetryBudgetRatio(t *testing.T) {
+	b := NewRetryBudget(0.5, time.Minute, 0)
+	for range 4 {
+		b.recordRequest()
+	}
+	for i := range 2 {
+		if !b.tryRetry() {
+			t.Fatalf("retry %d refused, want 2 of 4 requests allowed", i+1)
+		}
+	}
+	if b.tryRetry() {
+		t.Error("third retry allowed, want the budget exhausted")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withRetryAfter := func(v string) *resty.Response {
+		h := http.Header{}
+		h.Set("Retry-After", v)
+		return &resty.Response{RawResponse: &http.Response{Header: h}}
+	}
+	p := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+	if got := p.delay(0, withRetryAfter("2")); got != 2*time.Second {
+		t.Errorf("Retry-After 2: delay = %v, want 2s", got)
+	}
+	if got := p.delay(0, withRetryAfter("60")); got != 5*time.Second {
+		t.Errorf("Retry-After 60: delay = %v, want MaxBackoff 5s", got)
+	}
+	if got := p.delay(0, withRetryAfter("0")); got != 0 {
+		t.Errorf("Retry-After 0: delay = %v, want 0", got)
+	}
+	for attempt, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if got := p.delay(attempt, withRetryAfter("soon")); got <= 0 || got > ceiling {
+			t.Errorf("attempt %d: delay = %v, want in (0, %v]", attempt, got, ceiling)
+		}
+	}
+	if got := p.delay(40, nil); got <= 0 || got > 5*time.Second {
+		t.Errorf("attempt 40: delay = %v, want in (0, 5s]", got)
+	}
+}
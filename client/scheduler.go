@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Priority orders requests waiting for a Scheduler slot
+type Priority int
+
+const (
+	// PriorityBackground is for extracts and mass jobs that can wait
+	PriorityBackground Priority = iota
+	// PriorityNormal is used when the context carries no priority
+	PriorityNormal
+	// PriorityInteractive is for calls a user is waiting on
+	PriorityInteractive
+
+	priorityLevels = 3
+)
+
+type priorityKey struct{}
+
+// WithPriority marks the requests made with ctx, e.g. via odata.Service.WithContext
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set by WithPriority, or PriorityNormal
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return min(max(p, PriorityBackground), PriorityInteractive)
+	}
+	return PriorityNormal
+}
+
+// Scheduler limits concurrent requests like a Bulkhead, but when the limit is reached the
+// freed slots go to waiting requests in priority order (FIFO within a priority), so
+// background extracts yield to interactive calls.
+type Scheduler struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiting [priorityLevels][]*waiter
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewScheduler allows maxConcurrent requests in flight
+func NewScheduler(maxConcurrent int) *Scheduler {
+	return &Scheduler{limit: max(maxConcurrent, 1)}
+}
+
+// SetScheduler routes every request of the client through sched; nil removes it
+func (s *SAPClient) SetScheduler(sched *Scheduler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scheduler = sched
+}
+
+// Acquire waits for a slot at the priority carried by ctx; call release when done
+func (s *Scheduler) Acquire(ctx context.Context) (release func(), err error) {
+	p := PriorityFromContext(ctx)
+
+	s.mu.Lock()
+	if s.active < s.limit {
+		s.active++
+		s.mu.Unlock()
+		return s.release, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	s.waiting[p] = append(s.waiting[p], w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.release, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.granted {
+			// the slot was handed over while the context ended; pass it on
+			s.mu.Unlock()
+			s.release()
+			return nil, ctx.Err()
+		}
+		if i := slices.Index(s.waiting[p], w); i >= 0 {
+			s.waiting[p] = slices.Delete(s.waiting[p], i, i+1)
+		}
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// release hands the slot to the first waiter of the highest priority, or frees it
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := priorityLevels - 1; p >= 0; p-- {
+		if len(s.waiting[p]) == 0 {
+			continue
+		}
+		w := s.waiting[p][0]
+		s.waiting[p] = s.waiting[p][1:]
+		w.granted = true
+		close(w.ready)
+		return
+	}
+	s.active--
+}
+
+// Waiting returns the number of requests queued per priority
+func (s *Scheduler) Waiting() map[Priority]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[Priority]int, priorityLevels)
+	for p, q := range s.waiting {
+		out[Priority(p)] = len(q)
+	}
+	return out
+}
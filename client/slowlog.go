@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// StatisticsHeader requests (and carries back) the gateway's server side timings
+const StatisticsHeader = "sap-statistics"
+
+// redactedHeaders are replaced in slow request logs
+var redactedHeaders = map[string]bool{
+	"Authorization":                   true,
+	"Proxy-Authorization":             true,
+	"Cookie":                          true,
+	"X-Csrf-Token":                    true,
+	"Sap-Connectivity-Authentication": true,
+}
+
+type slowLog struct {
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+// SetSlowRequestLog logs a warning for every request taking longer than threshold, with the
+// redacted request headers and the sap-statistics timings of the gateway (requested on every
+// call while the log is enabled). A nil logger uses the client's logger (see WithLogger) or
+// slog.Default(); a zero threshold disables it.
+func (s *SAPClient) SetSlowRequestLog(threshold time.Duration, logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if threshold <= 0 {
+		s.slow = nil
+		return
+	}
+	s.slow = &slowLog{threshold: threshold, logger: logger}
+}
+
+// slowLog returns the slow request log with its logger resolved, or nil when disabled
+func (s *SAPClient) slowLog() *slowLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.slow == nil || s.slow.logger != nil {
+		return s.slow
+	}
+	l := *s.slow
+	l.logger = s.logger
+	if l.logger == nil {
+		l.logger = slog.Default()
+	}
+	return &l
+}
+
+// logIfSlow writes the slow request warning; resp is nil when the request failed
+func (l *slowLog) logIfSlow(ctx context.Context, r *Request, resp *resty.Response, err error, elapsed time.Duration) {
+	if l == nil || elapsed < l.threshold {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.Duration("duration", elapsed),
+		slog.Duration("threshold", l.threshold),
+	}
+	if resp != nil && resp.Request != nil && resp.Request.RawRequest != nil {
+		req := resp.Request.RawRequest
+		u := *req.URL
+		u.User = nil
+		attrs = append(attrs,
+			slog.String("url", u.String()),
+			slog.Int("status", resp.StatusCode()),
+			slog.Any("headers", redactHeaders(req.Header)),
+		)
+		if stats := parseStatistics(resp.Header().Get(StatisticsHeader)); len(stats) > 0 {
+			attrs = append(attrs, slog.Any(StatisticsHeader, stats))
+		}
+	} else {
+		attrs = append(attrs, slog.String("url", r.URL))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelWarn, "slow OData request", attrs...)
+}
+
+// redactHeaders flattens h with credentials masked
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			out[k] = "<redacted>"
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+// parseStatistics splits "total=120,fw=3,app=117,gwtotal=115" into milliseconds per component
+func parseStatistics(v string) map[string]int {
+	if v == "" {
+		return nil
+	}
+	stats := make(map[string]int)
+	for _, part := range strings.Split(v, ",") {
+		name, ms, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(ms)); err == nil {
+			stats[name] = n
+		}
+	}
+	return stats
+}
+
+func (s *SAPClient) slowLogEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slow != nil
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Grant types supported by TokenExchangeAuth
+const (
+	// GrantJWTBearer is the RFC 7523 JWT bearer grant used by XSUAA for principal propagation
+	GrantJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	// GrantTokenExchange is the RFC 8693 token exchange grant
+	GrantTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// GrantSAML2Bearer is the RFC 7522 SAML 2.0 bearer assertion grant.
+	// The user token in the context must then be the base64url encoded assertion.
+	GrantSAML2Bearer = "urn:ietf:params:oauth:grant-type:saml2-bearer"
+)
+
+const tokenTypeJWT = "urn:ietf:params:oauth:token-type:jwt"
+
+// ErrNoUserToken is returned when a request needs principal propagation but its context carries no user token
+var ErrNoUserToken = errors.New("no user token in request context")
+
+type userTokenKey struct{}
+
+// ContextWithUserToken returns a context carrying the end user's token (usually the JWT
+// received by our own API). Providers like TokenExchangeAuth use it to act on the user's behalf.
+func ContextWithUserToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, userTokenKey{}, strings.TrimPrefix(token, "Bearer "))
+}
+
+// UserTokenFromContext returns the user token stored by ContextWithUserToken
+func UserTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(userTokenKey{}).(string)
+	return token, ok && token != ""
+}
+
+// TokenExchangeAuth exchanges the user token found in the request context for a backend
+// access token, so calls to SAP run under the end user's identity rather than a technical user.
+// Exchanged tokens are cached per user token until they expire.
+type TokenExchangeAuth struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	grantType    string
+	// Audience/Resource are optional RFC 8693 parameters identifying the target system
+	Audience   string
+	Resource   string
+	Scopes     []string
+	httpClient *resty.Client
+	mtls       bool
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedToken
+}
+
+type cachedToken struct {
+	token  string
+	expiry time.Time
+}
+
+// NewTokenExchangeAuth creates a provider exchanging user tokens with the given grant type
+// (GrantJWTBearer, GrantTokenExchange or GrantSAML2Bearer)
+func NewTokenExchangeAuth(tokenURL, clientID, clientSecret, grantType string) *TokenExchangeAuth {
+	return &TokenExchangeAuth{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		grantType:    grantType,
+		httpClient:   resty.New().SetTimeout(30 * time.Second),
+		cache:        make(map[[sha256.Size]byte]cachedToken),
+	}
+}
+
+// SetCertificate authenticates at the token endpoint with a client certificate
+// (tls_client_auth) instead of the client secret
+func (t *TokenExchangeAuth) SetCertificate(cert tls.Certificate) {
+	t.httpClient.SetCertificates(cert)
+	t.mtls = true
+}
+
+// Authenticate implements AuthProvider
+func (t *TokenExchangeAuth) Authenticate(req *resty.Request) error {
+	userToken, ok := UserTokenFromContext(req.Context())
+	if !ok {
+		return ErrNoUserToken
+	}
+	token, err := t.Exchange(req.Context(), userToken)
+	if err != nil {
+		return err
+	}
+	req.SetAuthToken(token)
+	return nil
+}
+
+// Exchange returns a backend access token for the given user token
+func (t *TokenExchangeAuth) Exchange(ctx context.Context, userToken string) (string, error) {
+	// Key the cache by hash so raw user tokens are not retained as map keys
+	key := sha256.Sum256([]byte(userToken))
+
+	t.mu.Lock()
+	c, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && time.Now().Before(c.expiry) {
+		return c.token, nil
+	}
+
+	// The exchange runs unlocked so one slow user doesn't block every other user's requests
+	form := t.form(userToken)
+	clientID, clientSecret := clientAuthentication(form, t.clientID, t.clientSecret, t.mtls)
+	tok, err := requestToken(ctx, t.httpClient, t.tokenURL, clientID, clientSecret, form)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Drop expired entries so the cache doesn't grow with every user ever seen
+	now := time.Now()
+	for k, c := range t.cache {
+		if now.After(c.expiry) {
+			delete(t.cache, k)
+		}
+	}
+	t.cache[key] = cachedToken{token: tok.AccessToken, expiry: tok.expiry()}
+	return tok.AccessToken, nil
+}
+
+func (t *TokenExchangeAuth) form(userToken string) url.Values {
+	form := url.Values{}
+	form.Set("grant_type", t.grantType)
+
+	switch t.grantType {
+	case GrantTokenExchange:
+		form.Set("subject_token", userToken)
+		form.Set("subject_token_type", tokenTypeJWT)
+		form.Set("requested_token_type", tokenTypeJWT)
+	default:
+		// JWT and SAML bearer grants both carry the user token as assertion
+		form.Set("assertion", userToken)
+	}
+
+	if t.Audience != "" {
+		form.Set("audience", t.Audience)
+	}
+	if t.Resource != "" {
+		form.Set("resource", t.Resource)
+	}
+	if len(t.Scopes) > 0 {
+		form.Set("scope", strings.Join(t.Scopes, " "))
+	}
+	return form
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/Willias7788/go-odata-v2-sdk/config/envconfig"
+	"github.com/Willias7788/go-odata-v2-sdk/contract"
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+// connect builds the client from the SDK configuration. servicePath may also name a
+// service configured with SAP_SERVICE_<NAME>.
+func connect(servicePath string, common *commonFlags) (*client.SAPClient, *odata.Service, error) {
+	c, cfg, err := newClient(common)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasPrefix(servicePath, "/") {
+		if servicePath, err = cfg.ServicePath(servicePath); err != nil {
+			return nil, nil, err
+		}
+	}
+	return c, odata.NewService(c, servicePath, odata.WithFilterValidation()), nil
+}
+
+func newClient(common *commonFlags) (*client.SAPClient, *config.Config, error) {
+	cfg, err := envconfig.LoadProfile(common.profile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading config: %w", err)
+	}
+	cfg.Debug = cfg.Debug || common.debug
+	c, err := client.NewSAPClientFromConfig(cfg)
+	return c, cfg, err
+}
+
+func runGet(args []string) error {
+	fs, common := newFlagSet("get", "<service-path> <EntitySet|EntitySet(key)>", "json", "json, csv, table")
+	filter := fs.String("filter", "", "$filter expression")
+	selects := fs.String("select", "", "comma separated $select list")
+	expand := fs.String("expand", "", "comma separated $expand list")
+	orderby := fs.String("orderby", "", `$orderby clause, e.g. "Name desc"`)
+	top := fs.Int("top", 0, "$top (0 = server default)")
+	skip := fs.Int("skip", 0, "$skip")
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	_, svc, err := connect(pos[0], common)
+	if err != nil {
+		return err
+	}
+
+	opts := odata.NewQueryOptions()
+	if *filter != "" {
+		opts.Filter(*filter)
+	}
+	if *selects != "" {
+		opts.Select(strings.Split(*selects, ","))
+	}
+	if *expand != "" {
+		opts.Expand(strings.Split(*expand, ","))
+	}
+	if *top > 0 {
+		opts.Top(*top)
+	}
+	if *skip > 0 {
+		opts.Skip(*skip)
+	}
+	for _, clause := range strings.Split(*orderby, ",") {
+		if fields := strings.Fields(clause); len(fields) > 0 {
+			opts.OrderBy(fields[0], len(fields) < 2 || !strings.EqualFold(fields[1], "desc"))
+		}
+	}
+
+	var rows []map[string]interface{}
+	target := pos[1]
+	if i := strings.Index(target, "("); i > 0 {
+		resp, err := odata.GetEntityByKey[map[string]interface{}](svc, target[:i], target[i:], opts)
+		if err != nil {
+			return err
+		}
+		rows = []map[string]interface{}{resp.D.Result}
+	} else {
+		resp, err := odata.GetEntitySet[map[string]interface{}](svc, target, opts)
+		if err != nil {
+			return err
+		}
+		rows = resp.D.Result
+		fmt.Fprintf(os.Stderr, "%d entities\n", len(rows))
+	}
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return writeEntities(out, common.format, rows)
+}
+
+func runCount(args []string) error {
+	fs, common := newFlagSet("count", "<service-path> <EntitySet>", "text", "text")
+	filter := fs.String("filter", "", "$filter expression")
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	c, _, err := connect(pos[0], common)
+	if err != nil {
+		return err
+	}
+
+	query := map[string]string{}
+	if *filter != "" {
+		query["$filter"] = *filter
+	}
+	servicePath := "/" + strings.Trim(pos[0], "/") + "/"
+	resp, err := c.ExecuteRequest(http.MethodGet, servicePath+pos[1]+"/$count", nil, query)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("counting %s: %s: %s", pos[1], resp.Status(), bytes.TrimSpace(resp.Body()))
+	}
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = fmt.Fprintln(out, strings.TrimSpace(resp.String()))
+	return err
+}
+
+func runMetadata(args []string) error {
+	fs, common := newFlagSet("metadata", "<service-path>", "summary", "summary, xml, openapi, fingerprint")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, svc, err := connect(pos[0], common)
+	if err != nil {
+		return err
+	}
+	raw, err := odata.GetMetadataXML(svc)
+	if err != nil {
+		return err
+	}
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if common.format == "xml" {
+		_, err = out.Write(raw)
+		return err
+	}
+
+	doc, err := metadata.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	switch common.format {
+	case "summary":
+		return writeMetadataSummary(out, doc)
+	case "fingerprint":
+		_, err = fmt.Fprintln(out, doc.Fingerprint())
+		return err
+	case "openapi":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc.OpenAPI(metadata.OpenAPIOptions{Title: pos[0], ServerURL: pos[0]}))
+	default:
+		return fmt.Errorf("unknown format %q", common.format)
+	}
+}
+
+// runEnums writes typed constants for the properties restricted to fixed values, read from
+// the value help entity sets their metadata annotates
+func runEnums(args []string) error {
+	fs, common := newFlagSet("enums", "<service-path>", "go", "go")
+	pkg := fs.String("package", "model", "package name of the generated file")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, svc, err := connect(pos[0], common)
+	if err != nil {
+		return err
+	}
+	doc, err := odata.GetMetadata(svc)
+	if err != nil {
+		return err
+	}
+	domains, err := fixedDomains(svc, doc)
+	if err != nil {
+		return err
+	}
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return metadata.GenerateEnums(out, *pkg, domains)
+}
+
+// fixedDomains reads the values of every property with sap:value-list="fixed-values". A
+// domain is named after its property, prefixed with the entity type where two properties of
+// that name list different domains.
+func fixedDomains(svc *odata.Service, doc *metadata.Document) ([]metadata.FixedDomain, error) {
+	types := doc.EntityTypes()
+	var domains []metadata.FixedDomain
+	collections := make(map[string]string) // domain name -> value help entity set
+	for _, qualified := range slices.Sorted(maps.Keys(types)) {
+		et := types[qualified]
+		for _, p := range et.Properties {
+			if !p.HasFixedValues() {
+				continue
+			}
+			vl, ok := doc.ValueList(qualified, p.Name)
+			if !ok || vl.ValueProperty == "" {
+				fmt.Fprintf(os.Stderr, "skipping %s/%s: no value list annotated\n", et.Name, p.Name)
+				continue
+			}
+			name := p.Name
+			if collection, seen := collections[name]; seen {
+				if collection == vl.CollectionPath {
+					continue
+				}
+				name = et.Name + p.Name
+			}
+			collections[name] = vl.CollectionPath
+
+			resp, err := odata.GetEntitySet[map[string]interface{}](svc, vl.CollectionPath, odata.NewQueryOptions().Unbounded())
+			if err != nil {
+				return nil, fmt.Errorf("reading fixed values of %s/%s: %w", et.Name, p.Name, err)
+			}
+			d := metadata.FixedDomain{Name: name, Property: et.Name + "/" + p.Name}
+			for _, e := range resp.D.Result {
+				v := metadata.FixedValue{Code: fmt.Sprint(e[vl.ValueProperty])}
+				if text, ok := e[vl.TextProperty].(string); ok {
+					v.Text = text
+				}
+				d.Values = append(d.Values, v)
+			}
+			domains = append(domains, d)
+		}
+	}
+	return domains, nil
+}
+
+func runCall(args []string) error {
+	fs, common := newFlagSet("call", "<service-path> <FunctionImport>", "json", "json, csv, table")
+	method := fs.String("method", http.MethodGet, "HTTP method (GET or POST, see m:HttpMethod in $metadata)")
+	var params multiFlag
+	fs.Var(&params, "p", `parameter as name=literal, repeatable, e.g. -p Material="'M-01'" -p Quantity=3`)
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	_, svc, err := connect(pos[0], common)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		name, literal, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("parameter %q is not in name=literal form", p)
+		}
+		values[name] = odata.RawLiteral(literal)
+	}
+
+	resp, err := odata.CallFunction[interface{}](svc, pos[1], *method, values)
+	if err != nil {
+		return err
+	}
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Collections are written like entity sets, anything else as JSON
+	if list, ok := resp.D.Result.([]interface{}); ok {
+		rows := make([]map[string]interface{}, 0, len(list))
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				rows = append(rows, m)
+			}
+		}
+		if len(rows) == len(list) {
+			return writeEntities(out, common.format, rows)
+		}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resp.D.Result)
+}
+
+func runContract(args []string) error {
+	fs, common := newFlagSet("contract", "<suite.yaml>", "text", "text")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	suite, err := contract.Load(pos[0])
+	if err != nil {
+		return err
+	}
+	c, _, err := newClient(common)
+	if err != nil {
+		return err
+	}
+
+	report := contract.Run(context.Background(), c, suite)
+
+	out, err := common.openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	fmt.Fprint(out, report)
+	if !report.Passed() {
+		return fmt.Errorf("%d contract checks failed", len(report.Failures()))
+	}
+	return nil
+}
+
+// runEncrypt prints the enc: form of the value read from stdin, so it never ends up in the
+// shell history, or a new key with -genkey
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	genKey := fs.Bool("genkey", false, "print a new random key for SAP_CONFIG_KEY")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: odata-cli encrypt [flags] < value\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *genKey {
+		key, err := config.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("reading value: %w", err)
+	}
+	enc, err := envconfig.Encrypt(strings.TrimRight(string(value), "\r\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(enc)
+	return nil
+}
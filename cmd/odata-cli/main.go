@@ -0,0 +1,139 @@
+// Command odata-cli runs ad-hoc queries against an OData V2 service using the SDK configuration
+// (.env / environment variables / Cloud Foundry bindings), for inspecting a service without writing Go.
+//
+//	odata-cli get      /sap/opu/odata/sap/ZSALES_SRV SalesOrders -filter "Status eq 'OPEN'" -top 10
+//	odata-cli get      /sap/opu/odata/sap/ZSALES_SRV "SalesOrders('5000001')" -format table
+//	odata-cli count    /sap/opu/odata/sap/ZSALES_SRV SalesOrders -filter "Status eq 'OPEN'"
+//	odata-cli metadata /sap/opu/odata/sap/ZSALES_SRV -format summary
+//	odata-cli enums    /sap/opu/odata/sap/ZSALES_SRV -package sales -o enums_gen.go
+//	odata-cli call     /sap/opu/odata/sap/ZSALES_SRV GetPrice -p Material="'M-01'" -p Quantity=3
+//	odata-cli encrypt  < password.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usageText = `usage: odata-cli <command> [flags] <service-path> ...
+
+commands:
+  get      <service-path> <EntitySet|EntitySet(key)>   query entities
+  count    <service-path> <EntitySet>                  count entities
+  metadata <service-path>                              show $metadata
+  enums    <service-path>                              generate constants for fixed values
+  call     <service-path> <FunctionImport>             invoke a function import
+  contract <suite.yaml>                                run a contract test suite
+  encrypt  [-genkey] < value                           encrypt a value with SAP_CONFIG_KEY
+
+Connection settings come from .env or the environment (SAP_HOST, SAP_USERNAME,
+SAP_PASSWORD, SAP_CLIENT, SAP_OAUTH_*, SAP_DESTINATION). Profiles listed in
+SAP_PROFILES are selected with -profile and read SAP_<PROFILE>_HOST etc. A
+<service-path> may also be a service name configured as SAP_SERVICE_<NAME>. Run
+"odata-cli <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd {
+	case "get":
+		err = runGet(args)
+	case "count":
+		err = runCount(args)
+	case "metadata":
+		err = runMetadata(args)
+	case "enums":
+		err = runEnums(args)
+	case "call":
+		err = runCall(args)
+	case "contract":
+		err = runContract(args)
+	case "encrypt":
+		err = runEncrypt(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usageText)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usageText)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// commonFlags are shared by all commands
+type commonFlags struct {
+	debug   bool
+	format  string
+	output  string
+	profile string
+}
+
+func newFlagSet(name, usage string, defaultFormat string, formats string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	c := &commonFlags{}
+	fs.BoolVar(&c.debug, "debug", false, "log HTTP requests and responses")
+	fs.StringVar(&c.format, "format", defaultFormat, "output format: "+formats)
+	fs.StringVar(&c.output, "o", "", "write output to this file instead of stdout")
+	fs.StringVar(&c.profile, "profile", "", "configuration profile (default $SAP_PROFILE)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: odata-cli %s [flags] %s\n\nflags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs, c
+}
+
+// parseArgs parses flags that may appear before or after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+	if len(pos) != positional {
+		fs.Usage()
+		return nil, fmt.Errorf("expected %d arguments, got %d", positional, len(pos))
+	}
+	return pos, nil
+}
+
+// openOutput returns stdout or the file given with -o
+func (c *commonFlags) openOutput() (io.WriteCloser, error) {
+	if c.output == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	f, err := os.Create(c.output)
+	if err != nil {
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+	return f, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// multiFlag collects repeated -p name=value flags
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+// writeEntities prints rows as indented JSON, CSV or an aligned table
+func writeEntities(w io.Writer, format string, rows []map[string]interface{}) error {
+	for _, row := range rows {
+		delete(row, "__metadata")
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if len(rows) == 1 {
+			return enc.Encode(rows[0])
+		}
+		return enc.Encode(rows)
+	case "csv":
+		cols := columns(rows)
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(cells(row, cols)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		cols := columns(rows)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(cells(row, cols), "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// columns returns the union of the scalar property names, sorted. Deferred
+// navigation properties ({"__deferred": ...}) are left out.
+func columns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for k, v := range row {
+			if m, ok := v.(map[string]interface{}); ok {
+				if _, deferred := m["__deferred"]; deferred {
+					continue
+				}
+			}
+			seen[k] = true
+		}
+	}
+	cols := make([]string, 0, len(seen))
+	for k := range seen {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+func cells(row map[string]interface{}, cols []string) []string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		switch v := row[c].(type) {
+		case nil:
+		case string:
+			out[i] = v
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(v)
+			out[i] = string(b)
+		default:
+			out[i] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// writeMetadataSummary lists entity sets with their keys and properties, and function imports
+func writeMetadataSummary(w io.Writer, doc *metadata.Document) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTITY SET\tENTITY TYPE\tKEYS\tPROPERTIES\tNAVIGATION")
+	for _, set := range doc.EntitySets() {
+		_, et, err := doc.EntitySet(set.Name)
+		if err != nil {
+			return err
+		}
+		navs := make([]string, len(et.NavigationProperties))
+		for i, n := range et.NavigationProperties {
+			navs[i] = n.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", set.Name, set.EntityType,
+			strings.Join(et.KeyNames(), ","), len(et.Properties), strings.Join(navs, ","))
+	}
+
+	var functions []string
+	for _, s := range doc.Schemas {
+		for _, c := range s.EntityContainers {
+			for _, f := range c.FunctionImports {
+				params := make([]string, len(f.Parameters))
+				for i, p := range f.Parameters {
+					params[i] = p.Name + " " + p.Type
+				}
+				method := f.HTTPMethod
+				if method == "" {
+					method = "GET"
+				}
+				functions = append(functions, fmt.Sprintf("%s\t%s\t%s\t%s", f.Name, method, f.ReturnType, strings.Join(params, ", ")))
+			}
+		}
+	}
+	if len(functions) > 0 {
+		fmt.Fprintln(tw, "\nFUNCTION IMPORT\tMETHOD\tRETURNS\tPARAMETERS")
+		for _, f := range functions {
+			fmt.Fprintln(tw, f)
+		}
+	}
+	return tw.Flush()
+}
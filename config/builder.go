@@ -0,0 +1,190 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"time"
+)
+
+// Option sets part of a Config built with New
+type Option func(*Config)
+
+// New builds a Config in code, for applications that have their own configuration system.
+// Call Validate on the result to check it.
+//
+//	cfg := config.New("https://gateway.example.com",
+//		config.WithBasicAuth(user, password),
+//		config.WithSAPClient("100"),
+//		config.WithProfile("qa", config.WithHost("https://qa.example.com")),
+//	)
+func New(host string, opts ...Option) *Config {
+	c := &Config{SAPHost: host}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithHost sets the base URL of the SAP system, e.g. to override it in a profile
+func WithHost(host string) Option {
+	return func(c *Config) {
+		c.SAPHost = host
+	}
+}
+
+// WithBasicAuth authenticates with a username and password
+func WithBasicAuth(username, password string) Option {
+	return func(c *Config) {
+		c.SAPUsername = username
+		c.SAPPassword = password
+	}
+}
+
+// WithSAPClient selects the SAP client (mandant)
+func WithSAPClient(sapClient string) Option {
+	return func(c *Config) {
+		c.SAPClient = sapClient
+	}
+}
+
+// WithLanguage sets the logon language, e.g. "DE"
+func WithLanguage(language string) Option {
+	return func(c *Config) {
+		c.Language = language
+	}
+}
+
+// WithDebug logs every request and response
+func WithDebug(debug bool) Option {
+	return func(c *Config) {
+		c.Debug = debug
+	}
+}
+
+// WithOAuth authenticates with OAuth2 tokens from tokenURL, by default with the
+// client_credentials grant
+func WithOAuth(tokenURL, clientID, clientSecret string, scopes ...string) Option {
+	return func(c *Config) {
+		c.OAuthTokenURL = tokenURL
+		c.OAuthClientID = clientID
+		c.OAuthClientSecret = clientSecret
+		c.OAuthScopes = scopes
+	}
+}
+
+// WithOAuthGrant selects the OAuth grant type, one of the Grant* constants
+func WithOAuthGrant(grantType string) Option {
+	return func(c *Config) {
+		c.OAuthGrantType = grantType
+	}
+}
+
+// WithOAuthCertificate authenticates at the token endpoint with a PEM client certificate
+// instead of the client secret
+func WithOAuthCertificate(certFile, keyFile string) Option {
+	return func(c *Config) {
+		c.OAuthCertFile = certFile
+		c.OAuthKeyFile = keyFile
+	}
+}
+
+// WithReadHost serves GET requests from a read replica such as an HA read instance
+func WithReadHost(host string) Option {
+	return func(c *Config) {
+		c.SAPReadHost = host
+	}
+}
+
+// WithProxy sends requests through an HTTP proxy
+func WithProxy(proxyURL string) Option {
+	return func(c *Config) {
+		c.ProxyURL = proxyURL
+	}
+}
+
+// WithCACert trusts the PEM certificates in caFile in addition to the system roots
+func WithCACert(caFile string) Option {
+	return func(c *Config) {
+		c.CACertFile = caFile
+	}
+}
+
+// WithClientCertificate logs on to the SAP system with a PEM client certificate (X.509 logon)
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *Config) {
+		c.ClientCertFile = certFile
+		c.ClientKeyFile = keyFile
+	}
+}
+
+// WithInsecureSkipVerify disables TLS certificate verification; for development only
+func WithInsecureSkipVerify() Option {
+	return func(c *Config) {
+		c.InsecureSkipVerify = true
+	}
+}
+
+// WithDestination resolves the host and credentials through a BTP destination
+func WithDestination(name string) Option {
+	return func(c *Config) {
+		c.SAPDestination = name
+	}
+}
+
+// WithTimeout sets the overall timeout of a single request
+func WithTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = d
+	}
+}
+
+// WithRetry retries transient failures up to maxRetries times, starting with backoff
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Config) {
+		c.RetryMax = maxRetries
+		c.RetryBackoff = backoff
+	}
+}
+
+// WithRateLimit caps the request rate at perSecond with bursts of up to burst requests
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Config) {
+		c.RateLimit = perSecond
+		c.RateBurst = burst
+	}
+}
+
+// WithMaxConcurrency limits the requests in flight; further requests wait up to wait
+func WithMaxConcurrency(n int, wait time.Duration) Option {
+	return func(c *Config) {
+		c.MaxConcurrency = n
+		c.MaxConcurrencyWait = wait
+	}
+}
+
+// WithService registers the path of a named service, see Config.ServicePath
+func WithService(name, path string) Option {
+	return func(c *Config) {
+		c.Services = maps.Clone(c.Services) // profiles may share the map of their parent
+		if c.Services == nil {
+			c.Services = make(map[string]string)
+		}
+		c.Services[strings.ToLower(name)] = path
+	}
+}
+
+// WithProfile adds a named profile. It starts as a copy of the settings given before it and
+// is then changed by opts.
+func WithProfile(name string, opts ...Option) Option {
+	return func(c *Config) {
+		p := *c
+		p.Profiles = nil
+		for _, opt := range opts {
+			opt(&p)
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]*Config)
+		}
+		c.Profiles[strings.ToLower(name)] = &p
+	}
+}
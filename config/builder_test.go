@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	cfg := New("https://gw.example.com",
+		WithBasicAuth("user", "secret"),
+		WithSAPClient("100"),
+		WithProfile("QA", WithHost("https://qa.example.com"), WithSAPClient("200")),
+		WithProxy("http://proxy:3128"),
+		WithProfile("oauth", WithOAuth("https://uaa/oauth/token", "id", "s", "read", "write"), WithBasicAuth("", "")),
+	)
+
+	want := &Config{
+		SAPHost:     "https://gw.example.com",
+		SAPUsername: "user",
+		SAPPassword: "secret",
+		SAPClient:   "100",
+		ProxyURL:    "http://proxy:3128",
+	}
+	profiles := cfg.Profiles
+	cfg.Profiles = nil
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("New() = %+v, want %+v", cfg, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	qa := profiles["qa"]
+	if qa == nil {
+		t.Fatalf("profiles = %v, want qa keyed in lower case", profiles)
+	}
+	// a profile copies the settings given before it, not those after it
+	if qa.SAPHost != "https://qa.example.com" || qa.SAPClient != "200" || qa.SAPUsername != "user" || qa.ProxyURL != "" {
+		t.Errorf("profile qa = %+v", qa)
+	}
+
+	oauth := profiles["oauth"]
+	if oauth == nil || oauth.ProxyURL != "http://proxy:3128" || oauth.SAPUsername != "" ||
+		oauth.OAuthClientID != "id" || !reflect.DeepEqual(oauth.OAuthScopes, []string{"read", "write"}) {
+		t.Errorf("profile oauth = %+v", oauth)
+	}
+	if oauth.Profiles != nil || qa.Profiles != nil {
+		t.Error("profiles must not hold profiles")
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	cfg := New("https://gw",
+		WithOAuthGrant(GrantJWTBearer),
+		WithOAuthCertificate("oauth.crt", "oauth.key"),
+		WithCACert("ca.pem"),
+		WithClientCertificate("client.crt", "client.key"),
+		WithInsecureSkipVerify(),
+		WithDestination("ERP"),
+	)
+	want := &Config{
+		SAPHost:            "https://gw",
+		OAuthGrantType:     GrantJWTBearer,
+		OAuthCertFile:      "oauth.crt",
+		OAuthKeyFile:       "oauth.key",
+		CACertFile:         "ca.pem",
+		ClientCertFile:     "client.crt",
+		ClientKeyFile:      "client.key",
+		InsecureSkipVerify: true,
+		SAPDestination:     "ERP",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("New() = %+v, want %+v", cfg, want)
+	}
+}
@@ -1,34 +1,73 @@
 package config
 
-import (
-	"log"
+import "time"
 
-	"github.com/spf13/viper"
+// Grant types accepted in SAP_OAUTH_GRANT_TYPE. All but client_credentials exchange the
+// user token of the request context (principal propagation).
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantJWTBearer         = "jwt-bearer"
+	GrantTokenExchange     = "token-exchange"
+	GrantSAML2Bearer       = "saml2-bearer"
 )
 
+// Config describes the connection to an SAP system. Create it in code with New or a struct
+// literal, or load it from the environment with the envconfig subpackage; the mapstructure
+// tags name the variables envconfig reads.
 type Config struct {
 	SAPHost     string `mapstructure:"SAP_HOST"`
 	SAPUsername string `mapstructure:"SAP_USERNAME"`
 	SAPPassword string `mapstructure:"SAP_PASSWORD"`
-	SAPClient   string `mapstructure:"SAP_CLIENT"` // Optional: sap-client param
-}
+	SAPClient   string `mapstructure:"SAP_CLIENT"`   // Optional: sap-client param
+	Language    string `mapstructure:"SAP_LANGUAGE"` // Optional: sap-language param, e.g. EN
+	Debug       bool   `mapstructure:"SAP_DEBUG"`    // Optional: log requests and responses
+
+	// Optional: read replica (HA read instance or caching proxy) serving GET requests
+	SAPReadHost string `mapstructure:"SAP_READ_HOST"`
+
+	// Optional: OAuth2 client credentials, filled from an xsuaa binding when running on BTP
+	OAuthTokenURL     string   `mapstructure:"SAP_OAUTH_TOKEN_URL"`
+	OAuthClientID     string   `mapstructure:"SAP_OAUTH_CLIENT_ID"`
+	OAuthClientSecret string   `mapstructure:"SAP_OAUTH_CLIENT_SECRET"`
+	OAuthScopes       []string `mapstructure:"SAP_OAUTH_SCOPES"`     // comma separated
+	OAuthGrantType    string   `mapstructure:"SAP_OAUTH_GRANT_TYPE"` // one of the Grant* constants, default client_credentials
+	// Optional: PEM client certificate and key authenticating at the token endpoint instead of
+	// the client secret, as used by x509 bindings and SAML bearer trust setups
+	OAuthCertFile string `mapstructure:"SAP_OAUTH_CERT_FILE"`
+	OAuthKeyFile  string `mapstructure:"SAP_OAUTH_KEY_FILE"`
+
+	// Optional: connectivity. The CA file is added to the system roots; the client
+	// certificate authenticates at the SAP system (X.509 logon).
+	ProxyURL           string `mapstructure:"SAP_PROXY_URL"`
+	CACertFile         string `mapstructure:"SAP_CA_CERT_FILE"`
+	ClientCertFile     string `mapstructure:"SAP_CLIENT_CERT_FILE"`
+	ClientKeyFile      string `mapstructure:"SAP_CLIENT_KEY_FILE"`
+	InsecureSkipVerify bool   `mapstructure:"SAP_INSECURE_SKIP_VERIFY"` // development only
+
+	// Optional: tuning. Durations are written like 30s or 1m30s; zero values keep the client defaults.
+	Timeout            time.Duration `mapstructure:"SAP_TIMEOUT"`
+	RetryMax           int           `mapstructure:"SAP_RETRY_MAX"` // retries after the first attempt, 0 disables retries
+	RetryBackoff       time.Duration `mapstructure:"SAP_RETRY_BACKOFF"`
+	RetryMaxBackoff    time.Duration `mapstructure:"SAP_RETRY_MAX_BACKOFF"`
+	RetryBudget        float64       `mapstructure:"SAP_RETRY_BUDGET"`         // max share of requests retried per minute, e.g. 0.1
+	RateLimit          float64       `mapstructure:"SAP_RATE_LIMIT"`           // requests per second
+	RateBurst          int           `mapstructure:"SAP_RATE_BURST"`           // default 1
+	MaxConcurrency     int           `mapstructure:"SAP_MAX_CONCURRENCY"`      // concurrent requests
+	MaxConcurrencyWait time.Duration `mapstructure:"SAP_MAX_CONCURRENCY_WAIT"` // wait for a free slot, zero waits as long as the context allows
+
+	// Optional: BTP destination name, resolved through the destination service binding
+	SAPDestination string `mapstructure:"SAP_DESTINATION"`
 
-// LoadConfig reads configuration from environment variables or .env file 
-func LoadConfig() (*Config, error) {
-	viper.SetConfigFile(".env")
-	viper.AutomaticEnv()
+	// VCAP holds the parsed Cloud Foundry service bindings, nil outside Cloud Foundry
+	VCAP VCAPServices `mapstructure:"-"`
 
-	// Try to read .env file, but don't fail if it doesn't exist (Docker/Prod runtime)
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			log.Printf("Warning: Error reading config file: %v", err)
-		}
-	}
+	// Services maps names to service paths, e.g. material: /sap/opu/odata/sap/YGW_MM_001_SRV/,
+	// keyed by lower-case name. envconfig reads them from SAP_SERVICE_<NAME> variables.
+	Services map[string]string `mapstructure:"-"`
 
-	config := &Config{}
-	if err := viper.Unmarshal(config); err != nil {
-		return nil, err
-	}
+	// Profiles holds named system profiles (see WithProfile and envconfig), keyed by lower-case name
+	Profiles map[string]*Config `mapstructure:"-"`
 
-	return config, nil
+	// secretRefs maps the keys of resolved fields to their secret references
+	secretRefs map[string]SecretRef
 }
@@ -0,0 +1,63 @@
+package envconfig
+
+import (
+	"fmt"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/spf13/viper"
+)
+
+// KeyEnv holds the base64 key that decrypts enc: values (see config.AESResolver). It can be
+// mounted as a file with SAP_CONFIG_KEY_FILE like any other secret.
+const KeyEnv = "SAP_CONFIG_KEY"
+
+// registerKey registers a config.AESResolver for SAP_CONFIG_KEY, if it is set. Without it a
+// resolver registered by the application (e.g. with a key unwrapped from a KMS) is used.
+func registerKey() error {
+	r, ok, err := keyResolver()
+	if err != nil || !ok {
+		return err
+	}
+	config.RegisterSecretResolver(config.EncryptedScheme, r)
+	return nil
+}
+
+func keyResolver() (*config.AESResolver, bool, error) {
+	_ = viper.BindEnv(KeyEnv)
+	value, ok, err := secretFile(KeyEnv)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		value = viper.GetString(KeyEnv)
+	}
+	if value == "" {
+		return nil, false, nil
+	}
+
+	key, err := config.ParseKey(value)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", KeyEnv, err)
+	}
+	r, err := config.NewAESResolver(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", KeyEnv, err)
+	}
+	return r, true, nil
+}
+
+// Encrypt encrypts value with the key in SAP_CONFIG_KEY, for writing it to a configuration file
+func Encrypt(value string) (string, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	viper.AutomaticEnv()
+	r, ok, err := keyResolver()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not set", KeyEnv)
+	}
+	return r.Encrypt(value)
+}
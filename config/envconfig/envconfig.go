@@ -0,0 +1,190 @@
+// Package envconfig loads a config.Config from environment variables and a .env file using
+// viper. It is kept out of the config package so that applications with their own
+// configuration system can build a Config in code without depending on viper.
+package envconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/spf13/viper"
+)
+
+// ProfilesEnv lists the named system profiles to load, comma separated (e.g. "dev,qa,prd")
+const ProfilesEnv = "SAP_PROFILES"
+
+// ServiceEnvPrefix names service paths: SAP_SERVICE_MATERIAL=/sap/opu/odata/sap/YGW_MM_001_SRV/
+// registers the service "material"
+const ServiceEnvPrefix = "SAP_SERVICE_"
+
+// ProfileEnv selects the profile used by LoadProfile("") and odata-cli when no profile is named
+const ProfileEnv = "SAP_PROFILE"
+
+// loadMu serializes Load, which works on the global viper instance, so Watch can
+// reload in the background
+var loadMu sync.Mutex
+
+// Load reads configuration from environment variables or .env file
+func Load() (*config.Config, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	viper.SetConfigFile(".env")
+	viper.AutomaticEnv()
+	// AutomaticEnv only resolves keys viper already knows, so register every field explicitly
+	bindEnv(reflect.TypeOf(config.Config{}))
+
+	// Try to read .env file, but don't fail if it doesn't exist (Docker/Prod runtime)
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: Error reading config file: %v", err)
+		}
+	}
+
+	cfg := &config.Config{}
+	if err := viper.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	if err := applySecretFiles(cfg); err != nil {
+		return nil, err
+	}
+	if err := registerKey(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cfg.RefreshSecrets(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg.Services = loadServices()
+
+	// Cloud Foundry bindings fill in whatever the environment left empty
+	if err := config.ApplyVCAPServices(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := loadProfiles(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadProfile loads the configuration and returns the named profile; an empty name selects
+// the profile in SAP_PROFILE, or the top-level configuration if that is unset too
+func LoadProfile(name string) (*config.Config, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = viper.GetString(ProfileEnv)
+	}
+	if name == "" {
+		return cfg, nil
+	}
+	return cfg.Profile(name)
+}
+
+// loadProfiles reads the profiles listed in SAP_PROFILES. A profile is configured with the
+// variables of Config carrying the upper-cased profile name after the SAP_ prefix
+// (SAP_QA_HOST, SAP_QA_PASSWORD_FILE, ...); whatever it does not set is taken from the top level.
+func loadProfiles(ctx context.Context, cfg *config.Config) error {
+	_ = viper.BindEnv(ProfilesEnv)
+	_ = viper.BindEnv(ProfileEnv)
+
+	for _, name := range strings.Split(viper.GetString(ProfilesEnv), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		overrides := viper.New()
+		for _, key := range configKeys(reflect.TypeOf(config.Config{})) {
+			pk := profileKey(name, key)
+			_ = viper.BindEnv(pk)
+			value, ok, err := secretFile(pk)
+			if err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+			switch {
+			case ok:
+				overrides.Set(key, value)
+			case viper.IsSet(pk):
+				overrides.Set(key, viper.Get(pk))
+			}
+		}
+
+		p := *cfg
+		p.Profiles = nil
+		if err := overrides.Unmarshal(&p); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if err := p.RefreshSecrets(ctx); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]*config.Config)
+		}
+		cfg.Profiles[name] = &p
+	}
+	return nil
+}
+
+// loadServices collects the SAP_SERVICE_<NAME> variables from the environment and .env file
+func loadServices() map[string]string {
+	keys := viper.AllKeys() // lower-cased keys of the .env file
+	for _, kv := range os.Environ() {
+		if key, _, ok := strings.Cut(kv, "="); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var services map[string]string
+	for _, key := range keys {
+		upper := strings.ToUpper(key)
+		if !strings.HasPrefix(upper, ServiceEnvPrefix) || len(upper) == len(ServiceEnvPrefix) {
+			continue
+		}
+		if path := viper.GetString(upper); path != "" {
+			if services == nil {
+				services = make(map[string]string)
+			}
+			services[strings.ToLower(strings.TrimPrefix(upper, ServiceEnvPrefix))] = path
+		}
+	}
+	return services
+}
+
+// profileKey turns SAP_HOST into SAP_<NAME>_HOST
+func profileKey(name, key string) string {
+	return "SAP_" + strings.ToUpper(name) + "_" + strings.TrimPrefix(key, "SAP_")
+}
+
+func bindEnv(t reflect.Type) {
+	for _, key := range configKeys(t) {
+		_ = viper.BindEnv(key)
+	}
+}
+
+// configKeys returns the variable names of the fields of t
+func configKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
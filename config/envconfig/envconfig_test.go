@@ -0,0 +1,77 @@
+package envconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	password := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(password, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SAP_HOST", "https://gw.example.com")
+	t.Setenv("SAP_USERNAME", "user")
+	t.Setenv("SAP_PASSWORD_FILE", password)
+	t.Setenv("SAP_CLIENT", "100")
+	t.Setenv(ProfilesEnv, "qa, prd")
+	t.Setenv("SAP_QA_HOST", "https://qa.example.com")
+	t.Setenv("SAP_PRD_CLIENT", "300")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SAPHost != "https://gw.example.com" || cfg.SAPUsername != "user" || cfg.SAPClient != "100" {
+		t.Errorf("Load() = %+v", cfg)
+	}
+	if cfg.SAPPassword != "s3cret" {
+		t.Errorf("password from SAP_PASSWORD_FILE = %q, want the file content without the newline", cfg.SAPPassword)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	qa, err := cfg.Profile("QA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qa.SAPHost != "https://qa.example.com" || qa.SAPClient != "100" || qa.SAPPassword != "s3cret" {
+		t.Errorf("profile qa = %+v, want its host and the top-level rest", qa)
+	}
+	prd, err := cfg.Profile("prd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prd.SAPHost != "https://gw.example.com" || prd.SAPClient != "300" {
+		t.Errorf("profile prd = %+v", prd)
+	}
+	if _, err := cfg.Profile("dev"); err == nil {
+		t.Error("Profile(dev) found a profile that is not configured")
+	}
+
+	t.Setenv(ProfileEnv, "qa")
+	selected, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected.SAPHost != "https://qa.example.com" {
+		t.Errorf("LoadProfile(\"\") with SAP_PROFILE=qa = %+v", selected)
+	}
+}
+
+func TestLoadSecretFileErrors(t *testing.T) {
+	t.Setenv("SAP_HOST", "https://gw")
+	t.Setenv("SAP_PASSWORD", "inline")
+	t.Setenv("SAP_PASSWORD_FILE", "/run/secrets/password")
+	if _, err := Load(); err == nil {
+		t.Error("Load() accepted both SAP_PASSWORD and SAP_PASSWORD_FILE")
+	}
+
+	t.Setenv("SAP_PASSWORD", "")
+	t.Setenv("SAP_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(); err == nil {
+		t.Error("Load() accepted a missing secret file")
+	}
+}
@@ -0,0 +1,53 @@
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/spf13/viper"
+)
+
+// FileSuffix marks a variable holding the path of a file with the actual value, e.g.
+// SAP_PASSWORD_FILE=/run/secrets/sap_password for Docker and Kubernetes secrets
+const FileSuffix = "_FILE"
+
+// applySecretFiles sets every field of cfg whose _FILE variant is set to the content of that
+// file. The values go through a separate viper instance so they are decoded like any other
+// setting without being stored in the global one.
+func applySecretFiles(cfg *config.Config) error {
+	files := viper.New()
+	for _, key := range configKeys(reflect.TypeOf(config.Config{})) {
+		value, ok, err := secretFile(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			files.Set(key, value)
+		}
+	}
+	return files.Unmarshal(cfg)
+}
+
+// secretFile returns the content of the file named by key + FileSuffix, if that is set.
+// Setting both the variable and its _FILE variant is an error.
+func secretFile(key string) (string, bool, error) {
+	fileKey := key + FileSuffix
+	_ = viper.BindEnv(fileKey)
+	path := viper.GetString(fileKey)
+	if path == "" {
+		return "", false, nil
+	}
+	if viper.GetString(key) != "" {
+		return "", false, fmt.Errorf("both %s and %s are set", key, fileKey)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("reading %s: %w", fileKey, err)
+	}
+	// Secrets written with echo or an editor usually end in a newline that is not part of the value
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
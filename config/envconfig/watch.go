@@ -0,0 +1,48 @@
+package envconfig
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+)
+
+// Watch reloads the configuration every interval and passes it to onChange whenever it
+// differs from the previous one, until ctx is done. _FILE secrets and secret references are
+// read again on every reload, so rotated credentials and changed hosts are picked up without
+// a restart. A failed reload is logged and the previous configuration stays in effect.
+//
+//	go envconfig.Watch(ctx, time.Minute, func(cfg *config.Config) {
+//		if err := sapClient.ApplyConfig(cfg); err != nil {
+//			log.Print(err)
+//		}
+//	})
+func Watch(ctx context.Context, interval time.Duration, onChange func(*config.Config)) error {
+	current, err := Load()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		next, err := Load()
+		if err != nil {
+			log.Printf("Warning: Error reloading config: %v", err)
+			continue
+		}
+		if reflect.DeepEqual(current, next) {
+			continue
+		}
+		current = next
+		onChange(next)
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile returns the named profile. Names are case-insensitive.
+func (c *Config) Profile(name string) (*Config, error) {
+	if p, ok := c.Profiles[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown profile %q (configured: %s)", name, strings.Join(c.ProfileNames(), ", "))
+}
+
+// ServicePath returns the path of the named service. Names are case-insensitive.
+func (c *Config) ServicePath(name string) (string, error) {
+	if p, ok := c.Services[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(c.Services))
+	for n := range c.Services {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown service %q (configured: %s)", name, strings.Join(names, ", "))
+}
+
+// ProfileNames returns the names of the configured profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
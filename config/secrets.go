@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SecretRef is a reference to a value in a secret manager, written scheme:path#key in a
+// configuration value, e.g. vault:secret/data/sap#password
+type SecretRef struct {
+	Scheme string
+	Path   string
+	Key    string // optional
+}
+
+func (r SecretRef) String() string {
+	s := r.Scheme + ":" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// SecretResolver fetches the value a SecretRef points to, e.g. from Vault, AWS Secrets
+// Manager or Azure Key Vault
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref SecretRef) (string, error)
+}
+
+// SecretResolverFunc adapts a function to SecretResolver
+type SecretResolverFunc func(ctx context.Context, ref SecretRef) (string, error)
+
+// Resolve implements SecretResolver
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]SecretResolver{}
+)
+
+// RegisterSecretResolver makes RefreshSecrets (and so envconfig.Load) resolve values starting with scheme + ":" through r.
+// Values of unregistered schemes (such as https:) are used as they are.
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[scheme] = r
+}
+
+// ParseSecretRef parses value as a reference to a registered resolver
+func ParseSecretRef(value string) (SecretRef, bool) {
+	scheme, rest, ok := strings.Cut(value, ":")
+	if !ok || rest == "" {
+		return SecretRef{}, false
+	}
+	resolversMu.RLock()
+	_, registered := resolvers[scheme]
+	resolversMu.RUnlock()
+	// enc: values are always references, so a missing key fails instead of the ciphertext
+	// being sent as the password
+	if !registered && scheme != EncryptedScheme {
+		return SecretRef{}, false
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	return SecretRef{Scheme: scheme, Path: path, Key: key}, true
+}
+
+// RefreshSecrets resolves the secret references of the configuration and its profiles,
+// replacing them with their values. Resolved references are remembered, so calling it again,
+// e.g. after the backend rejected a rotated password, fetches the current values.
+func (c *Config) RefreshSecrets(ctx context.Context) error {
+	if err := resolveSecrets(ctx, c); err != nil {
+		return err
+	}
+	for _, name := range c.ProfileNames() {
+		if err := resolveSecrets(ctx, c.Profiles[name]); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// resolveSecrets replaces the string fields of cfg holding a secret reference with the
+// resolved value. The references are kept so RefreshSecrets can resolve them again.
+func resolveSecrets(ctx context.Context, cfg *Config) error {
+	refs := make(map[string]SecretRef, len(cfg.secretRefs))
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		field := v.Field(i)
+		if key == "" || key == "-" || field.Kind() != reflect.String {
+			continue
+		}
+
+		ref, ok := cfg.secretRefs[key]
+		if !ok {
+			if ref, ok = ParseSecretRef(field.String()); !ok {
+				continue
+			}
+		}
+
+		resolversMu.RLock()
+		r := resolvers[ref.Scheme]
+		resolversMu.RUnlock()
+		if r == nil {
+			return fmt.Errorf("resolving %s: no key for encrypted values, set SAP_CONFIG_KEY or register an AESResolver", key)
+		}
+		value, err := r.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("resolving %s (%s): %w", key, ref, err)
+		}
+		field.SetString(value)
+		refs[key] = ref
+	}
+	cfg.secretRefs = refs
+	return nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found by Config.Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the configuration before it is used, so that a missing host or a half
+// configured credential is reported at startup with all other problems, not by the first
+// request. Profiles are validated too. It returns a *ValidationError.
+func (c *Config) Validate() error {
+	var problems []string
+	c.validate("", &problems)
+	for _, name := range c.ProfileNames() {
+		c.Profiles[name].validate("profile "+name+": ", &problems)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *Config) validate(prefix string, problems *[]string) {
+	add := func(format string, args ...interface{}) {
+		*problems = append(*problems, prefix+fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case c.SAPHost == "" && c.SAPDestination == "":
+		add("SAP_HOST or SAP_DESTINATION is required")
+	case c.SAPHost != "":
+		if err := checkURL(c.SAPHost); err != nil {
+			add("SAP_HOST %v", err)
+		}
+	}
+
+	if (c.SAPUsername == "") != (c.SAPPassword == "") {
+		add("SAP_USERNAME and SAP_PASSWORD must be set together")
+	}
+
+	oauth := c.OAuthTokenURL != "" || c.OAuthClientID != "" || c.OAuthClientSecret != "" || c.OAuthCertFile != ""
+	if oauth {
+		if c.OAuthTokenURL == "" || c.OAuthClientID == "" || (c.OAuthClientSecret == "" && c.OAuthCertFile == "") {
+			add("SAP_OAUTH_TOKEN_URL, SAP_OAUTH_CLIENT_ID and SAP_OAUTH_CLIENT_SECRET (or SAP_OAUTH_CERT_FILE) must be set together")
+		}
+		if c.OAuthTokenURL != "" {
+			if err := checkURL(c.OAuthTokenURL); err != nil {
+				add("SAP_OAUTH_TOKEN_URL %v", err)
+			}
+		}
+	}
+	switch c.OAuthGrantType {
+	case "", GrantClientCredentials, GrantJWTBearer, GrantTokenExchange, GrantSAML2Bearer:
+	default:
+		add("SAP_OAUTH_GRANT_TYPE %q must be one of %s, %s, %s, %s", c.OAuthGrantType,
+			GrantClientCredentials, GrantJWTBearer, GrantTokenExchange, GrantSAML2Bearer)
+	}
+	if (c.OAuthCertFile == "") != (c.OAuthKeyFile == "") {
+		add("SAP_OAUTH_CERT_FILE and SAP_OAUTH_KEY_FILE must be set together")
+	}
+	// On Cloud Foundry the xsuaa binding fills the OAuth fields alongside basic credentials
+	// of a destination, so only explicit configuration can conflict
+	if oauth && c.SAPUsername != "" && c.VCAP == nil {
+		add("SAP_USERNAME and SAP_OAUTH_CLIENT_ID are mutually exclusive")
+	}
+
+	if c.SAPReadHost != "" {
+		if err := checkURL(c.SAPReadHost); err != nil {
+			add("SAP_READ_HOST %v", err)
+		}
+	}
+	if c.ProxyURL != "" {
+		if err := checkURL(c.ProxyURL); err != nil {
+			add("SAP_PROXY_URL %v", err)
+		}
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		add("SAP_CLIENT_CERT_FILE and SAP_CLIENT_KEY_FILE must be set together")
+	}
+
+	if c.SAPClient != "" && !isSAPClient(c.SAPClient) {
+		add("SAP_CLIENT %q must be a number from 000 to 999", c.SAPClient)
+	}
+
+	for name, path := range c.Services {
+		if !strings.HasPrefix(path, "/") {
+			add("service %s path %q must start with /", name, path)
+		}
+	}
+
+	if c.Language != "" && !isLanguage(c.Language) {
+		add("SAP_LANGUAGE %q must be a two letter language code", c.Language)
+	}
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"SAP_TIMEOUT", c.Timeout},
+		{"SAP_RETRY_BACKOFF", c.RetryBackoff},
+		{"SAP_RETRY_MAX_BACKOFF", c.RetryMaxBackoff},
+		{"SAP_MAX_CONCURRENCY_WAIT", c.MaxConcurrencyWait},
+	} {
+		if d.value < 0 {
+			add("%s must not be negative", d.key)
+		}
+	}
+	if c.RetryMax < 0 || c.RetryMax > 10 {
+		add("SAP_RETRY_MAX %d must be between 0 and 10", c.RetryMax)
+	}
+	if c.RetryBudget < 0 || c.RetryBudget > 1 {
+		add("SAP_RETRY_BUDGET %g must be between 0 and 1", c.RetryBudget)
+	}
+	if c.RateLimit < 0 {
+		add("SAP_RATE_LIMIT must not be negative")
+	}
+	if c.RateBurst < 0 {
+		add("SAP_RATE_BURST must not be negative")
+	}
+	if c.MaxConcurrency < 0 {
+		add("SAP_MAX_CONCURRENCY must not be negative")
+	}
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("is not a valid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must start with http:// or https://", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// isLanguage reports whether s is a two letter ISO language code
+func isLanguage(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for _, r := range strings.ToLower(s) {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSAPClient reports whether s is a three digit client number
+func isSAPClient(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"basic auth", Config{SAPHost: "https://gw.example.com", SAPUsername: "u", SAPPassword: "p", SAPClient: "100"}, nil},
+		{"destination only", Config{SAPDestination: "ERP"}, nil},
+		{"oauth", Config{SAPHost: "https://gw", OAuthTokenURL: "https://uaa/oauth/token", OAuthClientID: "id", OAuthClientSecret: "secret"}, nil},
+		{"no host", Config{}, []string{"SAP_HOST or SAP_DESTINATION is required"}},
+		{"host without scheme", Config{SAPHost: "gw.example.com"}, []string{`SAP_HOST "gw.example.com" must start with http:// or https://`}},
+		{"host without host", Config{SAPHost: "https://"}, []string{`SAP_HOST "https://" has no host`}},
+		{"password without user", Config{SAPHost: "https://gw", SAPPassword: "p"}, []string{"SAP_USERNAME and SAP_PASSWORD must be set together"}},
+		{"half oauth", Config{SAPHost: "https://gw", OAuthClientID: "id"}, []string{"SAP_OAUTH_TOKEN_URL, SAP_OAUTH_CLIENT_ID and SAP_OAUTH_CLIENT_SECRET (or SAP_OAUTH_CERT_FILE) must be set together"}},
+		{"oauth with a certificate", Config{SAPHost: "https://gw", OAuthTokenURL: "https://uaa", OAuthClientID: "id", OAuthCertFile: "c.pem", OAuthKeyFile: "k.pem", OAuthGrantType: GrantJWTBearer}, nil},
+		{"certificate without key", Config{SAPHost: "https://gw", OAuthTokenURL: "https://uaa", OAuthClientID: "id", OAuthCertFile: "c.pem"}, []string{"SAP_OAUTH_CERT_FILE and SAP_OAUTH_KEY_FILE must be set together"}},
+		{"unknown grant", Config{SAPHost: "https://gw", OAuthGrantType: "password"}, []string{`SAP_OAUTH_GRANT_TYPE "password" must be one of client_credentials, jwt-bearer, token-exchange, saml2-bearer`}},
+		{"bad token url", Config{SAPHost: "https://gw", OAuthTokenURL: "uaa", OAuthClientID: "id", OAuthClientSecret: "s"}, []string{`SAP_OAUTH_TOKEN_URL "uaa" must start with http:// or https://`}},
+		{"basic and oauth", Config{SAPHost: "https://gw", SAPUsername: "u", SAPPassword: "p", OAuthTokenURL: "https://uaa", OAuthClientID: "id", OAuthClientSecret: "s"}, []string{"SAP_USERNAME and SAP_OAUTH_CLIENT_ID are mutually exclusive"}},
+		{"basic and oauth from a binding", Config{SAPHost: "https://gw", SAPUsername: "u", SAPPassword: "p", OAuthTokenURL: "https://uaa", OAuthClientID: "id", OAuthClientSecret: "s", VCAP: VCAPServices{}}, nil},
+		{"bad client", Config{SAPHost: "https://gw", SAPClient: "1a0"}, []string{`SAP_CLIENT "1a0" must be a number from 000 to 999`}},
+		{"all problems", Config{SAPUsername: "u", SAPClient: "1000"}, []string{
+			"SAP_HOST or SAP_DESTINATION is required",
+			"SAP_USERNAME and SAP_PASSWORD must be set together",
+			`SAP_CLIENT "1000" must be a number from 000 to 999`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(verr.Problems, tt.want) {
+				t.Errorf("problems = %q, want %q", verr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	cfg := &Config{
+		SAPHost: "https://gw",
+		Profiles: map[string]*Config{
+			"qa":  {SAPHost: "https://qa"},
+			"prd": {SAPHost: "prd", SAPClient: "x"},
+		},
+	}
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("Validate() accepted an invalid profile")
+	}
+	want := []string{
+		`profile prd: SAP_HOST "prd" must start with http:// or https://`,
+		`profile prd: SAP_CLIENT "x" must be a number from 000 to 999`,
+	}
+	if !reflect.DeepEqual(verr.Problems, want) {
+		t.Errorf("problems = %q, want %q", verr.Problems, want)
+	}
+	if got := verr.Error(); got != "invalid configuration: "+want[0]+"; "+want[1] {
+		t.Errorf("Error() = %q", got)
+	}
+}
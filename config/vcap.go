@@ -0,0 +1,281 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// VCAPServicesEnv is the environment variable Cloud Foundry uses to expose service bindings
+const VCAPServicesEnv = "VCAP_SERVICES"
+
+// ServiceBinding is a single entry of VCAP_SERVICES. Credentials are kept raw
+// because their shape depends on the service offering.
+type ServiceBinding struct {
+	Name         string          `json:"name"`
+	Label        string          `json:"label"`
+	Plan         string          `json:"plan"`
+	InstanceName string          `json:"instance_name"`
+	Tags         []string        `json:"tags"`
+	Credentials  json.RawMessage `json:"credentials"`
+}
+
+// VCAPServices is the parsed VCAP_SERVICES document keyed by service label
+type VCAPServices map[string][]ServiceBinding
+
+// XSUAACredentials are the credentials of an xsuaa service binding
+type XSUAACredentials struct {
+	ClientID     string `json:"clientid"`
+	ClientSecret string `json:"clientsecret"`
+	URL          string `json:"url"`
+	UAADomain    string `json:"uaadomain"`
+	XSAppName    string `json:"xsappname"`
+	IdentityZone string `json:"identityzone"`
+	TenantID     string `json:"tenantid"`
+	// Certificate/Key are set instead of ClientSecret for x509 bindings
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+	CertURL     string `json:"certurl"`
+}
+
+// TokenURL returns the OAuth2 token endpoint of the binding
+func (c *XSUAACredentials) TokenURL() string {
+	return strings.TrimSuffix(c.URL, "/") + "/oauth/token"
+}
+
+// DestinationCredentials are the credentials of a destination service binding
+type DestinationCredentials struct {
+	ClientID     string `json:"clientid"`
+	ClientSecret string `json:"clientsecret"`
+	URL          string `json:"url"` // XSUAA base URL used to obtain a token
+	URI          string `json:"uri"` // Destination service REST API
+}
+
+// ConnectivityCredentials are the credentials of a connectivity service binding,
+// used to reach on-premise systems through the Cloud Connector
+type ConnectivityCredentials struct {
+	ClientID               string `json:"clientid"`
+	ClientSecret           string `json:"clientsecret"`
+	URL                    string `json:"url"`               // XSUAA base URL used to obtain a token
+	TokenServiceURL        string `json:"token_service_url"` // Preferred over URL when present
+	OnPremiseProxyHost     string `json:"onpremise_proxy_host"`
+	OnPremiseProxyHTTPPort string `json:"onpremise_proxy_http_port"`
+}
+
+// TokenURL returns the OAuth2 token endpoint of the binding
+func (c *ConnectivityCredentials) TokenURL() string {
+	base := c.TokenServiceURL
+	if base == "" {
+		base = c.URL
+	}
+	return strings.TrimSuffix(base, "/") + "/oauth/token"
+}
+
+// ProxyURL returns the HTTP proxy through which on-premise hosts are reachable
+func (c *ConnectivityCredentials) ProxyURL() string {
+	return "http://" + c.OnPremiseProxyHost + ":" + c.OnPremiseProxyHTTPPort
+}
+
+// Destination is the subset of a destination configuration the SDK consumes
+type Destination struct {
+	Name           string `json:"Name"`
+	URL            string `json:"URL"`
+	Authentication string `json:"Authentication"`
+	ProxyType      string `json:"ProxyType"`
+	User           string `json:"User"`
+	Password       string `json:"Password"`
+	SAPClient      string `json:"sap-client"`
+}
+
+// ParseVCAPServices parses the JSON content of VCAP_SERVICES
+func ParseVCAPServices(raw string) (VCAPServices, error) {
+	var v VCAPServices
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", VCAPServicesEnv, err)
+	}
+	return v, nil
+}
+
+// Binding returns the first binding with the given label, or the first binding tagged with it
+func (v VCAPServices) Binding(label string) (*ServiceBinding, bool) {
+	if bindings := v[label]; len(bindings) > 0 {
+		return &bindings[0], true
+	}
+	// User-provided services are listed under "user-provided" and identified by tags
+	for _, bindings := range v {
+		for i := range bindings {
+			for _, tag := range bindings[i].Tags {
+				if tag == label {
+					return &bindings[i], true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// XSUAA returns the credentials of the first xsuaa binding
+func (v VCAPServices) XSUAA() (*XSUAACredentials, error) {
+	var creds XSUAACredentials
+	if err := v.credentials("xsuaa", &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// Destination returns the credentials of the first destination service binding
+func (v VCAPServices) Destination() (*DestinationCredentials, error) {
+	var creds DestinationCredentials
+	if err := v.credentials("destination", &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// Connectivity returns the credentials of the first connectivity service binding
+func (v VCAPServices) Connectivity() (*ConnectivityCredentials, error) {
+	var creds ConnectivityCredentials
+	if err := v.credentials("connectivity", &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+func (v VCAPServices) credentials(label string, target interface{}) error {
+	b, ok := v.Binding(label)
+	if !ok {
+		return fmt.Errorf("no %s binding found in %s", label, VCAPServicesEnv)
+	}
+	if err := json.Unmarshal(b.Credentials, target); err != nil {
+		return fmt.Errorf("decoding %s credentials: %w", label, err)
+	}
+	return nil
+}
+
+// ApplyVCAPServices fills unset Config fields from the Cloud Foundry bindings in VCAP_SERVICES,
+// if present. Explicitly configured values always win over bindings.
+func ApplyVCAPServices(cfg *Config) error {
+	raw := os.Getenv(VCAPServicesEnv)
+	if raw == "" {
+		return nil
+	}
+
+	vcap, err := ParseVCAPServices(raw)
+	if err != nil {
+		return err
+	}
+	cfg.VCAP = vcap
+
+	if xsuaa, err := vcap.XSUAA(); err == nil {
+		if cfg.OAuthTokenURL == "" {
+			cfg.OAuthTokenURL = xsuaa.TokenURL()
+		}
+		if cfg.OAuthClientID == "" {
+			cfg.OAuthClientID = xsuaa.ClientID
+		}
+		if cfg.OAuthClientSecret == "" {
+			cfg.OAuthClientSecret = xsuaa.ClientSecret
+		}
+	}
+
+	// Resolve the backend host and credentials from the destination service, if one is named
+	if cfg.SAPDestination == "" || cfg.SAPHost != "" {
+		return nil
+	}
+	creds, err := vcap.Destination()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dest, err := FetchDestination(ctx, creds, cfg.SAPDestination)
+	if err != nil {
+		return err
+	}
+
+	cfg.SAPHost = dest.URL
+	if cfg.SAPUsername == "" && dest.Authentication == "BasicAuthentication" {
+		cfg.SAPUsername = dest.User
+		cfg.SAPPassword = dest.Password
+	}
+	if cfg.SAPClient == "" {
+		cfg.SAPClient = dest.SAPClient
+	}
+	return nil
+}
+
+// FetchDestination looks up a destination by name through the destination service REST API
+func FetchDestination(ctx context.Context, creds *DestinationCredentials, name string) (*Destination, error) {
+	token, err := fetchClientCredentialsToken(ctx, strings.TrimSuffix(creds.URL, "/")+"/oauth/token", creds.ClientID, creds.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("destination service token: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(creds.URI, "/") + "/destination-configuration/v1/destinations/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		DestinationConfiguration Destination `json:"destinationConfiguration"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return nil, fmt.Errorf("destination %q lookup: %w", name, err)
+	}
+	if result.DestinationConfiguration.URL == "" {
+		return nil, fmt.Errorf("destination %q has no URL", name)
+	}
+	return &result.DestinationConfiguration, nil
+}
+
+func fetchClientCredentialsToken(ctx context.Context, tokenURL, clientID, clientSecret string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(clientID, clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &tok); err != nil {
+		return "", err
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("token response did not contain an access_token")
+	}
+	return tok.AccessToken, nil
+}
+
+func doJSON(req *http.Request, target interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, target)
+}
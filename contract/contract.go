@@ -0,0 +1,272 @@
+// Package contract verifies that live OData services still satisfy the integration contract
+// an application relies on: entity sets, key and field definitions and representative
+// filters. Suites are declared in YAML so they can be run against DEV/QA after every transport:
+//
+//	services:
+//	  - path: /sap/opu/odata/sap/ZSALES_SRV
+//	    entitySets:
+//	      - name: SalesOrders
+//	        keys: [SalesOrderID]
+//	        fields: [SalesOrderID, CustomerID, "NetAmount:Edm.Decimal"]
+//	        filters:
+//	          - name: open orders
+//	            filter: Status eq 'OPEN'
+//	            minResults: 1
+package contract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+// Suite is a set of service contracts
+type Suite struct {
+	Services []ServiceContract `yaml:"services"`
+}
+
+// ServiceContract lists the expectations on one OData service
+type ServiceContract struct {
+	Path       string              `yaml:"path"`
+	EntitySets []EntitySetContract `yaml:"entitySets"`
+}
+
+// EntitySetContract describes an entity set the integration depends on
+type EntitySetContract struct {
+	Name string `yaml:"name"`
+	// Keys are the expected key properties, in order
+	Keys []string `yaml:"keys"`
+	// Fields must exist in $metadata; "Name:Edm.Type" also pins the EDM type
+	Fields []string `yaml:"fields"`
+	// Filters are sample queries that must succeed
+	Filters []FilterCase `yaml:"filters"`
+	// SkipRead disables the sample read, e.g. for sets that require a filter
+	SkipRead bool `yaml:"skipRead"`
+}
+
+// FilterCase is a sample $filter with optional bounds on the number of results
+type FilterCase struct {
+	Name       string `yaml:"name"`
+	Filter     string `yaml:"filter"`
+	MinResults int    `yaml:"minResults"`
+	// MaxResults bounds the result count; zero means unbounded. At most
+	// max(MinResults, MaxResults)+1 entities are requested.
+	MaxResults int `yaml:"maxResults"`
+}
+
+// Load reads a suite from a YAML file
+func Load(path string) (*Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening contract suite: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a suite from YAML
+func Parse(r io.Reader) (*Suite, error) {
+	var s Suite
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("parsing contract suite: %w", err)
+	}
+	for _, svc := range s.Services {
+		if svc.Path == "" {
+			return nil, fmt.Errorf("parsing contract suite: service without path")
+		}
+		for _, es := range svc.EntitySets {
+			if es.Name == "" {
+				return nil, fmt.Errorf("parsing contract suite: entity set without name in %s", svc.Path)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Service  string
+	Check    string
+	Passed   bool
+	Message  string
+	Duration time.Duration
+}
+
+// Report collects the results of a run
+type Report struct {
+	Results []Result
+}
+
+// Passed reports whether every check passed
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if !res.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Failures returns the failed checks
+func (r *Report) Failures() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// String renders one line per check and a summary
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s  %s  %s (%s)", status, res.Service, res.Check, res.Duration.Round(time.Millisecond))
+		if res.Message != "" {
+			fmt.Fprintf(&b, ": %s", res.Message)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d checks, %d failed\n", len(r.Results), len(r.Failures()))
+	return b.String()
+}
+
+// Run executes the suite. Failed checks are reported, not returned as errors,
+// so one broken entity set does not hide the state of the others.
+func Run(ctx context.Context, c *client.SAPClient, suite *Suite) *Report {
+	report := &Report{}
+	for _, sc := range suite.Services {
+		svc := odata.NewService(c, sc.Path).WithContext(ctx)
+		runService(svc, sc, report)
+	}
+	return report
+}
+
+func runService(svc *odata.Service, sc ServiceContract, report *Report) {
+	check := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		res := Result{Service: sc.Path, Check: name, Passed: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			res.Message = err.Error()
+		}
+		report.Results = append(report.Results, res)
+		return err == nil
+	}
+
+	var doc *metadata.Document
+	if !check("$metadata", func() (err error) {
+		doc, err = odata.GetMetadata(svc)
+		return err
+	}) {
+		return // every other check depends on the metadata
+	}
+
+	for _, es := range sc.EntitySets {
+		found := false
+		check(es.Name+" definition", func() error {
+			_, et, err := doc.EntitySet(es.Name)
+			if err != nil {
+				return err
+			}
+			found = true
+			return checkDefinition(et, es)
+		})
+		if !found {
+			continue // reads would only repeat the failure
+		}
+
+		if !es.SkipRead {
+			check(es.Name+" read", func() error {
+				resp, err := odata.GetEntitySet[map[string]interface{}](svc, es.Name, odata.NewQueryOptions().Top(1))
+				if err != nil {
+					return err
+				}
+				if len(resp.D.Result) == 0 {
+					return nil // empty sets cannot be checked for fields
+				}
+				return checkFieldsPresent(resp.D.Result[0], es.Fields)
+			})
+		}
+
+		for i, fc := range es.Filters {
+			name := fc.Name
+			if name == "" {
+				name = fmt.Sprintf("filter #%d", i+1)
+			}
+			check(fmt.Sprintf("%s %s", es.Name, name), func() error {
+				return checkFilter(svc, es.Name, fc)
+			})
+		}
+	}
+}
+
+func checkDefinition(et *metadata.EntityType, es EntitySetContract) error {
+	var problems []string
+	if len(es.Keys) > 0 {
+		if got := et.KeyNames(); strings.Join(got, ",") != strings.Join(es.Keys, ",") {
+			problems = append(problems, fmt.Sprintf("keys are %v, expected %v", got, es.Keys))
+		}
+	}
+	for _, f := range es.Fields {
+		name, typ, _ := strings.Cut(f, ":")
+		p, ok := et.Property(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field %s missing", name))
+			continue
+		}
+		if typ != "" && p.Type != typ {
+			problems = append(problems, fmt.Sprintf("field %s is %s, expected %s", name, p.Type, typ))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkFieldsPresent(entity map[string]interface{}, fields []string) error {
+	var missing []string
+	for _, f := range fields {
+		name, _, _ := strings.Cut(f, ":")
+		if _, ok := entity[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("fields %v not returned by the service", missing)
+	}
+	return nil
+}
+
+func checkFilter(svc *odata.Service, entitySet string, fc FilterCase) error {
+	limit := max(fc.MinResults, fc.MaxResults) + 1
+	resp, err := odata.GetEntitySet[map[string]interface{}](svc, entitySet, odata.NewQueryOptions().Filter(fc.Filter).Top(limit))
+	if err != nil {
+		return err
+	}
+	n := len(resp.D.Result)
+	if n < fc.MinResults {
+		return fmt.Errorf("got %d results, expected at least %d", n, fc.MinResults)
+	}
+	if fc.MaxResults > 0 && n > fc.MaxResults {
+		return fmt.Errorf("got more than %d results", fc.MaxResults)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package contract_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/contract"
+	"github.com/Willias7788/go-odata-v2-sdk/odatatest"
+)
+
+const salesMetadata = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="1.0" xmlns:edmx="http://schemas.microsoft.com/ado/2007/06/edmx" xmlns:m="http://schemas.microsoft.com/ado/2007/08/dataservices/metadata">
+  <edmx:DataServices m:DataServiceVersion="2.0">
+    <Schema Namespace="ZSALES_SRV" xmlns="http://schemas.microsoft.com/ado/2008/09/edm">
+      <EntityType Name="SalesOrder">
+        <Key><PropertyRef Name="SalesOrderID"/></Key>
+        <Property Name="SalesOrderID" Type="Edm.String" Nullable="false"/>
+        <Property Name="Status" Type="Edm.String"/>
+        <Property Name="NetAmount" Type="Edm.Decimal"/>
+      </EntityType>
+      <EntityContainer Name="ZSALES_SRV_Entities" m:IsDefaultEntityContainer="true">
+        <EntitySet Name="SalesOrders" EntityType="ZSALES_SRV.SalesOrder"/>
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+const suite = `
+services:
+  - path: /sap/opu/odata/sap/ZSALES_SRV
+    entitySets:
+      - name: SalesOrders
+        keys: [SalesOrderID]
+        fields: [SalesOrderID, Status, "NetAmount:Edm.Decimal"]
+        filters:
+          - name: open orders
+            filter: Status eq 'OPEN'
+            minResults: 1
+            maxResults: 2
+          - filter: Status eq 'CLOSED'
+            minResults: 1
+      - name: Customers
+      - name: Invoices
+        keys: [InvoiceID]
+        skipRead: true
+`
+
+func TestRun(t *testing.T) {
+	srv := odatatest.NewServer("/sap/opu/odata/sap/ZSALES_SRV")
+	defer srv.Close()
+	if err := srv.SetMetadata([]byte(salesMetadata)); err != nil {
+		t.Fatal(err)
+	}
+	err := srv.Seed("SalesOrders",
+		map[string]interface{}{"SalesOrderID": "1", "Status": "OPEN", "NetAmount": "10.00"},
+		map[string]interface{}{"SalesOrderID": "2", "Status": "OPEN", "NetAmount": "20.00"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := contract.Parse(strings.NewReader(suite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := contract.Run(context.Background(), client.NewSAPClient(srv.URL, "", ""), s)
+
+	want := map[string]bool{
+		"$metadata":               true,
+		"SalesOrders definition":  true,
+		"SalesOrders read":        true,
+		"SalesOrders open orders": true,
+		"SalesOrders filter #2":   false, // no closed orders
+		"Customers definition":    false, // not in $metadata, so not read either
+		"Invoices definition":     false,
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %d checks:\n%s", len(report.Results), report)
+	}
+	for _, res := range report.Results {
+		passed, ok := want[res.Check]
+		if !ok {
+			t.Errorf("unexpected check %q", res.Check)
+			continue
+		}
+		if res.Passed != passed {
+			t.Errorf("check %q passed = %v (%s), want %v", res.Check, res.Passed, res.Message, passed)
+		}
+	}
+	if report.Passed() || len(report.Failures()) != 3 {
+		t.Errorf("got %d failures:\n%s", len(report.Failures()), report)
+	}
+	if !strings.Contains(report.String(), "7 checks, 3 failed") {
+		t.Errorf("summary missing:\n%s", report)
+	}
+}
+
+func TestRunWithoutMetadata(t *testing.T) {
+	srv := odatatest.NewServer("/sap/opu/odata/sap/ZSALES_SRV")
+	defer srv.Close()
+
+	s, err := contract.Parse(strings.NewReader(suite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := contract.Run(context.Background(), client.NewSAPClient(srv.URL, "", ""), s)
+	if len(report.Results) != 1 || report.Results[0].Check != "$metadata" || report.Results[0].Passed {
+		t.Errorf("got:\n%s", report)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":  "services:\n  - path: /svc\n    entitysets: []\n",
+		"no path":        "services:\n  - entitySets: []\n",
+		"unnamed set":    "services:\n  - path: /svc\n    entitySets:\n      - keys: [ID]\n",
+		"not a document": "services: 42\n",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := contract.Parse(strings.NewReader(src)); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+// Package datasync replicates entity sets into a local Store, for edge deployments that must
+// keep working while the SAP system is unreachable. The first sync of an entity set loads it
+// completely; later syncs read only the changes, through the service's delta tokens where
+// the entity set supports SAP delta queries, or through a change timestamp property.
+package datasync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+// Set selects an entity set, or part of one, to replicate
+type Set struct {
+	Name string
+	// Keys are the key properties, used to identify entities in the store. If empty, the
+	// key predicate of the __metadata uri is used.
+	Keys   []string
+	Filter string
+	Select []string
+	Expand []string
+	// Timestamp is an Edm.DateTime property holding the time of the last change, e.g.
+	// ChangedAt. Entity sets without delta query support are then synced by reading the
+	// entities changed since the last sync; deletions are only seen by a full load.
+	// Without delta support or Timestamp every sync is a full load.
+	Timestamp string
+}
+
+// Conflict is a remote change to an entity that was changed locally. Remote is nil when
+// the entity was deleted on the server.
+type Conflict struct {
+	EntitySet string
+	Key       string
+	Local     json.RawMessage
+	Remote    json.RawMessage
+}
+
+// ConflictFunc resolves a conflict by returning the entity to keep, nil to delete it. A
+// result other than Remote stays marked as a local change.
+type ConflictFunc func(ctx context.Context, c *Conflict) (json.RawMessage, error)
+
+// SetReport describes the sync of one entity set
+type SetReport struct {
+	EntitySet string
+	// Full is set when the entity set was loaded completely rather than by its changes
+	Full      bool
+	Upserted  int
+	Deleted   int
+	Conflicts int
+}
+
+// Syncer replicates entity sets of a service into a store
+type Syncer struct {
+	service *odata.Service
+	store   Store
+	sets    []Set
+	// OnConflict resolves remote changes to locally changed entities. By default the remote
+	// change wins.
+	OnConflict ConflictFunc
+}
+
+// New returns a Syncer replicating sets of s into store
+func New(s *odata.Service, store Store, sets ...Set) *Syncer {
+	return &Syncer{service: s, store: store, sets: sets}
+}
+
+// Sync brings every set up to date. A set that fails does not stop the others; the
+// returned error joins their errors. Since a checkpoint is only saved once a set has been
+// read to the end, a sync interrupted by lost connectivity resumes where the last
+// successful one ended.
+func (y *Syncer) Sync(ctx context.Context) ([]SetReport, error) {
+	var reports []SetReport
+	var errs []error
+	for _, set := range y.sets {
+		report, err := y.SyncSet(ctx, set)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("syncing %s: %w", set.Name, err))
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, errors.Join(errs...)
+}
+
+// checkpoint prefixes
+const (
+	deltaCheckpoint = "delta:"
+	sinceCheckpoint = "since:"
+)
+
+// SyncSet brings a single set up to date
+func (y *Syncer) SyncSet(ctx context.Context, set Set) (SetReport, error) {
+	report := SetReport{EntitySet: set.Name}
+	svc := y.service.WithContext(ctx)
+	checkpoint, err := y.store.Checkpoint(ctx, set.Name)
+	if err != nil {
+		return report, err
+	}
+
+	var link string
+	var since int64 // ms of the latest change timestamp seen
+	opts := set.query("")
+	switch {
+	case strings.HasPrefix(checkpoint, deltaCheckpoint):
+		link = strings.TrimPrefix(checkpoint, deltaCheckpoint)
+	case strings.HasPrefix(checkpoint, sinceCheckpoint) && set.Timestamp != "":
+		if since, err = strconv.ParseInt(strings.TrimPrefix(checkpoint, sinceCheckpoint), 10, 64); err != nil {
+			return report, fmt.Errorf("invalid checkpoint %q", checkpoint)
+		}
+		opts = set.query(fmt.Sprintf("%s ge datetime'%s'", set.Timestamp, time.UnixMilli(since).UTC().Format("2006-01-02T15:04:05.000")))
+	default:
+		report.Full = true
+	}
+
+	seen := make(map[string]bool)
+	var delta string
+	for {
+		page, err := odata.GetDeltaPage(svc, set.Name, opts, link)
+		if err != nil {
+			return report, err
+		}
+		if err := y.apply(ctx, set, page, &report, seen, &since); err != nil {
+			return report, err
+		}
+		if page.Next == "" {
+			delta = page.Delta
+			break
+		}
+		link = page.Next
+	}
+
+	if report.Full {
+		// Entities no longer returned by a full load were deleted on the server
+		if err := y.removeUnseen(ctx, set, seen, &report); err != nil {
+			return report, err
+		}
+	}
+	switch {
+	case delta != "":
+		checkpoint = deltaCheckpoint + delta
+	case set.Timestamp != "" && since > 0:
+		checkpoint = sinceCheckpoint + strconv.FormatInt(since, 10)
+	default:
+		checkpoint = ""
+	}
+	return report, y.store.SetCheckpoint(ctx, set.Name, checkpoint)
+}
+
+// query returns the options of set, with filter and'ed to its own filter
+func (set Set) query(filter string) *odata.QueryOptions {
+	opts := odata.NewQueryOptions().Unbounded().Filter(set.Filter).Filter(filter)
+	if len(set.Select) > 0 {
+		opts.Select(set.Select)
+	}
+	if len(set.Expand) > 0 {
+		opts.Expand(set.Expand)
+	}
+	return opts
+}
+
+// apply writes a page to the store
+func (y *Syncer) apply(ctx context.Context, set Set, page *odata.DeltaPage, report *SetReport, seen map[string]bool, since *int64) error {
+	records := make([]Record, 0, len(page.Entities))
+	for _, raw := range page.Entities {
+		key, err := entityKey(raw, set.Keys)
+		if err != nil {
+			return err
+		}
+		seen[key] = true
+		if ms, ok := timestamp(raw, set.Timestamp); ok && ms > *since {
+			*since = ms
+		}
+		entity, local, conflict, err := y.resolve(ctx, set.Name, key, raw)
+		if err != nil {
+			return err
+		}
+		if conflict {
+			report.Conflicts++
+		}
+		if entity == nil {
+			if err := y.store.Delete(ctx, set.Name, key); err != nil {
+				return err
+			}
+			report.Deleted++
+			continue
+		}
+		records = append(records, Record{Key: key, Entity: entity, Local: local})
+	}
+	if len(records) > 0 {
+		if err := y.store.Put(ctx, set.Name, records...); err != nil {
+			return err
+		}
+		report.Upserted += len(records)
+	}
+
+	for _, raw := range page.Deleted {
+		key, err := entityKey(raw, set.Keys)
+		if err != nil {
+			return err
+		}
+		if err := y.remove(ctx, set.Name, key, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns what to store for a remote entity (nil for nothing), whether it remains
+// a local change and whether it conflicted with one
+func (y *Syncer) resolve(ctx context.Context, entitySet, key string, remote json.RawMessage) (entity json.RawMessage, local, conflict bool, err error) {
+	existing, ok, err := y.store.Get(ctx, entitySet, key)
+	if err != nil || !ok || !existing.Local {
+		return remote, false, false, err
+	}
+	if y.OnConflict == nil {
+		return remote, false, true, nil
+	}
+	entity, err = y.OnConflict(ctx, &Conflict{EntitySet: entitySet, Key: key, Local: existing.Entity, Remote: remote})
+	if err != nil {
+		return nil, false, true, fmt.Errorf("resolving conflict on %s%s: %w", entitySet, key, err)
+	}
+	return entity, entity != nil && !bytes.Equal(entity, remote), true, nil
+}
+
+// remove deletes an entity deleted on the server, through the conflict handler if it
+// was changed locally
+func (y *Syncer) remove(ctx context.Context, entitySet, key string, report *SetReport) error {
+	entity, local, conflict, err := y.resolve(ctx, entitySet, key, nil)
+	if err != nil {
+		return err
+	}
+	if conflict {
+		report.Conflicts++
+	}
+	if entity != nil {
+		return y.store.Put(ctx, entitySet, Record{Key: key, Entity: entity, Local: local})
+	}
+	report.Deleted++
+	return y.store.Delete(ctx, entitySet, key)
+}
+
+func (y *Syncer) removeUnseen(ctx context.Context, set Set, seen map[string]bool, report *SetReport) error {
+	records, err := y.store.List(ctx, set.Name)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if !seen[r.Key] {
+			if err := y.remove(ctx, set.Name, r.Key, report); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Update stores a local change to an entity, to be written back to the service by the
+// application. Until then remote changes to it are passed to OnConflict.
+func (y *Syncer) Update(ctx context.Context, entitySet, key string, entity interface{}) error {
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("encoding %s%s: %w", entitySet, key, err)
+	}
+	return y.store.Put(ctx, entitySet, Record{Key: key, Entity: raw, Local: true})
+}
+
+// Get decodes the stored entity of entitySet with the given key
+func Get[T any](ctx context.Context, store Store, entitySet, key string) (T, bool, error) {
+	var v T
+	r, ok, err := store.Get(ctx, entitySet, key)
+	if err != nil || !ok {
+		return v, ok, err
+	}
+	if err := json.Unmarshal(r.Entity, &v); err != nil {
+		return v, false, fmt.Errorf("decoding %s%s: %w", entitySet, key, err)
+	}
+	return v, true, nil
+}
+
+// List decodes the stored entities of entitySet in key order
+func List[T any](ctx context.Context, store Store, entitySet string) ([]T, error) {
+	records, err := store.List(ctx, entitySet)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, len(records))
+	for i, r := range records {
+		if err := json.Unmarshal(r.Entity, &out[i]); err != nil {
+			return nil, fmt.Errorf("decoding %s%s: %w", entitySet, r.Key, err)
+		}
+	}
+	return out, nil
+}
+
+// entityKey returns the key predicate of an entity from its key properties, or from its
+// __metadata uri when keys is empty
+func entityKey(raw json.RawMessage, keys []string) (string, error) {
+	var e map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return "", fmt.Errorf("decoding entity: %w", err)
+	}
+	if len(keys) == 0 {
+		var meta struct {
+			URI string `json:"uri"`
+		}
+		_ = json.Unmarshal(e["__metadata"], &meta)
+		if i := strings.LastIndex(meta.URI, "("); i >= 0 && strings.HasSuffix(meta.URI, ")") {
+			return meta.URI[i:], nil
+		}
+		return "", fmt.Errorf("entity has no __metadata uri, set the key properties of the set")
+	}
+
+	literals := make([]string, len(keys))
+	for i, k := range keys {
+		v, ok := e[k]
+		if !ok {
+			return "", fmt.Errorf("entity lacks key property %s", k)
+		}
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			literals[i] = "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		} else {
+			literals[i] = string(v)
+		}
+		if len(keys) > 1 {
+			literals[i] = k + "=" + literals[i]
+		}
+	}
+	return "(" + strings.Join(literals, ",") + ")", nil
+}
+
+// timestamp returns the value of an Edm.DateTime property in ms
+func timestamp(raw json.RawMessage, property string) (int64, bool) {
+	if property == "" {
+		return 0, false
+	}
+	var e map[string]json.RawMessage
+	if json.Unmarshal(raw, &e) != nil {
+		return 0, false
+	}
+	var s string
+	if json.Unmarshal(e[property], &s) != nil {
+		return 0, false
+	}
+	t, err := models.ParseDateTime(s)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixMilli(), true
+}
@@ -0,0 +1,286 @@
+package datasync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+type product struct {
+	ProductID string
+	Name      string
+}
+
+// gateway answers GET requests on /svc/ with the body registered for the query string
+type gateway struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	responses map[string]string // raw query -> body
+	queries   []string
+}
+
+func newGateway(t *testing.T) (*gateway, *odata.Service) {
+	g := &gateway{t: t, responses: make(map[string]string)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		q := r.URL.Query()
+		key := strings.TrimPrefix(r.URL.Path, "/svc/")
+		for _, p := range []string{"!deltatoken", "$skiptoken", "$filter"} {
+			if v := q.Get(p); v != "" {
+				key += "?" + p + "=" + v
+			}
+		}
+		g.queries = append(g.queries, key)
+		body, ok := g.responses[key]
+		if !ok {
+			t.Errorf("unexpected request %s", key)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return g, odata.NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/")
+}
+
+func (g *gateway) answer(query string, d map[string]interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	raw, err := json.Marshal(map[string]interface{}{"d": d})
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	g.responses[query] = string(raw)
+}
+
+func entities(ids ...string) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		name := id
+		if j := strings.IndexByte(id, ':'); j >= 0 {
+			id, name = id[:j], id[j+1:]
+		}
+		out[i] = map[string]interface{}{"ProductID": id, "Name": name}
+	}
+	return out
+}
+
+func names(t *testing.T, store Store) []string {
+	t.Helper()
+	products, err := List[product](context.Background(), store, "ProductSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, p := range products {
+		out = append(out, p.ProductID+":"+p.Name)
+	}
+	return out
+}
+
+func TestSyncDelta(t *testing.T) {
+	ctx := context.Background()
+	g, service := newGateway(t)
+	g.answer("ProductSet", map[string]interface{}{"results": entities("A", "B"), "__next": "/svc/ProductSet?$skiptoken=2"})
+	g.answer("ProductSet?$skiptoken=2", map[string]interface{}{"results": entities("C"), "__delta": "/svc/ProductSet?!deltatoken='t1'"})
+	g.answer("ProductSet?!deltatoken='t1'", map[string]interface{}{
+		"results":   entities("B:changed", "D"),
+		"__deleted": entities("A"),
+		"__delta":   "/svc/ProductSet?!deltatoken='t2'",
+	})
+
+	store := NewMemoryStore()
+	syncer := New(service, store, Set{Name: "ProductSet", Keys: []string{"ProductID"}})
+
+	reports, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || !reports[0].Full || reports[0].Upserted != 3 {
+		t.Errorf("first sync reports %+v, want a full load of 3", reports)
+	}
+	if got := strings.Join(names(t, store), " "); got != "A:A B:B C:C" {
+		t.Errorf("after the first sync the store holds %s", got)
+	}
+	if cp, _ := store.Checkpoint(ctx, "ProductSet"); cp != "delta:/svc/ProductSet?!deltatoken='t1'" {
+		t.Errorf("checkpoint = %q", cp)
+	}
+
+	reports, err = syncer.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := reports[0]; r.Full || r.Upserted != 2 || r.Deleted != 1 {
+		t.Errorf("delta sync reports %+v, want 2 upserted and 1 deleted", r)
+	}
+	if got := strings.Join(names(t, store), " "); got != "B:changed C:C D:D" {
+		t.Errorf("after the delta sync the store holds %s", got)
+	}
+	if p, ok, err := Get[product](ctx, store, "ProductSet", "('D')"); err != nil || !ok || p.Name != "D" {
+		t.Errorf("Get('D') = %+v, %v, %v", p, ok, err)
+	}
+}
+
+func TestSyncTimestamp(t *testing.T) {
+	ctx := context.Background()
+	g, service := newGateway(t)
+	g.answer("ProductSet", map[string]interface{}{"results": []map[string]interface{}{
+		{"ProductID": "A", "Name": "A", "ChangedAt": "/Date(1700000000000)/"},
+		{"ProductID": "B", "Name": "B", "ChangedAt": "/Date(1700000005000)/"},
+	}})
+	g.answer("ProductSet?$filter=ChangedAt ge datetime'2023-11-14T22:13:25.000'", map[string]interface{}{"results": []map[string]interface{}{
+		{"ProductID": "B", "Name": "B2", "ChangedAt": "/Date(1700000009000)/"},
+	}})
+
+	store := NewMemoryStore()
+	syncer := New(service, store, Set{Name: "ProductSet", Keys: []string{"ProductID"}, Timestamp: "ChangedAt"})
+	for range 2 {
+		if _, err := syncer.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(names(t, store), " "); got != "A:A B:B2" {
+		t.Errorf("store holds %s", got)
+	}
+	if cp, _ := store.Checkpoint(ctx, "ProductSet"); cp != "since:1700000009000" {
+		t.Errorf("checkpoint = %q, want the latest change timestamp", cp)
+	}
+}
+
+func TestSyncFullLoadRemovesUnseen(t *testing.T) {
+	ctx := context.Background()
+	g, service := newGateway(t)
+	g.answer("ProductSet", map[string]interface{}{"results": entities("A", "B")})
+
+	store := NewMemoryStore()
+	syncer := New(service, store, Set{Name: "ProductSet", Keys: []string{"ProductID"}})
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	g.answer("ProductSet", map[string]interface{}{"results": entities("B")})
+	reports, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := reports[0]; !r.Full || r.Deleted != 1 {
+		t.Errorf("second full load reports %+v, want A deleted", r)
+	}
+	if got := strings.Join(names(t, store), " "); got != "B:B" {
+		t.Errorf("store holds %s", got)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	ctx := context.Background()
+	g, service := newGateway(t)
+	g.answer("ProductSet", map[string]interface{}{"results": entities("A", "B")})
+
+	store := NewMemoryStore()
+	syncer := New(service, store, Set{Name: "ProductSet", Keys: []string{"ProductID"}})
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Update(ctx, "ProductSet", "('A')", product{ProductID: "A", Name: "local"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Update(ctx, "ProductSet", "('B')", product{ProductID: "B", Name: "local"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var conflicts []string
+	syncer.OnConflict = func(_ context.Context, c *Conflict) (json.RawMessage, error) {
+		conflicts = append(conflicts, c.Key)
+		if c.Key == "('A')" {
+			return c.Local, nil // keep the local change
+		}
+		return c.Remote, nil
+	}
+	g.answer("ProductSet", map[string]interface{}{"results": entities("A:remote", "B:remote")})
+	reports, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports[0].Conflicts != 2 || len(conflicts) != 2 {
+		t.Errorf("reports %+v, conflicts %v, want 2", reports, conflicts)
+	}
+	if got := strings.Join(names(t, store), " "); got != "A:local B:remote" {
+		t.Errorf("store holds %s", got)
+	}
+	a, _, _ := store.Get(ctx, "ProductSet", "('A')")
+	b, _, _ := store.Get(ctx, "ProductSet", "('B')")
+	if !a.Local || b.Local {
+		t.Errorf("local marks: A %v, B %v, want only the kept local change marked", a.Local, b.Local)
+	}
+}
+
+func TestEntityKey(t *testing.T) {
+	tests := []struct {
+		raw     string
+		keys    []string
+		want    string
+		wantErr bool
+	}{
+		{`{"ProductID":"HT-1000"}`, []string{"ProductID"}, "('HT-1000')", false},
+		{`{"Name":"O'Neil"}`, []string{"Name"}, "('O''Neil')", false},
+		{`{"Vbeln":"1","Posnr":10}`, []string{"Vbeln", "Posnr"}, "(Vbeln='1',Posnr=10)", false},
+		{`{"__metadata":{"uri":"http://gw/svc/ProductSet('X')"}}`, nil, "('X')", false},
+		{`{"ProductID":"A"}`, nil, "", true},
+		{`{"Name":"A"}`, []string{"ProductID"}, "", true},
+		{`not json`, []string{"ProductID"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := entityKey(json.RawMessage(tt.raw), tt.keys)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("entityKey(%s, %v) = %q, %v, want %q", tt.raw, tt.keys, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "ProductSet",
+		Record{Key: "('B')", Entity: json.RawMessage(`{"ProductID":"B"}`)},
+		Record{Key: "('A')", Entity: json.RawMessage(`{"ProductID":"A"}`), Local: true},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetCheckpoint(ctx, "ProductSet", "delta:x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "ProductSet", "('B')"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := reopened.List(ctx, "ProductSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Key != "('A')" || !records[0].Local {
+		t.Errorf("reopened store lists %+v", records)
+	}
+	if cp, err := reopened.Checkpoint(ctx, "ProductSet"); err != nil || cp != "delta:x" {
+		t.Errorf("Checkpoint() = %q, %v", cp, err)
+	}
+	if _, ok, err := reopened.Get(ctx, "OtherSet", "('A')"); ok || err != nil {
+		t.Errorf("Get on an unknown set = %v, %v", ok, err)
+	}
+}
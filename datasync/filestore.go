@@ -0,0 +1,155 @@
+package datasync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a Store keeping one JSON file per entity set in a directory. Every change
+// rewrites the file of its entity set atomically, so a crash leaves the previous state.
+// It suits the moderate volumes of edge deployments; use a database backed Store for more.
+type FileStore struct {
+	dir string
+
+	mu   sync.Mutex
+	sets map[string]*storedSet // loaded lazily
+}
+
+// NewFileStore returns a FileStore in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating sync store: %w", err)
+	}
+	return &FileStore{dir: dir, sets: make(map[string]*storedSet)}, nil
+}
+
+func (f *FileStore) path(entitySet string) string {
+	return filepath.Join(f.dir, entitySet+".json")
+}
+
+// load returns the content of entitySet, reading its file on first use
+func (f *FileStore) load(entitySet string) (*storedSet, error) {
+	if s, ok := f.sets[entitySet]; ok {
+		return s, nil
+	}
+	s := newStoredSet()
+	raw, err := os.ReadFile(f.path(entitySet))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading sync store: %w", err)
+	default:
+		if err := json.Unmarshal(raw, s); err != nil {
+			return nil, fmt.Errorf("reading sync store %s: %w", f.path(entitySet), err)
+		}
+		if s.Records == nil {
+			s.Records = make(map[string]Record)
+		}
+	}
+	f.sets[entitySet] = s
+	return s, nil
+}
+
+// save writes entitySet through a temporary file renamed over the old one
+func (f *FileStore) save(entitySet string, s *storedSet) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, entitySet+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing sync store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing sync store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing sync store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing sync store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path(entitySet)); err != nil {
+		return fmt.Errorf("writing sync store: %w", err)
+	}
+	return nil
+}
+
+// update applies fn to entitySet and saves it. A failed save drops the cached content, so
+// the next call reads what is on disk again.
+func (f *FileStore) update(entitySet string, fn func(*storedSet)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.load(entitySet)
+	if err != nil {
+		return err
+	}
+	fn(s)
+	if err := f.save(entitySet, s); err != nil {
+		delete(f.sets, entitySet)
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) Get(_ context.Context, entitySet, key string) (Record, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.load(entitySet)
+	if err != nil {
+		return Record{}, false, err
+	}
+	r, ok := s.Records[key]
+	return r, ok, nil
+}
+
+func (f *FileStore) List(_ context.Context, entitySet string) ([]Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.load(entitySet)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(), nil
+}
+
+func (f *FileStore) Put(_ context.Context, entitySet string, records ...Record) error {
+	return f.update(entitySet, func(s *storedSet) {
+		for _, r := range records {
+			s.Records[r.Key] = r
+		}
+	})
+}
+
+func (f *FileStore) Delete(_ context.Context, entitySet string, keys ...string) error {
+	return f.update(entitySet, func(s *storedSet) {
+		for _, k := range keys {
+			delete(s.Records, k)
+		}
+	})
+}
+
+func (f *FileStore) Checkpoint(_ context.Context, entitySet string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.load(entitySet)
+	if err != nil {
+		return "", err
+	}
+	return s.Checkpoint, nil
+}
+
+func (f *FileStore) SetCheckpoint(_ context.Context, entitySet, checkpoint string) error {
+	return f.update(entitySet, func(s *storedSet) {
+		s.Checkpoint = checkpoint
+	})
+}
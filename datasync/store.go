@@ -0,0 +1,128 @@
+package datasync
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Record is a replicated entity as it was read from the service
+type Record struct {
+	// Key is the key predicate of the entity, e.g. ('M-01') or (Vbeln='1',Posnr='10')
+	Key    string          `json:"key"`
+	Entity json.RawMessage `json:"entity"`
+	// Local marks an entity changed locally (see Syncer.Update) and not written back yet.
+	// Remote changes to it go through the conflict handler.
+	Local bool `json:"local,omitempty"`
+}
+
+// Store persists replicated entity sets and their sync checkpoints. Implement it on top of
+// SQLite, bbolt or any other local database; MemoryStore and FileStore need no dependencies.
+type Store interface {
+	Get(ctx context.Context, entitySet, key string) (Record, bool, error)
+	// List returns the records of entitySet in key order
+	List(ctx context.Context, entitySet string) ([]Record, error)
+	Put(ctx context.Context, entitySet string, records ...Record) error
+	Delete(ctx context.Context, entitySet string, keys ...string) error
+	// Checkpoint returns where the last sync of entitySet ended, "" before the first one
+	Checkpoint(ctx context.Context, entitySet string) (string, error)
+	SetCheckpoint(ctx context.Context, entitySet, checkpoint string) error
+}
+
+// MemoryStore is a Store that keeps everything in memory, e.g. for tests or for caches
+// rebuilt on every start
+type MemoryStore struct {
+	mu   sync.RWMutex
+	sets map[string]*storedSet
+}
+
+// storedSet is the content of one entity set, shared with FileStore
+type storedSet struct {
+	Checkpoint string            `json:"checkpoint"`
+	Records    map[string]Record `json:"records"`
+}
+
+func newStoredSet() *storedSet {
+	return &storedSet{Records: make(map[string]Record)}
+}
+
+func (s *storedSet) list() []Record {
+	records := make([]Record, 0, len(s.Records))
+	for _, r := range s.Records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sets: make(map[string]*storedSet)}
+}
+
+func (m *MemoryStore) set(entitySet string) *storedSet {
+	s, ok := m.sets[entitySet]
+	if !ok {
+		s = newStoredSet()
+		m.sets[entitySet] = s
+	}
+	return s
+}
+
+func (m *MemoryStore) Get(_ context.Context, entitySet, key string) (Record, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sets[entitySet]
+	if !ok {
+		return Record{}, false, nil
+	}
+	r, ok := s.Records[key]
+	return r, ok, nil
+}
+
+func (m *MemoryStore) List(_ context.Context, entitySet string) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sets[entitySet]
+	if !ok {
+		return nil, nil
+	}
+	return s.list(), nil
+}
+
+func (m *MemoryStore) Put(_ context.Context, entitySet string, records ...Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.set(entitySet)
+	for _, r := range records {
+		s.Records[r.Key] = r
+	}
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, entitySet string, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.set(entitySet)
+	for _, k := range keys {
+		delete(s.Records, k)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Checkpoint(_ context.Context, entitySet string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if s, ok := m.sets[entitySet]; ok {
+		return s.Checkpoint, nil
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) SetCheckpoint(_ context.Context, entitySet, checkpoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(entitySet).Checkpoint = checkpoint
+	return nil
+}
@@ -5,7 +5,7 @@ import (
 	"log"
 
 	"github.com/Willias7788/go-odata-v2-sdk/client"
-	"github.com/Willias7788/go-odata-v2-sdk/config"
+	"github.com/Willias7788/go-odata-v2-sdk/config/envconfig"
 	"github.com/Willias7788/go-odata-v2-sdk/odata"
 )
 
@@ -20,13 +20,11 @@ type Material struct {
 
 func main() {
 	// 1. Load Configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := envconfig.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	fmt.Println(cfg)
-
 	// For demo purposes, we fallback if config is empty (so it runs without env file)
 	if cfg.SAPHost == "" {
 		fmt.Println("No configuration found, using example values...")
@@ -35,9 +33,12 @@ func main() {
 		cfg.SAPPassword = "Pass" // Replace with real one
 	}
 
-	// 2. Initialize Base Client
-	sapClient := client.NewSAPClient(cfg.SAPHost, cfg.SAPUsername, cfg.SAPPassword)
-	sapClient.SetDebug(true) // Enable to see request/response logs
+	// 2. Initialize Base Client (sap-client, language, timeouts etc. come from the config)
+	cfg.Debug = true // Enable to see request/response logs
+	sapClient, err := client.NewSAPClientFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
 
 	// 3. Initialize OData Service
 	// We point to a standard demo service
@@ -61,7 +62,7 @@ func main() {
 	} else {
 		// Access results: resp.D.Result (due to our generic wrapper)
 		for _, p := range productsResp.D.Result {
-			fmt.Printf("Product: %s - %s ($%.2f)\n", p.Material, p.CreatedOn, p.MatType)
+			fmt.Printf("Product: %s - %s (%s)\n", p.Material, p.CreatedOn, p.MatType)
 		}
 	}
 
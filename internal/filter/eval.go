@@ -0,0 +1,424 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Match evaluates a parsed expression against an entity in JSON form
+// (map[string]interface{} as produced by encoding/json) and reports whether it matches
+func Match(n Node, entity map[string]interface{}) (bool, error) {
+	v, err := Eval(n, entity)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("filter does not evaluate to a boolean but to %T", v)
+	}
+	return b, nil
+}
+
+// Eval evaluates n against entity. Navigation paths (A/B) are resolved into nested
+// maps; missing properties evaluate to null.
+func Eval(n Node, entity map[string]interface{}) (interface{}, error) {
+	switch n := n.(type) {
+	case *Literal:
+		return n.Value, nil
+	case *Property:
+		return lookup(entity, n.Name), nil
+	case *Unary:
+		v, err := Eval(n.Operand, entity)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == "not" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("not expects a boolean operand, got %T", v)
+			}
+			return !b, nil
+		}
+		f, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %T", v)
+		}
+		return -f, nil
+	case *Binary:
+		return evalBinary(n, entity)
+	case *Call:
+		return evalCall(n, entity)
+	default:
+		return nil, fmt.Errorf("unsupported node %T", n)
+	}
+}
+
+func lookup(entity map[string]interface{}, path string) interface{} {
+	var cur interface{} = entity
+	for _, part := range strings.Split(path, "/") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+func evalBinary(n *Binary, entity map[string]interface{}) (interface{}, error) {
+	left, err := Eval(n.Left, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit the logical operators
+	if n.Op == "and" || n.Op == "or" {
+		lb, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s expects boolean operands, got %T", n.Op, left)
+		}
+		if n.Op == "and" && !lb || n.Op == "or" && lb {
+			return lb, nil
+		}
+		right, err := Eval(n.Right, entity)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s expects boolean operands, got %T", n.Op, right)
+		}
+		return rb, nil
+	}
+
+	right, err := Eval(n.Right, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case "eq":
+		return Equal(left, right), nil
+	case "ne":
+		return !Equal(left, right), nil
+	case "gt", "ge", "lt", "le":
+		c, ok := Compare(left, right)
+		if !ok {
+			// Comparisons involving null or incompatible types are false, like on the server
+			return false, nil
+		}
+		switch n.Op {
+		case "gt":
+			return c > 0, nil
+		case "ge":
+			return c >= 0, nil
+		case "lt":
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	}
+
+	// Arithmetic
+	a, okA := toNumber(left)
+	b, okB := toNumber(right)
+	if !okA || !okB {
+		return nil, fmt.Errorf("%s expects numeric operands, got %T and %T", n.Op, left, right)
+	}
+	switch n.Op {
+	case "add":
+		return a + b, nil
+	case "sub":
+		return a - b, nil
+	case "mul":
+		return a * b, nil
+	case "div":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case "mod":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(a, b), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", n.Op)
+}
+
+func evalCall(n *Call, entity map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.Args))
+	for i, a := range n.Args {
+		v, err := Eval(a, entity)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	str := func(i int) (string, error) {
+		switch v := args[i].(type) {
+		case string:
+			return v, nil
+		case nil:
+			return "", nil
+		default:
+			return "", fmt.Errorf("%s expects a string as argument %d, got %T", n.Name, i+1, v)
+		}
+	}
+	num := func(i int) (float64, error) {
+		f, ok := toNumber(args[i])
+		if !ok {
+			return 0, fmt.Errorf("%s expects a number as argument %d, got %T", n.Name, i+1, args[i])
+		}
+		return f, nil
+	}
+	date := func(i int) (time.Time, error) {
+		t, ok := toTime(args[i])
+		if !ok {
+			return time.Time{}, fmt.Errorf("%s expects a date as argument %d, got %T", n.Name, i+1, args[i])
+		}
+		return t, nil
+	}
+
+	switch n.Name {
+	case "substringof", "startswith", "endswith", "indexof", "concat":
+		a, err := str(0)
+		if err != nil {
+			return nil, err
+		}
+		b, err := str(1)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Name {
+		case "substringof":
+			// V2 argument order: substringof(needle, haystack)
+			return strings.Contains(b, a), nil
+		case "startswith":
+			return strings.HasPrefix(a, b), nil
+		case "endswith":
+			return strings.HasSuffix(a, b), nil
+		case "indexof":
+			return float64(strings.Index(a, b)), nil
+		default:
+			return a + b, nil
+		}
+	case "length", "tolower", "toupper", "trim":
+		s, err := str(0)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Name {
+		case "length":
+			return float64(len([]rune(s))), nil
+		case "tolower":
+			return strings.ToLower(s), nil
+		case "toupper":
+			return strings.ToUpper(s), nil
+		default:
+			return strings.TrimSpace(s), nil
+		}
+	case "replace":
+		s, err := str(0)
+		if err != nil {
+			return nil, err
+		}
+		from, err := str(1)
+		if err != nil {
+			return nil, err
+		}
+		to, err := str(2)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s, from, to), nil
+	case "substring":
+		s, err := str(0)
+		if err != nil {
+			return nil, err
+		}
+		r := []rune(s)
+		start, err := num(1)
+		if err != nil {
+			return nil, err
+		}
+		from := clamp(int(start), len(r))
+		to := len(r)
+		if len(args) == 3 {
+			length, err := num(2)
+			if err != nil {
+				return nil, err
+			}
+			to = clamp(from+int(length), len(r))
+		}
+		return string(r[from:to]), nil
+	case "year", "month", "day", "hour", "minute", "second":
+		t, err := date(0)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Name {
+		case "year":
+			return float64(t.Year()), nil
+		case "month":
+			return float64(t.Month()), nil
+		case "day":
+			return float64(t.Day()), nil
+		case "hour":
+			return float64(t.Hour()), nil
+		case "minute":
+			return float64(t.Minute()), nil
+		default:
+			return float64(t.Second()), nil
+		}
+	case "round", "floor", "ceiling":
+		f, err := num(0)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Name {
+		case "round":
+			return math.Round(f), nil
+		case "floor":
+			return math.Floor(f), nil
+		default:
+			return math.Ceil(f), nil
+		}
+	}
+	return nil, fmt.Errorf("function %s is not supported", n.Name)
+}
+
+func clamp(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// Equal compares two values with the same coercions as Compare; null only equals null
+func Equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := Compare(a, b); ok {
+		return c == 0
+	}
+	return false
+}
+
+// Compare orders two values. Numbers sent as strings (Edm.Decimal, Edm.Int64) are compared
+// numerically and "/Date(ms)/" strings as dates. ok is false when the values are not comparable.
+func Compare(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime || isDateString(a) || isDateString(b) {
+		ta, okA := toTime(a)
+		tb, okB := toTime(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if aStr && bStr {
+		return strings.Compare(a.(string), b.(string)), true
+	}
+
+	fa, okA := toNumber(a)
+	fb, okB := toNumber(b)
+	if okA && okB {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func isDateString(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "/Date(")
+}
+
+// toTime accepts time.Time, V2 JSON "/Date(ms)/" or "/Date(ms+0100)/" and ISO 8601 strings
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if strings.HasPrefix(v, "/Date(") && strings.HasSuffix(v, ")/") {
+			inner := v[len("/Date(") : len(v)-len(")/")]
+			if i := strings.IndexAny(inner[1:], "+-"); i >= 0 {
+				inner = inner[:i+1] // offset is informational, ms are UTC
+			}
+			ms, err := strconv.ParseInt(inner, 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.UnixMilli(ms).UTC(), true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		for _, layout := range DateTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,92 @@
+package filter
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	entity := map[string]interface{}{
+		"Name":       "Notebook Basic 15",
+		"Category":   "Notebooks",
+		"Price":      "956.00", // Edm.Decimal
+		"Quantity":   float64(3),
+		"Active":     true,
+		"Deleted":    nil,
+		"Created":    "/Date(1704164640000)/", // 2024-01-02T03:04Z
+		"ToSupplier": map[string]interface{}{"Name": "SAP"},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"Name eq 'Notebook Basic 15'", true},
+		{"Name eq 'notebook basic 15'", false},
+		{"Name gt 'Notebook'", true},
+		{"Name lt 'notebook'", true}, // case-sensitive like the ABAP comparison
+		{"Price gt 900", true},
+		{"Price eq 956", true},
+		{"Quantity mul 2 eq 6", true},
+		{"Quantity mod 2 eq 1", true},
+		{"-Quantity lt 0", true},
+		{"Active and not (Quantity gt 5)", true},
+		{"Active eq false or Category eq 'Notebooks'", true},
+		{"Deleted eq null", true},
+		{"Deleted gt 1", false},
+		{"Missing eq null", true},
+		{"ToSupplier/Name eq 'SAP'", true},
+		{"substringof('Basic', Name)", true},
+		{"startswith(Name, 'Note') and endswith(Name, '15')", true},
+		{"indexof(Name, 'Basic') eq 9", true},
+		{"length(Category) eq 9", true},
+		{"tolower(Name) eq 'notebook basic 15'", true},
+		{"toupper(Category) eq 'NOTEBOOKS'", true},
+		{"substring(Name, 9, 5) eq 'Basic'", true},
+		{"substring(Name, 100) eq ''", true},
+		{"replace(Category, 's', '') eq 'Notebook'", true},
+		{"concat(Category, '!') eq 'Notebooks!'", true},
+		{"Created ge datetime'2024-01-02T00:00'", true},
+		{"Created lt datetime'2024-01-02'", false},
+		{"year(Created) eq 2024 and month(Created) eq 1 and day(Created) eq 2", true},
+		{"hour(Created) eq 3 and minute(Created) eq 4", true},
+		{"round(2.5) eq 3 and floor(2.5) eq 2 and ceiling(2.1) eq 3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := Match(n, entity)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	entity := map[string]interface{}{"Name": "A", "Quantity": float64(3)}
+	tests := []string{
+		"Name",
+		"Quantity and true",
+		"not Name",
+		"Name add 1 eq 2",
+		"Quantity div 0 eq 1",
+		"Quantity mod 0 eq 1",
+		"length(Quantity) eq 1",
+		"year(Name) eq 2024",
+		"isof('Edm.String')",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			n, err := Parse(expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if _, err := Match(n, entity); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrderTerm is one property of an $orderby clause
+type OrderTerm struct {
+	Property string
+	Desc     bool
+	// Numeric compares string values as numbers, for Edm.Decimal and Edm.Int64 properties
+	// whose JSON values are strings
+	Numeric bool
+}
+
+// ParseOrderBy parses an $orderby clause such as "Price desc,Name"
+func ParseOrderBy(clause string) ([]OrderTerm, error) {
+	var terms []OrderTerm
+	for _, part := range strings.Split(clause, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid $orderby clause %q", part)
+		}
+		t := OrderTerm{Property: fields[0]}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				t.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid $orderby direction %q", fields[1])
+			}
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// Sort orders entities by terms, keeping the order of equal entities. Nulls sort first.
+func Sort(entities []map[string]interface{}, terms []OrderTerm) {
+	sort.SliceStable(entities, func(i, j int) bool {
+		return CompareEntities(entities[i], entities[j], terms) < 0
+	})
+}
+
+// Sorted reports whether entities are already ordered by terms
+func Sorted(entities []map[string]interface{}, terms []OrderTerm) bool {
+	for i := 1; i < len(entities); i++ {
+		if CompareEntities(entities[i-1], entities[i], terms) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareEntities compares two entities by terms like Sort, returning -1, 0 or 1
+func CompareEntities(x, y map[string]interface{}, terms []OrderTerm) int {
+	for _, t := range terms {
+		a, b := lookup(x, t.Property), lookup(y, t.Property)
+		if t.Numeric {
+			if f, ok := toNumber(a); ok {
+				a = f
+			}
+			if f, ok := toNumber(b); ok {
+				b = f
+			}
+		}
+		c, ok := Compare(a, b)
+		if !ok {
+			switch {
+			case a == nil && b != nil:
+				c = -1
+			case a != nil && b == nil:
+				c = 1
+			}
+		}
+		if c == 0 {
+			continue
+		}
+		if t.Desc {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+// Properties returns the property paths n references, each once, in order of appearance
+func Properties(n Node) []string {
+	var props []string
+	seen := make(map[string]bool)
+	var walk func(Node)
+	walk = func(n Node) {
+		switch n := n.(type) {
+		case *Property:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				props = append(props, n.Name)
+			}
+		case *Binary:
+			walk(n.Left)
+			walk(n.Right)
+		case *Unary:
+			walk(n.Operand)
+		case *Call:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		}
+	}
+	walk(n)
+	return props
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		clause  string
+		want    []OrderTerm
+		wantErr bool
+	}{
+		{clause: "Name", want: []OrderTerm{{Property: "Name"}}},
+		{clause: "Price desc,Name", want: []OrderTerm{{Property: "Price", Desc: true}, {Property: "Name"}}},
+		{clause: " Price  DESC , ToSupplier/Name asc", want: []OrderTerm{{Property: "Price", Desc: true}, {Property: "ToSupplier/Name"}}},
+		{clause: "", wantErr: true},
+		{clause: "Name,", wantErr: true},
+		{clause: "Name down", wantErr: true},
+		{clause: "Name asc desc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.clause, func(t *testing.T) {
+			got, err := ParseOrderBy(tt.clause)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []OrderTerm
+		want  []string
+	}{
+		{"by name", []OrderTerm{{Property: "Name"}}, []string{"1", "2", "3", "4"}},
+		{"by name desc", []OrderTerm{{Property: "Name", Desc: true}}, []string{"4", "3", "2", "1"}},
+		{"nulls first", []OrderTerm{{Property: "Group"}}, []string{"3", "1", "4", "2"}},
+		{"numeric strings", []OrderTerm{{Property: "Price", Numeric: true}}, []string{"2", "3", "1", "4"}},
+		{"lexical strings", []OrderTerm{{Property: "Price"}}, []string{"1", "4", "3", "2"}},
+		{"stable ties", []OrderTerm{{Property: "Group", Desc: true}, {Property: "Name"}}, []string{"2", "1", "4", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entities := []map[string]interface{}{
+				{"ID": "3", "Name": "c", "Group": nil, "Price": "20"},
+				{"ID": "1", "Name": "a", "Group": "x", "Price": "100"},
+				{"ID": "4", "Name": "d", "Group": "x", "Price": "1000"},
+				{"ID": "2", "Name": "b", "Group": "y", "Price": "9.5"},
+			}
+			Sort(entities, tt.terms)
+			var got []string
+			for _, e := range entities {
+				got = append(got, e["ID"].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !Sorted(entities, tt.terms) {
+				t.Error("Sorted reports the sorted entities as unsorted")
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+// Package filter parses and evaluates OData V2 $filter expressions.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyntaxError reports an invalid expression together with the byte offset of the problem
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// Node is an element of a parsed filter expression
+type Node interface {
+	Pos() int
+}
+
+// Literal is a constant such as 'text', 12.5M, true or datetime'2020-01-01T00:00'
+type Literal struct {
+	Offset int
+	// Value is a string, int64, float64, bool, time.Time or nil.
+	// Guid literals are kept as strings.
+	Value interface{}
+}
+
+// Property references an entity property (or a navigation path like ToSupplier/Name)
+type Property struct {
+	Offset int
+	Name   string
+}
+
+// Binary is a logical, comparison or arithmetic operation
+type Binary struct {
+	Offset      int
+	Op          string
+	Left, Right Node
+}
+
+// Unary is "not" or arithmetic negation ("-")
+type Unary struct {
+	Offset  int
+	Op      string
+	Operand Node
+}
+
+// Call is a canonical function call like substringof('a', Name)
+type Call struct {
+	Offset int
+	Name   string
+	Args   []Node
+}
+
+func (n *Literal) Pos() int  { return n.Offset }
+func (n *Property) Pos() int { return n.Offset }
+func (n *Binary) Pos() int   { return n.Offset }
+func (n *Unary) Pos() int    { return n.Offset }
+func (n *Call) Pos() int     { return n.Offset }
+
+// Functions lists the canonical V2 functions with their minimum and maximum argument count
+var Functions = map[string][2]int{
+	"substringof": {2, 2},
+	"startswith":  {2, 2},
+	"endswith":    {2, 2},
+	"length":      {1, 1},
+	"indexof":     {2, 2},
+	"replace":     {3, 3},
+	"substring":   {2, 3},
+	"tolower":     {1, 1},
+	"toupper":     {1, 1},
+	"trim":        {1, 1},
+	"concat":      {2, 2},
+	"day":         {1, 1},
+	"hour":        {1, 1},
+	"minute":      {1, 1},
+	"month":       {1, 1},
+	"second":      {1, 1},
+	"year":        {1, 1},
+	"round":       {1, 1},
+	"floor":       {1, 1},
+	"ceiling":     {1, 1},
+	"isof":        {1, 2},
+}
+
+var (
+	logicalOps    = map[string]bool{"and": true, "or": true}
+	comparisonOps = map[string]bool{"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true}
+	additiveOps   = map[string]bool{"add": true, "sub": true}
+	multOps       = map[string]bool{"mul": true, "div": true, "mod": true}
+)
+
+// Parse parses a $filter expression
+func Parse(expr string) (Node, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, src: expr}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokIdent {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown operator %q", t.text)}
+	} else if t.kind != tokEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return n, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokLiteral
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value interface{} // for literals
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			toks = append(toks, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '\'':
+			str, end, err := readQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokLiteral, text: s[i:end], pos: i, value: str})
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			if c == '-' && (i+1 >= len(s) || s[i+1] < '0' || s[i+1] > '9') {
+				// Negation of a non-numeric operand
+				toks = append(toks, token{kind: tokIdent, text: "-", pos: i})
+				i++
+				continue
+			}
+			tok, end, err := readNumber(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = end
+		case isIdentStart(c):
+			start := i
+			for i < len(s) && isIdentPart(s[i]) {
+				i++
+			}
+			word := s[start:i]
+			// Typed literals: datetime'...', guid'...', time'...', datetimeoffset'...', X'...'
+			if i < len(s) && s[i] == '\'' {
+				raw, end, err := readQuoted(s, i)
+				if err != nil {
+					return nil, err
+				}
+				v, err := typedLiteral(word, raw, start)
+				if err != nil {
+					return nil, err
+				}
+				toks = append(toks, token{kind: tokLiteral, text: s[start:end], pos: start, value: v})
+				i = end
+				continue
+			}
+			switch word {
+			case "true", "false":
+				toks = append(toks, token{kind: tokLiteral, text: word, pos: start, value: word == "true"})
+			case "null":
+				toks = append(toks, token{kind: tokLiteral, text: word, pos: start, value: nil})
+			default:
+				toks = append(toks, token{kind: tokIdent, text: word, pos: start})
+			}
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(s)})
+	return toks, nil
+}
+
+// readQuoted reads a single quoted string starting at s[i]; a doubled quote escapes a quote
+func readQuoted(s string, i int) (string, int, error) {
+	var b strings.Builder
+	j := i + 1
+	for j < len(s) {
+		if s[j] == '\'' {
+			if j+1 < len(s) && s[j+1] == '\'' {
+				b.WriteByte('\'')
+				j += 2
+				continue
+			}
+			return b.String(), j + 1, nil
+		}
+		b.WriteByte(s[j])
+		j++
+	}
+	return "", 0, &SyntaxError{Pos: i, Msg: "unterminated string literal"}
+}
+
+func readNumber(s string, i int) (token, int, error) {
+	start := i
+	if s[i] == '-' {
+		i++
+	}
+	isFloat := false
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.' || s[i] == 'e' || s[i] == 'E' ||
+		((s[i] == '+' || s[i] == '-') && (s[i-1] == 'e' || s[i-1] == 'E'))) {
+		if s[i] == '.' || s[i] == 'e' || s[i] == 'E' {
+			isFloat = true
+		}
+		i++
+	}
+	text := s[start:i]
+
+	// Type suffixes: M (decimal), L (int64), d/f (double/single)
+	if i < len(s) {
+		switch s[i] {
+		case 'M', 'm', 'd', 'D', 'f', 'F':
+			isFloat = true
+			i++
+		case 'L', 'l':
+			i++
+		}
+	}
+	if i < len(s) && isIdentPart(s[i]) {
+		return token{}, 0, &SyntaxError{Pos: start, Msg: fmt.Sprintf("invalid number %q", s[start:i+1])}
+	}
+
+	var v interface{}
+	var err error
+	if isFloat {
+		v, err = strconv.ParseFloat(text, 64)
+	} else {
+		v, err = strconv.ParseInt(text, 10, 64)
+	}
+	if err != nil {
+		return token{}, 0, &SyntaxError{Pos: start, Msg: fmt.Sprintf("invalid number %q", text)}
+	}
+	return token{kind: tokLiteral, text: s[start:i], pos: start, value: v}, i, nil
+}
+
+// DateTimeLayouts are the accepted forms of datetime'...' literals
+var DateTimeLayouts = []string{
+	"2006-01-02T15:04:05.9999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func typedLiteral(prefix, raw string, pos int) (interface{}, error) {
+	switch strings.ToLower(prefix) {
+	case "datetime":
+		for _, layout := range DateTimeLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid datetime literal %q, expected yyyy-mm-ddThh:mm[:ss[.fffffff]]", raw)}
+	case "datetimeoffset":
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid datetimeoffset literal %q", raw)}
+		}
+		return t, nil
+	case "guid":
+		if len(raw) != 36 || strings.Count(raw, "-") != 4 {
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid guid literal %q", raw)}
+		}
+		return strings.ToLower(raw), nil
+	case "time":
+		if !strings.HasPrefix(raw, "PT") {
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid time literal %q, expected PTnnHnnMnnS", raw)}
+		}
+		return raw, nil
+	case "x", "binary":
+		return raw, nil
+	default:
+		return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unknown literal type %q", prefix)}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '/' || c == '.'
+}
+
+type parser struct {
+	toks []token
+	i    int
+	src  string
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// peekOp returns the operator keyword at the current position, if it is one of ops
+func (p *parser) peekOp(ops map[string]bool) (token, bool) {
+	t := p.peek()
+	return t, t.kind == tokIdent && ops[t.text]
+}
+
+func (p *parser) parseOr() (Node, error) {
+	return p.parseBinary(map[string]bool{"or": true}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	return p.parseBinary(map[string]bool{"and": true}, p.parseComparison)
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peekOp(comparisonOps); ok {
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Offset: t.pos, Op: t.text, Left: left, Right: right}
+		if t2, ok := p.peekOp(comparisonOps); ok {
+			return nil, &SyntaxError{Pos: t2.pos, Msg: fmt.Sprintf("comparison operators cannot be chained, combine with and/or before %q", t2.text)}
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	return p.parseBinary(additiveOps, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	return p.parseBinary(multOps, p.parseUnary)
+}
+
+func (p *parser) parseBinary(ops map[string]bool, operand func() (Node, error)) (Node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peekOp(ops)
+		if !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Offset: t.pos, Op: t.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.peek()
+	if t.kind == tokIdent && (t.text == "not" || t.text == "-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Offset: t.pos, Op: t.text, Operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLiteral:
+		return &Literal{Offset: t.pos, Value: t.value}, nil
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind == tokIdent {
+			return nil, &SyntaxError{Pos: c.pos, Msg: fmt.Sprintf("unknown operator %q", c.text)}
+		} else if c.kind != tokRParen {
+			return nil, &SyntaxError{Pos: c.pos, Msg: fmt.Sprintf("missing closing parenthesis for the one opened at position %d", t.pos)}
+		}
+		return n, nil
+	case tokIdent:
+		if logicalOps[t.text] || comparisonOps[t.text] || additiveOps[t.text] || multOps[t.text] {
+			return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("operator %q is missing its left operand", t.text)}
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		return &Property{Offset: t.pos, Name: t.text}, nil
+	case tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	default:
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+}
+
+func (p *parser) parseCall(name token) (Node, error) {
+	arity, known := Functions[name.text]
+	if !known {
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	open := p.next() // (
+
+	call := &Call{Offset: name.pos, Name: name.text}
+	if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if c := p.next(); c.kind != tokRParen {
+		return nil, &SyntaxError{Pos: c.pos, Msg: fmt.Sprintf("missing closing parenthesis for %s( at position %d", name.text, open.pos)}
+	}
+	if len(call.Args) < arity[0] || len(call.Args) > arity[1] {
+		want := strconv.Itoa(arity[0])
+		if arity[1] != arity[0] {
+			want += "-" + strconv.Itoa(arity[1])
+		}
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("%s expects %s arguments, got %d", name.text, want, len(call.Args))}
+	}
+	return call, nil
+}
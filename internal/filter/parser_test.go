@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // the tree in prefix form
+	}{
+		{"Name eq 'A'", "(eq Name 'A')"},
+		{"Price gt 10 and Price le 20.5M", "(and (gt Price 10) (le Price 20.5))"},
+		{"A eq 1 or B eq 2 and C eq 3", "(or (eq A 1) (and (eq B 2) (eq C 3)))"},
+		{"(A eq 1 or B eq 2) and C eq 3", "(and (or (eq A 1) (eq B 2)) (eq C 3))"},
+		{"not substringof('x', Name)", "(not (substringof 'x' Name))"},
+		{"Price add 1 mul 2 eq 5", "(eq (add Price (mul 1 2)) 5)"},
+		{"- Price lt -3", "(lt (- Price) -3)"},
+		{"ToSupplier/Name eq 'it''s'", "(eq ToSupplier/Name 'it's')"},
+		{"Deleted eq null or Active eq true", "(or (eq Deleted <nil>) (eq Active true))"},
+		{"Id eq guid'0A1B2C3D-0000-0000-0000-000000000000'", "(eq Id '0a1b2c3d-0000-0000-0000-000000000000')"},
+		{"Created ge datetime'2024-01-02T03:04'", "(ge Created 2024-01-02T03:04:00Z)"},
+		{"Count eq 12L", "(eq Count 12)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := dump(n); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{"Name eq 'A", 8},
+		{"Name eq 'A' an Price gt 1", 12},
+		{"Name equals 'A'", 5},
+		{"A eq 1 eq 2", 7},
+		{"(A eq 1", 7},
+		{"A eq 12x", 5},
+		{"A eq datetime'yesterday'", 5},
+		{"A eq foo'1'", 5},
+		{"A eq #", 5},
+		{"unknown(Name)", 0},
+		{"startswith(Name)", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("got %v, want a *SyntaxError", err)
+			}
+			if se.Pos != tt.pos {
+				t.Errorf("got position %d (%s), want %d", se.Pos, se.Msg, tt.pos)
+			}
+		})
+	}
+}
+
+// dump renders n in prefix form for comparisons
+func dump(n Node) string {
+	switch n := n.(type) {
+	case *Literal:
+		switch v := n.Value.(type) {
+		case string:
+			return "'" + v + "'"
+		case time.Time:
+			return v.Format(time.RFC3339)
+		case nil:
+			return "<nil>"
+		default:
+			return fmt.Sprint(v)
+		}
+	case *Property:
+		return n.Name
+	case *Unary:
+		return "(" + n.Op + " " + dump(n.Operand) + ")"
+	case *Binary:
+		return "(" + n.Op + " " + dump(n.Left) + " " + dump(n.Right) + ")"
+	case *Call:
+		s := "(" + n.Name
+		for _, a := range n.Args {
+			s += " " + dump(a)
+		}
+		return s + ")"
+	}
+	return "?"
+}
@@ -0,0 +1,354 @@
+// Package loadtest generates load against an OData service through the SDK client, to size
+// gateways and to check rate limiting and retry behaviour before go-live.
+//
+//	report, err := loadtest.Run(ctx, sapClient, "/sap/opu/odata/sap/ZSALES_SRV", loadtest.Config{
+//		Concurrency: 20,
+//		Duration:    time.Minute,
+//		Templates: []loadtest.Template{
+//			{Name: "list", Path: "SalesOrders", Query: map[string]string{"$top": "50"}},
+//			{Name: "by key", Path: "SalesOrders('{{pick \"orders\"}}')", Weight: 3},
+//		},
+//		Values: map[string][]string{"orders": {"5000001", "5000002"}},
+//	})
+//	fmt.Println(report)
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+// Template describes one kind of request. Path, query values and Body are text/templates
+// evaluated per request with the fields of TemplateData and the functions pick and rand.
+type Template struct {
+	Name string
+	// Method defaults to GET
+	Method string
+	// Path is relative to the service path, e.g. "Products('{{.Seq}}')"
+	Path  string
+	Query map[string]string
+	// Body is a JSON payload template for modifying requests
+	Body string
+	// Weight sets the relative frequency of the template (default 1)
+	Weight int
+}
+
+// TemplateData is available inside templates
+type TemplateData struct {
+	// Seq is a run wide sequence number, unique per request
+	Seq int64
+	// Worker is the index of the goroutine sending the request
+	Worker int
+}
+
+// Config controls a load test run
+type Config struct {
+	// Concurrency is the number of workers sending requests back to back (default 1)
+	Concurrency int
+	// Duration bounds the run; zero runs until Requests are sent or ctx is done
+	Duration time.Duration
+	// Requests bounds the total number of requests; zero means unlimited
+	Requests int64
+	// Rate caps the overall requests per second; zero means as fast as the workers go
+	Rate float64
+	// Templates are picked at random according to their weight
+	Templates []Template
+	// Values are pools for the pick template function: {{pick "customers"}}
+	Values map[string][]string
+}
+
+// Stats summarizes the requests of one template
+type Stats struct {
+	Name        string
+	Requests    int64
+	Errors      int64
+	StatusCodes map[int]int64
+	Min         time.Duration
+	Mean        time.Duration
+	P50         time.Duration
+	P90         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+
+	latencies []time.Duration
+}
+
+// Report is the outcome of a run
+type Report struct {
+	Duration   time.Duration
+	Requests   int64
+	Errors     int64
+	Throughput float64 // requests per second
+	Templates  []*Stats
+}
+
+type compiledTemplate struct {
+	Template
+	path  *template.Template
+	query map[string]*template.Template
+	body  *template.Template
+}
+
+// Run executes the load test against the service at servicePath. Requests go through
+// c, so its authentication, CSRF handling, rate limiting and retries are exercised as in production.
+func Run(ctx context.Context, c *client.SAPClient, servicePath string, cfg Config) (*Report, error) {
+	if len(cfg.Templates) == 0 {
+		return nil, fmt.Errorf("load test needs at least one template")
+	}
+	if cfg.Duration <= 0 && cfg.Requests <= 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, fmt.Errorf("load test needs a duration, a request count or a context deadline")
+		}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if !strings.HasSuffix(servicePath, "/") {
+		servicePath += "/"
+	}
+
+	funcs := template.FuncMap{
+		"pick": func(pool string) (string, error) {
+			values := cfg.Values[pool]
+			if len(values) == 0 {
+				return "", fmt.Errorf("value pool %q is empty", pool)
+			}
+			return values[rand.IntN(len(values))], nil
+		},
+		"rand": func(lo, hi int) int { return lo + rand.IntN(hi-lo+1) },
+	}
+
+	templates := make([]*compiledTemplate, len(cfg.Templates))
+	totalWeight := 0
+	for i, t := range cfg.Templates {
+		ct, err := compile(t, funcs)
+		if err != nil {
+			return nil, err
+		}
+		templates[i] = ct
+		totalWeight += ct.Weight
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var ticks <-chan time.Time
+	if cfg.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	stats := make([]*Stats, len(templates))
+	for i, t := range templates {
+		stats[i] = &Stats{Name: t.Name, StatusCodes: make(map[int]int64)}
+	}
+	var (
+		mu  sync.Mutex
+		seq atomic.Int64
+		wg  sync.WaitGroup
+	)
+
+	start := time.Now()
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				n := seq.Add(1)
+				if cfg.Requests > 0 && n > cfg.Requests {
+					return
+				}
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				idx := pickTemplate(templates, totalWeight)
+				status, latency, err := send(ctx, c, servicePath, templates[idx], TemplateData{Seq: n, Worker: worker})
+				if err != nil && ctx.Err() != nil {
+					return // cancelled at the end of the run, not a failure
+				}
+
+				mu.Lock()
+				s := stats[idx]
+				s.Requests++
+				s.latencies = append(s.latencies, latency)
+				if status != 0 {
+					s.StatusCodes[status]++
+				}
+				if err != nil || status >= 400 {
+					s.Errors++
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	report := &Report{Duration: time.Since(start), Templates: stats}
+	for _, s := range stats {
+		s.summarize()
+		report.Requests += s.Requests
+		report.Errors += s.Errors
+	}
+	if secs := report.Duration.Seconds(); secs > 0 {
+		report.Throughput = float64(report.Requests) / secs
+	}
+	return report, nil
+}
+
+func compile(t Template, funcs template.FuncMap) (*compiledTemplate, error) {
+	if t.Name == "" {
+		t.Name = t.Path
+	}
+	if t.Method == "" {
+		t.Method = http.MethodGet
+	}
+	if t.Weight <= 0 {
+		t.Weight = 1
+	}
+	parse := func(what, text string) (*template.Template, error) {
+		tmpl, err := template.New(t.Name + " " + what).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s of template %q: %w", what, t.Name, err)
+		}
+		return tmpl, nil
+	}
+
+	ct := &compiledTemplate{Template: t, query: make(map[string]*template.Template, len(t.Query))}
+	var err error
+	if ct.path, err = parse("path", t.Path); err != nil {
+		return nil, err
+	}
+	for k, v := range t.Query {
+		if ct.query[k], err = parse(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if t.Body != "" {
+		if ct.body, err = parse("body", t.Body); err != nil {
+			return nil, err
+		}
+	}
+	return ct, nil
+}
+
+func pickTemplate(templates []*compiledTemplate, totalWeight int) int {
+	n := rand.IntN(totalWeight)
+	for i, t := range templates {
+		if n < t.Weight {
+			return i
+		}
+		n -= t.Weight
+	}
+	return len(templates) - 1
+}
+
+func send(ctx context.Context, c *client.SAPClient, servicePath string, t *compiledTemplate, data TemplateData) (int, time.Duration, error) {
+	render := func(tmpl *template.Template) (string, error) {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	req := &client.Request{Method: t.Method, QueryParams: make(map[string]string, len(t.query))}
+	path, err := render(t.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.URL = servicePath + strings.TrimPrefix(path, "/")
+	for k, tmpl := range t.query {
+		if req.QueryParams[k], err = render(tmpl); err != nil {
+			return 0, 0, err
+		}
+	}
+	if t.body != nil {
+		body, err := render(t.body)
+		if err != nil {
+			return 0, 0, err
+		}
+		req.Body = []byte(body)
+	}
+
+	start := time.Now()
+	resp, err := c.Do(ctx, req)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, latency, err
+	}
+	return resp.StatusCode(), latency, nil
+}
+
+func (s *Stats) summarize() {
+	if len(s.latencies) == 0 {
+		return
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	var total time.Duration
+	for _, l := range s.latencies {
+		total += l
+	}
+	s.Min = s.latencies[0]
+	s.Max = s.latencies[len(s.latencies)-1]
+	s.Mean = total / time.Duration(len(s.latencies))
+	s.P50 = percentile(s.latencies, 0.50)
+	s.P90 = percentile(s.latencies, 0.90)
+	s.P99 = percentile(s.latencies, 0.99)
+	s.latencies = nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// String renders the report as a table
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests in %s (%.1f req/s), %d errors\n", r.Requests, r.Duration.Round(time.Millisecond), r.Throughput, r.Errors)
+	fmt.Fprintf(&b, "%-24s %8s %7s %9s %9s %9s %9s %9s  %s\n", "TEMPLATE", "REQS", "ERRORS", "MEAN", "P50", "P90", "P99", "MAX", "STATUS")
+	for _, s := range r.Templates {
+		codes := make([]int, 0, len(s.StatusCodes))
+		for code := range s.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		parts := make([]string, len(codes))
+		for i, code := range codes {
+			parts[i] = fmt.Sprintf("%d:%d", code, s.StatusCodes[code])
+		}
+		fmt.Fprintf(&b, "%-24s %8d %7d %9s %9s %9s %9s %9s  %s\n", s.Name, s.Requests, s.Errors,
+			s.Mean.Round(time.Microsecond), s.P50.Round(time.Microsecond), s.P90.Round(time.Microsecond),
+			s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond), strings.Join(parts, " "))
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path+"?"+r.URL.RawQuery]++
+		mu.Unlock()
+		if strings.Contains(r.URL.Path, "'broken'") {
+			http.Error(w, `{"error":{"message":{"value":"boom"}}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"d":{"results":[]}}`))
+	}))
+	defer srv.Close()
+
+	report, err := Run(context.Background(), client.NewSAPClient(srv.URL, "", ""), "/svc", Config{
+		Concurrency: 4,
+		Requests:    40,
+		Templates: []Template{
+			{Name: "list", Path: "Orders", Query: map[string]string{"$top": "{{rand 1 3}}"}},
+			{Name: "by key", Path: "Orders('{{pick \"orders\"}}')", Weight: 3},
+		},
+		Values: map[string][]string{"orders": {"1", "broken"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 40 || len(report.Templates) != 2 {
+		t.Fatalf("got %d requests over %d templates", report.Requests, len(report.Templates))
+	}
+	list, byKey := report.Templates[0], report.Templates[1]
+	if list.Requests+byKey.Requests != 40 || byKey.Requests < list.Requests {
+		t.Errorf("weights not applied: list %d, by key %d", list.Requests, byKey.Requests)
+	}
+	if list.Errors != 0 || byKey.Errors != byKey.StatusCodes[http.StatusBadRequest] {
+		t.Errorf("errors: list %d, by key %d with status codes %v", list.Errors, byKey.Errors, byKey.StatusCodes)
+	}
+	if report.Errors != list.Errors+byKey.Errors {
+		t.Errorf("report counts %d errors, templates %d", report.Errors, list.Errors+byKey.Errors)
+	}
+	if byKey.Min > byKey.P50 || byKey.P50 > byKey.P99 || byKey.P99 > byKey.Max {
+		t.Errorf("percentiles out of order: %+v", byKey)
+	}
+	for p := range paths {
+		switch p {
+		case "/svc/Orders?%24top=1", "/svc/Orders?%24top=2", "/svc/Orders?%24top=3",
+			"/svc/Orders('1')?", "/svc/Orders('broken')?":
+		default:
+			t.Errorf("unexpected request %s", p)
+		}
+	}
+	if !strings.Contains(report.String(), "by key") {
+		t.Errorf("report does not name the templates:\n%s", report)
+	}
+}
+
+func TestRunConfigErrors(t *testing.T) {
+	c := client.NewSAPClient("http://localhost:1", "", "")
+	tests := map[string]Config{
+		"no templates": {Requests: 1},
+		"unbounded":    {Templates: []Template{{Path: "Orders"}}},
+		"bad template": {Requests: 1, Templates: []Template{{Path: "Orders({{.Missing"}}},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Run(context.Background(), c, "/svc", cfg); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestRunStopsAtDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"d":{}}`))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	report, err := Run(context.Background(), client.NewSAPClient(srv.URL, "", ""), "/svc", Config{
+		Duration:  100 * time.Millisecond,
+		Rate:      50,
+		Templates: []Template{{Path: "Orders"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("run took %v", elapsed)
+	}
+	// 50 requests per second for 100ms
+	if report.Requests == 0 || report.Requests > 10 {
+		t.Errorf("sent %d requests", report.Requests)
+	}
+}
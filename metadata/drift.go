@@ -0,0 +1,251 @@
+package metadata
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind classifies a schema difference
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Retyped ChangeKind = "retyped"
+)
+
+// Change is a single difference between two schemas. Property is empty when
+// the whole entity type was added or removed.
+type Change struct {
+	EntityType string
+	Property   string
+	Kind       ChangeKind
+	OldType    string
+	NewType    string
+}
+
+func (c Change) String() string {
+	target := c.EntityType
+	if c.Property != "" {
+		target += "." + c.Property
+	}
+	if c.Kind == Retyped {
+		return fmt.Sprintf("%s %s: %s -> %s", c.Kind, target, c.OldType, c.NewType)
+	}
+	return fmt.Sprintf("%s %s", c.Kind, target)
+}
+
+// DriftReport lists the differences found by Diff or CompareStruct
+type DriftReport struct {
+	Changes []Change
+}
+
+// HasDrift reports whether any difference was found
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Changes) > 0
+}
+
+func (r *DriftReport) String() string {
+	lines := make([]string, len(r.Changes))
+	for i, c := range r.Changes {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *DriftReport) add(c Change) {
+	r.Changes = append(r.Changes, c)
+}
+
+func (r *DriftReport) sort() {
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		a, b := r.Changes[i], r.Changes[j]
+		if a.EntityType != b.EntityType {
+			return a.EntityType < b.EntityType
+		}
+		return a.Property < b.Property
+	})
+}
+
+// Diff compares a previously saved metadata document with the current one and
+// reports added, removed and retyped entity types and properties
+func Diff(old, current *Document) *DriftReport {
+	report := &DriftReport{}
+	oldTypes, newTypes := old.EntityTypes(), current.EntityTypes()
+
+	for name, ot := range oldTypes {
+		nt, ok := newTypes[name]
+		if !ok {
+			report.add(Change{EntityType: name, Kind: Removed})
+			continue
+		}
+		for _, op := range ot.Properties {
+			np, ok := nt.Property(op.Name)
+			switch {
+			case !ok:
+				report.add(Change{EntityType: name, Property: op.Name, Kind: Removed, OldType: op.Type})
+			case np.Type != op.Type:
+				report.add(Change{EntityType: name, Property: op.Name, Kind: Retyped, OldType: op.Type, NewType: np.Type})
+			}
+		}
+		for _, np := range nt.Properties {
+			if _, ok := ot.Property(np.Name); !ok {
+				report.add(Change{EntityType: name, Property: np.Name, Kind: Added, NewType: np.Type})
+			}
+		}
+	}
+	for name := range newTypes {
+		if _, ok := oldTypes[name]; !ok {
+			report.add(Change{EntityType: name, Kind: Added})
+		}
+	}
+
+	report.sort()
+	return report
+}
+
+// CompareStruct compares a Go entity struct (mapped through its odata or json tags) with
+// an entity type. Properties the backend has but the struct lacks are reported as Added,
+// struct fields the backend no longer knows as Removed, and fields whose Go type
+// cannot hold the EDM type as Retyped. Fields matching navigation properties are ignored.
+func CompareStruct(et *EntityType, v interface{}) *DriftReport {
+	report := &DriftReport{}
+	fields := WireFields(reflect.TypeOf(v))
+
+	for _, p := range et.Properties {
+		f, ok := fields[p.Name]
+		if !ok {
+			report.add(Change{EntityType: et.Name, Property: p.Name, Kind: Added, NewType: p.Type})
+			continue
+		}
+		if !GoTypeCompatible(p.Type, f.Type) {
+			report.add(Change{EntityType: et.Name, Property: p.Name, Kind: Retyped, OldType: f.Type.String(), NewType: p.Type})
+		}
+	}
+	for name, f := range fields {
+		if _, ok := et.Property(name); ok {
+			continue
+		}
+		if _, ok := et.NavigationProperty(name); ok {
+			continue
+		}
+		report.add(Change{EntityType: et.Name, Property: name, Kind: Removed, OldType: f.Type.String()})
+	}
+
+	report.sort()
+	return report
+}
+
+// StructFields returns the exported fields of a struct keyed by their JSON name,
+// flattening embedded structs the way encoding/json does
+func StructFields(t reflect.Type) map[string]reflect.StructField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := make(map[string]reflect.StructField)
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			for k, v := range StructFields(f.Type) {
+				if _, exists := fields[k]; !exists {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.HasPrefix(name, "__") {
+			// __metadata, __deferred and friends are protocol fields, not properties
+			continue
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// WireName returns the property name a struct field with the given JSON name has on the
+// wire: its odata tag, which lets it differ from the name used in JSON, or the JSON name
+func WireName(jsonName string, f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("odata"), ","); name != "" {
+		return name
+	}
+	return jsonName
+}
+
+// WireFields is StructFields keyed by the names the properties have on the wire
+func WireFields(t reflect.Type) map[string]reflect.StructField {
+	fields := StructFields(t)
+	wire := make(map[string]reflect.StructField, len(fields))
+	for name, f := range fields {
+		wire[WireName(name, f)] = f
+	}
+	return wire
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// GoTypeCompatible reports whether a Go type can hold values of the given EDM type
+// as serialized by OData V2 JSON (which sends Int64 and Decimal as strings)
+func GoTypeCompatible(edmType string, t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return true
+	}
+	// Custom types decide for themselves how to decode
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	k := t.Kind()
+	switch edmType {
+	case "Edm.String", "Edm.Guid":
+		return k == reflect.String
+	case "Edm.Boolean":
+		return k == reflect.Bool
+	case "Edm.Byte", "Edm.SByte", "Edm.Int16", "Edm.Int32":
+		return isInt(k)
+	case "Edm.Int64", "Edm.Decimal":
+		return k == reflect.String || isInt(k) || isFloat(k)
+	case "Edm.Double", "Edm.Single":
+		return isFloat(k) || k == reflect.String
+	case "Edm.DateTime", "Edm.DateTimeOffset", "Edm.Time":
+		return k == reflect.String || k == reflect.Struct
+	case "Edm.Binary":
+		return k == reflect.String || (k == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	default:
+		// Complex types map to structs or maps
+		return k == reflect.Struct || k == reflect.Map
+	}
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Uint64
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old := loadSample(t)
+	current := loadSample(t)
+
+	if r := Diff(old, current); r.HasDrift() {
+		t.Fatalf("identical documents drift:\n%s", r)
+	}
+
+	product, _ := current.EntityType("Product")
+	product.Properties = product.Properties[1:] // removes ProductID
+	product.Properties[0].Type = "Edm.Int32"
+	product.Properties = append(product.Properties, Property{Name: "Weight", Type: "Edm.Decimal"})
+	current.Schemas[0].EntityTypes = append(current.Schemas[0].EntityTypes, EntityType{Name: "Contact"})
+	line, _ := current.EntityType("SalesOrderLineItem")
+	line.Name = "SalesOrderItem"
+
+	want := []string{
+		"added GWSAMPLE_BASIC.Contact",
+		"removed GWSAMPLE_BASIC.Product.ProductID",
+		"retyped GWSAMPLE_BASIC.Product.Name: Edm.String -> Edm.Int32",
+		"added GWSAMPLE_BASIC.Product.Weight",
+		"removed GWSAMPLE_BASIC.SalesOrderLineItem",
+		"added GWSAMPLE_BASIC.SalesOrderItem",
+	}
+	r := Diff(old, current)
+	if len(r.Changes) != len(want) {
+		t.Fatalf("got changes:\n%s", r)
+	}
+	for _, w := range want {
+		if !strings.Contains(r.String(), w) {
+			t.Errorf("missing change %q in:\n%s", w, r)
+		}
+	}
+}
+
+func TestCompareStruct(t *testing.T) {
+	_, product, err := loadSample(t).EntitySet("ProductSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Base struct {
+		ProductID string
+	}
+	type upToDate struct {
+		Base
+		Name       string
+		Price      string
+		CreatedAt  time.Time
+		ToSupplier *struct{}
+		Metadata   struct{} `json:"__metadata"`
+		Ignored    string   `json:"-"`
+	}
+	type drifted struct {
+		ProductID int
+		Title     string `json:"Name"`
+		Price     float64
+		Color     string
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want []string
+	}{
+		{"up to date", upToDate{}, nil},
+		{"pointer", &upToDate{}, nil},
+		{"drifted", drifted{}, []string{
+			"removed Product.Color",
+			"added Product.CreatedAt",
+			"retyped Product.ProductID: int -> Edm.String",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := CompareStruct(product, tt.v)
+			if len(r.Changes) != len(tt.want) {
+				t.Fatalf("got changes:\n%s", r)
+			}
+			for i, c := range r.Changes {
+				if c.String() != tt.want[i] {
+					t.Errorf("change %d = %q, want %q", i, c, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGoTypeCompatible(t *testing.T) {
+	var (
+		s   string
+		i   int32
+		f   float64
+		b   bool
+		raw []byte
+		tm  time.Time
+		m   map[string]interface{}
+	)
+	tests := []struct {
+		edm  string
+		v    interface{}
+		want bool
+	}{
+		{"Edm.String", s, true},
+		{"Edm.String", i, false},
+		{"Edm.Guid", s, true},
+		{"Edm.Int32", i, true},
+		{"Edm.Int32", s, false},
+		{"Edm.Int64", s, true},
+		{"Edm.Decimal", f, true},
+		{"Edm.Double", s, true},
+		{"Edm.Boolean", b, true},
+		{"Edm.Boolean", s, false},
+		{"Edm.DateTime", tm, true},
+		{"Edm.DateTime", i, false},
+		{"Edm.Binary", raw, true},
+		{"GWSAMPLE_BASIC.CT_Address", m, true},
+		{"GWSAMPLE_BASIC.CT_Address", s, false},
+	}
+	for _, tt := range tests {
+		if got := GoTypeCompatible(tt.edm, reflect.TypeOf(tt.v)); got != tt.want {
+			t.Errorf("GoTypeCompatible(%s, %T) = %v, want %v", tt.edm, tt.v, got, tt.want)
+		}
+	}
+}
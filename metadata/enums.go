@@ -0,0 +1,86 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FixedDomain is the fixed value domain of a property, as listed by its value help
+// (see Document.ValueList), for GenerateEnums
+type FixedDomain struct {
+	Name     string // Go type name, e.g. "MaterialType"
+	Property string // the property it was read for, e.g. "Product/MaterialType"
+	Values   []FixedValue
+}
+
+// FixedValue is a value of a fixed domain with its description
+type FixedValue struct {
+	Code string
+	Text string
+}
+
+// GenerateEnums writes a Go source file of package pkg declaring a string type per domain
+// and a typed constant per value, so code reads MaterialTypeFERT instead of "FERT".
+// Characters not allowed in identifiers become underscores; the empty value is <Name>Empty.
+func GenerateEnums(w io.Writer, pkg string, domains []FixedDomain) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by odata-cli enums. DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, d := range domains {
+		typeName := identifier(d.Name)
+		fmt.Fprintf(&b, "\n// %s is a fixed value of %s\ntype %s string\n\nconst (\n", typeName, d.Property, typeName)
+		seen := make(map[string]int)
+		for _, v := range d.Values {
+			name := typeName + "Empty"
+			if v.Code != "" {
+				name = typeName + upperFirst(sanitize(v.Code))
+			}
+			if seen[name]++; seen[name] > 1 {
+				name += "_" + strconv.Itoa(seen[name])
+			}
+			fmt.Fprintf(&b, "\t%s %s = %s", name, typeName, strconv.Quote(v.Code))
+			if text := strings.Join(strings.Fields(v.Text), " "); text != "" {
+				fmt.Fprintf(&b, " // %s", text)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(")\n")
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated enums: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// identifier turns s into an exported Go identifier
+func identifier(s string) string {
+	id := upperFirst(sanitize(s))
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		return "X" + id
+	}
+	return id
+}
+
+// sanitize replaces the characters of s not allowed in identifiers with underscores
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns a short hash of the shape of the document: its entity types,
+// complex types, associations, entity sets and function imports with their names and
+// types. Labels and other annotations as well as the order and formatting of the
+// document do not change it, so equal fingerprints mean code built against one
+// document reads and writes the other unchanged.
+func (d *Document) Fingerprint() string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	property := func(owner string, p Property) {
+		add("P %s.%s %s null=%t len=%s prec=%s scale=%s", owner, p.Name, p.Type, p.IsNullable(), p.MaxLength, p.Precision, p.Scale)
+	}
+	for _, s := range d.Schemas {
+		for _, et := range s.EntityTypes {
+			name := s.Namespace + "." + et.Name
+			add("E %s key=%s stream=%t", name, strings.Join(et.KeyNames(), ","), et.HasStream)
+			for _, p := range et.Properties {
+				property(name, p)
+			}
+			for _, n := range et.NavigationProperties {
+				add("N %s.%s %s %s>%s", name, n.Name, n.Relationship, n.FromRole, n.ToRole)
+			}
+		}
+		for _, ct := range s.ComplexTypes {
+			name := s.Namespace + "." + ct.Name
+			add("C %s", name)
+			for _, p := range ct.Properties {
+				property(name, p)
+			}
+		}
+		for _, a := range s.Associations {
+			for _, end := range a.Ends {
+				add("A %s.%s %s %s %s", s.Namespace, a.Name, end.Role, end.Type, end.Multiplicity)
+			}
+		}
+		for _, c := range s.EntityContainers {
+			for _, set := range c.EntitySets {
+				add("S %s %s", set.Name, set.EntityType)
+			}
+			for _, f := range c.FunctionImports {
+				params := make([]string, len(f.Parameters))
+				for i, p := range f.Parameters {
+					params[i] = p.Name + ":" + p.Type
+				}
+				add("F %s %s %s %s (%s)", f.Name, f.HTTPMethod, f.ReturnType, f.EntitySet, strings.Join(params, ","))
+			}
+		}
+	}
+	sort.Strings(lines)
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,311 @@
+// Package metadata parses OData V2 service metadata documents ($metadata, EDMX 1.0)
+// including the SAP annotations (sap:label, sap:filterable, ...).
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SAPNamespace is the XML namespace of the SAP specific annotations
+const SAPNamespace = "http://www.sap.com/Protocols/SAPData"
+
+// Document is the root of a parsed $metadata document
+type Document struct {
+	Version string   `xml:"Version,attr"`
+	Schemas []Schema `xml:"DataServices>Schema"`
+}
+
+type Schema struct {
+	Namespace        string            `xml:"Namespace,attr"`
+	EntityTypes      []EntityType      `xml:"EntityType"`
+	ComplexTypes     []ComplexType     `xml:"ComplexType"`
+	Associations     []Association     `xml:"Association"`
+	EntityContainers []EntityContainer `xml:"EntityContainer"`
+	// VocabularyAnnotations are the <Annotations> elements, see ValueList
+	VocabularyAnnotations []TargetAnnotations `xml:"Annotations"`
+}
+
+type EntityType struct {
+	Name                 string               `xml:"Name,attr"`
+	HasStream            bool                 `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices/metadata HasStream,attr"`
+	Key                  []PropertyRef        `xml:"Key>PropertyRef"`
+	Properties           []Property           `xml:"Property"`
+	NavigationProperties []NavigationProperty `xml:"NavigationProperty"`
+	Annotations
+}
+
+type ComplexType struct {
+	Name       string     `xml:"Name,attr"`
+	Properties []Property `xml:"Property"`
+	Annotations
+}
+
+type PropertyRef struct {
+	Name string `xml:"Name,attr"`
+}
+
+type Property struct {
+	Name      string `xml:"Name,attr"`
+	Type      string `xml:"Type,attr"`
+	Nullable  string `xml:"Nullable,attr"`
+	MaxLength string `xml:"MaxLength,attr"`
+	Precision string `xml:"Precision,attr"`
+	Scale     string `xml:"Scale,attr"`
+	Annotations
+}
+
+type NavigationProperty struct {
+	Name         string `xml:"Name,attr"`
+	Relationship string `xml:"Relationship,attr"`
+	FromRole     string `xml:"FromRole,attr"`
+	ToRole       string `xml:"ToRole,attr"`
+	Annotations
+}
+
+type Association struct {
+	Name string           `xml:"Name,attr"`
+	Ends []AssociationEnd `xml:"End"`
+}
+
+type AssociationEnd struct {
+	Type         string `xml:"Type,attr"`
+	Multiplicity string `xml:"Multiplicity,attr"`
+	Role         string `xml:"Role,attr"`
+}
+
+type EntityContainer struct {
+	Name            string           `xml:"Name,attr"`
+	EntitySets      []EntitySet      `xml:"EntitySet"`
+	FunctionImports []FunctionImport `xml:"FunctionImport"`
+}
+
+type EntitySet struct {
+	Name       string `xml:"Name,attr"`
+	EntityType string `xml:"EntityType,attr"` // Namespace qualified
+	Annotations
+}
+
+type FunctionImport struct {
+	Name       string      `xml:"Name,attr"`
+	ReturnType string      `xml:"ReturnType,attr"`
+	EntitySet  string      `xml:"EntitySet,attr"`
+	HTTPMethod string      `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices/metadata HttpMethod,attr"`
+	Parameters []Parameter `xml:"Parameter"`
+	Annotations
+}
+
+type Parameter struct {
+	Name      string `xml:"Name,attr"`
+	Type      string `xml:"Type,attr"`
+	Mode      string `xml:"Mode,attr"`
+	Nullable  string `xml:"Nullable,attr"`
+	MaxLength string `xml:"MaxLength,attr"`
+}
+
+// Annotations captures all remaining attributes of an element, such as the sap:* annotations
+type Annotations struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+// SAP returns the value of the sap:<name> annotation, or "" if absent
+func (a Annotations) SAP(name string) string {
+	for _, attr := range a.Attrs {
+		if attr.Name.Space == SAPNamespace && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// sapFlag evaluates a boolean SAP annotation that defaults to true when absent
+func (a Annotations) sapFlag(name string) bool {
+	return a.SAP(name) != "false"
+}
+
+// Parse reads a $metadata document
+func Parse(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing metadata: %w", err)
+	}
+	if len(doc.Schemas) == 0 {
+		return nil, fmt.Errorf("parsing metadata: no schema found")
+	}
+	return &doc, nil
+}
+
+// IsNullable reports whether the property accepts null (the EDM default)
+func (p *Property) IsNullable() bool {
+	return p.Nullable != "false"
+}
+
+// Label returns the sap:label annotation
+func (p *Property) Label() string { return p.SAP("label") }
+
+// ValueList returns the sap:value-list annotation: "standard" for a value help, or
+// "fixed-values" when the property only takes the values of a fixed domain
+func (p *Property) ValueList() string { return p.SAP("value-list") }
+
+// Semantics returns the sap:semantics annotation, the vCard or iCalendar field the property
+// holds, e.g. "city", "email" or "tel;type=work,pref"
+func (p *Property) Semantics() string { return p.SAP("semantics") }
+
+// HasFixedValues reports whether the property is restricted to a fixed value domain
+func (p *Property) HasFixedValues() bool { return p.ValueList() == "fixed-values" }
+
+// Filterable reports whether the property may be used in $filter
+func (p *Property) Filterable() bool { return p.sapFlag("filterable") }
+
+// Sortable reports whether the property may be used in $orderby
+func (p *Property) Sortable() bool { return p.sapFlag("sortable") }
+
+// Creatable reports whether the property may be set on create
+func (p *Property) Creatable() bool { return p.sapFlag("creatable") }
+
+// Updatable reports whether the property may be changed on update
+func (p *Property) Updatable() bool { return p.sapFlag("updatable") }
+
+// Semantics returns the sap:semantics annotation of the entity type, e.g. "vcard" for
+// contacts or "vevent" for appointments
+func (t *EntityType) Semantics() string { return t.SAP("semantics") }
+
+// Property returns the property with the given name
+func (t *EntityType) Property(name string) (*Property, bool) {
+	for i := range t.Properties {
+		if t.Properties[i].Name == name {
+			return &t.Properties[i], true
+		}
+	}
+	return nil, false
+}
+
+// NavigationProperty returns the navigation property with the given name
+func (t *EntityType) NavigationProperty(name string) (*NavigationProperty, bool) {
+	for i := range t.NavigationProperties {
+		if t.NavigationProperties[i].Name == name {
+			return &t.NavigationProperties[i], true
+		}
+	}
+	return nil, false
+}
+
+// KeyNames returns the names of the key properties in declaration order
+func (t *EntityType) KeyNames() []string {
+	names := make([]string, len(t.Key))
+	for i, k := range t.Key {
+		names[i] = k.Name
+	}
+	return names
+}
+
+// EntityTypes returns all entity types keyed by their namespace qualified name
+func (d *Document) EntityTypes() map[string]*EntityType {
+	types := make(map[string]*EntityType)
+	for i := range d.Schemas {
+		s := &d.Schemas[i]
+		for j := range s.EntityTypes {
+			types[s.Namespace+"."+s.EntityTypes[j].Name] = &s.EntityTypes[j]
+		}
+	}
+	return types
+}
+
+// EntityType looks up an entity type by qualified ("NS.Product") or simple ("Product") name
+func (d *Document) EntityType(name string) (*EntityType, bool) {
+	for i := range d.Schemas {
+		s := &d.Schemas[i]
+		local := strings.TrimPrefix(name, s.Namespace+".")
+		for j := range s.EntityTypes {
+			if s.EntityTypes[j].Name == local {
+				return &s.EntityTypes[j], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ComplexType looks up a complex type by qualified or simple name
+func (d *Document) ComplexType(name string) (*ComplexType, bool) {
+	for i := range d.Schemas {
+		s := &d.Schemas[i]
+		local := strings.TrimPrefix(name, s.Namespace+".")
+		for j := range s.ComplexTypes {
+			if s.ComplexTypes[j].Name == local {
+				return &s.ComplexTypes[j], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// EntitySets returns the entity sets of all containers
+func (d *Document) EntitySets() []*EntitySet {
+	var sets []*EntitySet
+	for i := range d.Schemas {
+		for j := range d.Schemas[i].EntityContainers {
+			c := &d.Schemas[i].EntityContainers[j]
+			for k := range c.EntitySets {
+				sets = append(sets, &c.EntitySets[k])
+			}
+		}
+	}
+	return sets
+}
+
+// EntitySet looks up an entity set and its entity type by set name
+func (d *Document) EntitySet(name string) (*EntitySet, *EntityType, error) {
+	for _, set := range d.EntitySets() {
+		if set.Name != name {
+			continue
+		}
+		et, ok := d.EntityType(set.EntityType)
+		if !ok {
+			return nil, nil, fmt.Errorf("entity type %s of entity set %s not found", set.EntityType, name)
+		}
+		return set, et, nil
+	}
+	return nil, nil, fmt.Errorf("entity set %s not found in metadata", name)
+}
+
+// NavigationTarget resolves the entity type a navigation property points to and
+// whether it yields a collection (multiplicity "*")
+func (d *Document) NavigationTarget(nav *NavigationProperty) (*EntityType, bool, error) {
+	for i := range d.Schemas {
+		s := &d.Schemas[i]
+		local := strings.TrimPrefix(nav.Relationship, s.Namespace+".")
+		for _, a := range s.Associations {
+			if a.Name != local {
+				continue
+			}
+			for _, end := range a.Ends {
+				if end.Role != nav.ToRole {
+					continue
+				}
+				et, ok := d.EntityType(end.Type)
+				if !ok {
+					return nil, false, fmt.Errorf("entity type %s of navigation %s not found", end.Type, nav.Name)
+				}
+				return et, end.Multiplicity == "*", nil
+			}
+		}
+	}
+	return nil, false, fmt.Errorf("association %s of navigation %s not found", nav.Relationship, nav.Name)
+}
+
+// FunctionImport looks up a function import by name
+func (d *Document) FunctionImport(name string) (*FunctionImport, bool) {
+	for i := range d.Schemas {
+		for j := range d.Schemas[i].EntityContainers {
+			c := &d.Schemas[i].EntityContainers[j]
+			for k := range c.FunctionImports {
+				if c.FunctionImports[k].Name == name {
+					return &c.FunctionImports[k], true
+				}
+			}
+		}
+	}
+	return nil, false
+}
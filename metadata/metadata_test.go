@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadSample(t *testing.T) *Document {
+	t.Helper()
+	f, err := os.Open("testdata/sample.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestParse(t *testing.T) {
+	doc := loadSample(t)
+
+	if len(doc.EntitySets()) != 4 {
+		t.Errorf("got %d entity sets, want 4", len(doc.EntitySets()))
+	}
+	set, et, err := doc.EntitySet("ProductSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.EntityType != "GWSAMPLE_BASIC.Product" || et.Name != "Product" {
+		t.Errorf("ProductSet resolves to %s (%s)", set.EntityType, et.Name)
+	}
+	if set.SAP("content-version") != "1" {
+		t.Errorf("sap:content-version of ProductSet = %q", set.SAP("content-version"))
+	}
+	if _, ok := et.NavigationProperty("ToSupplier"); !ok {
+		t.Error("ToSupplier navigation property not found")
+	}
+
+	line, ok := doc.EntityType("GWSAMPLE_BASIC.SalesOrderLineItem")
+	if !ok {
+		t.Fatal("qualified entity type lookup failed")
+	}
+	if got := line.KeyNames(); !reflect.DeepEqual(got, []string{"SalesOrderID", "ItemPosition"}) {
+		t.Errorf("KeyNames() = %v", got)
+	}
+	if _, ok := doc.ComplexType("CT_Address"); !ok {
+		t.Error("complex type CT_Address not found")
+	}
+
+	fi, ok := doc.FunctionImport("RegenerateAllData")
+	if !ok {
+		t.Fatal("function import not found")
+	}
+	if fi.HTTPMethod != "POST" || len(fi.Parameters) != 1 || fi.Parameters[0].Mode != "In" {
+		t.Errorf("got function import %+v", fi)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"not xml":   "{}",
+		"no schema": `<edmx:Edmx xmlns:edmx="http://schemas.microsoft.com/ado/2007/06/edmx"><edmx:DataServices/></edmx:Edmx>`,
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(src)); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	doc := loadSample(t)
+	for _, set := range []string{"NoSuchSet", "OrphanSet"} {
+		if _, _, err := doc.EntitySet(set); err == nil {
+			t.Errorf("EntitySet(%q) returned no error", set)
+		}
+	}
+}
+
+func TestPropertyAnnotations(t *testing.T) {
+	_, product, err := loadSample(t).EntitySet("ProductSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		property                                             string
+		label                                                string
+		nullable, filterable, sortable, creatable, updatable bool
+	}{
+		{"ProductID", "Product ID", false, true, true, true, false},
+		{"Name", "Name", true, true, false, true, true},
+		{"Price", "", true, false, true, true, true},
+		{"CreatedAt", "", true, true, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.property, func(t *testing.T) {
+			p, ok := product.Property(tt.property)
+			if !ok {
+				t.Fatal("property not found")
+			}
+			got := []bool{p.IsNullable(), p.Filterable(), p.Sortable(), p.Creatable(), p.Updatable()}
+			want := []bool{tt.nullable, tt.filterable, tt.sortable, tt.creatable, tt.updatable}
+			if p.Label() != tt.label || !reflect.DeepEqual(got, want) {
+				t.Errorf("got label %q and nullable, filterable, sortable, creatable, updatable %v, want %q and %v", p.Label(), got, tt.label, want)
+			}
+		})
+	}
+}
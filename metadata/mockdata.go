@@ -0,0 +1,208 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator produces fake entities that conform to the types, lengths and precisions
+// declared in metadata, in OData V2 JSON wire format (Decimal and Int64 as strings,
+// DateTime as "/Date(ms)/"). Output is deterministic for a given seed, so generated
+// fixtures are stable across test runs.
+type Generator struct {
+	rnd *rand.Rand
+	// seq numbers key properties so generated keys are unique per entity type
+	seq map[string]int
+	// Epoch anchors generated dates; defaults to 2020-01-01 UTC
+	Epoch time.Time
+}
+
+// NewGenerator creates a generator with a fixed seed
+func NewGenerator(seed uint64) *Generator {
+	return &Generator{
+		rnd:   rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		seq:   make(map[string]int),
+		Epoch: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// Entities generates n entities of the given type
+func (g *Generator) Entities(et *EntityType, n int) []map[string]interface{} {
+	out := make([]map[string]interface{}, n)
+	for i := range out {
+		out[i] = g.Entity(et)
+	}
+	return out
+}
+
+// Entity generates a single entity. Key properties receive sequential values.
+func (g *Generator) Entity(et *EntityType) map[string]interface{} {
+	keys := make(map[string]bool, len(et.Key))
+	for _, k := range et.Key {
+		keys[k.Name] = true
+	}
+
+	g.seq[et.Name]++
+	n := g.seq[et.Name]
+
+	e := make(map[string]interface{}, len(et.Properties))
+	for i := range et.Properties {
+		p := &et.Properties[i]
+		if keys[p.Name] {
+			e[p.Name] = g.keyValue(p, n)
+			continue
+		}
+		e[p.Name] = g.Value(p)
+	}
+	return e
+}
+
+// Value generates a random value for a single property
+func (g *Generator) Value(p *Property) interface{} {
+	maxLen, _ := strconv.Atoi(p.MaxLength)
+
+	switch p.Type {
+	case "Edm.String":
+		return g.stringValue(p.Name, maxLen)
+	case "Edm.Guid":
+		return g.guid()
+	case "Edm.Boolean":
+		return g.rnd.IntN(2) == 1
+	case "Edm.Byte":
+		return g.rnd.IntN(256)
+	case "Edm.SByte":
+		return g.rnd.IntN(256) - 128
+	case "Edm.Int16":
+		return g.rnd.IntN(1000)
+	case "Edm.Int32":
+		return g.rnd.IntN(100000)
+	case "Edm.Int64":
+		return strconv.Itoa(g.rnd.IntN(1000000))
+	case "Edm.Decimal":
+		return g.decimal(p)
+	case "Edm.Double", "Edm.Single":
+		return math.Round(g.rnd.Float64()*100000) / 100
+	case "Edm.DateTime":
+		return fmt.Sprintf("/Date(%d)/", g.date().UnixMilli())
+	case "Edm.DateTimeOffset":
+		return g.date().Format(time.RFC3339)
+	case "Edm.Time":
+		return fmt.Sprintf("PT%02dH%02dM%02dS", g.rnd.IntN(24), g.rnd.IntN(60), g.rnd.IntN(60))
+	case "Edm.Binary":
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(g.rnd.IntN(256))
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return nil
+	}
+}
+
+// keyValue produces the n-th key value, zero padded like SAP number ranges when the length is known
+func (g *Generator) keyValue(p *Property, n int) interface{} {
+	switch p.Type {
+	case "Edm.String":
+		maxLen, _ := strconv.Atoi(p.MaxLength)
+		if maxLen <= 0 || maxLen > 10 {
+			maxLen = 10
+		}
+		s := strconv.Itoa(n)
+		if len(s) < maxLen {
+			s = strings.Repeat("0", maxLen-len(s)) + s
+		}
+		return s[len(s)-maxLen:]
+	case "Edm.Guid":
+		return g.guid()
+	case "Edm.Int64", "Edm.Decimal":
+		return strconv.Itoa(n)
+	default:
+		return g.Value(p)
+	}
+}
+
+var (
+	sampleWords     = []string{"Alpha", "Bravo", "Nordic", "Summit", "Vertex", "Harbor", "Atlas", "Pioneer", "Cobalt", "Meridian"}
+	sampleCities    = []string{"Walldorf", "Berlin", "Paris", "Chicago", "Singapore", "Sydney", "Toronto", "Madrid"}
+	sampleCountries = []string{"DE", "FR", "US", "SG", "AU", "CA", "ES", "GB"}
+	sampleCurrency  = []string{"EUR", "USD", "GBP", "JPY", "CHF"}
+	sampleUnits     = []string{"EA", "PC", "KG", "L", "M"}
+)
+
+// stringValue picks a plausible value based on common SAP property naming, truncated to maxLen
+func (g *Generator) stringValue(name string, maxLen int) string {
+	lower := strings.ToLower(name)
+	var s string
+	switch {
+	case strings.Contains(lower, "email"):
+		s = strings.ToLower(g.pick(sampleWords)) + "@example.com"
+	case strings.Contains(lower, "phone") || strings.Contains(lower, "fax"):
+		s = fmt.Sprintf("+49 %03d %07d", g.rnd.IntN(1000), g.rnd.IntN(10000000))
+	case strings.Contains(lower, "currency") || strings.HasSuffix(lower, "waers"):
+		s = g.pick(sampleCurrency)
+	case strings.Contains(lower, "country") || strings.HasSuffix(lower, "land1"):
+		s = g.pick(sampleCountries)
+	case strings.Contains(lower, "city") || strings.HasSuffix(lower, "ort01"):
+		s = g.pick(sampleCities)
+	case strings.Contains(lower, "unit") || lower == "uom" || strings.HasSuffix(lower, "meins"):
+		s = g.pick(sampleUnits)
+	case strings.Contains(lower, "name") || strings.Contains(lower, "desc") || strings.Contains(lower, "text"):
+		s = g.pick(sampleWords) + " " + g.pick(sampleWords)
+	default:
+		s = fmt.Sprintf("%s%d", strings.ToUpper(g.pick(sampleWords)[:2]), g.rnd.IntN(100000))
+	}
+	if maxLen > 0 && len(s) > maxLen {
+		s = strings.TrimSpace(s[:maxLen])
+	}
+	return s
+}
+
+// decimal respects Precision and Scale and returns the V2 string representation
+func (g *Generator) decimal(p *Property) string {
+	precision, err := strconv.Atoi(p.Precision)
+	if err != nil || precision <= 0 {
+		precision = 10
+	}
+	scale, err := strconv.Atoi(p.Scale)
+	if err != nil || scale < 0 {
+		scale = 2
+	}
+	intDigits := precision - scale
+	if intDigits > 6 {
+		intDigits = 6 // keep values readable
+	}
+
+	whole := 0
+	if intDigits > 0 {
+		whole = g.rnd.IntN(int(math.Pow10(intDigits)))
+	}
+	if scale == 0 {
+		return strconv.Itoa(whole)
+	}
+	frac := g.rnd.IntN(int(math.Pow10(min(scale, 6))))
+	return fmt.Sprintf("%d.%0*d", whole, scale, frac*int(math.Pow10(scale-min(scale, 6))))
+}
+
+// date returns a day within five years after Epoch at midnight UTC, matching how SAP sends Edm.DateTime dates
+func (g *Generator) date() time.Time {
+	return g.Epoch.AddDate(0, 0, g.rnd.IntN(5*365))
+}
+
+func (g *Generator) guid() string {
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = byte(g.rnd.IntN(256))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rnd.IntN(len(values))]
+}
@@ -0,0 +1,391 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIOptions controls the document produced by OpenAPI
+type OpenAPIOptions struct {
+	Title     string
+	Version   string
+	ServerURL string // Service root, e.g. https://host/sap/opu/odata/sap/ZMY_SRV
+}
+
+// OpenAPIDocument is an OpenAPI 3.0 document; marshal it with encoding/json
+type OpenAPIDocument struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       OpenAPIInfo         `json:"info"`
+	Servers    []OpenAPIServer     `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components OpenAPIComponents   `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+// PathItem maps lower case HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Required    bool        `json:"required,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *JSONSchema `json:"schema"`
+}
+
+// JSONSchema is the subset of the OpenAPI schema object we emit
+type JSONSchema struct {
+	Ref         string                 `json:"$ref,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Pattern     string                 `json:"pattern,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	MaxLength   *int                   `json:"maxLength,omitempty"`
+	Nullable    bool                   `json:"nullable,omitempty"`
+	ReadOnly    bool                   `json:"readOnly,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+}
+
+// OpenAPI converts the metadata into an OpenAPI 3 document describing the V2 JSON
+// wire format, including the "d" / "d.results" envelopes and the system query options
+func (d *Document) OpenAPI(opts OpenAPIOptions) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{Title: opts.Title, Version: opts.Version},
+		Paths:      make(map[string]PathItem),
+		Components: OpenAPIComponents{Schemas: make(map[string]*JSONSchema)},
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "OData service"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	if opts.ServerURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: opts.ServerURL}}
+	}
+
+	for i := range d.Schemas {
+		s := &d.Schemas[i]
+		for j := range s.ComplexTypes {
+			ct := &s.ComplexTypes[j]
+			doc.Components.Schemas[ct.Name] = d.propertiesSchema(ct.Properties, nil)
+		}
+		for j := range s.EntityTypes {
+			et := &s.EntityTypes[j]
+			schema := d.propertiesSchema(et.Properties, et.KeyNames())
+			for k := range et.NavigationProperties {
+				nav := &et.NavigationProperties[k]
+				target, many, err := d.NavigationTarget(nav)
+				if err != nil {
+					continue
+				}
+				// Navigation properties only appear when expanded
+				ref := &JSONSchema{Ref: schemaRef(target.Name)}
+				if many {
+					ref = &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{
+						"results": {Type: "array", Items: ref},
+					}}
+				}
+				schema.Properties[nav.Name] = ref
+			}
+			doc.Components.Schemas[et.Name] = schema
+		}
+	}
+
+	for _, set := range d.EntitySets() {
+		et, ok := d.EntityType(set.EntityType)
+		if !ok {
+			continue
+		}
+		d.addEntitySetPaths(doc, set, et)
+	}
+
+	for i := range d.Schemas {
+		for j := range d.Schemas[i].EntityContainers {
+			for k := range d.Schemas[i].EntityContainers[j].FunctionImports {
+				d.addFunctionImportPath(doc, &d.Schemas[i].EntityContainers[j].FunctionImports[k])
+			}
+		}
+	}
+	return doc
+}
+
+func (d *Document) addEntitySetPaths(doc *OpenAPIDocument, set *EntitySet, et *EntityType) {
+	ref := &JSONSchema{Ref: schemaRef(et.Name)}
+	tags := []string{set.Name}
+
+	collection := PathItem{
+		"get": {
+			OperationID: "list" + set.Name,
+			Summary:     "Query " + set.Name,
+			Tags:        tags,
+			Parameters:  queryOptionParameters(),
+			Responses: map[string]OpenAPIResponse{
+				"200": jsonResponse("Entities of "+set.Name, envelope(&JSONSchema{
+					Type: "object",
+					Properties: map[string]*JSONSchema{
+						"results": {Type: "array", Items: ref},
+						"__count": {Type: "string", Description: "Present with $inlinecount=allpages"},
+						"__next":  {Type: "string", Description: "Link to the next page when server side paging applies"},
+					},
+				})),
+			},
+		},
+	}
+	if set.sapFlag("creatable") {
+		collection["post"] = &Operation{
+			OperationID: "create" + et.Name + "In" + set.Name,
+			Summary:     "Create an entity in " + set.Name,
+			Tags:        tags,
+			RequestBody: jsonBody(ref),
+			Responses: map[string]OpenAPIResponse{
+				"201": jsonResponse("Created entity", envelope(ref)),
+			},
+		}
+	}
+	doc.Paths["/"+set.Name] = collection
+
+	if !set.sapFlag("addressable") || len(et.Key) == 0 {
+		return
+	}
+
+	predicate, params := keyPredicate(et)
+	entity := PathItem{
+		"get": {
+			OperationID: "get" + et.Name + "From" + set.Name,
+			Summary:     "Read a single entity of " + set.Name,
+			Tags:        tags,
+			Parameters:  append(params, selectExpandParameters()...),
+			Responses: map[string]OpenAPIResponse{
+				"200": jsonResponse("Entity", envelope(ref)),
+			},
+		},
+	}
+	if set.sapFlag("updatable") {
+		entity["put"] = &Operation{
+			OperationID: "update" + et.Name + "In" + set.Name,
+			Tags:        tags,
+			Parameters:  params,
+			RequestBody: jsonBody(ref),
+			Responses:   map[string]OpenAPIResponse{"204": {Description: "Updated"}},
+		}
+		entity["patch"] = &Operation{
+			OperationID: "patch" + et.Name + "In" + set.Name,
+			Tags:        tags,
+			Parameters:  params,
+			RequestBody: jsonBody(ref),
+			Responses:   map[string]OpenAPIResponse{"204": {Description: "Updated"}},
+		}
+	}
+	if set.sapFlag("deletable") {
+		entity["delete"] = &Operation{
+			OperationID: "delete" + et.Name + "From" + set.Name,
+			Tags:        tags,
+			Parameters:  params,
+			Responses:   map[string]OpenAPIResponse{"204": {Description: "Deleted"}},
+		}
+	}
+	doc.Paths["/"+set.Name+predicate] = entity
+}
+
+func (d *Document) addFunctionImportPath(doc *OpenAPIDocument, fi *FunctionImport) {
+	method := strings.ToLower(fi.HTTPMethod)
+	if method == "" {
+		method = "get"
+	}
+
+	op := &Operation{
+		OperationID: fi.Name,
+		Summary:     "Function import " + fi.Name,
+		Tags:        []string{"FunctionImports"},
+		Responses:   map[string]OpenAPIResponse{},
+	}
+	for _, p := range fi.Parameters {
+		schema := d.edmSchema(&Property{Type: p.Type, MaxLength: p.MaxLength})
+		schema.Description = "OData literal, e.g. '" + p.Name + "' for strings"
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:     p.Name,
+			In:       "query",
+			Required: p.Nullable == "false",
+			Schema:   schema,
+		})
+	}
+
+	if fi.ReturnType == "" {
+		op.Responses["204"] = OpenAPIResponse{Description: "No content"}
+	} else {
+		op.Responses["200"] = jsonResponse("Result", envelope(d.returnTypeSchema(fi.ReturnType)))
+	}
+	doc.Paths["/"+fi.Name] = PathItem{method: op}
+}
+
+// returnTypeSchema handles "Collection(NS.Type)" and simple or structured return types
+func (d *Document) returnTypeSchema(returnType string) *JSONSchema {
+	inner, isCollection := strings.CutPrefix(returnType, "Collection(")
+	inner = strings.TrimSuffix(inner, ")")
+
+	var item *JSONSchema
+	if strings.HasPrefix(inner, "Edm.") {
+		item = d.edmSchema(&Property{Type: inner})
+	} else {
+		item = &JSONSchema{Ref: schemaRef(localName(inner))}
+	}
+	if !isCollection {
+		return item
+	}
+	return &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{
+		"results": {Type: "array", Items: item},
+	}}
+}
+
+func (d *Document) propertiesSchema(props []Property, keys []string) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema), Required: keys}
+	for i := range props {
+		p := &props[i]
+		schema.Properties[p.Name] = d.edmSchema(p)
+	}
+	return schema
+}
+
+// edmSchema maps EDM primitive types to their V2 JSON representation
+func (d *Document) edmSchema(p *Property) *JSONSchema {
+	s := &JSONSchema{Title: p.Label(), Nullable: p.IsNullable(), ReadOnly: !p.Creatable() && !p.Updatable()}
+
+	switch p.Type {
+	case "Edm.String":
+		s.Type = "string"
+		if n, err := strconv.Atoi(p.MaxLength); err == nil {
+			s.MaxLength = &n
+		}
+	case "Edm.Guid":
+		s.Type, s.Format = "string", "uuid"
+	case "Edm.Boolean":
+		s.Type = "boolean"
+	case "Edm.Byte", "Edm.SByte", "Edm.Int16", "Edm.Int32":
+		s.Type, s.Format = "integer", "int32"
+	case "Edm.Int64":
+		s.Type, s.Format = "string", "int64"
+	case "Edm.Decimal":
+		s.Type, s.Format = "string", "decimal"
+		if p.Precision != "" {
+			s.Description = fmt.Sprintf("Decimal(%s,%s)", p.Precision, p.Scale)
+		}
+	case "Edm.Double":
+		s.Type, s.Format = "number", "double"
+	case "Edm.Single":
+		s.Type, s.Format = "number", "float"
+	case "Edm.DateTime":
+		s.Type, s.Pattern = "string", `^/Date\(-?\d+([+-]\d{4})?\)/$`
+	case "Edm.DateTimeOffset":
+		s.Type, s.Format = "string", "date-time"
+	case "Edm.Time":
+		s.Type, s.Format = "string", "duration"
+	case "Edm.Binary":
+		s.Type, s.Format = "string", "byte"
+	default:
+		return &JSONSchema{Ref: schemaRef(localName(p.Type))}
+	}
+	return s
+}
+
+func keyPredicate(et *EntityType) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	var parts []string
+	for _, k := range et.Key {
+		p, _ := et.Property(k.Name)
+		literal := "{" + k.Name + "}"
+		if p == nil || p.Type == "Edm.String" {
+			literal = "'" + literal + "'"
+		}
+		parts = append(parts, k.Name+"="+literal)
+		params = append(params, OpenAPIParameter{Name: k.Name, In: "path", Required: true, Schema: &JSONSchema{Type: "string"}})
+	}
+	if len(parts) == 1 {
+		// Single keys are usually addressed without the property name
+		_, literal, _ := strings.Cut(parts[0], "=")
+		return "(" + literal + ")", params
+	}
+	return "(" + strings.Join(parts, ",") + ")", params
+}
+
+func queryOptionParameters() []OpenAPIParameter {
+	params := []OpenAPIParameter{
+		{Name: "$top", In: "query", Schema: &JSONSchema{Type: "integer"}},
+		{Name: "$skip", In: "query", Schema: &JSONSchema{Type: "integer"}},
+		{Name: "$filter", In: "query", Schema: &JSONSchema{Type: "string"}},
+		{Name: "$orderby", In: "query", Schema: &JSONSchema{Type: "string"}},
+		{Name: "$inlinecount", In: "query", Schema: &JSONSchema{Type: "string"}},
+		{Name: "$skiptoken", In: "query", Schema: &JSONSchema{Type: "string"}},
+	}
+	return append(params, selectExpandParameters()...)
+}
+
+func selectExpandParameters() []OpenAPIParameter {
+	return []OpenAPIParameter{
+		{Name: "$select", In: "query", Schema: &JSONSchema{Type: "string"}},
+		{Name: "$expand", In: "query", Schema: &JSONSchema{Type: "string"}},
+	}
+}
+
+func envelope(inner *JSONSchema) *JSONSchema {
+	return &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{"d": inner}}
+}
+
+func jsonResponse(description string, schema *JSONSchema) OpenAPIResponse {
+	return OpenAPIResponse{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func jsonBody(schema *JSONSchema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+func localName(qualified string) string {
+	if i := strings.LastIndex(qualified, "."); i >= 0 {
+		return qualified[i+1:]
+	}
+	return qualified
+}
@@ -0,0 +1,102 @@
+package metadata
+
+import "strings"
+
+// ValueListTerm is the vocabulary term SAP Gateway annotates value helps with
+const ValueListTerm = "com.sap.vocabularies.Common.v1.ValueList"
+
+// TargetAnnotations are the vocabulary annotations of one model element, such as
+// <Annotations Target="NS.Product/Category">, which SAP Gateway emits for value helps
+type TargetAnnotations struct {
+	Target      string       `xml:"Target,attr"`
+	Annotations []Annotation `xml:"Annotation"`
+}
+
+// Annotation is a vocabulary annotation; only record values are kept
+type Annotation struct {
+	Term   string  `xml:"Term,attr"`
+	Record *Record `xml:"Record"`
+}
+
+type Record struct {
+	Type   string          `xml:"Type,attr"`
+	Values []PropertyValue `xml:"PropertyValue"`
+}
+
+type PropertyValue struct {
+	Property     string   `xml:"Property,attr"`
+	String       string   `xml:"String,attr"`
+	PropertyPath string   `xml:"PropertyPath,attr"`
+	Records      []Record `xml:"Collection>Record"`
+}
+
+// Value returns the PropertyValue named property of r
+func (r *Record) Value(property string) (*PropertyValue, bool) {
+	for i := range r.Values {
+		if r.Values[i].Property == property {
+			return &r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValueList describes the value help of a property: the entity set listing its values, the
+// property of that set holding the value and the one holding its description, if any
+type ValueList struct {
+	CollectionPath string
+	ValueProperty  string
+	TextProperty   string
+}
+
+// ValueList returns the value help annotated for property of entityType (qualified or simple
+// name). For a property with HasFixedValues, the collection lists the fixed domain.
+func (d *Document) ValueList(entityType, property string) (*ValueList, bool) {
+	et, ok := d.EntityType(entityType)
+	if !ok {
+		return nil, false
+	}
+	// SAP Gateway puts the annotations in a schema of their own, targeting qualified names
+	for _, s := range d.Schemas {
+		for _, ta := range s.VocabularyAnnotations {
+			target, prop, _ := strings.Cut(ta.Target, "/")
+			if prop != property || !strings.Contains(target, ".") {
+				continue
+			}
+			if t, ok := d.EntityType(target); !ok || t != et {
+				continue
+			}
+			for _, a := range ta.Annotations {
+				if a.Term == ValueListTerm && a.Record != nil {
+					return valueList(a.Record, property)
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func valueList(r *Record, property string) (*ValueList, bool) {
+	collection, ok := r.Value("CollectionPath")
+	if !ok || collection.String == "" {
+		return nil, false
+	}
+	vl := &ValueList{CollectionPath: collection.String}
+	params, _ := r.Value("Parameters")
+	if params == nil {
+		return vl, true
+	}
+	for _, p := range params.Records {
+		local, _ := p.Value("LocalDataProperty")
+		remote, _ := p.Value("ValueListProperty")
+		if remote == nil {
+			continue
+		}
+		switch {
+		case local != nil && local.PropertyPath == property:
+			vl.ValueProperty = remote.String
+		case strings.HasSuffix(p.Type, "ValueListParameterDisplayOnly") && vl.TextProperty == "":
+			vl.TextProperty = remote.String
+		}
+	}
+	return vl, true
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Clone returns a deep copy of an entity: pointers, slices and maps are copied, so the
+// copy can be changed or handed to another goroutine without touching v. Values of
+// structs with unexported fields, such as time.Time, are copied as they are. References
+// that are shared or cyclic, e.g. a navigation property pointing back at its parent, are
+// shared or cyclic in the same way within the copy.
+func Clone[T any](v T) T {
+	src := reflect.ValueOf(&v).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	c := cloner{copies: make(map[reference]reflect.Value)}
+	c.cloneValue(dst, src)
+	return dst.Interface().(T)
+}
+
+// reference identifies what a pointer, slice or map refers to. The type tells apart a
+// struct from its first field, and the length slices of the same array.
+type reference struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func referenceOf(v reflect.Value) reference {
+	r := reference{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		r.len = v.Len()
+	}
+	return r
+}
+
+// cloner remembers the copies made so far, so a value reached again is not copied twice
+type cloner struct {
+	copies map[reference]reflect.Value
+}
+
+// copied sets dst to the copy already made of src, if there is one
+func (c *cloner) copied(dst, src reflect.Value) bool {
+	cp, ok := c.copies[referenceOf(src)]
+	if ok {
+		dst.Set(cp)
+	}
+	return ok
+}
+
+func (c *cloner) cloneValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() || c.copied(dst, src) {
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.copies[referenceOf(src)] = p
+		c.cloneValue(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		c.cloneValue(v, src.Elem())
+		dst.Set(v)
+	case reflect.Slice:
+		if src.IsNil() || c.copied(dst, src) {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		c.copies[referenceOf(src)] = s
+		for i := 0; i < src.Len(); i++ {
+			c.cloneValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.cloneValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() || c.copied(dst, src) {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.copies[referenceOf(src)] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			c.cloneValue(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		dst.Set(src) // carries the unexported fields, which cannot be copied one by one
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				c.cloneValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// Equal reports whether two entities hold the same values. Unlike reflect.DeepEqual it
+// compares times by instant, so the same moment read in different locations is equal.
+// Null (a nil pointer) differs from a pointer to the zero value, as it does for a
+// Nullable property; nil and empty slices and maps are equal since both encode the same.
+// Cyclic references are compared like reflect.DeepEqual does.
+func Equal[T any](a, b T) bool {
+	e := equality{visited: make(map[[2]reference]bool)}
+	return e.equalValue(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+}
+
+// equality remembers the pairs of references being compared. A pair reached again is
+// taken as equal; if it is not, the comparison in progress finds the difference.
+type equality struct {
+	visited map[[2]reference]bool
+}
+
+// seen records the pair a, b and reports whether it was compared before
+func (e *equality) seen(a, b reflect.Value) bool {
+	pair := [2]reference{referenceOf(a), referenceOf(b)}
+	if e.visited[pair] {
+		return true
+	}
+	e.visited[pair] = true
+	return false
+}
+
+func (e *equality) equalValue(a, b reflect.Value) bool {
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+	}
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Pointer() == b.Pointer() || e.seen(a, b) || e.equalValue(a.Elem(), b.Elem())
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Elem().Type() == b.Elem().Type() && e.equalValue(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		if a.Kind() == reflect.Slice && a.Len() > 0 && e.seen(a, b) {
+			return true
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !e.equalValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		if a.Len() > 0 && e.seen(a, b) {
+			return true
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			bv := b.MapIndex(iter.Key())
+			if !bv.IsValid() || !e.equalValue(iter.Value(), bv) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !a.Type().Field(i).IsExported() {
+				return reflect.DeepEqual(a.Interface(), b.Interface())
+			}
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !e.equalValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	}
+	return a.Equal(b)
+}
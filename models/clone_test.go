@@ -0,0 +1,176 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City  string
+	Lines []string
+}
+
+type testPartner struct {
+	ID       string
+	Name     *string
+	Created  time.Time
+	Address  *testAddress
+	Tags     []string
+	Props    map[string]*testAddress
+	Extra    interface{}
+	Priority [2]int
+}
+
+func newTestPartner() testPartner {
+	name := "ACME"
+	return testPartner{
+		ID:       "100",
+		Name:     &name,
+		Created:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Address:  &testAddress{City: "Walldorf", Lines: []string{"Dietmar-Hopp-Allee 16"}},
+		Tags:     []string{"customer"},
+		Props:    map[string]*testAddress{"billing": {City: "Berlin"}},
+		Extra:    []int{1, 2},
+		Priority: [2]int{1, 2},
+	}
+}
+
+func TestClone(t *testing.T) {
+	orig := newTestPartner()
+	c := Clone(orig)
+	if !Equal(orig, c) {
+		t.Fatalf("Clone() = %+v, not equal to the original", c)
+	}
+
+	*c.Name = "changed"
+	c.Address.City = "changed"
+	c.Address.Lines[0] = "changed"
+	c.Tags[0] = "changed"
+	c.Props["billing"].City = "changed"
+	c.Props["shipping"] = &testAddress{}
+	c.Extra.([]int)[0] = 99
+	c.Priority[0] = 99
+
+	want := newTestPartner()
+	if !Equal(orig, want) {
+		t.Errorf("changing the clone changed the original: %+v", orig)
+	}
+	if orig.Extra.([]int)[0] != 1 {
+		t.Error("the slice in an interface field is shared with the clone")
+	}
+}
+
+func TestCloneNil(t *testing.T) {
+	var p *testPartner
+	if Clone(p) != nil {
+		t.Error("Clone(nil pointer) is not nil")
+	}
+	c := Clone(testPartner{})
+	if c.Name != nil || c.Address != nil || c.Tags != nil || c.Props != nil || c.Extra != nil {
+		t.Errorf("Clone(zero value) = %+v, want nil fields kept nil", c)
+	}
+
+	ptr := &testPartner{ID: "1"}
+	if cp := Clone(ptr); cp == ptr || cp.ID != "1" {
+		t.Errorf("Clone(pointer) = %p %+v, want a new entity", cp, cp)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name   string
+		change func(*testPartner)
+		want   bool
+	}{
+		{"unchanged", func(*testPartner) {}, true},
+		{"same instant elsewhere", func(p *testPartner) { p.Created = p.Created.In(time.FixedZone("CET", 3600)) }, true},
+		{"other instant", func(p *testPartner) { p.Created = p.Created.Add(time.Second) }, false},
+		{"null and zero value", func(p *testPartner) { p.Name = &empty }, false},
+		{"null", func(p *testPartner) { p.Name = nil }, false},
+		{"nested field", func(p *testPartner) { p.Address.Lines[0] = "other" }, false},
+		{"slice removed", func(p *testPartner) { p.Tags = nil }, false},
+		{"map value", func(p *testPartner) { p.Props["billing"].City = "Hamburg" }, false},
+		{"map key", func(p *testPartner) { p.Props = map[string]*testAddress{"other": {City: "Berlin"}} }, false},
+		{"interface type", func(p *testPartner) { p.Extra = []int64{1, 2} }, false},
+		{"array", func(p *testPartner) { p.Priority[1] = 3 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newTestPartner(), newTestPartner()
+			tt.change(&b)
+			if got := Equal(a, b); got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !Equal(testPartner{Tags: nil}, testPartner{Tags: []string{}}) {
+		t.Error("nil and empty slices differ")
+	}
+	if !Equal(testPartner{Props: nil}, testPartner{Props: map[string]*testAddress{}}) {
+		t.Error("nil and empty maps differ")
+	}
+}
+
+// testNode is an entity with navigation properties back to itself
+type testNode struct {
+	ID       string
+	Parent   *testNode
+	Children []*testNode
+	Related  map[string]*testNode
+}
+
+func newTestTree() *testNode {
+	root := &testNode{ID: "1", Related: map[string]*testNode{}}
+	child := &testNode{ID: "2", Parent: root}
+	root.Children = []*testNode{child}
+	root.Related["self"] = root
+	root.Related["child"] = child
+	return root
+}
+
+func TestCloneCyclic(t *testing.T) {
+	orig := newTestTree()
+	c := Clone(orig)
+	if c == orig || c.Children[0] == orig.Children[0] {
+		t.Fatal("Clone shares pointers with the original")
+	}
+	if c.Children[0].Parent != c {
+		t.Error("the child of the copy does not point back at the copy")
+	}
+	if c.Related["self"] != c || c.Related["child"] != c.Children[0] {
+		t.Error("the copy does not keep the shared references of the original")
+	}
+	c.Children[0].ID = "changed"
+	if orig.Children[0].ID != "2" {
+		t.Error("changing the copy changed the original")
+	}
+
+	// a slice holding itself through an interface
+	s := []interface{}{nil}
+	s[0] = s
+	cs := Clone(s)
+	if inner, ok := cs[0].([]interface{}); !ok || &inner[0] != &cs[0] {
+		t.Error("the copy of a self-referencing slice does not refer to itself")
+	}
+}
+
+func TestEqualCyclic(t *testing.T) {
+	a, b := newTestTree(), newTestTree()
+	if !Equal(a, b) {
+		t.Error("Equal(a, b) = false for trees built the same way")
+	}
+	if !Equal(a, Clone(a)) {
+		t.Error("Equal(a, Clone(a)) = false")
+	}
+	b.Children[0].ID = "3"
+	if Equal(a, b) {
+		t.Error("Equal(a, b) = true for trees with different child IDs")
+	}
+	b = newTestTree()
+	b.Related["self"] = b.Children[0]
+	if Equal(a, b) {
+		t.Error("Equal(a, b) = true for a different map entry in the cycle")
+	}
+}
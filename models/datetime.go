@@ -0,0 +1,68 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ODataDateTime is an Edm.DateTime or Edm.DateTimeOffset value, written by OData V2 JSON
+// as "/Date(1700000000000)/" (milliseconds since the epoch, optionally followed by an
+// offset in minutes such as +0060). Values are decoded in UTC; see odata.WithTimeZone for
+// backends writing system local time. The zero value encodes as null.
+type ODataDateTime struct {
+	time.Time
+}
+
+// MarshalJSON implements json.Marshaler
+func (d ODataDateTime) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"\/Date(` + strconv.FormatInt(d.UnixMilli(), 10) + `)\/"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Besides "/Date(...)/" it accepts ISO 8601 strings.
+func (d *ODataDateTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		d.Time = time.Time{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := ParseDateTime(s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// ParseDateTime parses "/Date(ms)/", "/Date(ms+0060)/" or an ISO 8601 timestamp into UTC
+func ParseDateTime(s string) (time.Time, error) {
+	if inner, ok := strings.CutPrefix(s, "/Date("); ok {
+		inner, ok = strings.CutSuffix(inner, ")/")
+		if !ok || inner == "" {
+			return time.Time{}, fmt.Errorf("invalid OData date %q", s)
+		}
+		if i := strings.IndexAny(inner[1:], "+-"); i >= 0 {
+			inner = inner[:i+1] // the offset is informational, ms are UTC
+		}
+		ms, err := strconv.ParseInt(inner, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid OData date %q", s)
+		}
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.9999999", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid OData date %q", s)
+}
@@ -1,11 +1,44 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // ODataResponse is the generic container for OData v2 JSON responses.
 // V2 typically wraps results in a "d" object.
 type ODataResponse[T any] struct {
 	D DWrapper[T] `json:"d"`
+	// Execution describes how the response was obtained, for SLO reporting
+	Execution ExecutionInfo `json:"-"`
+}
+
+// ExecutionInfo describes the execution of a request
+type ExecutionInfo struct {
+	// Duration is the time from sending the request until its response was read, including
+	// retries and waiting for a concurrency slot
+	Duration time.Duration
+	// Attempts counts the HTTP requests sent, including retries and the repeat after a
+	// CSRF token refresh
+	Attempts      int
+	CSRFRefreshes int
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// Add returns the sum of e and other, for results that took several requests
+func (e ExecutionInfo) Add(other ExecutionInfo) ExecutionInfo {
+	return ExecutionInfo{
+		Duration:      e.Duration + other.Duration,
+		Attempts:      e.Attempts + other.Attempts,
+		CSRFRefreshes: e.CSRFRefreshes + other.CSRFRefreshes,
+		BytesSent:     e.BytesSent + other.BytesSent,
+		BytesReceived: e.BytesReceived + other.BytesReceived,
+	}
 }
 
 // DWrapper handles the "result" vs "results" discrepancy or direct object return.
@@ -17,6 +50,9 @@ type ODataResponse[T any] struct {
 // DWrapper handles the "result" vs "results" discrepancy.
 type DWrapper[T any] struct {
 	Result T
+
+	next  string // __next link of server-side paging
+	count string // __count of $inlinecount=allpages
 }
 
 func (w *DWrapper[T]) UnmarshalJSON(data []byte) error {
@@ -27,6 +63,8 @@ func (w *DWrapper[T]) UnmarshalJSON(data []byte) error {
 
 	// Case 1: d.results exists (Common for collections and some single entities)
 	if val, ok := raw["results"]; ok {
+		_ = json.Unmarshal(raw["__next"], &w.next)
+		_ = json.Unmarshal(raw["__count"], &w.count)
 		return json.Unmarshal(val, &w.Result)
 	}
 
@@ -34,6 +72,76 @@ func (w *DWrapper[T]) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, &w.Result)
 }
 
+// ResultTarget returns a pointer to Result, for decoders that fill it themselves instead of
+// through UnmarshalJSON
+func (w *DWrapper[T]) ResultTarget() interface{} {
+	return &w.Result
+}
+
+// SetPaging sets the __next link and __count of a collection, for decoders that fill Result
+// themselves instead of through UnmarshalJSON
+func (w *DWrapper[T]) SetPaging(next, count string) {
+	w.next, w.count = next, count
+}
+
+// Len returns the number of entities of a collection response, and 1 for a single entity
+func (r *ODataResponse[T]) Len() int {
+	if r == nil {
+		return 0
+	}
+	v := reflect.ValueOf(r.D.Result)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return v.Len()
+	case reflect.Invalid:
+		return 0
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+	}
+	return 1
+}
+
+// IsEmpty reports whether a response holds no entities
+func (r *ODataResponse[T]) IsEmpty() bool {
+	return r.Len() == 0
+}
+
+// NextLink returns the __next link of a collection the server returned in pages, empty on
+// the last page
+func (r *ODataResponse[T]) NextLink() string {
+	if r == nil {
+		return ""
+	}
+	return r.D.next
+}
+
+// TotalCount returns the __count of a collection read with $inlinecount=allpages: the number
+// of all matching entities, regardless of $top and paging. The result is false without it.
+func (r *ODataResponse[T]) TotalCount() (int64, bool) {
+	if r == nil || r.D.count == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(r.D.count, 10, 64)
+	return n, err == nil
+}
+
+// Count returns the __count of a collection read with $inlinecount=allpages, like TotalCount
+func (r *ODataResponse[T]) Count() (int64, bool) {
+	return r.TotalCount()
+}
+
+// First returns the first entity of a collection response and whether there is one. It is a
+// function rather than a method because methods cannot name the element type of T.
+func First[E any](r *ODataResponse[[]E]) (E, bool) {
+	if r == nil || len(r.D.Result) == 0 {
+		var zero E
+		return zero, false
+	}
+	return r.D.Result[0], true
+}
+
 // ODataErrorResponse handles OData error structures
 type ODataErrorResponse struct {
 	Err ODataError `json:"error"`
@@ -42,6 +150,66 @@ type ODataErrorResponse struct {
 type ODataError struct {
 	Code    string       `json:"code"`
 	Message ODataMessage `json:"message"`
+	// Messages holds every language variant of the message the backend sent, for logging;
+	// Message is the one selected by SelectLanguage
+	Messages []ODataMessage `json:"-"`
+}
+
+// UnmarshalJSON accepts the message as a single object, as a list of language variants or
+// as a bare string, and selects the English variant or else the first with a text
+func (e *ODataError) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Code    string          `json:"code"`
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e.Code = raw.Code
+	e.Messages = nil
+	var one ODataMessage
+	var text string
+	switch {
+	case len(raw.Message) == 0, string(raw.Message) == "null":
+	case json.Unmarshal(raw.Message, &e.Messages) == nil:
+	case json.Unmarshal(raw.Message, &one) == nil:
+		e.Messages = []ODataMessage{one}
+	case json.Unmarshal(raw.Message, &text) == nil:
+		e.Messages = []ODataMessage{{Value: text}}
+	default:
+		return fmt.Errorf("invalid error message %s", raw.Message)
+	}
+	e.SelectLanguage("")
+	return nil
+}
+
+// SelectLanguage sets Message to the variant in lang (e.g. "DE" or "de-DE"), falling back to
+// English and then to the first variant with a text
+func (e *ODataError) SelectLanguage(lang string) {
+	e.Message = ODataMessage{}
+	for _, want := range []string{lang, "en"} {
+		for _, m := range e.Messages {
+			if want != "" && m.Value != "" && sameLanguage(m.Lang, want) {
+				e.Message = m
+				return
+			}
+		}
+	}
+	for _, m := range e.Messages {
+		if m.Value != "" {
+			e.Message = m
+			return
+		}
+	}
+}
+
+// sameLanguage compares language codes by their primary subtag, case-insensitively
+func sameLanguage(a, b string) bool {
+	primary := func(code string) string {
+		code, _, _ = strings.Cut(code, "-")
+		return code
+	}
+	return strings.EqualFold(primary(a), primary(b))
 }
 
 type ODataMessage struct {
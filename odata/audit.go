@@ -0,0 +1,190 @@
+package odata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// AuditRecord describes a create, update, patch or delete sent by a service, successful
+// or not
+type AuditRecord struct {
+	Time time.Time
+	// User is who the operation was performed for, as returned by AuditOptions.User
+	User      string
+	Method    string
+	Operation string
+	EntitySet string
+	Key       string
+	URL       string
+	// Changes lists the fields written, in field order. Old values are only known with
+	// AuditOptions.ReadBefore.
+	Changes []FieldChange
+	// StatusCode is zero when no response was received
+	StatusCode int
+	// Message is the backend's message: the sap-message header of a success or the error
+	Message       string
+	Failed        bool
+	CorrelationID string
+	Elapsed       time.Duration
+}
+
+// FieldChange is a field of a write. Old is nil if it was not read or the entity is new,
+// New is nil for deletes.
+type FieldChange struct {
+	Field string
+	Old   json.RawMessage
+	New   json.RawMessage
+}
+
+// AuditSink stores audit records, e.g. in a database table or an append-only log
+type AuditSink interface {
+	Record(ctx context.Context, r *AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to an AuditSink
+type AuditSinkFunc func(ctx context.Context, r *AuditRecord) error
+
+func (f AuditSinkFunc) Record(ctx context.Context, r *AuditRecord) error {
+	return f(ctx, r)
+}
+
+// AuditOptions configure the audit log of a service
+type AuditOptions struct {
+	// User returns who a request is performed for, e.g. the authenticated user stored in
+	// ctx by the application
+	User func(ctx context.Context) string
+	// ReadBefore reads every entity before updating or deleting it, so the records carry
+	// the old values and list only fields that actually change. It costs a GET per write.
+	ReadBefore bool
+}
+
+// WithAudit records every write of the service, including failed ones, in sink: creates,
+// updates, patches and deletes, media uploads, $links changes and function imports invoked
+// with POST. If recording fails after a write succeeded, the write has happened and the
+// call returns the recording error. Operations sent in $batch requests are not recorded.
+func WithAudit(sink AuditSink, opts AuditOptions) ServiceOption {
+	return func(s *Service) {
+		s.audit = &auditLog{sink: sink, opts: opts}
+	}
+}
+
+type auditLog struct {
+	sink AuditSink
+	opts AuditOptions
+}
+
+// audited reports whether c is recorded
+func (a *auditLog) audited(c *call) bool {
+	return a != nil && c.modifies()
+}
+
+// before returns the properties of the entity c modifies, if the log reads them
+func (a *auditLog) before(s *Service, c *call) map[string]json.RawMessage {
+	if !a.opts.ReadBefore || c.creates() {
+		return nil
+	}
+	switch c.operation {
+	case OpUpdate, OpPatch, OpDelete:
+	default:
+		return nil // not an entity
+	}
+	var resp struct {
+		D map[string]json.RawMessage `json:"d"`
+	}
+	read := &call{operation: OpGet, entitySet: c.entitySet, method: http.MethodGet, url: c.url, correlationID: c.correlationID}
+	if err := s.roundTrip(read, &resp); err != nil {
+		return nil // the write reports what is wrong with the entity
+	}
+	return resp.D
+}
+
+// record writes the record of c, sent at start and ending with err
+func (a *auditLog) record(s *Service, c *call, start time.Time, old map[string]json.RawMessage, err error) error {
+	r := &AuditRecord{
+		Time:          start,
+		Method:        c.method,
+		Operation:     c.operation,
+		EntitySet:     c.entitySet,
+		Key:           s.entityKey(c),
+		URL:           c.url,
+		StatusCode:    c.status,
+		Message:       sapMessage(c.header),
+		CorrelationID: c.correlationID,
+		Elapsed:       time.Since(start),
+	}
+	if a.opts.User != nil {
+		r.User = a.opts.User(s.context())
+	}
+	if err != nil {
+		r.Failed = true
+		r.Message = err.Error()
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) && reqErr.Err != nil {
+			r.StatusCode = reqErr.StatusCode
+			r.Message = reqErr.Err.Error()
+		}
+	}
+	r.Changes = s.changes(c, old)
+	if recErr := a.sink.Record(s.context(), r); recErr != nil {
+		return fmt.Errorf("recording audit of %s %s: %w", c.method, c.url, recErr)
+	}
+	return nil
+}
+
+// changes compares the payload of c, as sent, with the old properties of the entity
+func (s *Service) changes(c *call, old map[string]json.RawMessage) []FieldChange {
+	var fields map[string]json.RawMessage
+	if c.payload != nil {
+		raw, _ := s.EncodePayload(c.payload)
+		_ = json.Unmarshal(raw, &fields)
+	} else if c.operation == OpDelete {
+		fields = make(map[string]json.RawMessage, len(old))
+		for name := range old {
+			fields[name] = nil
+		}
+	}
+
+	var changes []FieldChange
+	for name, value := range fields {
+		if name == "__metadata" {
+			continue
+		}
+		before, known := old[name]
+		if known && value != nil && jsonEqual(before, value) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Old: before, New: value})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// sapMessage returns the text of the sap-message header SAP gateways add to successful
+// writes, e.g. "Sales order 4711 saved"
+func sapMessage(header http.Header) string {
+	raw := header.Get("sap-message")
+	if raw == "" {
+		return ""
+	}
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal([]byte(raw), &msg) != nil || msg.Message == "" {
+		return raw
+	}
+	return msg.Message
+}
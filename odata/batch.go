@@ -0,0 +1,555 @@
+package odata
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// BatchOperation is a single request inside a $batch
+type BatchOperation struct {
+	Method string
+	// Path is relative to the service root, e.g. "ProductSet('HT-1000')".
+	// Inside a changeset it may start with a Content-ID reference like "$1/ToItems".
+	Path    string
+	Query   map[string]string
+	Body    interface{}
+	Headers map[string]string
+	// ContentID is assigned automatically for changeset operations
+	ContentID string
+}
+
+// Changeset groups mutating operations that the gateway executes atomically
+type Changeset struct {
+	batch      *Batch
+	operations []*BatchOperation
+}
+
+// Add queues a mutating operation in the changeset and returns it.
+// The returned operation's ContentID can be referenced by later operations as "$<ContentID>".
+func (c *Changeset) Add(method, path string, body interface{}) *BatchOperation {
+	op := &BatchOperation{
+		Method:    method,
+		Path:      path,
+		Body:      body,
+		ContentID: c.batch.nextContentID(),
+	}
+	c.operations = append(c.operations, op)
+	return op
+}
+
+// Operations returns the queued operations of the changeset in request order
+func (c *Changeset) Operations() []*BatchOperation {
+	return c.operations
+}
+
+// Err returns a *TxError if the gateway rolled the changeset back in resp, naming the
+// failed operations, and nil if all of its operations were applied
+func (c *Changeset) Err(resp *BatchResponse) error {
+	results := make([]*BatchResult, 0, len(c.operations))
+	for _, r := range resp.Results {
+		if slices.Contains(c.operations, r.Operation) {
+			results = append(results, r)
+		}
+	}
+	return txError(c.operations, results)
+}
+
+// batchPart is either a single retrieve operation or a changeset
+type batchPart struct {
+	operation *BatchOperation
+	changeset *Changeset
+}
+
+// Batch collects operations to be sent as one multipart/mixed $batch request
+type Batch struct {
+	service   *Service
+	parts     []batchPart
+	contentID func() string
+	// resolve rewrites the operation paths when encoding, see BatchSession
+	resolve func(path string) string
+}
+
+// NewBatch starts an empty batch against the service
+func (s *Service) NewBatch() *Batch {
+	counter := 0
+	return &Batch{
+		service: s,
+		contentID: func() string {
+			counter++
+			return strconv.Itoa(counter)
+		},
+	}
+}
+
+func (b *Batch) nextContentID() string {
+	return b.contentID()
+}
+
+// Query queues a retrieve operation (GET) outside of any changeset
+func (b *Batch) Query(path string, opts *QueryOptions) *BatchOperation {
+	op := &BatchOperation{Method: http.MethodGet, Path: path}
+	if opts != nil {
+		op.Query = opts.Build()
+	}
+	b.parts = append(b.parts, batchPart{operation: op})
+	return op
+}
+
+// Changeset opens a new changeset in the batch
+func (b *Batch) Changeset() *Changeset {
+	cs := &Changeset{batch: b}
+	b.parts = append(b.parts, batchPart{changeset: cs})
+	return cs
+}
+
+// Operations returns all queued operations in request order
+func (b *Batch) Operations() []*BatchOperation {
+	var ops []*BatchOperation
+	for _, p := range b.parts {
+		if p.changeset != nil {
+			ops = append(ops, p.changeset.operations...)
+		} else {
+			ops = append(ops, p.operation)
+		}
+	}
+	return ops
+}
+
+// BatchResult is the outcome of a single operation of a batch
+type BatchResult struct {
+	Operation  *BatchOperation
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	names *nameMapping
+	codec JSONCodec
+	// language selects the message of an error response
+	language string
+	// shared marks the single error response to a failed changeset, copied for each operation
+	shared bool
+}
+
+// Err returns the parsed OData error if the operation failed
+func (r *BatchResult) Err() error {
+	if r.StatusCode < 400 {
+		return nil
+	}
+	return parseError(r.Body, r.language)
+}
+
+// Decode unmarshals the operation's response body into v
+func (r *BatchResult) Decode(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.Body) == 0 {
+		return nil
+	}
+	return r.names.unmarshal(r.codec, r.Body, v)
+}
+
+// BatchResponse holds one result per queued operation, in request order
+type BatchResponse struct {
+	Results []*BatchResult
+}
+
+// Execute sends the batch and parses the multipart response
+func (b *Batch) Execute() (*BatchResponse, error) {
+	c := b.call()
+	start := time.Now()
+	resp, err := b.send(c, nil, nil, false)
+	if err != nil {
+		return nil, b.finish(c, start, nil, 0, err)
+	}
+	result, err := b.parseResponse(resp)
+	if err := b.finish(c, start, resp, int64(len(resp.Body())), err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Transient reports whether the operation failed because the gateway or backend could not
+// take it right now (408, 429 or 5xx), so that sending it again may succeed
+func (r *BatchResult) Transient() bool {
+	return r.StatusCode >= 500 || r.StatusCode == http.StatusRequestTimeout || r.StatusCode == http.StatusTooManyRequests
+}
+
+// RetryFailed sends again the parts of b that failed transiently in resp, an earlier response
+// to b, and returns resp with their results replaced. A changeset is retried as a whole, with
+// its Content-IDs, since a failed changeset was not applied; one that failed with any other
+// error is not retried, as the same error would follow. If nothing needs a retry, resp is
+// returned unchanged without a request.
+func (b *Batch) RetryFailed(resp *BatchResponse) (*BatchResponse, error) {
+	byOp := make(map[*BatchOperation]*BatchResult, len(resp.Results))
+	for _, r := range resp.Results {
+		if r != nil {
+			byOp[r.Operation] = r
+		}
+	}
+	retry := &Batch{service: b.service, contentID: b.contentID}
+	for _, p := range b.parts {
+		ops := []*BatchOperation{p.operation}
+		if p.changeset != nil {
+			ops = p.changeset.operations
+		}
+		if retryable(ops, byOp) {
+			retry.parts = append(retry.parts, p)
+		}
+	}
+	if len(retry.parts) == 0 {
+		return resp, nil
+	}
+
+	again, err := retry.Execute()
+	if err != nil {
+		return resp, err
+	}
+	for _, r := range again.Results {
+		byOp[r.Operation] = r
+	}
+	merged := &BatchResponse{Results: make([]*BatchResult, len(resp.Results))}
+	for i, r := range resp.Results {
+		if r != nil {
+			r = byOp[r.Operation]
+		}
+		merged.Results[i] = r
+	}
+	return merged, nil
+}
+
+// retryable reports whether the operations of a batch part failed, all of them transiently
+func retryable(ops []*BatchOperation, results map[*BatchOperation]*BatchResult) bool {
+	failed := false
+	for _, op := range ops {
+		r, ok := results[op]
+		if !ok || r.StatusCode < 400 {
+			continue
+		}
+		if !r.Transient() {
+			return false
+		}
+		failed = true
+	}
+	return failed
+}
+
+// Stream sends the batch and passes each operation's result to fn as soon as its part of the
+// response has been read, in request order. Unlike Execute, the response is never held in
+// memory as a whole, which keeps bulk jobs with thousands of operations bounded. An error
+// returned by fn stops reading.
+func (b *Batch) Stream(fn func(*BatchResult) error) (err error) {
+	c := b.call()
+	start := time.Now()
+	var resp *resty.Response
+	body := &countingReader{}
+	defer func() { err = b.finish(c, start, resp, body.n, err) }()
+
+	resp, err = b.send(c, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	body.r = resp.RawBody()
+	defer resp.RawBody().Close()
+
+	if resp.IsError() {
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("reading batch response: %w", err)
+		}
+		return parseError(raw, b.service.language())
+	}
+	return b.walkResponse(body, resp.Header().Get("Content-Type"), fn)
+}
+
+// call describes the batch request for metrics and errors
+func (b *Batch) call() *call {
+	return &call{
+		operation:     OpBatch,
+		entitySet:     "$batch",
+		method:        http.MethodPost,
+		url:           b.service.buildURL("$batch"),
+		correlationID: correlationID(b.service.context()),
+	}
+}
+
+// finish reports the batch request to the service's metrics hook and wraps err in a RequestError
+func (b *Batch) finish(c *call, start time.Time, resp *resty.Response, size int64, err error) error {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode()
+	}
+	b.service.observe(c, start, status, size, err)
+	return b.service.fail(c, start, status, err)
+}
+
+// send posts the encoded batch described by c with optional extra headers and cookies
+func (b *Batch) send(c *call, headers map[string]string, cookies []*http.Cookie, stream bool) (*resty.Response, error) {
+	if len(b.parts) == 0 {
+		return nil, fmt.Errorf("batch is empty")
+	}
+
+	boundary := "batch_" + randomBoundary()
+	body, err := b.encode(boundary)
+	if err != nil {
+		return nil, err
+	}
+
+	h := map[string]string{"Content-Type": "multipart/mixed; boundary=" + boundary}
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	c.payload, c.headers = body, h
+	req, err := b.service.request(c)
+	if err != nil {
+		return nil, err
+	}
+	req.Cookies = cookies
+	req.Stream = stream
+	// $batch only accepts POST, so the token comes from the service root
+	req.CSRFURL = b.service.servicePath
+	return b.service.client.Do(b.service.context(), req)
+}
+
+// encode writes the multipart/mixed body of the batch
+func (b *Batch) encode(boundary string) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, p := range b.parts {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		if p.changeset == nil {
+			if err := b.writeOperation(&buf, p.operation); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		csBoundary := "changeset_" + randomBoundary()
+		fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", csBoundary)
+		for _, op := range p.changeset.operations {
+			fmt.Fprintf(&buf, "--%s\r\n", csBoundary)
+			if err := b.writeOperation(&buf, op); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Fprintf(&buf, "--%s--\r\n", csBoundary)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// writeOperation writes one application/http part
+func (b *Batch) writeOperation(buf *bytes.Buffer, op *BatchOperation) error {
+	buf.WriteString("Content-Type: application/http\r\n")
+	buf.WriteString("Content-Transfer-Encoding: binary\r\n")
+	if op.ContentID != "" {
+		fmt.Fprintf(buf, "Content-ID: %s\r\n", op.ContentID)
+	}
+	buf.WriteString("\r\n")
+
+	target := op.Path
+	if b.resolve != nil {
+		target = b.resolve(target)
+	}
+	if len(op.Query) > 0 {
+		target += "?" + encodeQuery(b.service.localQuery(op.Query))
+	}
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\r\n", op.Method, target)
+	buf.WriteString("Accept: application/json\r\n")
+
+	var payload []byte
+	if op.Body != nil {
+		var err error
+		if payload, err = b.service.jsonCodec().Marshal(op.Body); err == nil {
+			payload, err = b.service.wireNames().rename(payload, reflect.TypeOf(op.Body), true)
+		}
+		if err != nil {
+			return fmt.Errorf("encoding batch payload for %s %s: %w", op.Method, op.Path, err)
+		}
+		if b.service.location != nil {
+			payload = b.service.datesToLocal(payload)
+		}
+		buf.WriteString("Content-Type: application/json\r\n")
+		fmt.Fprintf(buf, "Content-Length: %d\r\n", len(payload))
+	}
+	for k, v := range op.Headers {
+		fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(payload)
+	buf.WriteString("\r\n")
+	return nil
+}
+
+// parseResponse maps the multipart response back onto the queued operations
+func (b *Batch) parseResponse(resp *resty.Response) (*BatchResponse, error) {
+	if resp.IsError() {
+		return nil, parseError(resp.Body(), b.service.language())
+	}
+
+	result := &BatchResponse{}
+	err := b.walkResponse(bytes.NewReader(resp.Body()), resp.Header().Get("Content-Type"), func(r *BatchResult) error {
+		result.Results = append(result.Results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// walkResponse reads the multipart response part by part and calls fn with one result
+// per queued operation, in request order
+func (b *Batch) walkResponse(r io.Reader, contentType string, fn func(*BatchResult) error) error {
+	boundary, err := multipartBoundary(contentType)
+	if err != nil {
+		return err
+	}
+
+	mr := multipart.NewReader(r, boundary)
+	for i, p := range b.parts {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return fmt.Errorf("batch response has %d parts, expected %d", i, len(b.parts))
+		}
+		if err != nil {
+			return fmt.Errorf("reading batch response: %w", err)
+		}
+		results, err := readBatchPart(part)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			r.Body = b.service.datesToUTC(r.Body)
+			r.names = b.service.wireNames()
+			r.codec = b.service.jsonCodec()
+			r.language = b.service.language()
+		}
+
+		if p.changeset == nil {
+			if len(results) != 1 {
+				return fmt.Errorf("batch part %d: expected a single response", i)
+			}
+			results[0].Operation = p.operation
+			if err := fn(results[0]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ops := p.changeset.operations
+		switch {
+		case len(results) == len(ops):
+			for j, r := range results {
+				r.Operation = ops[j]
+			}
+		case len(results) == 1:
+			// A failed changeset is answered with a single error response for the whole set
+			failed := results[0]
+			results = make([]*BatchResult, len(ops))
+			for j, op := range ops {
+				r := *failed
+				r.Operation = op
+				r.shared = len(ops) > 1
+				results[j] = &r
+			}
+		default:
+			return fmt.Errorf("changeset in batch part %d has %d responses, expected %d", i, len(results), len(ops))
+		}
+		for _, r := range results {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch _, err := mr.NextPart(); {
+	case err == nil:
+		return fmt.Errorf("batch response has more parts than the %d expected", len(b.parts))
+	case err != io.EOF:
+		return fmt.Errorf("reading batch response: %w", err)
+	}
+	return nil
+}
+
+func readBatchPart(part *multipart.Part) ([]*BatchResult, error) {
+	ct := part.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "multipart/mixed") {
+		r, err := readHTTPResponse(part)
+		if err != nil {
+			return nil, err
+		}
+		return []*BatchResult{r}, nil
+	}
+
+	boundary, err := multipartBoundary(ct)
+	if err != nil {
+		return nil, err
+	}
+	var results []*BatchResult
+	mr := multipart.NewReader(part, boundary)
+	for {
+		inner, err := mr.NextPart()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading changeset response: %w", err)
+		}
+		r, err := readHTTPResponse(inner)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+}
+
+// readHTTPResponse parses an embedded application/http response
+func readHTTPResponse(r io.Reader) (*BatchResult, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(r), nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing batch operation response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading batch operation body: %w", err)
+	}
+	return &BatchResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       bytes.TrimSpace(body),
+	}, nil
+}
+
+func multipartBoundary(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "", fmt.Errorf("unexpected batch response content type %q", contentType)
+	}
+	if params["boundary"] == "" {
+		return "", fmt.Errorf("batch response content type %q has no boundary", contentType)
+	}
+	return params["boundary"], nil
+}
+
+func randomBoundary() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package odata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BatchProgress is reported after every completed $batch request of a BatchExecutor
+type BatchProgress struct {
+	Batches        int // total $batch requests
+	BatchesDone    int
+	Operations     int // total operations
+	OperationsDone int
+	// Failed counts operations that failed, including every operation of a $batch
+	// request that could not be sent
+	Failed int
+}
+
+// BatchExecutor splits a large number of operations into $batch requests and sends several
+// of them concurrently, for loads too big for a single batch
+type BatchExecutor struct {
+	service *Service
+	// BatchSize is the number of operations per $batch request (default 100), lowered to the
+	// service's BatchLimits
+	BatchSize int
+	// Parallelism is the number of $batch requests in flight (default 4)
+	Parallelism int
+	// Atomic puts the modifying operations of each $batch request into one changeset, so they
+	// succeed or fail together. By default every modifying operation gets its own changeset.
+	Atomic bool
+	// Progress, if set, is called after each $batch request; calls are serialized
+	Progress func(BatchProgress)
+}
+
+// NewBatchExecutor returns an executor with default batch size and parallelism
+func (s *Service) NewBatchExecutor() *BatchExecutor {
+	return &BatchExecutor{service: s, BatchSize: 100, Parallelism: 4}
+}
+
+// Execute sends ops and returns their results in input order. Operations must not reference
+// each other through Content-IDs, since they may end up in different $batch requests.
+// Results of a $batch request that failed as a whole are nil and its error is part of the
+// returned error; cancelling ctx stops sending further requests.
+func (e *BatchExecutor) Execute(ctx context.Context, ops []*BatchOperation) (*BatchResponse, error) {
+	size := e.BatchSize
+	if size <= 0 {
+		size = 100
+	}
+	size = e.service.batchLimits.chunkSize(size, e.Atomic)
+	var chunks [][]*BatchOperation
+	for start := 0; start < len(ops); start += size {
+		chunks = append(chunks, ops[start:min(start+size, len(ops))])
+	}
+
+	// Batch results are in request order, which is not input order when GETs are queued
+	// around changesets, so they are mapped back through the operation pointers
+	index := make(map[*BatchOperation]int, len(ops))
+	for i, op := range ops {
+		index[op] = i
+	}
+	results := make([]*BatchResult, len(ops))
+	var mu sync.Mutex
+	progress := BatchProgress{Batches: len(chunks), Operations: len(ops)}
+
+	parallelism := e.Parallelism
+	if parallelism <= 0 {
+		parallelism = 4
+	}
+	run := ParallelForEach(ctx, chunks, parallelism, func(ctx context.Context, chunk []*BatchOperation) error {
+		resp, err := e.send(ctx, chunk)
+
+		mu.Lock()
+		defer mu.Unlock()
+		progress.BatchesDone++
+		progress.OperationsDone += len(chunk)
+		if err != nil {
+			progress.Failed += len(chunk) - len(resp.Results)
+		}
+		for _, r := range resp.Results {
+			results[index[r.Operation]] = r
+			if r.Err() != nil {
+				progress.Failed++
+			}
+		}
+		if e.Progress != nil {
+			e.Progress(progress)
+		}
+		return err
+	})
+
+	var errs []error
+	for _, res := range run.Results {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("batch %d: %w", res.Index, res.Err))
+		}
+	}
+	return &BatchResponse{Results: results}, errors.Join(errs...)
+}
+
+// send executes the $batch request of one chunk, splitting it while the gateway rejects it as
+// too large. The response holds the results of the parts sent successfully, also on error.
+func (e *BatchExecutor) send(ctx context.Context, ops []*BatchOperation) (*BatchResponse, error) {
+	resp, err := e.batch(ctx, ops).Execute()
+	if err == nil {
+		return resp, nil
+	}
+	if len(ops) == 1 || !tooLarge(err) {
+		return &BatchResponse{}, err
+	}
+	size := e.service.batchLimits.shrink(len(ops), e.Atomic)
+	merged := &BatchResponse{}
+	for start := 0; start < len(ops); start += size {
+		part, err := e.send(ctx, ops[start:min(start+size, len(ops))])
+		merged.Results = append(merged.Results, part.Results...)
+		if err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
+
+// batch builds the $batch request for one chunk
+func (e *BatchExecutor) batch(ctx context.Context, ops []*BatchOperation) *Batch {
+	b := e.service.WithContext(ctx).NewBatch()
+	var shared *Changeset
+	for _, op := range ops {
+		if op.Method == http.MethodGet {
+			b.parts = append(b.parts, batchPart{operation: op})
+			continue
+		}
+		cs := shared
+		if cs == nil {
+			cs = b.Changeset()
+			if e.Atomic {
+				shared = cs
+			}
+		}
+		op.ContentID = b.nextContentID()
+		cs.operations = append(cs.operations, op)
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package odata
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// BatchLimits are the most operations a gateway accepts in one $batch request and in one
+// changeset; zero means no known limit
+type BatchLimits struct {
+	Operations          int
+	ChangesetOperations int
+}
+
+// WithBatchLimits sets the batch limits of the system behind the service, which BatchExecutors
+// size their $batch requests and atomic changesets to. Without it the limits are learned: a
+// $batch request rejected with 413 Request Entity Too Large is split in half and sent again,
+// and the smaller size is kept for later requests of the service and its copies.
+func WithBatchLimits(limits BatchLimits) ServiceOption {
+	return func(s *Service) {
+		s.batchLimits = &batchLimits{limits: limits}
+	}
+}
+
+// BatchLimits returns the configured or learned batch limits of the service
+func (s *Service) BatchLimits() BatchLimits {
+	return s.batchLimits.get()
+}
+
+// batchLimits is shared by copies of the Service, so what one learns applies to all
+type batchLimits struct {
+	mu     sync.Mutex
+	limits BatchLimits
+}
+
+func newBatchLimits() *batchLimits {
+	return &batchLimits{}
+}
+
+func (l *batchLimits) get() BatchLimits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// chunkSize caps size by the limits, counting the changeset limit if all modifying
+// operations share one changeset
+func (l *batchLimits) chunkSize(size int, atomic bool) int {
+	limits := l.get()
+	if limits.Operations > 0 {
+		size = min(size, limits.Operations)
+	}
+	if atomic && limits.ChangesetOperations > 0 {
+		size = min(size, limits.ChangesetOperations)
+	}
+	return size
+}
+
+// shrink records that a $batch request of size operations, in one changeset if atomic, was
+// too large, and returns the size to try instead
+func (l *batchLimits) shrink(size int, atomic bool) int {
+	size = max(size/2, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limits.Operations == 0 || size < l.limits.Operations {
+		l.limits.Operations = size
+	}
+	if atomic && (l.limits.ChangesetOperations == 0 || size < l.limits.ChangesetOperations) {
+		l.limits.ChangesetOperations = size
+	}
+	return l.limits.Operations
+}
+
+// tooLarge reports whether err rejected a $batch request for its size
+func tooLarge(err error) bool {
+	var reqErr *RequestError
+	return errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusRequestEntityTooLarge
+}
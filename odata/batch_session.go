@@ -0,0 +1,159 @@
+package odata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SAP soft-state headers: the client asks for a context ID and echoes it on every follow-up call
+const (
+	ContextIDHeader       = "sap-contextid"
+	ContextIDAcceptHeader = "sap-contextid-accept"
+)
+
+// BatchSession runs a sequence of $batch requests within one stateful (soft-state)
+// backend session, for services that build up state server-side across calls,
+// e.g. pricing simulations.
+//
+// Within a session, Content-IDs are unique across all batches, and an operation path
+// may start with a reference to an entity created by an earlier batch ("$3/ToItems");
+// the session rewrites it to the entity's location before sending.
+type BatchSession struct {
+	service *Service
+
+	mu        sync.Mutex
+	contextID string
+	cookies   map[string]*http.Cookie
+	counter   int
+	locations map[string]string // Content-ID -> entity path relative to the service root
+}
+
+// NewBatchSession starts a new stateful session against the service
+func (s *Service) NewBatchSession() *BatchSession {
+	return &BatchSession{
+		service:   s,
+		cookies:   make(map[string]*http.Cookie),
+		locations: make(map[string]string),
+	}
+}
+
+// NewBatch starts a batch whose Content-IDs are allocated from the session
+func (bs *BatchSession) NewBatch() *Batch {
+	b := bs.service.NewBatch()
+	b.contentID = func() string {
+		bs.mu.Lock()
+		defer bs.mu.Unlock()
+		bs.counter++
+		return strconv.Itoa(bs.counter)
+	}
+	b.resolve = bs.resolve
+	return b
+}
+
+// ContextID returns the soft-state context ID issued by the backend, if any
+func (bs *BatchSession) ContextID() string {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.contextID
+}
+
+// Execute sends b within the session. Batches of one session must be executed sequentially.
+func (bs *BatchSession) Execute(b *Batch) (*BatchResponse, error) {
+	bs.mu.Lock()
+	headers := map[string]string{ContextIDAcceptHeader: "header"}
+	if bs.contextID != "" {
+		headers[ContextIDHeader] = bs.contextID
+	}
+	cookies := make([]*http.Cookie, 0, len(bs.cookies))
+	for _, c := range bs.cookies {
+		cookies = append(cookies, c)
+	}
+	bs.mu.Unlock()
+
+	c := b.call()
+	start := time.Now()
+	resp, err := b.send(c, headers, cookies, false)
+	if err != nil {
+		return nil, b.finish(c, start, nil, 0, err)
+	}
+
+	bs.mu.Lock()
+	if id := resp.Header().Get(ContextIDHeader); id != "" {
+		bs.contextID = id
+	}
+	// Pin the session cookies (e.g. SAP_SESSIONID) so follow-up batches hit the same session
+	for _, c := range resp.Cookies() {
+		bs.cookies[c.Name] = c
+	}
+	bs.mu.Unlock()
+
+	result, err := b.parseResponse(resp)
+	if err := b.finish(c, start, resp, int64(len(resp.Body())), err); err != nil {
+		return nil, err
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	for _, r := range result.Results {
+		if r.Operation.ContentID == "" || r.Err() != nil {
+			continue
+		}
+		if loc := bs.service.entityLocation(r); loc != "" {
+			bs.locations[r.Operation.ContentID] = loc
+		}
+	}
+	return result, nil
+}
+
+// resolve rewrites a leading "$<id>" that refers to an entity of a previous batch. Paths are
+// rewritten as the batch is encoded; the queued operations keep theirs.
+func (bs *BatchSession) resolve(path string) string {
+	if !strings.HasPrefix(path, "$") {
+		return path
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	id, rest, _ := strings.Cut(path[1:], "/")
+	loc, ok := bs.locations[id]
+	if !ok {
+		// Either a reference within the same changeset or a system resource like $batch
+		return path
+	}
+	if rest == "" {
+		return loc
+	}
+	return loc + "/" + rest
+}
+
+// entityLocation returns the path of the entity a batch operation created, relative to the service root
+func (s *Service) entityLocation(r *BatchResult) string {
+	loc := r.Header.Get("Location")
+	if loc == "" && len(r.Body) > 0 {
+		var body struct {
+			D struct {
+				Metadata struct {
+					URI string `json:"uri"`
+				} `json:"__metadata"`
+			} `json:"d"`
+		}
+		if json.Unmarshal(r.Body, &body) == nil {
+			loc = body.D.Metadata.URI
+		}
+	}
+	if loc == "" {
+		return ""
+	}
+
+	if u, err := url.Parse(loc); err == nil {
+		loc = u.EscapedPath()
+	}
+	if i := strings.Index(loc, s.servicePath); i >= 0 {
+		return loc[i+len(s.servicePath):]
+	}
+	return loc
+}
@@ -0,0 +1,188 @@
+package odata
+
+import (
+	"bufio"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+// receivedOp is an operation of a $batch request as the gateway received it
+type receivedOp struct {
+	Method    string
+	Target    string
+	ContentID string
+}
+
+// readBatchRequest returns the operations of a $batch request, one slice per part
+func readBatchRequest(t *testing.T, r *http.Request) [][]receivedOp {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("batch content type: %v", err)
+	}
+	var parts [][]receivedOp
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return parts
+		}
+		ct, csParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if ct != "multipart/mixed" {
+			parts = append(parts, []receivedOp{readOperation(t, part)})
+			continue
+		}
+		var ops []receivedOp
+		cs := multipart.NewReader(part, csParams["boundary"])
+		for {
+			inner, err := cs.NextPart()
+			if err != nil {
+				break
+			}
+			ops = append(ops, readOperation(t, inner))
+		}
+		parts = append(parts, ops)
+	}
+}
+
+func readOperation(t *testing.T, part *multipart.Part) receivedOp {
+	t.Helper()
+	// request lines of batch operations are relative to the service root, which
+	// http.ReadRequest does not accept
+	line, err := bufio.NewReader(part).ReadString('\n')
+	fields := strings.Fields(line)
+	if err != nil || len(fields) != 3 {
+		t.Fatalf("batch operation request line %q: %v", line, err)
+	}
+	return receivedOp{Method: fields[0], Target: fields[1], ContentID: part.Header.Get("Content-ID")}
+}
+
+// writeBatchResponse answers a $batch request with parts, each an application/http
+// response or a changeset of them (see changesetPart)
+func writeBatchResponse(w http.ResponseWriter, parts ...string) {
+	w.Header().Set("Content-Type", "multipart/mixed; boundary=batchresponse")
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString("--batchresponse\r\n" + p)
+	}
+	b.WriteString("--batchresponse--\r\n")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// httpPart returns an application/http part holding a response with status, headers
+// given as "Name: value" lines, and body
+func httpPart(status string, body string, headers ...string) string {
+	var b strings.Builder
+	b.WriteString("Content-Type: application/http\r\nContent-Transfer-Encoding: binary\r\n\r\n")
+	b.WriteString("HTTP/1.1 " + status + "\r\n")
+	if body != "" {
+		b.WriteString("Content-Type: application/json\r\n")
+	}
+	for _, h := range headers {
+		b.WriteString(h + "\r\n")
+	}
+	b.WriteString("\r\n" + body + "\r\n")
+	return b.String()
+}
+
+// changesetPart wraps responses into the multipart part of a changeset
+func changesetPart(responses ...string) string {
+	var b strings.Builder
+	b.WriteString("Content-Type: multipart/mixed; boundary=changesetresponse\r\n\r\n")
+	for _, r := range responses {
+		b.WriteString("--changesetresponse\r\n" + r)
+	}
+	b.WriteString("--changesetresponse--\r\n")
+	return b.String()
+}
+
+func TestBatchSession(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		batches  [][][]receivedOp
+		contexts []string
+		cookies  []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		mu.Lock()
+		n := len(batches)
+		batches = append(batches, readBatchRequest(t, r))
+		contexts = append(contexts, r.Header.Get(ContextIDHeader))
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		cookies = append(cookies, strings.Join(names, ";"))
+		mu.Unlock()
+
+		if n == 0 {
+			if r.Header.Get(ContextIDAcceptHeader) != "header" {
+				t.Errorf("first batch did not ask for a context ID")
+			}
+			w.Header().Set(ContextIDHeader, "ctx-1")
+			http.SetCookie(w, &http.Cookie{Name: "SAP_SESSIONID_X", Value: "s1"})
+			writeBatchResponse(w, changesetPart(
+				httpPart("201 Created", `{"d":{"SalesOrderID":"500"}}`, "Location: http://gw/svc/SalesOrderSet('500')"),
+				httpPart("201 Created", `{"d":{"__metadata":{"uri":"http://gw/svc/SalesOrderSet('501')"}}}`),
+			))
+			return
+		}
+		writeBatchResponse(w,
+			httpPart("200 OK", `{"d":{"results":[]}}`),
+			httpPart("200 OK", `{"d":{"SalesOrderID":"501"}}`),
+			httpPart("200 OK", `{"d":{"results":[]}}`),
+		)
+	}))
+	defer srv.Close()
+
+	session := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/").NewBatchSession()
+
+	first := session.NewBatch()
+	cs := first.Changeset()
+	cs.Add(http.MethodPost, "SalesOrderSet", map[string]string{"Customer": "C1"})
+	cs.Add(http.MethodPost, "SalesOrderSet", map[string]string{"Customer": "C2"})
+	if _, err := session.Execute(first); err != nil {
+		t.Fatal(err)
+	}
+	if got := session.ContextID(); got != "ctx-1" {
+		t.Errorf("ContextID() = %q, want ctx-1", got)
+	}
+
+	second := session.NewBatch()
+	second.Query("$1/ToItems", nil)
+	second.Query("$2", nil)
+	second.Query("$3/ToItems", nil) // not an entity of an earlier batch
+	if _, err := session.Execute(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 2 {
+		t.Fatalf("gateway received %d batches, want 2", len(batches))
+	}
+	if ids := []string{batches[0][0][0].ContentID, batches[0][0][1].ContentID}; ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("Content-IDs of the first batch = %v, want [1 2]", ids)
+	}
+	wantTargets := []string{"SalesOrderSet('500')/ToItems", "SalesOrderSet('501')", "$3/ToItems"}
+	for i, want := range wantTargets {
+		if got := batches[1][i][0].Target; got != want {
+			t.Errorf("operation %d of the second batch targets %q, want %q", i, got, want)
+		}
+	}
+	if contexts[1] != "ctx-1" {
+		t.Errorf("second batch sent context ID %q, want ctx-1", contexts[1])
+	}
+	if !strings.Contains(cookies[1], "SAP_SESSIONID_X=s1") {
+		t.Errorf("second batch sent cookies %q, want the session cookie", cookies[1])
+	}
+}
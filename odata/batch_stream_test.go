@@ -0,0 +1,161 @@
+package odata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+// batchResponseBody renders parts the way writeBatchResponse sends them
+func batchResponseBody(parts ...string) string {
+	rec := httptest.NewRecorder()
+	writeBatchResponse(rec, parts...)
+	return rec.Body.String()
+}
+
+func TestBatchWalkResponse(t *testing.T) {
+	var (
+		ok         = httpPart("200 OK", `{"d":{"ID":"1"}}`)
+		created    = httpPart("201 Created", `{"d":{"ID":"2"}}`)
+		noContent  = httpPart("204 No Content", "")
+		badRequest = httpPart("400 Bad Request", `{"error":{"code":"X/1","message":{"lang":"en","value":"invalid"}}}`)
+	)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		statuses    []int
+		wantErr     string
+	}{
+		{
+			name:     "query and changeset",
+			body:     batchResponseBody(ok, changesetPart(created, noContent)),
+			statuses: []int{200, 201, 204},
+		},
+		{
+			name:     "failed changeset",
+			body:     batchResponseBody(ok, badRequest),
+			statuses: []int{200, 400, 400},
+		},
+		{
+			name:    "missing part",
+			body:    batchResponseBody(ok),
+			wantErr: "batch response has 1 parts, expected 2",
+		},
+		{
+			name:    "extra part",
+			body:    batchResponseBody(ok, changesetPart(created, noContent), ok),
+			wantErr: "more parts than the 2 expected",
+		},
+		{
+			name:    "changeset response count",
+			body:    batchResponseBody(ok, changesetPart(created, noContent, noContent)),
+			wantErr: "changeset in batch part 1 has 3 responses, expected 2",
+		},
+		{
+			name:    "changeset answering a query",
+			body:    batchResponseBody(changesetPart(ok, ok), changesetPart(created, noContent)),
+			wantErr: "batch part 0: expected a single response",
+		},
+		{
+			name:    "garbled operation",
+			body:    batchResponseBody("Content-Type: application/http\r\n\r\ngarbage\r\n", changesetPart(created, noContent)),
+			wantErr: "parsing batch operation response",
+		},
+		{
+			name:        "no boundary",
+			contentType: "multipart/mixed",
+			wantErr:     "has no boundary",
+		},
+		{
+			name:        "not multipart",
+			contentType: "application/json",
+			wantErr:     "unexpected batch response content type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewService(client.NewSAPClient("http://localhost", "", ""), "/svc/").NewBatch()
+			b.Query("ProductSet('1')", nil)
+			cs := b.Changeset()
+			cs.Add(http.MethodPost, "ProductSet", map[string]string{"ID": "2"})
+			cs.Add(http.MethodDelete, "ProductSet('3')", nil)
+
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "multipart/mixed; boundary=batchresponse"
+			}
+			var results []*BatchResult
+			err := b.walkResponse(strings.NewReader(tt.body), contentType, func(r *BatchResult) error {
+				results = append(results, r)
+				return nil
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.statuses) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.statuses))
+			}
+			for i, op := range b.Operations() {
+				if results[i].Operation != op {
+					t.Errorf("result %d belongs to %s %s", i, results[i].Operation.Method, results[i].Operation.Path)
+				}
+				if results[i].StatusCode != tt.statuses[i] {
+					t.Errorf("result %d has status %d, want %d", i, results[i].StatusCode, tt.statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBatchStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		parts := make([]string, len(readBatchRequest(t, r)))
+		for i := range parts {
+			parts[i] = httpPart("200 OK", `{"d":{"ID":"`+string(rune('a'+i))+`"}}`)
+		}
+		writeBatchResponse(w, parts...)
+	}))
+	defer srv.Close()
+
+	b := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/").NewBatch()
+	for i := 0; i < 5; i++ {
+		b.Query("ProductSet", nil)
+	}
+
+	var ids []string
+	errStop := errors.New("stop")
+	err := b.Stream(func(r *BatchResult) error {
+		var v struct {
+			D struct{ ID string } `json:"d"`
+		}
+		if err := r.Decode(&v); err != nil {
+			return err
+		}
+		ids = append(ids, v.D.ID)
+		if len(ids) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("Stream returned %v, want the error of the callback", err)
+	}
+	if strings.Join(ids, "") != "abc" {
+		t.Errorf("got results %v before stopping", ids)
+	}
+}
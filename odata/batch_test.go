@@ -0,0 +1,143 @@
+package odata
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+func TestBatchExecute(t *testing.T) {
+	type product struct {
+		ProductID string
+		Name      string
+	}
+	var received [][]receivedOp
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/svc/$batch" {
+			t.Errorf("batch sent as %s %s, want POST /svc/$batch", r.Method, r.URL.Path)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		r.Body = io.NopCloser(strings.NewReader(body))
+		received = readBatchRequest(t, r)
+		writeBatchResponse(w,
+			httpPart("200 OK", `{"d":{"ProductID":"HT-1000","Name":"Notebook"}}`),
+			httpPart("404 Not Found", `{"error":{"code":"/IWBEP/CM_MGW_RT/020","message":{"lang":"en","value":"Resource not found"}}}`),
+			changesetPart(
+				httpPart("201 Created", `{"d":{"ProductID":"HT-2000","Name":"Tablet"}}`),
+				httpPart("204 No Content", ""),
+				httpPart("204 No Content", ""),
+			),
+		)
+	}))
+	defer srv.Close()
+
+	batch := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/").NewBatch()
+	batch.Query("ProductSet('HT-1000')", NewQueryOptions().Select([]string{"ProductID", "Name"}))
+	batch.Query("ProductSet('nope')", nil)
+	cs := batch.Changeset()
+	cs.Add(http.MethodPost, "ProductSet", product{ProductID: "HT-2000", Name: "Tablet"})
+	cs.Add(http.MethodPut, "ProductSet('HT-1001')", product{ProductID: "HT-1001", Name: "Renamed"})
+	cs.Add(http.MethodDelete, "ProductSet('HT-1002')", nil)
+
+	resp, err := batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]receivedOp{
+		{{Method: "GET", Target: "ProductSet('HT-1000')?$select=ProductID,Name"}},
+		{{Method: "GET", Target: "ProductSet('nope')"}},
+		{
+			{Method: "POST", Target: "ProductSet", ContentID: "1"},
+			{Method: "PUT", Target: "ProductSet('HT-1001')", ContentID: "2"},
+			{Method: "DELETE", Target: "ProductSet('HT-1002')", ContentID: "3"},
+		},
+	}
+	if len(received) != len(want) {
+		t.Fatalf("gateway received %d parts, want %d:\n%s", len(received), len(want), body)
+	}
+	for i := range want {
+		if len(received[i]) != len(want[i]) {
+			t.Fatalf("part %d holds %v, want %v", i, received[i], want[i])
+		}
+		for j := range want[i] {
+			got := received[i][j]
+			got.Target = strings.ReplaceAll(got.Target, "%2C", ",")
+			if got != want[i][j] {
+				t.Errorf("part %d operation %d = %+v, want %+v", i, j, got, want[i][j])
+			}
+		}
+	}
+	if !strings.Contains(body, `"Name":"Renamed"`) {
+		t.Errorf("the PUT body is missing from the request:\n%s", body)
+	}
+
+	ops := batch.Operations()
+	if len(resp.Results) != len(ops) {
+		t.Fatalf("%d results for %d operations", len(resp.Results), len(ops))
+	}
+	for i, r := range resp.Results {
+		if r.Operation != ops[i] {
+			t.Errorf("result %d belongs to %s %s, want %s %s", i, r.Operation.Method, r.Operation.Path, ops[i].Method, ops[i].Path)
+		}
+	}
+
+	var got models.ODataResponse[product]
+	if err := resp.Results[0].Decode(&got); err != nil || got.D.Result.Name != "Notebook" {
+		t.Errorf("Decode() of the query = %+v, %v", got.D.Result, err)
+	}
+	if err := resp.Results[1].Err(); err == nil || !strings.Contains(err.Error(), "Resource not found") {
+		t.Errorf("Err() of the failed query = %v, want the gateway message", err)
+	}
+	var created models.ODataResponse[product]
+	if err := resp.Results[2].Decode(&created); err != nil || created.D.Result.ProductID != "HT-2000" || resp.Results[2].StatusCode != http.StatusCreated {
+		t.Errorf("created = %+v (status %d), %v", created.D.Result, resp.Results[2].StatusCode, err)
+	}
+	for _, r := range resp.Results[3:] {
+		if r.StatusCode != http.StatusNoContent || r.Err() != nil {
+			t.Errorf("%s result: status %d, err %v", r.Operation.Method, r.StatusCode, r.Err())
+		}
+	}
+}
+
+func TestBatchEncode(t *testing.T) {
+	batch := NewService(client.NewSAPClient("http://gw", "", ""), "/svc/").NewBatch()
+	batch.Query("ProductSet", NewQueryOptions().Top(2))
+	cs := batch.Changeset()
+	order := cs.Add(http.MethodPost, "SalesOrderSet", map[string]string{"Customer": "C1"})
+	cs.Add(http.MethodPost, "$"+order.ContentID+"/ToItems", map[string]string{"Product": "HT-1000"})
+
+	raw, err := batch.encode("batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(raw)
+	for _, want := range []string{
+		"--batch_test\r\nContent-Type: application/http\r\nContent-Transfer-Encoding: binary\r\n\r\nGET ProductSet?$top=2 HTTP/1.1\r\n",
+		"--batch_test\r\nContent-Type: multipart/mixed; boundary=changeset_",
+		"Content-ID: 1\r\n",
+		"POST SalesOrderSet HTTP/1.1\r\n",
+		"Content-ID: 2\r\n",
+		"POST $1/ToItems HTTP/1.1\r\n",
+		`{"Customer":"C1"}`,
+		"--batch_test--\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("encoded batch lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "GET ProductSet") > strings.Index(body, "POST SalesOrderSet") {
+		t.Error("parts are not encoded in the order they were queued")
+	}
+}
@@ -0,0 +1,111 @@
+package odata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+func TestChangesetErr(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		writeBatchResponse(w,
+			// a rolled back changeset is answered with a single error response
+			httpPart("400 Bad Request", `{"error":{"code":"SY/530","message":{"lang":"en","value":"Quantity must be positive"}}}`, "Content-ID: 2"),
+			httpPart("200 OK", `{"d":{"results":[]}}`),
+			changesetPart(
+				httpPart("201 Created", `{"d":{"SalesOrderID":"500"}}`),
+				httpPart("204 No Content", ""),
+			),
+		)
+	}))
+	defer srv.Close()
+
+	batch := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/").NewBatch()
+	rejected := batch.Changeset()
+	rejected.Add(http.MethodPost, "SalesOrderSet", map[string]string{"Customer": "C1"})
+	bad := rejected.Add(http.MethodPost, "$1/ToItems", map[string]int{"Quantity": -1})
+	batch.Query("ProductSet", nil)
+	applied := batch.Changeset()
+	applied.Add(http.MethodPost, "SalesOrderSet", map[string]string{"Customer": "C2"})
+	applied.Add(http.MethodDelete, "SalesOrderSet('400')", nil)
+
+	resp, err := batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every operation of the rolled back changeset reports the failure
+	for _, r := range resp.Results[:2] {
+		if r.Err() == nil {
+			t.Errorf("%s %s of the rolled back changeset has no error", r.Operation.Method, r.Operation.Path)
+		}
+	}
+
+	err = rejected.Err(resp)
+	var txErr *TxError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("Err() of the rolled back changeset = %v, want a *TxError", err)
+	}
+	if len(txErr.Failures) != 1 {
+		t.Fatalf("failures = %+v, want the single error response once", txErr.Failures)
+	}
+	f := txErr.Failures[0]
+	if f.Operation != bad || f.Index != 1 || f.StatusCode != http.StatusBadRequest {
+		t.Errorf("failure = %+v, want operation 2 with status 400", f)
+	}
+	if !strings.Contains(err.Error(), "operation 2 (POST $1/ToItems): status 400") || !strings.Contains(err.Error(), "Quantity must be positive") {
+		t.Errorf("Error() = %q", err)
+	}
+
+	if err := applied.Err(resp); err != nil {
+		t.Errorf("Err() of the applied changeset = %v, want nil", err)
+	}
+	if ops := applied.Operations(); len(ops) != 2 || ops[1].Method != http.MethodDelete {
+		t.Errorf("Operations() = %v", ops)
+	}
+	if resp.Results[2].Err() != nil {
+		t.Errorf("the query next to the rolled back changeset failed: %v", resp.Results[2].Err())
+	}
+}
+
+func TestChangesetErrPerOperation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") == "Fetch" {
+			w.Header().Set("X-CSRF-Token", "token")
+			return
+		}
+		// some gateways answer every operation of a failed changeset
+		writeBatchResponse(w, changesetPart(
+			httpPart("424 Failed Dependency", `{"error":{"code":"X","message":{"lang":"en","value":"not applied"}}}`),
+			httpPart("409 Conflict", `{"error":{"code":"Y","message":{"lang":"en","value":"already exists"}}}`),
+		))
+	}))
+	defer srv.Close()
+
+	batch := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/").NewBatch()
+	cs := batch.Changeset()
+	cs.Add(http.MethodPut, "ProductSet('A')", map[string]string{})
+	cs.Add(http.MethodPost, "ProductSet", map[string]string{})
+
+	resp, err := batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txErr *TxError
+	if !errors.As(cs.Err(resp), &txErr) || len(txErr.Failures) != 2 {
+		t.Fatalf("Err() = %v, want both operations reported", cs.Err(resp))
+	}
+	for i, f := range txErr.Failures {
+		if f.Index != i || f.Operation != cs.Operations()[i] {
+			t.Errorf("failure %d = %+v", i, f)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSONCodec encodes the payloads a service sends and decodes the entities it returns. Swap
+// encoding/json for a faster library compatible with it, such as json-iterator or sonic,
+// where decoding dominates the CPU time of large extracts.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdJSON is the JSONCodec of encoding/json, used by default
+var StdJSON JSONCodec = stdJSON{}
+
+type stdJSON struct{}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (stdJSON) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// WithJSONCodec makes the service encode payloads and decode results with codec, including
+// the entities StreamEntitySet and the client-side fallback decode. The rewriting of
+// response envelopes the service does itself, such as renaming properties or converting
+// dates, and the tokenizing of streamed responses still use encoding/json.
+func WithJSONCodec(codec JSONCodec) ServiceOption {
+	return func(s *Service) {
+		s.codec = codec
+	}
+}
+
+// jsonCodec returns the codec of the service
+func (s *Service) jsonCodec() JSONCodec {
+	return orStdJSON(s.codec)
+}
+
+// orStdJSON returns codec, or StdJSON for results built without a service
+func orStdJSON(codec JSONCodec) JSONCodec {
+	if codec == nil {
+		return StdJSON
+	}
+	return codec
+}
+
+// resultDecoder is a response envelope whose entities unmarshal decodes itself, as
+// *models.DWrapper provides
+type resultDecoder interface {
+	ResultTarget() interface{}
+	SetPaging(next, count string)
+}
+
+// unmarshal decodes data into v with codec. The DWrapper of a *models.ODataResponse decodes
+// itself with encoding/json, so with another codec its entities are decoded here instead.
+func unmarshal(codec JSONCodec, data []byte, v interface{}) error {
+	codec = orStdJSON(codec)
+	if codec == StdJSON {
+		return json.Unmarshal(data, v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return codec.Unmarshal(data, v)
+	}
+	d := rv.Elem().FieldByName("D")
+	if !d.IsValid() || !d.CanAddr() {
+		return codec.Unmarshal(data, v)
+	}
+	wrapper, ok := d.Addr().Interface().(resultDecoder)
+	if !ok {
+		return codec.Unmarshal(data, v)
+	}
+
+	var envelope struct {
+		D json.RawMessage `json:"d"`
+	}
+	if err := codec.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	if len(envelope.D) == 0 || string(envelope.D) == "null" {
+		return nil
+	}
+	var page struct {
+		Results json.RawMessage `json:"results"`
+		Next    json.RawMessage `json:"__next"`
+		Count   json.RawMessage `json:"__count"`
+	}
+	if err := codec.Unmarshal(envelope.D, &page); err != nil {
+		return err
+	}
+	if page.Results == nil {
+		return codec.Unmarshal(envelope.D, wrapper.ResultTarget())
+	}
+	var next, count string
+	_ = codec.Unmarshal(page.Next, &next)
+	_ = codec.Unmarshal(page.Count, &count)
+	wrapper.SetPaging(next, count)
+	return codec.Unmarshal(page.Results, wrapper.ResultTarget())
+}
+
+// marshalPayload encodes a payload with the service's codec, leaving encoded bodies as they are
+func (s *Service) marshalPayload(payload interface{}) (interface{}, error) {
+	switch payload.(type) {
+	case nil, []byte, string, json.RawMessage:
+		return payload, nil
+	}
+	if s.codec == nil {
+		return payload, nil // encoded by the client
+	}
+	body, err := s.codec.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding payload: %w", err)
+	}
+	return body, nil
+}
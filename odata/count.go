@@ -0,0 +1,69 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CountWhere returns the number of entities of entitySet matching filter, or of all its
+// entities if filter is empty. It reads EntitySet/$count, which answers with the bare
+// number; backends that do not implement $count, as some do not together with a $filter,
+// are asked for $inlinecount=allpages with $top=0 instead. Both requests carry the filter
+// of the service's default query options.
+func CountWhere(s *Service, entitySet, filter string) (int64, error) {
+	query := map[string]string{}
+	if filter != "" {
+		query[OptionFilter] = filter
+	}
+	var n int64
+	err := s.execute(&call{operation: OpCount, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet) + "/$count", query: query}, &n)
+	if err == nil || !countUnsupported(err) {
+		return n, err
+	}
+
+	query[OptionInlineCount] = "allpages"
+	query[OptionTop] = "0"
+	var resp struct {
+		D struct {
+			Count string `json:"__count"`
+		} `json:"d"`
+	}
+	if err := s.execute(&call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: query}, &resp); err != nil {
+		return 0, err
+	}
+	n, err = strconv.ParseInt(resp.D.Count, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid __count %q of %s", resp.D.Count, entitySet)
+	}
+	return n, nil
+}
+
+// Count returns the number of entities of entitySet matching filter, or of all its entities
+// if filter is empty. It is CountWhere under the name of the $count segment it reads.
+func Count(s *Service, entitySet, filter string) (int64, error) {
+	return CountWhere(s, entitySet, filter)
+}
+
+// countUnsupported reports whether a $count request failed because the backend does not
+// implement it: a 404, 405 or 501, or a 400 whose message says so. Other 400s, such as an
+// invalid filter, are returned as they are.
+func countUnsupported(err error) bool {
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		return false
+	}
+	switch reqErr.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusBadRequest:
+		if reqErr.Err == nil {
+			return false
+		}
+		msg := strings.ToLower(reqErr.Err.Error())
+		return strings.Contains(msg, "$count") && (strings.Contains(msg, "support") || strings.Contains(msg, "implement"))
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package odata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+	"github.com/go-resty/resty/v2"
+)
+
+// maxPooledBuffer keeps buffers grown by an occasional huge response out of the pool
+const maxPooledBuffer = 4 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// call is a single SDK request, described for sending and for the metrics hook
+type call struct {
+	operation string
+	entitySet string
+	method    string
+	url       string
+	payload   interface{}
+	query     map[string]string
+	headers   map[string]string
+	// correlationID is assigned by request
+	correlationID string
+	// unbounded exempts the call from the maximum page size
+	unbounded bool
+	// created is the entity returned by a create, kept for event sinks and the audit log
+	created json.RawMessage
+	// refetch keeps the created entity of a create to read it back afterwards
+	refetch bool
+	// execution describes the round trip, for the response
+	execution models.ExecutionInfo
+	// status and header of the response, for the audit log
+	status int
+	header http.Header
+}
+
+// modifies reports whether c changes data on the backend: entity writes, media uploads,
+// $links changes and function imports invoked with POST
+func (c *call) modifies() bool {
+	switch c.operation {
+	case OpCreate, OpUpdate, OpPatch, OpDelete, OpMedia:
+		return true
+	case OpLinks, OpFunction:
+		return c.method != http.MethodGet
+	}
+	return false
+}
+
+// creates reports whether c creates an entity, including a media entity
+func (c *call) creates() bool {
+	return c.operation == OpCreate || c.operation == OpMedia && c.method == http.MethodPost
+}
+
+// request builds the client request for c with the service's headers and query defaults
+// and assigns the correlation ID of c. It fails when c exceeds the maximum page size,
+// carries an invalid $filter or the metadata does not match the pin of the service.
+func (s *Service) request(c *call) (*client.Request, error) {
+	if c.correlationID == "" {
+		c.correlationID = correlationID(s.context())
+	}
+	if s.pin != nil && c.operation != OpMetadata {
+		if err := s.pin.verify(s); err != nil {
+			return nil, err
+		}
+	}
+	defaults := s.defaults != nil && (c.operation == OpList || c.operation == OpGet || c.operation == OpNavigation || c.operation == OpCount)
+	query := c.query
+	if len(s.query) > 0 || defaults {
+		query = make(map[string]string, len(s.query)+len(c.query))
+		merge(query, s.query)
+		if defaults {
+			merge(query, defaultQuery(c, s.defaults.Build()))
+		}
+		merge(query, c.query)
+	}
+	query = s.localQuery(s.formatQuery(c, query))
+	query, err := s.limitPage(c, query)
+	if err != nil {
+		return nil, err
+	}
+	if expr := query[OptionFilter]; s.validateFilter && expr != "" {
+		if err := ValidateFilter(expr); err != nil {
+			return nil, err
+		}
+	}
+	headers := merge(merge(nil, s.headers), c.headers)
+	if headers["If-Match"] == "" {
+		etag, err := s.ifMatch(c)
+		if err != nil {
+			return nil, err
+		}
+		if etag != "" {
+			headers["If-Match"] = etag
+		}
+	}
+	if id := headers[CorrelationHeader]; id != "" {
+		c.correlationID = id
+	} else {
+		headers[CorrelationHeader] = c.correlationID
+	}
+	body := c.payload
+	if c.operation != OpMedia { // media content is sent as it is
+		if body, err = s.marshalPayload(s.wireNames().encode(c.payload, s.jsonCodec())); err != nil {
+			return nil, err
+		}
+		body = s.localPayload(body)
+	}
+	return &client.Request{
+		Method:      c.method,
+		URL:         c.url,
+		Body:        body,
+		QueryParams: query,
+		Headers:     headers,
+		Bulkhead:    s.bulkhead,
+	}, nil
+}
+
+// defaultQuery returns the default options that apply to c. A $count only takes the filter
+// and custom parameters, as paging and projection options would change or break it.
+func defaultQuery(c *call, defaults map[string]string) map[string]string {
+	if c.operation != OpCount {
+		return defaults
+	}
+	defaults = merge(nil, defaults) // Build caches its map
+	for _, option := range []string{OptionTop, OptionSkip, OptionSkipToken, OptionOrderBy, OptionSelect, OptionExpand, OptionInlineCount, OptionFormat} {
+		delete(defaults, option)
+	}
+	return defaults
+}
+
+// send executes c, leaving the response body unread for the caller to stream and close
+func (s *Service) send(c *call) (*resty.Response, error) {
+	req, err := s.request(c)
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = true
+	return s.client.Do(s.context(), req)
+}
+
+// execute sends c between the entity hooks and validation of the service, decodes a
+// successful response into out (skipped when out is nil) and publishes the write events
+func (s *Service) execute(c *call, out interface{}) error {
+	if err := s.runHooks(s.beforeHooks(c), c, nil); err != nil {
+		return err
+	}
+	if s.validate != nil && (c.operation == OpCreate || c.operation == OpUpdate) {
+		if err := s.validate(c.payload); err != nil {
+			return err
+		}
+	}
+	var old map[string]json.RawMessage
+	if s.audit.audited(c) {
+		old = s.audit.before(s, c)
+	}
+	start := time.Now()
+	err := s.roundTrip(c, out)
+	if s.audit.audited(c) {
+		if auditErr := s.audit.record(s, c, start, old, err); auditErr != nil && err == nil {
+			err = auditErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if c.operation == OpCreate {
+		if err := s.runHooks(s.hooks.afterCreate, c, out); err != nil {
+			return err
+		}
+	}
+	return s.publish(c)
+}
+
+// roundTrip sends c and decodes the response for execute. The body is read into a pooled
+// buffer instead of a fresh slice per response; json.Unmarshal copies what it keeps, so the
+// buffer can be reused as soon as decoding returns.
+func (s *Service) roundTrip(c *call, out interface{}) (err error) {
+	start := time.Now()
+	status, size := 0, int64(0)
+	stats := &client.RequestStats{}
+	defer func() {
+		c.execution = models.ExecutionInfo{
+			Duration:      time.Since(start),
+			Attempts:      stats.Attempts,
+			CSRFRefreshes: stats.CSRFRefreshes,
+			BytesSent:     stats.BytesSent,
+			BytesReceived: size,
+		}
+		s.observe(c, start, status, size, err)
+		err = s.fail(c, start, status, err)
+	}()
+
+	req, err := s.request(c)
+	if err != nil {
+		return err
+	}
+	if err := s.checkProjection(c, req.QueryParams, out); err != nil {
+		return err
+	}
+	req.Stream = true
+	req.Stats = stats
+	fb, query, err := s.planFallback(c, req.QueryParams)
+	if err != nil {
+		return err
+	}
+	req.QueryParams = query
+	resp, err := s.client.Do(s.context(), req)
+	if err != nil {
+		return err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	status = resp.StatusCode()
+	c.status, c.header = status, resp.Header()
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	size, err = buf.ReadFrom(body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	s.rememberETag(c, status, resp.Header(), buf.Bytes())
+	if resp.IsError() {
+		return parseError(buf.Bytes(), s.language())
+	}
+	if c.creates() && (len(s.sinks) > 0 || s.audit != nil || c.refetch) {
+		c.created = createdEntity(s.datesToUTC(buf.Bytes()))
+	}
+	if c.operation == OpGet && noEntity(status, buf.Bytes()) {
+		return ErrNotFound
+	}
+	if out == nil || status == http.StatusNoContent {
+		return nil
+	}
+	data := buf.Bytes()
+	if err := checkJSON(resp.Header().Get("Content-Type"), data); err != nil {
+		return err
+	}
+	if s.limits.enabled() {
+		lc := limitCounter{limits: s.limits}
+		if err := lc.check(data, 0, false); err != nil {
+			return err
+		}
+	}
+	if fb != nil {
+		var more int64
+		data, more, err = s.drain(req, data)
+		size += more
+		if err != nil {
+			return err
+		}
+		// before datesToUTC: the $filter literals were converted to backend time by localQuery
+		if data, err = fb.apply(data); err != nil {
+			return err
+		}
+	}
+	data = s.datesToUTC(data)
+	if err := s.wireNames().unmarshal(s.codec, data, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
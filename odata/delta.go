@@ -0,0 +1,45 @@
+package odata
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DeltaPage is one page of an entity set read with its paging and delta links, as used to
+// replicate entity sets (see package datasync)
+type DeltaPage struct {
+	Entities []json.RawMessage
+	// Deleted holds the tombstones of the entities deleted since the delta token of the
+	// request, which SAP delta queries return in d.__deleted
+	Deleted []json.RawMessage
+	// Next is the __next link of the following page, empty on the last page
+	Next string
+	// Delta is the __delta link on the last page of an entity set with delta query support;
+	// reading it later returns what changed since this read
+	Delta string
+}
+
+// GetDeltaPage reads a page of entitySet with opts, or, if link is not empty, the page a
+// Next or Delta link of an earlier page points to. Links are followed on the service's
+// own host; opts do not apply to them since the link carries the query.
+func GetDeltaPage(s *Service, entitySet string, opts *QueryOptions, link string) (*DeltaPage, error) {
+	c := &call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: queryParams(opts), unbounded: opts.unboundedAllowed()}
+	if link != "" {
+		if err := s.follow(c, link); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp struct {
+		D struct {
+			Results []json.RawMessage `json:"results"`
+			Deleted []json.RawMessage `json:"__deleted"`
+			Next    string            `json:"__next"`
+			Delta   string            `json:"__delta"`
+		} `json:"d"`
+	}
+	if err := s.execute(c, &resp); err != nil {
+		return nil, err
+	}
+	return &DeltaPage{Entities: resp.D.Results, Deleted: resp.D.Deleted, Next: resp.D.Next, Delta: resp.D.Delta}, nil
+}
@@ -0,0 +1,120 @@
+package odata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CorrelationHeader carries the correlation ID of every request, so a failed call can be
+// found in the gateway logs (/IWFND/ERROR_LOG) and in the caller's own logs
+const CorrelationHeader = "X-CorrelationID"
+
+type correlationKey struct{}
+
+// WithCorrelationID makes requests sent with ctx use id instead of a generated correlation ID,
+// e.g. to propagate the ID of an incoming request
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns the ID set by WithCorrelationID
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// RequestError wraps every error returned by a request of the odata package with the request
+// it belongs to. Use errors.As to reach the *models.ODataErrorResponse of an SAP error.
+type RequestError struct {
+	Method        string
+	URL           string
+	EntitySet     string
+	Operation     string
+	StatusCode    int // zero when no response was received
+	CorrelationID string
+	Elapsed       time.Duration
+	Err           error
+}
+
+func (e *RequestError) Error() string {
+	msg := fmt.Sprintf("odata: %s %s", e.Method, e.URL)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d", e.StatusCode)
+	} else {
+		msg += " (no response"
+	}
+	msg += fmt.Sprintf(", %s, correlation %s): %v", e.Elapsed.Round(time.Millisecond), e.CorrelationID, e.Err)
+	return msg
+}
+
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// Is makes a 404 response match ErrNotFound, like a read that found no entity
+func (e *RequestError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// fail wraps err, if any, in a RequestError for c
+func (s *Service) fail(c *call, start time.Time, status int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RequestError{
+		Method:        c.method,
+		URL:           c.url,
+		EntitySet:     c.entitySet,
+		Operation:     c.operation,
+		StatusCode:    status,
+		CorrelationID: c.correlationID,
+		Elapsed:       time.Since(start),
+		Err:           err,
+	}
+}
+
+// correlationID returns the ID for the next request: the one in ctx or a new random one
+func correlationID(ctx context.Context) string {
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		return id
+	}
+	return randomBoundary()
+}
+
+// ErrNotFound is returned when a read by key succeeds without an entity, which some services
+// answer for a missing record instead of 404: a 204, an empty body or an empty d object
+var ErrNotFound = errors.New("odata: entity not found")
+
+// noEntity reports whether a successful single entity response holds no entity
+func noEntity(status int, body []byte) bool {
+	if status == http.StatusNoContent || len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+	var resp struct {
+		D json.RawMessage `json:"d"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return false // left to decoding to report
+	}
+	d := bytes.TrimSpace(resp.D)
+	if len(d) == 0 || string(d) == "null" {
+		return true
+	}
+	var props map[string]json.RawMessage
+	return d[0] == '{' && len(d) < 64 && json.Unmarshal(d, &props) == nil && len(props) == 0
+}
+
+// ErrXMLResponse is returned when a successful response is XML (Atom) instead of JSON,
+// which gateways ignoring the Accept header send; create the service WithJSONFormat
+var ErrXMLResponse = errors.New("odata: the service answered with XML instead of JSON, try odata.WithJSONFormat")
+
+// checkJSON reports an XML body with ErrXMLResponse instead of a JSON syntax error
+func checkJSON(contentType string, body []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("<")) {
+		return fmt.Errorf("%w (Content-Type %q)", ErrXMLResponse, contentType)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package odata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ETagStore keeps the ETags of the entities read and written through a service, keyed by
+// the entity's URL path. Implementations shared between instances of an application let
+// one instance update what another one read without losing optimistic concurrency.
+type ETagStore interface {
+	// Get returns the ETag stored for key and whether there is one
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, etag string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryETagStore is an ETagStore for a single instance. Entries are kept until the entity
+// is deleted or its ETag goes stale.
+type MemoryETagStore struct {
+	mu    sync.RWMutex
+	etags map[string]string
+}
+
+// NewMemoryETagStore returns an empty in-memory ETagStore
+func NewMemoryETagStore() *MemoryETagStore {
+	return &MemoryETagStore{etags: make(map[string]string)}
+}
+
+func (m *MemoryETagStore) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	etag, ok := m.etags[key]
+	return etag, ok, nil
+}
+
+func (m *MemoryETagStore) Set(_ context.Context, key, etag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.etags[key] = etag
+	return nil
+}
+
+func (m *MemoryETagStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.etags, key)
+	return nil
+}
+
+// WithETagStore makes the service remember the ETags of the entities it reads, creates and
+// updates in store (a MemoryETagStore if nil) and send them as If-Match with updates and
+// deletes of the same entities, so concurrent changes fail with 412 Precondition Failed
+// instead of being overwritten. ETags that turn out stale are forgotten.
+func WithETagStore(store ETagStore) ServiceOption {
+	if store == nil {
+		store = NewMemoryETagStore()
+	}
+	return func(s *Service) {
+		s.etags = store
+	}
+}
+
+// ifMatch returns the stored ETag to send with c, if it modifies an entity
+func (s *Service) ifMatch(c *call) (string, error) {
+	if s.etags == nil || (c.operation != OpUpdate && c.operation != OpPatch && c.operation != OpDelete) {
+		return "", nil
+	}
+	etag, ok, err := s.etags.Get(s.context(), c.url)
+	if err != nil {
+		return "", fmt.Errorf("looking up ETag of %s: %w", c.url, err)
+	}
+	if !ok {
+		return "", nil
+	}
+	return etag, nil
+}
+
+// rememberETag updates the store with the outcome of c. Store failures are not reported:
+// the request itself succeeded, and a missing ETag only leaves the If-Match off a later write.
+func (s *Service) rememberETag(c *call, status int, header http.Header, body []byte) {
+	if s.etags == nil {
+		return
+	}
+	ctx := s.context()
+	switch {
+	case status == http.StatusPreconditionFailed:
+		_ = s.etags.Delete(ctx, c.url)
+	case status >= 400:
+	case c.operation == OpDelete:
+		_ = s.etags.Delete(ctx, c.url)
+	case c.operation == OpGet, c.operation == OpUpdate, c.operation == OpPatch:
+		etag := header.Get("ETag")
+		if etag == "" && c.operation == OpGet {
+			etag, _ = entityETag(body)
+		}
+		if etag == "" {
+			_ = s.etags.Delete(ctx, c.url) // changed, so what is stored is stale
+			return
+		}
+		_ = s.etags.Set(ctx, c.url, etag)
+	case c.operation == OpCreate:
+		etag, uri := entityETag(body)
+		if etag == "" {
+			etag = header.Get("ETag")
+		}
+		if uri == "" {
+			uri = header.Get("Location")
+		}
+		if u, err := url.Parse(uri); err == nil && u.Path != "" && etag != "" {
+			_ = s.etags.Set(ctx, u.EscapedPath(), etag)
+		}
+	}
+}
+
+// entityETag returns the __metadata etag and uri of a single entity response
+func entityETag(body []byte) (etag, uri string) {
+	var resp struct {
+		D struct {
+			Metadata struct {
+				URI  string `json:"uri"`
+				ETag string `json:"etag"`
+			} `json:"__metadata"`
+		} `json:"d"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return "", ""
+	}
+	return resp.D.Metadata.ETag, resp.D.Metadata.URI
+}
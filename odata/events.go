@@ -0,0 +1,148 @@
+package odata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WriteEvent describes a successful write, for event sinks: a create, update or delete, a
+// media upload, a $links change or a function import invoked with POST
+type WriteEvent struct {
+	Operation string // OpCreate, OpUpdate, OpPatch, OpDelete, OpMedia, OpLinks or OpFunction
+	EntitySet string
+	// Key is the key predicate of the entity, e.g. ('HT-1000'). For creates it is taken from
+	// the __metadata uri of the response, if there is one.
+	Key string
+	URL string
+	// Payload is a JSON snapshot of the payload sent, nil for deletes and media content
+	Payload json.RawMessage
+	// Entity is the JSON of the entity returned by a create
+	Entity        json.RawMessage
+	CorrelationID string
+	Time          time.Time
+}
+
+// EventSink receives the write events of a service, e.g. to publish them to Kafka, NATS or
+// a webhook for downstream consumers
+type EventSink interface {
+	Publish(ctx context.Context, e *WriteEvent) error
+}
+
+// EventSinkFunc adapts a function to an EventSink
+type EventSinkFunc func(ctx context.Context, e *WriteEvent) error
+
+func (f EventSinkFunc) Publish(ctx context.Context, e *WriteEvent) error {
+	return f(ctx, e)
+}
+
+// WithEventSink publishes an event to sink after every successful write of the service, see
+// WriteEvent. Sinks are called in the order they were added.
+func WithEventSink(sink EventSink) ServiceOption {
+	return func(s *Service) {
+		s.sinks = append(slices.Clip(s.sinks), sink)
+	}
+}
+
+// PublishError is returned when a write succeeded but its event could not be published
+type PublishError struct {
+	Event *WriteEvent
+	Err   error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("publishing %s event for %s%s: %v", e.Event.Operation, e.Event.EntitySet, e.Event.Key, e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// publish passes the event of c, which succeeded, to the sinks of the service
+func (s *Service) publish(c *call) error {
+	if len(s.sinks) == 0 {
+		return nil
+	}
+	if !c.modifies() {
+		return nil
+	}
+
+	e := &WriteEvent{
+		Operation:     c.operation,
+		EntitySet:     c.entitySet,
+		Key:           s.entityKey(c),
+		URL:           c.url,
+		Entity:        c.created,
+		CorrelationID: c.correlationID,
+		Time:          time.Now(),
+	}
+	if c.payload != nil && c.operation != OpMedia { // media content is not JSON
+		switch p := c.payload.(type) {
+		case json.RawMessage:
+			e.Payload = append(json.RawMessage(nil), p...)
+		case []byte:
+			e.Payload = append(json.RawMessage(nil), p...)
+		default:
+			e.Payload, _ = json.Marshal(p)
+		}
+	}
+	for _, sink := range s.sinks {
+		if err := sink.Publish(s.context(), e); err != nil {
+			return &PublishError{Event: e, Err: err}
+		}
+	}
+	return nil
+}
+
+// entityKey returns the key predicate of the entity c writes. For creates it is taken from
+// the __metadata uri of the created entity, if known.
+func (s *Service) entityKey(c *call) string {
+	if !c.creates() {
+		if c.operation == OpFunction {
+			return ""
+		}
+		// the predicate ends the first path segment, e.g. of Set('1')/$value
+		predicate, _, _ := strings.Cut(strings.TrimPrefix(c.url, s.buildURL(c.entitySet)), "/")
+		return unescapeKey(predicate)
+	}
+	var created struct {
+		Metadata struct {
+			URI string `json:"uri"`
+		} `json:"__metadata"`
+	}
+	_ = json.Unmarshal(c.created, &created)
+	return uriKey(created.Metadata.URI)
+}
+
+// uriKey returns the key predicate at the end of an entity's __metadata uri
+func uriKey(uri string) string {
+	if strings.HasSuffix(uri, ")") {
+		if i := strings.LastIndex(uri, "("); i >= 0 {
+			return unescapeKey(uri[i:])
+		}
+	}
+	return ""
+}
+
+// unescapeKey returns a key predicate of a URL as the caller would write it
+func unescapeKey(predicate string) string {
+	if key, err := url.PathUnescape(predicate); err == nil {
+		return key
+	}
+	return predicate
+}
+
+// createdEntity returns a copy of the entity in a create response, for WriteEvent.Entity
+func createdEntity(body []byte) json.RawMessage {
+	var resp struct {
+		D json.RawMessage `json:"d"`
+	}
+	if json.Unmarshal(body, &resp) != nil || len(resp.D) == 0 {
+		return nil
+	}
+	return append(json.RawMessage(nil), resp.D...)
+}
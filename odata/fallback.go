@@ -0,0 +1,341 @@
+package odata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/internal/filter"
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+// WithClientSideFallback makes entity set reads return the requested $filter and $orderby
+// even where the backend does not support them. Options on properties the metadata marks
+// sap:filterable="false" or sap:sortable="false" are not sent but applied to the fetched
+// entities, together with $skip and $top, which then fetches the whole (server filtered)
+// set, following the __next links of server-side paging; the __count of $inlinecount is
+// corrected to the entities the local $filter kept. Options that are sent are left to the
+// backend and its collation. Entities the local $filter cannot evaluate, e.g. with
+// functions or operands it does not support, are kept; strings compare case-sensitively.
+// Every fallback is logged as a warning to logger (slog.Default() if nil), once per entity
+// set and option. StreamEntitySet sends its options as they are.
+func WithClientSideFallback(logger *slog.Logger) ServiceOption {
+	return func(s *Service) {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		s.fallback = &fallbackLog{logger: logger}
+		if s.meta == nil {
+			s.meta = &metadataCache{}
+		}
+	}
+}
+
+// fallbackLog warns once per entity set and reason; it is shared by copies of the Service
+type fallbackLog struct {
+	logger *slog.Logger
+	warned sync.Map
+}
+
+func (l *fallbackLog) warn(entitySet, option, reason string) {
+	if _, seen := l.warned.LoadOrStore(entitySet+"\x00"+option+"\x00"+reason, true); seen {
+		return
+	}
+	l.logger.Warn("OData option applied client-side", "entitySet", entitySet, "option", option, "reason", reason)
+}
+
+// fallback is the client-side part of a collection read
+type fallback struct {
+	log       *fallbackLog
+	codec     JSONCodec
+	entitySet string
+	where     filter.Node
+	terms     []filter.OrderTerm
+	// stripped lists the options not sent, with the reason; skip and top are then applied
+	// locally
+	stripped  map[string]string
+	skip, top int
+}
+
+// planFallback removes the options of query the backend cannot handle and returns what
+// to apply to the response. It returns nil when every option is sent. Removing options
+// reads the whole set, which WithMaxPageSize refuses with ErrPageSizeExceeded.
+func (s *Service) planFallback(c *call, query map[string]string) (*fallback, map[string]string, error) {
+	if s.fallback == nil || c.operation != OpList {
+		return nil, query, nil
+	}
+	fb := &fallback{log: s.fallback, codec: s.jsonCodec(), entitySet: c.entitySet, top: -1}
+	if expr := query[OptionFilter]; expr != "" {
+		where, err := filter.Parse(expr)
+		if err != nil {
+			return nil, query, nil // let the gateway report it
+		}
+		fb.where = where
+	}
+	if clause := query[OptionOrderBy]; clause != "" {
+		terms, err := filter.ParseOrderBy(clause)
+		if err != nil {
+			return nil, query, nil
+		}
+		fb.terms = terms
+	}
+	if fb.where == nil && fb.terms == nil {
+		return nil, query, nil
+	}
+
+	reason := fb.unsupported(s.entityType(c.entitySet))
+	if len(reason) == 0 {
+		return nil, query, nil
+	}
+	if s.maxPageSize > 0 && !c.unbounded {
+		return nil, nil, fmt.Errorf("%w: applying %s client-side reads all of %s (use QueryOptions.Unbounded to allow it)", ErrPageSizeExceeded, strings.Join(slices.Sorted(maps.Keys(reason)), " and "), c.entitySet)
+	}
+	query = merge(nil, query)
+	fb.stripped = reason
+	for option := range reason {
+		delete(query, option)
+	}
+	fb.skip, _ = strconv.Atoi(query[OptionSkip])
+	if top, err := strconv.Atoi(query[OptionTop]); err == nil {
+		fb.top = top
+	}
+	delete(query, OptionSkip)
+	delete(query, OptionTop)
+	// options that are sent are applied by the backend, in its collation
+	if reason[OptionFilter] == "" {
+		fb.where = nil
+	}
+	if reason[OptionOrderBy] == "" {
+		fb.terms = nil
+	}
+	return fb, fb.selectReferenced(query), nil
+}
+
+// selectReferenced makes sure a $select returns the properties the fallback evaluates
+func (fb *fallback) selectReferenced(query map[string]string) map[string]string {
+	sel := query[OptionSelect]
+	if sel == "" || sel == "*" {
+		return query
+	}
+	selected := make(map[string]bool)
+	for _, p := range strings.Split(sel, ",") {
+		selected[strings.TrimSpace(p)] = true
+	}
+	var missing []string
+	var referenced []string
+	if fb.where != nil {
+		referenced = filter.Properties(fb.where)
+	}
+	for _, t := range fb.terms {
+		referenced = append(referenced, t.Property)
+	}
+	for _, p := range referenced {
+		if root, _, _ := strings.Cut(p, "/"); !selected[root] {
+			selected[root] = true
+			missing = append(missing, root)
+		}
+	}
+	if len(missing) == 0 {
+		return query
+	}
+	query = merge(nil, query)
+	query[OptionSelect] = sel + "," + strings.Join(missing, ",")
+	return query
+}
+
+// entityType returns the metadata of entitySet's type, or nil if it is not available
+func (s *Service) entityType(entitySet string) *metadata.EntityType {
+	doc, err := GetMetadata(s)
+	if err != nil {
+		return nil
+	}
+	_, et, err := doc.EntitySet(entitySet)
+	if err != nil {
+		return nil
+	}
+	return et
+}
+
+// unsupported returns the options of fb referencing properties et marks as not filterable
+// or sortable, with the offending property. It also marks the numeric sort terms.
+func (fb *fallback) unsupported(et *metadata.EntityType) map[string]string {
+	if et == nil {
+		return nil
+	}
+	reason := make(map[string]string)
+	if fb.where != nil {
+		for _, name := range filter.Properties(fb.where) {
+			if p, ok := et.Property(name); ok && !p.Filterable() {
+				reason[OptionFilter] = name + " is not filterable"
+				break
+			}
+		}
+	}
+	for i, t := range fb.terms {
+		p, ok := et.Property(t.Property)
+		if !ok {
+			continue
+		}
+		fb.terms[i].Numeric = p.Type == "Edm.Decimal" || p.Type == "Edm.Int64"
+		if !p.Sortable() && reason[OptionOrderBy] == "" {
+			reason[OptionOrderBy] = t.Property + " is not sortable"
+		}
+	}
+	return reason
+}
+
+// fallbackEntity is an entity of a response as sent and as decoded for evaluation
+type fallbackEntity struct {
+	raw    json.RawMessage
+	values map[string]interface{}
+}
+
+// apply filters, sorts and pages the entities of a collection response body as planned,
+// and sets its __count to the entities the filter kept. The entities kept are copied as the
+// backend encoded them.
+func (fb *fallback) apply(body []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := fb.codec.Unmarshal(body, &envelope); err != nil {
+		return body, nil // left to the regular decoding to report
+	}
+	var d map[string]json.RawMessage
+	rawResults := envelope["d"]
+	if err := fb.codec.Unmarshal(rawResults, &d); err == nil {
+		rawResults = d["results"]
+	}
+	var raws []json.RawMessage
+	if err := fb.codec.Unmarshal(rawResults, &raws); err != nil {
+		return body, nil
+	}
+	entities := make([]fallbackEntity, len(raws))
+	for i, raw := range raws {
+		entities[i].raw = raw
+		if err := fb.codec.Unmarshal(raw, &entities[i].values); err != nil {
+			return body, nil
+		}
+	}
+
+	if fb.where != nil {
+		kept := make([]fallbackEntity, 0, len(entities))
+		for _, e := range entities {
+			ok, err := filter.Match(fb.where, e.values)
+			if err != nil {
+				fb.log.warn(fb.entitySet, OptionFilter, "entities kept unfiltered: "+err.Error())
+				ok = true // not verifiable locally
+			}
+			if ok {
+				kept = append(kept, e)
+			}
+		}
+		fb.log.warn(fb.entitySet, OptionFilter, fb.stripped[OptionFilter])
+		entities = kept
+	}
+	if fb.terms != nil {
+		fb.log.warn(fb.entitySet, OptionOrderBy, fb.stripped[OptionOrderBy])
+		slices.SortStableFunc(entities, func(x, y fallbackEntity) int {
+			return filter.CompareEntities(x.values, y.values, fb.terms)
+		})
+	}
+	count := len(entities)
+	entities = entities[min(fb.skip, len(entities)):]
+	if fb.top >= 0 && fb.top < len(entities) {
+		entities = entities[:fb.top]
+	}
+
+	var results bytes.Buffer
+	results.WriteByte('[')
+	for i, e := range entities {
+		if i > 0 {
+			results.WriteByte(',')
+		}
+		results.Write(e.raw)
+	}
+	results.WriteByte(']')
+	var err error
+	if d != nil {
+		d["results"] = results.Bytes()
+		if _, ok := d["__count"]; ok {
+			d["__count"] = json.RawMessage(strconv.Quote(strconv.Itoa(count)))
+		}
+		if envelope["d"], err = fb.codec.Marshal(d); err != nil {
+			return nil, err
+		}
+	} else {
+		envelope["d"] = results.Bytes()
+	}
+	return fb.codec.Marshal(envelope)
+}
+
+// drain reads the pages following the first one of a read with a fallback, since the
+// options applied client-side must see the whole set. It returns the entities of all pages
+// in one body without __next, and the number of bytes read for the further pages.
+func (s *Service) drain(req *client.Request, body []byte) ([]byte, int64, error) {
+	codec := s.jsonCodec()
+	var envelope map[string]json.RawMessage
+	var d map[string]json.RawMessage
+	if codec.Unmarshal(body, &envelope) != nil || codec.Unmarshal(envelope["d"], &d) != nil {
+		return body, 0, nil // left to the regular decoding to report
+	}
+	var next string
+	if codec.Unmarshal(d["__next"], &next) != nil || next == "" {
+		return body, 0, nil
+	}
+	var results []json.RawMessage
+	if err := codec.Unmarshal(d["results"], &results); err != nil {
+		return body, 0, nil
+	}
+
+	var size int64
+	for next != "" {
+		var c call
+		if err := s.follow(&c, next); err != nil {
+			return nil, size, err
+		}
+		pageReq := *req
+		pageReq.URL, pageReq.QueryParams = c.url, c.query
+		pageReq.Stream, pageReq.Stats = false, nil
+		resp, err := s.client.Do(s.context(), &pageReq)
+		if err != nil {
+			return nil, size, err
+		}
+		size += int64(len(resp.Body()))
+		if resp.IsError() {
+			return nil, size, parseError(resp.Body(), s.language())
+		}
+		if s.limits.enabled() {
+			lc := limitCounter{limits: s.limits}
+			if err := lc.check(resp.Body(), 0, false); err != nil {
+				return nil, size, err
+			}
+		}
+		var page struct {
+			D struct {
+				Results []json.RawMessage `json:"results"`
+				Next    string            `json:"__next"`
+			} `json:"d"`
+		}
+		if err := codec.Unmarshal(resp.Body(), &page); err != nil {
+			return nil, size, fmt.Errorf("decoding response: %w", err)
+		}
+		results = append(results, page.D.Results...)
+		next = page.D.Next
+	}
+
+	delete(d, "__next")
+	var err error
+	if d["results"], err = codec.Marshal(results); err != nil {
+		return nil, size, err
+	}
+	if envelope["d"], err = codec.Marshal(d); err != nil {
+		return nil, size, err
+	}
+	body, err = codec.Marshal(envelope)
+	return body, size, err
+}
@@ -0,0 +1,164 @@
+package odata
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+const fallbackMetadata = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="1.0" xmlns:edmx="http://schemas.microsoft.com/ado/2007/06/edmx" xmlns:m="http://schemas.microsoft.com/ado/2007/08/dataservices/metadata" xmlns:sap="http://www.sap.com/Protocols/SAPData">
+  <edmx:DataServices m:DataServiceVersion="2.0">
+    <Schema Namespace="ZSHOP" xmlns="http://schemas.microsoft.com/ado/2008/09/edm">
+      <EntityType Name="Product">
+        <Key><PropertyRef Name="ProductID"/></Key>
+        <Property Name="ProductID" Type="Edm.String" Nullable="false"/>
+        <Property Name="Name" Type="Edm.String" sap:sortable="false"/>
+        <Property Name="Stock" Type="Edm.Int32" sap:filterable="false"/>
+        <Property Name="Category" Type="Edm.String"/>
+      </EntityType>
+      <EntityContainer Name="ZSHOP_Entities" m:IsDefaultEntityContainer="true">
+        <EntitySet Name="ProductSet" EntityType="ZSHOP.Product"/>
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+type fallbackProduct struct {
+	ProductID string
+	Name      string
+	Stock     int
+	Category  string
+}
+
+// newFallbackServer serves ProductSet in two pages of server-side paging, ignoring $filter
+// and $orderby, and returns the queries it received
+func newFallbackServer(t *testing.T, logs *bytes.Buffer) (*Service, func() []string) {
+	t.Helper()
+	pages := [][]fallbackProduct{
+		{{"A", "pear", 10, "b"}, {"B", "Apple", 3, "a"}, {"C", "banana", 7, "a"}},
+		{{"D", "cherry", 1, "c"}, {"E", "Date", 20, "b"}, {"F", "fig", 6, "a"}},
+	}
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/$metadata") {
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(fallbackMetadata))
+			return
+		}
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		page := 0
+		if r.URL.Query().Get(OptionSkipToken) == "3" {
+			page = 1
+		}
+		d := map[string]interface{}{"results": pages[page]}
+		if page == 0 {
+			d["__next"] = "/svc/ProductSet?$skiptoken=3"
+		}
+		if r.URL.Query().Get(OptionInlineCount) == "allpages" {
+			d["__count"] = "6"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"d": d})
+	}))
+	t.Cleanup(srv.Close)
+	service := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/",
+		WithClientSideFallback(slog.New(slog.NewTextHandler(logs, nil))))
+	return service, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func productIDs(products []fallbackProduct) string {
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ProductID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestClientSideFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      *QueryOptions
+		want      string
+		wantCount int64 // -1 without $inlinecount
+		wantPages int
+		wantSent  []string // options that must reach the server
+		wantWarn  bool
+	}{
+		{
+			name:      "filter over all pages with count and paging",
+			opts:      NewQueryOptions().Filter("Stock gt 5").InlineCount(true).Skip(1).Top(2),
+			want:      "C,E",
+			wantCount: 4,
+			wantPages: 2,
+			wantSent:  []string{OptionInlineCount},
+			wantWarn:  true,
+		},
+		{
+			name:      "orderby on a property that is not sortable",
+			opts:      NewQueryOptions().OrderBy("Name", true),
+			want:      "B,E,C,D,F,A",
+			wantCount: -1,
+			wantPages: 2,
+			wantWarn:  true,
+		},
+		{
+			name:      "sent orderby keeps the backend collation",
+			opts:      NewQueryOptions().OrderBy("Category", true),
+			want:      "A,B,C",
+			wantCount: -1,
+			wantPages: 1,
+			wantSent:  []string{OptionOrderBy},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			service, queries := newFallbackServer(t, &logs)
+			resp, err := GetEntitySet[fallbackProduct](service, "ProductSet", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := productIDs(resp.D.Result); got != tt.want {
+				t.Errorf("products = %s, want %s", got, tt.want)
+			}
+			count, ok := resp.TotalCount()
+			if tt.wantCount >= 0 && (!ok || count != tt.wantCount) {
+				t.Errorf("TotalCount() = %d, %v, want %d", count, ok, tt.wantCount)
+			}
+			if tt.wantPages == 2 && resp.NextLink() != "" {
+				t.Errorf("NextLink() = %q after all pages were read", resp.NextLink())
+			}
+			sent := queries()
+			if len(sent) != tt.wantPages {
+				t.Errorf("server received %d requests, want %d: %q", len(sent), tt.wantPages, sent)
+			}
+			for _, option := range []string{OptionFilter, OptionOrderBy, OptionTop, OptionSkip, OptionInlineCount} {
+				want := false
+				for _, o := range tt.wantSent {
+					want = want || o == option
+				}
+				if got := strings.Contains(sent[0], strings.TrimPrefix(option, "$")+"="); got != want {
+					t.Errorf("%s sent = %v, want %v: %s", option, got, want, sent[0])
+				}
+			}
+			if got := strings.Contains(logs.String(), "applied client-side"); got != tt.wantWarn {
+				t.Errorf("warning logged = %v, want %v: %s", got, tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Willias7788/go-odata-v2-sdk/internal/filter"
+)
+
+// FilterError reports an invalid $filter expression found by ValidateFilter
+type FilterError struct {
+	Expr string
+	Pos  int // byte offset of the problem in Expr
+	Msg  string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid $filter at position %d: %s (near %q)", e.Pos, e.Msg, e.near())
+}
+
+// near returns the part of the expression around the problem
+func (e *FilterError) near() string {
+	start, end := max(e.Pos-10, 0), min(e.Pos+20, len(e.Expr))
+	if start > end {
+		return ""
+	}
+	return e.Expr[start:end]
+}
+
+// ValidateFilter checks the syntax of a $filter expression: balanced parentheses, known
+// operators and functions with their argument counts, and the format of literals such as
+// datetime'...' or guid'...'. Property names are not checked.
+func ValidateFilter(expr string) error {
+	if _, err := filter.Parse(expr); err != nil {
+		var se *filter.SyntaxError
+		if errors.As(err, &se) {
+			return &FilterError{Expr: expr, Pos: se.Pos, Msg: se.Msg}
+		}
+		return err
+	}
+	return nil
+}
+
+// WithFilterValidation checks the $filter of every request with ValidateFilter before it
+// is sent, so a typo fails locally with its position instead of with the gateway's generic 400
+func WithFilterValidation() ServiceOption {
+	return func(s *Service) {
+		s.validateFilter = true
+	}
+}
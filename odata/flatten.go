@@ -0,0 +1,121 @@
+package odata
+
+import "sort"
+
+// Flatten joins every parent with each of its children into a row, e.g. sales orders read with
+// $expand=ToItems into one line per item for an export. Parents without children are left
+// out; see FlattenLeft to keep them.
+//
+//	lines := odata.Flatten(orders, func(o Order) []Item { return o.ToItems.Results }, toLine)
+func Flatten[P, C, R any](parents []P, children func(P) []C, row func(P, C) R) []R {
+	var out []R
+	for _, p := range parents {
+		for _, c := range children(p) {
+			out = append(out, row(p, c))
+		}
+	}
+	return out
+}
+
+// FlattenLeft is Flatten keeping parents without children as a single row with the zero
+// child, like a left join
+func FlattenLeft[P, C, R any](parents []P, children func(P) []C, row func(P, C) R) []R {
+	var out []R
+	for _, p := range parents {
+		cs := children(p)
+		if len(cs) == 0 {
+			var zero C
+			out = append(out, row(p, zero))
+			continue
+		}
+		for _, c := range cs {
+			out = append(out, row(p, c))
+		}
+	}
+	return out
+}
+
+// FlattenRows turns untyped entities with expanded navigation properties into flat rows, for
+// tabular output without a struct per parent-child pair. Properties of an expanded entity
+// become columns named by their path, e.g. "ToCustomer/Name"; an expanded collection yields
+// one row per child ("ToItems/Quantity"), or one row without its columns if it is empty.
+// Several expanded collections of one entity are multiplied out. __metadata and deferred
+// navigation properties are dropped.
+//
+// Entities decode into the map[string]interface{} form used here with
+// GetEntitySet[map[string]interface{}] or RawEntities.Decode.
+func FlattenRows(entities []map[string]interface{}) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, e := range entities {
+		out = append(out, flattenEntity("", e)...)
+	}
+	return out
+}
+
+// flattenEntity returns the rows of entity e, with columns prefixed by prefix
+func flattenEntity(prefix string, e map[string]interface{}) []map[string]interface{} {
+	rows := []map[string]interface{}{{}}
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names) // for a stable row order when collections are multiplied out
+
+	for _, name := range names {
+		if name == "__metadata" {
+			continue
+		}
+		column := prefix + name
+		switch v := e[name].(type) {
+		case map[string]interface{}:
+			if _, deferred := v["__deferred"]; deferred {
+				continue
+			}
+			if results, ok := v["results"].([]interface{}); ok {
+				rows = crossJoin(rows, flattenCollection(column+"/", results))
+				continue
+			}
+			rows = crossJoin(rows, flattenEntity(column+"/", v))
+		case []interface{}:
+			rows = crossJoin(rows, flattenCollection(column+"/", v))
+		default:
+			for _, r := range rows {
+				r[column] = v
+			}
+		}
+	}
+	return rows
+}
+
+// flattenCollection returns the rows of the entities of an expanded collection
+func flattenCollection(prefix string, items []interface{}) []map[string]interface{} {
+	var rows []map[string]interface{}
+	for _, item := range items {
+		if child, ok := item.(map[string]interface{}); ok {
+			rows = append(rows, flattenEntity(prefix, child)...)
+		}
+	}
+	return rows
+}
+
+// crossJoin combines every row with every one of more, keeping rows as they are if more is
+// empty
+func crossJoin(rows, more []map[string]interface{}) []map[string]interface{} {
+	if len(more) == 0 {
+		return rows
+	}
+	out := make([]map[string]interface{}, 0, len(rows)*len(more))
+	for _, r := range rows {
+		for _, m := range more {
+			joined := make(map[string]interface{}, len(r)+len(m))
+			for k, v := range r {
+				joined[k] = v
+			}
+			for k, v := range m {
+				joined[k] = v
+			}
+			out = append(out, joined)
+		}
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+// RawLiteral is passed through FormatLiteral unchanged, for literals the Go type
+// cannot express such as guid'...' or decimals (12.5M)
+type RawLiteral string
+
+// FormatLiteral renders a Go value as an OData V2 URI literal:
+// strings are quoted with embedded quotes doubled, time.Time becomes datetime'2006-01-02T15:04:05'
+func FormatLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case RawLiteral:
+		return string(v)
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, uint, uint8, uint16, uint32:
+		return fmt.Sprint(v)
+	case int64:
+		return strconv.FormatInt(v, 10) + "L"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "L"
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case time.Time:
+		return "datetime'" + v.Format("2006-01-02T15:04:05") + "'"
+	case models.ODataDateTime:
+		return "datetime'" + v.UTC().Format("2006-01-02T15:04:05") + "'"
+	case fmt.Stringer:
+		return FormatLiteral(v.String())
+	default:
+		return FormatLiteral(fmt.Sprint(v))
+	}
+}
+
+// CallFunction invokes a function import with GET (or POST for side-effecting functions
+// declared with m:HttpMethod="POST"). Parameters are rendered with FormatLiteral.
+//
+// Collection results decode like GetEntitySet (T = []Entity); for results paged by the
+// server this is the first page only, see CallFunctionSeq. Complex and primitive results
+// are wrapped by the server in an object named after the function, e.g. {"d":{"GetPrice":{...}}},
+// so T should be a struct with that field.
+func CallFunction[T any](s *Service, name, method string, params map[string]interface{}) (*models.ODataResponse[T], error) {
+	var result models.ODataResponse[T]
+	c := functionCall(s, name, method, params)
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
+	}
+	result.Execution = c.execution
+	return &result, nil
+}
+
+// functionCall describes the call of a function import
+func functionCall(s *Service, name, method string, params map[string]interface{}) *call {
+	if method == "" {
+		method = http.MethodGet
+	}
+	query := make(map[string]string, len(params))
+	for k, v := range params {
+		query[k] = FormatLiteral(v)
+	}
+	return &call{operation: OpFunction, entitySet: name, method: strings.ToUpper(method), url: s.buildURL(name), query: query}
+}
+
+// CallFunctionPage calls a function import returning a collection of T and returns the first
+// page of the result, or, if link is not empty, the page a Next link of an earlier page
+// points to. Function imports are paged by the server like entity sets.
+func CallFunctionPage[T any](s *Service, name, method string, params map[string]interface{}, link string) (*Page[T], error) {
+	return readPage[T](s, functionCall(s, name, method, params), link)
+}
+
+// CallFunctionSeq calls a function import returning a collection of T and yields its
+// entities, following the __next links of server-side paging as the iteration proceeds
+func CallFunctionSeq[T any](s *Service, name, method string, params map[string]interface{}) iter.Seq2[T, error] {
+	return seqPages(func(link string) (*Page[T], error) {
+		return CallFunctionPage[T](s, name, method, params, link)
+	})
+}
@@ -0,0 +1,75 @@
+package odata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+// PingOption customizes Service.Ping
+type PingOption func(*pingConfig)
+
+type pingConfig struct {
+	metadata bool
+}
+
+// WithMetadataCheck makes Ping also verify that $metadata can be retrieved
+func WithMetadataCheck() PingOption {
+	return func(c *pingConfig) {
+		c.metadata = true
+	}
+}
+
+// Ping verifies that the service document is reachable with the configured credentials
+// and, optionally, that $metadata is available. The probes carry the headers and query
+// parameters of the service, such as sap-client. The report is suitable for readiness probes.
+func (s *Service) Ping(ctx context.Context, opts ...PingOption) *client.HealthReport {
+	cfg := &pingConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	report := client.NewHealthReport()
+	reach, auth := s.probe(ctx, s.servicePath, nil)
+	report.Add(reach)
+	if !reach.OK {
+		return report
+	}
+	report.Add(auth)
+	if !auth.OK {
+		return report
+	}
+	report.Add(statusCheck("service", reach))
+
+	if cfg.metadata {
+		mReach, _ := s.probe(ctx, s.buildURL("$metadata"), map[string]string{"Accept": "application/xml"})
+		if !mReach.OK {
+			mReach.Name = "metadata"
+			report.Add(mReach)
+		} else {
+			report.Add(statusCheck("metadata", mReach))
+		}
+	}
+	return report
+}
+
+// probe probes url with the headers and query parameters of the service's requests. Probes
+// are not checked against the metadata pin.
+func (s *Service) probe(ctx context.Context, url string, headers map[string]string) (reachable, authenticated client.CheckResult) {
+	req, err := s.WithContext(ctx).request(&call{operation: OpMetadata, method: http.MethodGet, url: url, headers: headers})
+	if err != nil {
+		return client.CheckResult{Name: "reachability", Error: err.Error()}, client.CheckResult{Name: "authentication"}
+	}
+	return s.client.ProbeRequest(ctx, req)
+}
+
+// statusCheck turns a successful probe into a named check that fails on HTTP errors
+func statusCheck(name string, probe client.CheckResult) client.CheckResult {
+	c := client.CheckResult{Name: name, StatusCode: probe.StatusCode, LatencyMS: probe.LatencyMS, OK: probe.StatusCode < 400}
+	if !c.OK {
+		c.Error = fmt.Sprintf("unexpected status %d", probe.StatusCode)
+	}
+	return c
+}
@@ -0,0 +1,78 @@
+package odata
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// EntityEvent describes a create or update passed to entity hooks
+type EntityEvent struct {
+	Operation string // OpCreate, OpUpdate or OpPatch
+	EntitySet string
+	URL       string
+	// Payload is sent to the server. Before hooks may replace it, e.g. with a copy carrying
+	// audit fields.
+	Payload interface{}
+	// Result is the decoded response of a create (a *models.ODataResponse[T]), for AfterCreate
+	Result interface{}
+}
+
+// EntityHook is called around creates and updates; returning an error fails the call
+type EntityHook func(ctx context.Context, e *EntityEvent) error
+
+type entityHooks struct {
+	beforeCreate []EntityHook
+	afterCreate  []EntityHook
+	beforeUpdate []EntityHook
+}
+
+// WithBeforeCreate runs h before every CreateEntity and CreateNavigationEntity of the service.
+// An error aborts the create before anything is sent.
+func WithBeforeCreate(h EntityHook) ServiceOption {
+	return func(s *Service) {
+		s.hooks.beforeCreate = append(slices.Clip(s.hooks.beforeCreate), h)
+	}
+}
+
+// WithAfterCreate runs h after every successful create, e.g. to invalidate a cache. The entity
+// exists at that point even if h returns an error.
+func WithAfterCreate(h EntityHook) ServiceOption {
+	return func(s *Service) {
+		s.hooks.afterCreate = append(slices.Clip(s.hooks.afterCreate), h)
+	}
+}
+
+// WithBeforeUpdate runs h before every UpdateEntity and PatchEntity of the service.
+// An error aborts the update before anything is sent.
+func WithBeforeUpdate(h EntityHook) ServiceOption {
+	return func(s *Service) {
+		s.hooks.beforeUpdate = append(slices.Clip(s.hooks.beforeUpdate), h)
+	}
+}
+
+// runHooks calls hooks with the event of c and stores a replaced payload back into c
+func (s *Service) runHooks(hooks []EntityHook, c *call, result interface{}) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	e := &EntityEvent{Operation: c.operation, EntitySet: c.entitySet, URL: c.url, Payload: c.payload, Result: result}
+	for _, h := range hooks {
+		if err := h(s.context(), e); err != nil {
+			return fmt.Errorf("%s hook for %s: %w", c.operation, c.entitySet, err)
+		}
+	}
+	c.payload = e.Payload
+	return nil
+}
+
+// beforeHooks returns the hooks to run before c is sent
+func (s *Service) beforeHooks(c *call) []EntityHook {
+	switch c.operation {
+	case OpCreate:
+		return s.hooks.beforeCreate
+	case OpUpdate, OpPatch:
+		return s.hooks.beforeUpdate
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package odata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key returns the key predicate of an entity with a single key property, e.g. ('4711') for
+// a string or (10) for an int, rendering value with FormatLiteral. A % in value is written
+// as %25, so it is not taken for an escape.
+func Key(value interface{}) string {
+	return "(" + keyLiteral(value) + ")"
+}
+
+// CompositeKey returns the key predicate of an entity with several key properties, e.g.
+// (Item=10,OrderID='4711'). Properties are ordered by name, which gateways accept. Values
+// are rendered like those of Key.
+func CompositeKey(values map[string]interface{}) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + keyLiteral(values[name])
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// keyLiteral renders a key value with its % escaped, see escapeKey
+func keyLiteral(value interface{}) string {
+	return strings.ReplaceAll(FormatLiteral(value), "%", "%25")
+}
+
+// escapeKey percent-encodes a key predicate for the resource path. Quotes, parentheses,
+// commas and = keep the predicate readable; inside quoted literals everything but
+// unreserved characters and colons is encoded, so spaces, slashes, %, &, + and non-ASCII
+// characters of string keys reach the gateway intact. A % followed by two hex digits is
+// taken as an escape already made, e.g. by a key copied from a URL, and kept, so a key
+// holding such a literal must pass its % as %25.
+func escapeKey(predicate string) string {
+	var b strings.Builder
+	inQuote := false
+	for i := 0; i < len(predicate); i++ {
+		c := predicate[i]
+		switch {
+		case c == '\'':
+			inQuote = !inQuote // a doubled quote leaves and reenters the literal
+			b.WriteByte(c)
+		case unreserved(c) || c == ':':
+			b.WriteByte(c)
+		case c == '%' && escaped(predicate[i:]):
+			b.WriteString(predicate[i : i+3])
+			i += 2
+		case !inQuote && strings.IndexByte("(),=+", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// escaped reports whether s starts with a percent-encoded byte
+func escaped(s string) bool {
+	return len(s) >= 3 && s[0] == '%' && ishex(s[1]) && ishex(s[2])
+}
+
+func ishex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unreserved reports whether c may appear unencoded anywhere in a URL (RFC 3986)
+func unreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0
+}
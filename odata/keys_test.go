@@ -0,0 +1,64 @@
+package odata
+
+import "testing"
+
+func TestEscapeKey(t *testing.T) {
+	tests := []struct {
+		predicate string
+		want      string
+	}{
+		{"('4711')", "('4711')"},
+		{"(10)", "(10)"},
+		{"(OrderID='4711',Item=10)", "(OrderID='4711',Item=10)"},
+		{"('A B')", "('A%20B')"},
+		{"('a/b')", "('a%2Fb')"},
+		{"('50%')", "('50%25')"},
+		{"('50%2')", "('50%252')"},
+		{"('100%zz')", "('100%25zz')"},
+		{"('A%20B')", "('A%20B')"},
+		{"('a%2Fb%2fc d')", "('a%2Fb%2fc%20d')"},
+		{"('M%C3%BCller')", "('M%C3%BCller')"},
+		{"(OrderID='4711'%2CItem=10)", "(OrderID='4711'%2CItem=10)"},
+		{"('x&y=z')", "('x%26y%3Dz')"},
+		{"('1+1')", "('1%2B1')"},
+		{"('a,b(c)')", "('a%2Cb%28c%29')"},
+		{"('O''Neil')", "('O''Neil')"},
+		{"('O''Neil, Jr.')", "('O''Neil%2C%20Jr.')"},
+		{"('Müller')", "('M%C3%BCller')"},
+		{"('~_-.')", "('~_-.')"},
+		{"(Date=datetime'2024-01-02T03:04:05')", "(Date=datetime'2024-01-02T03:04:05')"},
+		{"(Id=guid'0a1b2c3d-0000-0000-0000-000000000000')", "(Id=guid'0a1b2c3d-0000-0000-0000-000000000000')"},
+		{"(12.5M)", "(12.5M)"},
+		{"(-1)", "(-1)"},
+		{"('#?')", "('%23%3F')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.predicate, func(t *testing.T) {
+			if got := escapeKey(tt.predicate); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyPredicate(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"'4711'", "('4711')"},
+		{"('4711')", "('4711')"},
+		{"'a b'", "('a%20b')"},
+		{Key("x/y"), "('x%2Fy')"},
+		{Key("50%20"), "('50%2520')"},
+		{CompositeKey(map[string]interface{}{"Code": "A%2F"}), "(Code='A%252F')"},
+		{CompositeKey(map[string]interface{}{"OrderID": "4711", "Item": 10}), "(Item=10,OrderID='4711')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := keyPredicate(tt.key); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package odata
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DecodeLimits bound what a single response may contain, to protect the caller from
+// runaway $expand results. Zero values mean unlimited.
+type DecodeLimits struct {
+	// MaxEntities caps the entities in a response, including expanded ones
+	MaxEntities int
+	// MaxDepth caps the JSON nesting depth of the response
+	MaxDepth int
+}
+
+// DecodeLimitError is returned when a response exceeds the service's DecodeLimits
+type DecodeLimitError struct {
+	Limit string // "entities" or "depth"
+	Max   int
+}
+
+func (e *DecodeLimitError) Error() string {
+	return fmt.Sprintf("odata: response exceeds the %s limit of %d", e.Limit, e.Max)
+}
+
+// WithDecodeLimits returns a shallow copy of the service that rejects responses exceeding l
+func (s *Service) WithDecodeLimits(l DecodeLimits) *Service {
+	s2 := *s
+	s2.limits = l
+	return &s2
+}
+
+// limitCounter checks JSON against DecodeLimits before it is unmarshalled. It counts
+// the objects inside "results" arrays (and a bare d array), which is where V2 puts entities.
+type limitCounter struct {
+	limits   DecodeLimits
+	entities int
+}
+
+func (l DecodeLimits) enabled() bool {
+	return l.MaxEntities > 0 || l.MaxDepth > 0
+}
+
+// check scans data, found at nesting level depth; entity reports whether data is itself
+// an element of a results array
+func (c *limitCounter) check(data []byte, depth int, entity bool) error {
+	type frame struct{ results bool }
+	var stack []frame
+	var key []byte
+	for i := 0; i < len(data); i++ {
+		switch ch := data[i]; ch {
+		case '"':
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+			}
+			key = data[i+1 : min(j, len(data))]
+			i = j
+		case '{', '[':
+			inResults := entity
+			if len(stack) > 0 {
+				inResults = stack[len(stack)-1].results
+			}
+			if ch == '{' && inResults {
+				c.entities++
+				if c.limits.MaxEntities > 0 && c.entities > c.limits.MaxEntities {
+					return &DecodeLimitError{Limit: "entities", Max: c.limits.MaxEntities}
+				}
+			}
+			level := depth + len(stack)
+			results := ch == '[' && (bytes.Equal(key, []byte("results")) || (level == 1 && bytes.Equal(key, []byte("d"))))
+			stack = append(stack, frame{results: results})
+			if c.limits.MaxDepth > 0 && level+1 > c.limits.MaxDepth {
+				return &DecodeLimitError{Limit: "depth", Max: c.limits.MaxDepth}
+			}
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package odata
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimitCounterCheck(t *testing.T) {
+	const (
+		list     = `{"d":{"results":[{"ID":"1"},{"ID":"2"},{"ID":"3"}]}}`
+		bare     = `{"d":[{"ID":"1"},{"ID":"2"}]}`
+		expanded = `{"d":{"results":[{"ID":"1","ToItems":{"results":[{"N":1},{"N":2}]}}]}}`
+		single   = `{"d":{"ID":"1","ToSupplier":{"ID":"S"}}}`
+		escaped  = `{"d":{"results":[{"Text":"{\"results\":[{}]}"},{"Text":"[[[["}]}}`
+	)
+	tests := []struct {
+		name   string
+		data   string
+		depth  int
+		entity bool
+		limits DecodeLimits
+		want   string // the limit exceeded, "" for none
+	}{
+		{"list within entities", list, 0, false, DecodeLimits{MaxEntities: 3}, ""},
+		{"list over entities", list, 0, false, DecodeLimits{MaxEntities: 2}, "entities"},
+		{"bare d array", bare, 0, false, DecodeLimits{MaxEntities: 1}, "entities"},
+		{"expanded entities count", expanded, 0, false, DecodeLimits{MaxEntities: 2}, "entities"},
+		{"expanded within entities", expanded, 0, false, DecodeLimits{MaxEntities: 3}, ""},
+		{"single entity is not counted", single, 0, false, DecodeLimits{MaxEntities: 1}, ""},
+		{"strings are skipped", escaped, 0, false, DecodeLimits{MaxEntities: 2, MaxDepth: 4}, ""},
+		{"list within depth", list, 0, false, DecodeLimits{MaxDepth: 4}, ""},
+		{"list over depth", list, 0, false, DecodeLimits{MaxDepth: 3}, "depth"},
+		{"expanded over depth", expanded, 0, false, DecodeLimits{MaxDepth: 6}, "depth"},
+		{"expanded within depth", expanded, 0, false, DecodeLimits{MaxDepth: 7}, ""},
+		{"streamed entity", `{"ID":"1"}`, 3, true, DecodeLimits{MaxDepth: 4}, ""},
+		{"streamed entity over depth", `{"ID":"1","ToSupplier":{}}`, 3, true, DecodeLimits{MaxDepth: 4}, "depth"},
+		{"streamed entity over entities", `{"ID":"1","ToItems":{"results":[{}]}}`, 3, true, DecodeLimits{MaxEntities: 1}, "entities"},
+		{"unlimited", expanded, 0, false, DecodeLimits{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &limitCounter{limits: tt.limits}
+			err := c.check([]byte(tt.data), tt.depth, tt.entity)
+			var le *DecodeLimitError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("got %v, want no error", err)
+			case tt.want != "" && !errors.As(err, &le):
+				t.Fatalf("got %v, want a *DecodeLimitError", err)
+			case tt.want != "" && le.Limit != tt.want:
+				t.Errorf("got the %s limit, want %s", le.Limit, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitCounterAcrossChecks(t *testing.T) {
+	// a stream checks one entity at a time; the count carries over
+	c := &limitCounter{limits: DecodeLimits{MaxEntities: 2}}
+	for i, want := range []bool{false, false, true} {
+		err := c.check([]byte(`{"ID":"1"}`), 3, true)
+		if got := err != nil; got != want {
+			t.Fatalf("entity %d: got error %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Links address the relationships of an entity rather than the related entities, as
+// EntitySet(key)/$links/NavigationProperty. Relinking an entity this way does not rewrite
+// either entity. Targets are sent as {"uri": "TargetSet(key)"}, relative to the service root.
+
+// GetLinks returns the URIs of the entities navProperty of an entity links to: one for a
+// to-one property, if set, and any number for a to-many property
+func GetLinks(s *Service, entitySet, key, navProperty string, opts *QueryOptions) ([]string, error) {
+	var resp struct {
+		D struct {
+			Results json.RawMessage `json:"results"`
+			URI     string          `json:"uri"`
+		} `json:"d"`
+	}
+	c := &call{operation: OpLinks, entitySet: entitySet, method: http.MethodGet, url: s.linksURL(entitySet, key, navProperty), query: queryParams(opts)}
+	if err := s.execute(c, &resp); err != nil {
+		return nil, err
+	}
+	if resp.D.Results == nil {
+		if resp.D.URI == "" {
+			return nil, nil
+		}
+		return []string{resp.D.URI}, nil
+	}
+	var links []struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(resp.D.Results, &links); err != nil {
+		return nil, fmt.Errorf("decoding links of %s: %w", navProperty, err)
+	}
+	uris := make([]string, len(links))
+	for i, l := range links {
+		uris[i] = l.URI
+	}
+	return uris, nil
+}
+
+// AddLink links an entity to the entity targetKey of targetSet through the to-many
+// property navProperty (POST)
+func AddLink(s *Service, entitySet, key, navProperty, targetSet, targetKey string) error {
+	return s.execute(&call{operation: OpLinks, entitySet: entitySet, method: http.MethodPost, url: s.linksURL(entitySet, key, navProperty), payload: s.linkPayload(targetSet, targetKey)}, nil)
+}
+
+// SetLink points the to-one property navProperty of an entity to the entity targetKey of
+// targetSet (PUT)
+func SetLink(s *Service, entitySet, key, navProperty, targetSet, targetKey string) error {
+	return s.execute(&call{operation: OpLinks, entitySet: entitySet, method: http.MethodPut, url: s.linksURL(entitySet, key, navProperty), payload: s.linkPayload(targetSet, targetKey)}, nil)
+}
+
+// DeleteLink removes the link of navProperty to the entity targetKey of a to-many property,
+// or the link of a to-one property if targetKey is empty. The entities themselves remain.
+func DeleteLink(s *Service, entitySet, key, navProperty, targetKey string) error {
+	url := s.linksURL(entitySet, key, navProperty)
+	if targetKey != "" {
+		url += keyPredicate(targetKey)
+	}
+	return s.execute(&call{operation: OpLinks, entitySet: entitySet, method: http.MethodDelete, url: url}, nil)
+}
+
+func (s *Service) linksURL(entitySet, key, navProperty string) string {
+	return s.buildKeyURL(entitySet, key) + "/$links/" + navProperty
+}
+
+func (s *Service) linkPayload(targetSet, targetKey string) map[string]string {
+	return map[string]string{"uri": s.keyPath(targetSet, targetKey)}
+}
@@ -0,0 +1,143 @@
+package odata
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+// MediaChecksum verifies downloaded content: the bytes are fed to Hash and the
+// result must equal Sum (hex encoded), e.g. {Hash: sha256.New(), Sum: "9f86d0..."}
+type MediaChecksum struct {
+	Hash hash.Hash
+	Sum  string
+}
+
+// DownloadMedia streams the media resource (EntitySet(key)/$value) of a media link entry to w
+// without buffering it, returning the number of bytes written
+func DownloadMedia(s *Service, entitySet, key string, w io.Writer) (n int64, err error) {
+	c := &call{
+		operation: OpMedia,
+		entitySet: entitySet,
+		method:    http.MethodGet,
+		url:       s.buildKeyURL(entitySet, key) + "/$value",
+		headers:   map[string]string{"Accept": "*/*"},
+	}
+	start := time.Now()
+	status := 0
+	defer func() {
+		s.observe(c, start, status, n, err)
+		err = s.fail(c, start, status, err)
+	}()
+
+	resp, err := s.send(c)
+	if err != nil {
+		return 0, err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	status = resp.StatusCode()
+
+	if resp.IsError() {
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return 0, fmt.Errorf("reading response: %w", err)
+		}
+		return 0, parseError(raw, s.language())
+	}
+
+	n, err = io.Copy(w, body)
+	if err != nil {
+		return n, fmt.Errorf("downloading media: %w", err)
+	}
+	return n, nil
+}
+
+// CreateMedia creates a media link entry in entitySet by posting content as the media
+// resource, with contentType as its Content-Type and slug, e.g. a file name, as the Slug
+// header the backend may derive the key or properties from. It returns the created entry.
+// The content is read into memory first, so that the request can be repeated after a CSRF
+// token refresh or a retry.
+func CreateMedia[T any](s *Service, entitySet string, content io.Reader, contentType, slug string) (*models.ODataResponse[T], error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, fmt.Errorf("reading media content: %w", err)
+	}
+	headers := map[string]string{"Content-Type": contentType}
+	if slug != "" {
+		headers["Slug"] = slugHeader(slug)
+	}
+	var result models.ODataResponse[T]
+	c := &call{operation: OpMedia, entitySet: entitySet, method: http.MethodPost, url: s.buildURL(entitySet), payload: data, headers: headers}
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
+	}
+	result.Execution = c.execution
+	return &result, nil
+}
+
+// UpdateMedia replaces the media resource of an entity (PUT EntitySet(key)/$value) with
+// content of type contentType. Like CreateMedia, it reads content into memory first.
+func UpdateMedia(s *Service, entitySet, key string, content io.Reader, contentType string) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("reading media content: %w", err)
+	}
+	return s.execute(&call{operation: OpMedia, entitySet: entitySet, method: http.MethodPut, url: s.buildKeyURL(entitySet, key) + "/$value", payload: data, headers: map[string]string{"Content-Type": contentType}}, nil)
+}
+
+// slugHeader percent-encodes a Slug as RFC 5023 asks, so non-ASCII file names survive
+func slugHeader(slug string) string {
+	var b strings.Builder
+	for i := 0; i < len(slug); i++ {
+		if c := slug[i]; c < 0x20 || c >= 0x7f || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// DownloadMediaToFile streams the media resource to path. The content is written to a
+// temporary file next to path and renamed once complete (and verified against checksum,
+// when given), so path never holds a partial download.
+func DownloadMediaToFile(s *Service, entitySet, key, path string, checksum *MediaChecksum) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("creating file: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after the rename
+
+	var w io.Writer = f
+	if checksum != nil {
+		checksum.Hash.Reset()
+		w = io.MultiWriter(f, checksum.Hash)
+	}
+	n, err := DownloadMedia(s, entitySet, key, w)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("writing file: %w", cerr)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if checksum != nil {
+		if got := hex.EncodeToString(checksum.Hash.Sum(nil)); !strings.EqualFold(got, checksum.Sum) {
+			return n, fmt.Errorf("checksum mismatch for %s: got %s, expected %s", path, got, checksum.Sum)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return n, fmt.Errorf("renaming file: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,135 @@
+package odata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+// GetMetadataXML fetches the raw $metadata document, e.g. to store it as a snapshot for metadata.Diff
+func GetMetadataXML(s *Service) (raw []byte, err error) {
+	c := &call{
+		operation: OpMetadata,
+		entitySet: "$metadata",
+		method:    http.MethodGet,
+		url:       s.buildURL("$metadata"),
+		headers:   map[string]string{"Accept": "application/xml"},
+	}
+	start := time.Now()
+	status := 0
+	defer func() {
+		s.observe(c, start, status, int64(len(raw)), err)
+		err = s.fail(c, start, status, err)
+	}()
+
+	req, err := s.request(c)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(s.context(), req)
+	if err != nil {
+		return nil, err
+	}
+	status = resp.StatusCode()
+
+	if resp.IsError() {
+		return nil, parseError(resp.Body(), s.language())
+	}
+
+	return resp.Body(), nil
+}
+
+// GetMetadata fetches and parses the $metadata document of the service
+// (cached when the service was created WithMetadataPreload)
+func GetMetadata(s *Service) (*metadata.Document, error) {
+	if s.meta != nil {
+		s.meta.mu.Lock()
+		defer s.meta.mu.Unlock()
+		if s.meta.doc != nil {
+			return s.meta.doc, nil
+		}
+	}
+
+	raw, err := GetMetadataXML(s)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := metadata.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if s.meta != nil {
+		s.meta.doc = doc
+	}
+	return doc, nil
+}
+
+// DetectDrift compares the entity struct T with the live metadata of entitySet's entity type,
+// so backend changes surface before they cause silent zero values at runtime
+func DetectDrift[T any](s *Service, entitySet string) (*metadata.DriftReport, error) {
+	doc, err := GetMetadata(s)
+	if err != nil {
+		return nil, fmt.Errorf("loading metadata: %w", err)
+	}
+	_, et, err := doc.EntitySet(entitySet)
+	if err != nil {
+		return nil, err
+	}
+
+	var zero T
+	return metadata.CompareStruct(et, zero), nil
+}
+
+// ErrMetadataMismatch is returned for the requests of a service pinned WithMetadataPin
+// whose live $metadata no longer matches the pinned fingerprint
+var ErrMetadataMismatch = errors.New("odata: live metadata does not match the pinned version")
+
+// WithMetadataPin pins the service to the metadata.Document.Fingerprint of the $metadata its
+// entity types were written or generated against (see odata-cli metadata -format fingerprint).
+// The live document is checked before the first request. On a mismatch every request fails
+// with ErrMetadataMismatch, or, with a non-nil logger, a warning is logged and requests go
+// ahead; use DetectDrift to see what changed.
+func WithMetadataPin(fingerprint string, logger *slog.Logger) ServiceOption {
+	return func(s *Service) {
+		s.pin = &metadataPin{fingerprint: fingerprint, logger: logger}
+	}
+}
+
+// metadataPin remembers the outcome of the check, shared by copies of the Service
+type metadataPin struct {
+	fingerprint string
+	logger      *slog.Logger
+
+	mu      sync.Mutex
+	checked bool
+	err     error
+}
+
+// verify compares the live metadata with the pin once. Failures to load the metadata are
+// returned without being remembered, so the next request tries again.
+func (p *metadataPin) verify(s *Service) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.checked {
+		return p.err
+	}
+	doc, err := GetMetadata(s)
+	if err != nil {
+		return fmt.Errorf("verifying metadata pin: %w", err)
+	}
+	p.checked = true
+	if live := doc.Fingerprint(); live != p.fingerprint {
+		if p.logger != nil {
+			p.logger.Warn("OData metadata changed since the pinned version", "service", s.servicePath, "pinned", p.fingerprint, "live", live)
+			return nil
+		}
+		p.err = fmt.Errorf("%w: %s has fingerprint %s, pinned %s", ErrMetadataMismatch, s.servicePath, live, p.fingerprint)
+	}
+	return p.err
+}
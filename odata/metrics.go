@@ -0,0 +1,168 @@
+package odata
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operations reported in RequestMetric.Operation
+const (
+	OpList       = "list"
+	OpGet        = "get"
+	OpNavigation = "navigation"
+	OpCreate     = "create"
+	OpUpdate     = "update"
+	OpPatch      = "patch"
+	OpDelete     = "delete"
+	OpFunction   = "function"
+	OpMedia      = "media"
+	OpBatch      = "batch"
+	OpMetadata   = "metadata"
+	OpCount      = "count"
+	OpLinks      = "links"
+)
+
+// RequestMetric describes one completed request of a service
+type RequestMetric struct {
+	ServicePath string
+	// EntitySet is the entity set, function import, "$batch" or "$metadata" the request addressed
+	EntitySet string
+	Operation string
+	// StatusCode is zero when no response was received
+	StatusCode int
+	Duration   time.Duration
+	// ResponseSize is the number of body bytes read
+	ResponseSize int64
+	Err          error
+}
+
+// MetricsHook receives a RequestMetric after every request. It is called synchronously
+// and must be safe for concurrent use.
+type MetricsHook func(RequestMetric)
+
+// WithMetrics returns a shallow copy of the service that reports every request to hook
+func (s *Service) WithMetrics(hook MetricsHook) *Service {
+	s2 := *s
+	s2.metrics = hook
+	return &s2
+}
+
+func (s *Service) observe(c *call, start time.Time, status int, size int64, err error) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics(RequestMetric{
+		ServicePath:  s.servicePath,
+		EntitySet:    c.entitySet,
+		Operation:    c.operation,
+		StatusCode:   status,
+		Duration:     time.Since(start),
+		ResponseSize: size,
+		Err:          err,
+	})
+}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, used by NewHistograms
+var DefaultLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// DefaultSizeBuckets are the upper bounds, in bytes, used by NewHistograms
+var DefaultSizeBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20}
+
+// MetricKey identifies the series a request is recorded in
+type MetricKey struct {
+	ServicePath string
+	EntitySet   string
+	Operation   string
+}
+
+// Histogram counts observations per bucket (not cumulatively): Counts[i] holds values
+// above Bounds[i-1] and up to Bounds[i]; the last count holds values above every bound
+type Histogram struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(v float64) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, v)]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) clone() Histogram {
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
+
+// SeriesSnapshot holds the histograms of one MetricKey
+type SeriesSnapshot struct {
+	Latency Histogram // seconds
+	Size    Histogram // bytes
+	Errors  uint64
+}
+
+// Histograms records latency and response size histograms per service path, entity set and
+// operation, so a slow entity set is visible instead of being averaged away.
+// Pass its Observe method to Service.WithMetrics and export Snapshot to the monitoring system.
+type Histograms struct {
+	mu            sync.Mutex
+	latencyBounds []float64
+	sizeBounds    []float64
+	series        map[MetricKey]*series
+}
+
+type series struct {
+	latency *Histogram
+	size    *Histogram
+	errors  uint64
+}
+
+// NewHistograms creates a collector with DefaultLatencyBuckets and DefaultSizeBuckets
+func NewHistograms() *Histograms {
+	return NewHistogramsWithBuckets(DefaultLatencyBuckets, DefaultSizeBuckets)
+}
+
+// NewHistogramsWithBuckets creates a collector with custom ascending bucket bounds
+func NewHistogramsWithBuckets(latency, size []float64) *Histograms {
+	return &Histograms{
+		latencyBounds: latency,
+		sizeBounds:    size,
+		series:        make(map[MetricKey]*series),
+	}
+}
+
+// Observe records m; it satisfies MetricsHook
+func (h *Histograms) Observe(m RequestMetric) {
+	key := MetricKey{ServicePath: m.ServicePath, EntitySet: m.EntitySet, Operation: m.Operation}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &series{latency: newHistogram(h.latencyBounds), size: newHistogram(h.sizeBounds)}
+		h.series[key] = s
+	}
+	s.latency.observe(m.Duration.Seconds())
+	s.size.observe(float64(m.ResponseSize))
+	if m.Err != nil {
+		s.errors++
+	}
+}
+
+// Snapshot returns a copy of all series recorded so far
+func (h *Histograms) Snapshot() map[MetricKey]SeriesSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[MetricKey]SeriesSnapshot, len(h.series))
+	for k, s := range h.series {
+		out[k] = SeriesSnapshot{Latency: s.latency.clone(), Size: s.size.clone(), Errors: s.errors}
+	}
+	return out
+}
@@ -0,0 +1,410 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+// Struct fields may carry an odata tag naming the property on the wire when it differs from
+// the JSON name the application uses itself:
+//
+//	type Material struct {
+//		Number string `json:"materialNumber" odata:"Matnr"`
+//	}
+//
+// Payloads are sent with Matnr and responses decode Matnr into Number, while json.Marshal of
+// a Material still writes materialNumber. Query options always use the wire names.
+//
+// Fields with neither an odata tag nor a json name are named by the NameMapper of the
+// service, if one is set with WithNameMapper, and by their Go name otherwise.
+
+// NameMapper derives the wire name of a property from a Go field name
+type NameMapper func(field string) string
+
+// WithNameMapper names the properties of struct fields without an odata tag or json name
+// by applying m to the field name, e.g. UpperSnakeCase for a service exposing
+// MATERIAL_NUMBER, which saves tagging every field of large entity types
+func WithNameMapper(m NameMapper) ServiceOption {
+	return func(s *Service) {
+		if m == nil {
+			s.names = nil
+			return
+		}
+		s.names = &nameMapping{mapper: m}
+	}
+}
+
+// UpperSnakeCase maps MaterialNumber and SalesOrderID to MATERIAL_NUMBER and SALES_ORDER_ID
+func UpperSnakeCase(field string) string {
+	return strings.ToUpper(strings.Join(splitWords(field), "_"))
+}
+
+// PascalCase maps Go initialisms to the Pascal case SAP uses: SalesOrderID and URL become
+// SalesOrderId and Url
+func PascalCase(field string) string {
+	words := splitWords(field)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a Go identifier at its case changes, keeping initialisms together:
+// HTTPServerID is HTTP, Server, ID. Digits stay with the word before them.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		switch {
+		case cur == '_':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev)),
+			unicode.IsUpper(cur) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// structNames maps the JSON names of a struct's properties to their wire names and back
+type structNames struct {
+	toWire   map[string]string
+	fromWire map[string]string
+	// types holds the field types by JSON name
+	types map[string]reflect.Type
+}
+
+// nameMapping renames the properties of encoded entities between their JSON and wire names.
+// Types without renamed properties anywhere inside are passed through untouched.
+type nameMapping struct {
+	mapper  NameMapper
+	structs sync.Map // reflect.Type -> *structNames
+	mapped  sync.Map // reflect.Type -> bool, see renames
+	nested  sync.Map // reflect.Type -> bool, see nests
+}
+
+// tagNames maps the properties by their odata tags, for services without a NameMapper
+var tagNames = &nameMapping{}
+
+// wireNames returns the name mapping of the service
+func (s *Service) wireNames() *nameMapping {
+	if s.names == nil {
+		return tagNames
+	}
+	return s.names
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	modelsPath          = reflect.TypeOf(models.ODataError{}).PkgPath()
+)
+
+// encode returns payload encoded by codec with its wire names, or payload itself when
+// nothing is renamed
+func (m *nameMapping) encode(payload interface{}, codec JSONCodec) interface{} {
+	switch payload.(type) {
+	case nil, []byte, string, json.RawMessage:
+		return payload
+	}
+	t := reflect.TypeOf(payload)
+	if !m.renames(t) {
+		return payload
+	}
+	body, err := codec.Marshal(payload)
+	if err != nil {
+		return payload // let the client report it
+	}
+	if body, err = m.rename(body, t, true); err != nil {
+		return payload
+	}
+	return body
+}
+
+// EncodePayload returns the JSON body the service sends for payload, with wire names applied,
+// e.g. to store a write and send it later
+func (s *Service) EncodePayload(payload interface{}) (json.RawMessage, error) {
+	switch p := s.wireNames().encode(payload, s.jsonCodec()).(type) {
+	case []byte:
+		return p, nil
+	case json.RawMessage:
+		return p, nil
+	case string:
+		return json.RawMessage(p), nil
+	default:
+		body, err := s.jsonCodec().Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("encoding payload: %w", err)
+		}
+		return body, nil
+	}
+}
+
+// unmarshal decodes body into v with the wire names renamed to the JSON names of v's type.
+// A nil m maps by odata tags, for results built without a service. Collection properties,
+// which V2 wraps in {"results": [...]} or defers, are only unwrapped if the plain decoding
+// fails, so entities without renamed properties are decoded once.
+func (m *nameMapping) unmarshal(codec JSONCodec, body []byte, v interface{}) error {
+	if m == nil {
+		m = tagNames
+	}
+	t := reflect.TypeOf(v)
+	if t == nil || !m.renames(t) {
+		err := unmarshal(codec, body, v)
+		if err == nil || t == nil || !m.nests(t) {
+			return err
+		}
+		unwrapped, rerr := m.rename(body, t, false)
+		if rerr != nil {
+			return err
+		}
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv.Elem().SetZero()
+		}
+		return unmarshal(codec, unwrapped, v)
+	}
+	if renamed, err := m.rename(body, t, false); err == nil {
+		body = renamed
+	} // otherwise left to the regular decoding to report
+	return unmarshal(codec, body, v)
+}
+
+// rename renames the object keys of data, a JSON encoded t
+func (m *nameMapping) rename(data []byte, t reflect.Type, toWire bool) ([]byte, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if result, ok := wrappedType(t); ok {
+		var d map[string]json.RawMessage
+		if json.Unmarshal(data, &d) != nil {
+			return data, nil
+		}
+		if results, ok := d["results"]; ok {
+			renamed, err := m.rename(results, result, toWire)
+			if err != nil {
+				return nil, err
+			}
+			d["results"] = renamed
+			return json.Marshal(d)
+		}
+		return m.rename(data, result, toWire)
+	}
+	if customJSON(t) || !m.renames(t) && !m.nests(t) {
+		return data, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(data, &obj) != nil || obj == nil {
+			return data, nil
+		}
+		names := m.structNames(t)
+		out := make(map[string]json.RawMessage, len(obj))
+		for key, value := range obj {
+			jsonName := key
+			if toWire {
+				if wire, ok := names.toWire[key]; ok {
+					key = wire
+				}
+			} else if name, ok := names.fromWire[key]; ok {
+				jsonName, key = name, name
+			} else if _, renamed := names.toWire[key]; renamed {
+				continue // a wire property that happens to carry the JSON name of another field
+			}
+			if ft, ok := names.types[jsonName]; ok {
+				if collectionType(ft) {
+					if !toWire {
+						if value, ok = inlineCollection(value); !ok {
+							continue
+						}
+					}
+				}
+				renamed, err := m.rename(value, ft, toWire)
+				if err != nil {
+					return nil, err
+				}
+				value = renamed
+			}
+			out[key] = value
+		}
+		return json.Marshal(out)
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			return data, nil
+		}
+		for i, item := range items {
+			renamed, err := m.rename(item, t.Elem(), toWire)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = renamed
+		}
+		return json.Marshal(items)
+	case reflect.Map:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return data, nil
+		}
+		for k, v := range values {
+			renamed, err := m.rename(v, t.Elem(), toWire)
+			if err != nil {
+				return nil, err
+			}
+			values[k] = renamed
+		}
+		return json.Marshal(values)
+	}
+	return data, nil
+}
+
+// renames reports whether encoding t involves any struct with renamed properties
+func (m *nameMapping) renames(t reflect.Type) bool {
+	return m.search(&m.mapped, t, func(names *structNames) bool {
+		return len(names.toWire) > 0
+	})
+}
+
+// nests reports whether t involves any struct with a collection property. Such properties
+// are wrapped in {"results": [...]} or deferred in responses.
+func (m *nameMapping) nests(t reflect.Type) bool {
+	return m.search(&m.nested, t, func(names *structNames) bool {
+		for _, ft := range names.types {
+			if collectionType(ft) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// search reports whether match holds for any struct involved in encoding t, caching the
+// result in cache
+func (m *nameMapping) search(cache *sync.Map, t reflect.Type, match func(*structNames) bool) bool {
+	if v, ok := cache.Load(t); ok {
+		return v.(bool)
+	}
+	found := false
+	seen := make(map[reflect.Type]bool)
+	var visit func(reflect.Type)
+	visit = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if found || seen[t] {
+			return
+		}
+		seen[t] = true
+		if result, ok := wrappedType(t); ok {
+			visit(result)
+			return
+		}
+		if customJSON(t) {
+			return
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			names := m.structNames(t)
+			if match(names) {
+				found = true
+				return
+			}
+			for _, ft := range names.types {
+				visit(ft)
+			}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			visit(t.Elem())
+		}
+	}
+	visit(t)
+	cache.Store(t, found)
+	return found
+}
+
+// structNames returns the renamed properties and the field types of the struct t
+func (m *nameMapping) structNames(t reflect.Type) *structNames {
+	if v, ok := m.structs.Load(t); ok {
+		return v.(*structNames)
+	}
+	fields := metadata.StructFields(t)
+	names := &structNames{
+		toWire:   make(map[string]string),
+		fromWire: make(map[string]string),
+		types:    make(map[string]reflect.Type, len(fields)),
+	}
+	for name, f := range fields {
+		names.types[name] = f.Type
+		wire := metadata.WireName(name, f)
+		if jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ","); m.mapper != nil && wire == name && jsonName == "" {
+			wire = m.mapper(f.Name)
+		}
+		if wire != name {
+			names.toWire[name] = wire
+			names.fromWire[wire] = name
+		}
+	}
+	m.structs.Store(t, names)
+	return names
+}
+
+// collectionType reports whether t is a slice or array of entities or values, which a
+// navigation or collection property is decoded into
+func collectionType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 && !customJSON(t)
+}
+
+// inlineCollection returns the entities of an expanded navigation property, which V2 wraps
+// in {"results": [...]}. It reports false for a deferred one, {"__deferred": {...}}, which
+// leaves the field empty.
+func inlineCollection(value json.RawMessage) (json.RawMessage, bool) {
+	if len(value) == 0 || value[0] != '{' {
+		return value, true
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(value, &obj) != nil {
+		return value, true
+	}
+	if results, ok := obj["results"]; ok {
+		return results, true
+	}
+	if _, ok := obj["__deferred"]; ok {
+		return nil, false
+	}
+	return value, true
+}
+
+// wrappedType returns the result type of a models.DWrapper, which decodes itself
+func wrappedType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != modelsPath || !strings.HasPrefix(t.Name(), "DWrapper[") {
+		return nil, false
+	}
+	return t.Field(0).Type, true
+}
+
+// customJSON reports whether t encodes or decodes itself, like time types do
+func customJSON(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonUnmarshalerType)
+}
@@ -0,0 +1,69 @@
+package odata
+
+import "strings"
+
+// System query options, as set by the QueryOptions builder
+const (
+	OptionFilter      = "$filter"
+	OptionSelect      = "$select"
+	OptionExpand      = "$expand"
+	OptionOrderBy     = "$orderby"
+	OptionTop         = "$top"
+	OptionSkip        = "$skip"
+	OptionSkipToken   = "$skiptoken"
+	OptionInlineCount = "$inlinecount"
+	OptionFormat      = "$format"
+)
+
+// ComparisonOperator compares a property with a value in a $filter
+type ComparisonOperator string
+
+const (
+	Eq ComparisonOperator = "eq"
+	Ne ComparisonOperator = "ne"
+	Gt ComparisonOperator = "gt"
+	Ge ComparisonOperator = "ge"
+	Lt ComparisonOperator = "lt"
+	Le ComparisonOperator = "le"
+)
+
+// LogicalOperator combines $filter conditions
+type LogicalOperator string
+
+const (
+	And LogicalOperator = "and"
+	Or  LogicalOperator = "or"
+)
+
+// Compare returns the $filter condition comparing property with value, which is rendered
+// with FormatLiteral:
+//
+//	odata.Compare("Price", odata.Gt, 20.5) // Price gt 20.5
+func Compare(property string, op ComparisonOperator, value interface{}) string {
+	return property + " " + string(op) + " " + FormatLiteral(value)
+}
+
+// Combine joins conditions with op, parenthesizing each so that they keep their meaning.
+// Empty conditions are skipped.
+//
+//	odata.Combine(odata.And, odata.Compare("Price", odata.Gt, 20), "substringof('Pro',Name)")
+func Combine(op LogicalOperator, conditions ...string) string {
+	parts := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	for i, p := range parts {
+		parts[i] = "(" + p + ")"
+	}
+	return strings.Join(parts, " "+string(op)+" ")
+}
+
+// Not negates a $filter condition
+func Not(condition string) string {
+	return "not (" + condition + ")"
+}
@@ -0,0 +1,199 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrTooManyPages is returned by GetEntitySetAll when the server has more pages than allowed
+var ErrTooManyPages = errors.New("odata: collection has more pages than allowed")
+
+// Page is one page of a collection the server returned in parts (server-side paging)
+type Page[T any] struct {
+	Results []T
+	// Next is the __next link of the following page, empty on the last page
+	Next string
+}
+
+// SkipToken returns the $skiptoken of the Next link, "" on the last page or if the server
+// pages without skip tokens
+func (p *Page[T]) SkipToken() string {
+	return NextSkipToken(p.Next)
+}
+
+// NextSkipToken returns the $skiptoken of a __next link, e.g. resp.NextLink(). Saved as a
+// checkpoint, it resumes a long export with QueryOptions.SkipToken and the original options.
+func NextSkipToken(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get(OptionSkipToken)
+}
+
+// pageResponse decodes a page of a collection, {"d":{"results":[...],"__next":"..."}}
+type pageResponse[T any] struct {
+	D struct {
+		Results []T    `json:"results"`
+		Next    string `json:"__next"`
+	} `json:"d"`
+}
+
+// follow points c at a __next (or __delta) link. Links are followed on the service's own
+// host, and their query replaces that of c, since it carries the original options.
+func (s *Service) follow(c *call, link string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("invalid link %q: %w", link, err)
+	}
+	c.url = u.EscapedPath()
+	if !strings.HasPrefix(c.url, "/") {
+		c.url = s.servicePath + c.url
+	}
+	c.query = make(map[string]string)
+	for k, v := range u.Query() {
+		c.query[k] = v[0]
+	}
+	c.unbounded = true // the server chose the page size
+	return nil
+}
+
+// readPage executes c, or c pointed at link if that is not empty, and decodes a page
+func readPage[T any](s *Service, c *call, link string) (*Page[T], error) {
+	if link != "" {
+		if err := s.follow(c, link); err != nil {
+			return nil, err
+		}
+	}
+	var resp pageResponse[T]
+	if err := s.execute(c, &resp); err != nil {
+		return nil, err
+	}
+	return &Page[T]{Results: resp.D.Results, Next: resp.D.Next}, nil
+}
+
+// seqPages yields the entities of the pages read by read, starting with an empty link and
+// following the __next links, reading each page only once the previous one has been consumed
+func seqPages[T any](read func(link string) (*Page[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		link := ""
+		for {
+			page, err := read(link)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Results {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			link = page.Next
+		}
+	}
+}
+
+// GetEntitySetPage reads the first page of an entity set the server returns in parts, or,
+// if link is not empty, the page a Next link of an earlier page points to
+func GetEntitySetPage[T any](s *Service, entitySet string, opts *QueryOptions, link string) (*Page[T], error) {
+	c := &call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: queryParams(opts), unbounded: opts.unboundedAllowed()}
+	return readPage[T](s, c, link)
+}
+
+// GetEntitySetAll reads an entity set the server returns in parts, following the __next
+// links until the last page. It reads at most maxPages pages, unlimited if maxPages is 0,
+// and fails with ErrTooManyPages beyond that, so a forgotten $filter cannot load a whole
+// table. Where the server does not page, a service with WithMaxPageSize pages itself like
+// Pages does, unless opts sets $top or is Unbounded.
+func GetEntitySetAll[T any](s *Service, entitySet string, opts *QueryOptions, maxPages int) ([]T, error) {
+	var all []T
+	cur := s.newPageCursor(opts)
+	for pages := 1; ; pages++ {
+		page, err := readPage[T](s, cur.call(s, entitySet, opts), cur.link)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Results...)
+		if !cur.advance(s, page.Next, len(page.Results)) {
+			return all, nil
+		}
+		if maxPages > 0 && pages >= maxPages {
+			return nil, fmt.Errorf("reading %s: %w (%d)", entitySet, ErrTooManyPages, maxPages)
+		}
+	}
+}
+
+// Pages yields the pages of an entity set one at a time, reading each only once the previous
+// one has been consumed, so an export never holds more than a page in memory. It follows the
+// __next links of server-side paging. Where the server does not page, a service with
+// WithMaxPageSize pages itself: as long as a read returns the maximum, the next one is sent
+// with $skip advanced, unless opts sets $top or is Unbounded.
+func Pages[T any](s *Service, entitySet string, opts *QueryOptions) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		cur := s.newPageCursor(opts)
+		for first := true; ; first = false {
+			page, err := readPage[T](s, cur.call(s, entitySet, opts), cur.link)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(page.Results) == 0 && !first {
+				return // the previous page happened to end the set
+			}
+			if !yield(page.Results, nil) || !cur.advance(s, page.Next, len(page.Results)) {
+				return
+			}
+		}
+	}
+}
+
+// pageCursor is where the next read of a paged entity set starts: the __next link of the
+// server, or, where the server does not page, the query with $skip advanced past the
+// entities read so far
+type pageCursor struct {
+	query map[string]string
+	// paged is set when the service pages itself, see Pages
+	paged bool
+	link  string
+	skip  int
+	// read counts the entities since the query was last sent
+	read int
+}
+
+func (s *Service) newPageCursor(opts *QueryOptions) *pageCursor {
+	query := queryParams(opts)
+	_, topped := query[OptionTop]
+	skip, _ := strconv.Atoi(query[OptionSkip])
+	return &pageCursor{query: query, paged: s.maxPageSize > 0 && !topped && !opts.unboundedAllowed(), skip: skip}
+}
+
+// call returns the read of the cursor's query; readPage points it at the link, if any
+func (p *pageCursor) call(s *Service, entitySet string, opts *QueryOptions) *call {
+	return &call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: p.query, unbounded: opts.unboundedAllowed()}
+}
+
+// advance moves the cursor past a page of n entities with the __next link next and reports
+// whether there is more to read
+func (p *pageCursor) advance(s *Service, next string, n int) bool {
+	p.read += n
+	switch {
+	case next != "":
+		p.link = next
+	case p.paged && p.read == s.maxPageSize:
+		p.skip += p.read
+		p.query = merge(merge(nil, p.query), map[string]string{OptionSkip: strconv.Itoa(p.skip)})
+		p.link, p.read = "", 0
+	default:
+		return false
+	}
+	return true
+}
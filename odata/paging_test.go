@@ -0,0 +1,105 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+func TestNextSkipToken(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{"https://host/sap/opu/odata/SRV/ProductSet?$skiptoken='100'", "'100'"},
+		{"ProductSet?$filter=Price%20gt%2010&$skiptoken=abc%2Cdef&$top=50", "abc,def"},
+		{"ProductSet?$skiptoken=a+b", "a b"},
+		{"ProductSet?$skip=100&$top=100", ""},
+		{"ProductSet?$skiptoken=", ""},
+		{"%zz?$skiptoken=1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			if got := NextSkipToken(tt.link); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// skipServer serves an entity set of n entities without server-side paging, honouring
+// $skip and $top, and records the queries it received
+func skipServer(t *testing.T, n int, queries *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*queries = append(*queries, r.URL.RawQuery)
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		top, err := strconv.Atoi(r.URL.Query().Get("$top"))
+		if err != nil {
+			top = n
+		}
+		var results []string
+		for i := skip; i < min(n, skip+top); i++ {
+			results = append(results, fmt.Sprintf(`{"ID":"%d"}`, i))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"d":{"results":[%s]}}`, strings.Join(results, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetEntitySetAllMaxPageSize(t *testing.T) {
+	type entity struct{ ID string }
+	tests := []struct {
+		name     string
+		opts     *QueryOptions
+		maxPages int
+		want     int
+		queries  int
+		err      error
+	}{
+		{"pages with $skip", nil, 0, 5, 3, nil},
+		{"full last page", NewQueryOptions().Skip(1), 0, 4, 3, nil},
+		{"top set", NewQueryOptions().Top(2), 0, 2, 1, nil},
+		{"unbounded", NewQueryOptions().Unbounded(), 0, 5, 1, nil},
+		{"too many pages", nil, 2, 0, 2, ErrTooManyPages},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			srv := skipServer(t, 5, &queries)
+			s := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/", WithMaxPageSize(2))
+			got, err := GetEntitySetAll[entity](s, "ProductSet", tt.opts, tt.maxPages)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("read %d entities, want %d", len(got), tt.want)
+			}
+			for i, e := range got {
+				if tt.opts == nil && e.ID != strconv.Itoa(i) {
+					t.Errorf("entity %d has ID %s", i, e.ID)
+				}
+			}
+			if len(queries) != tt.queries {
+				t.Errorf("sent %d reads, want %d: %v", len(queries), tt.queries, queries)
+			}
+		})
+	}
+}
+
+func TestLimitPageInvalidTop(t *testing.T) {
+	s := NewService(client.NewSAPClient("http://gw", "", ""), "/svc/", WithMaxPageSize(2))
+	_, err := s.limitPage(&call{operation: OpList}, map[string]string{OptionTop: "all"})
+	if err == nil || !strings.Contains(err.Error(), `invalid $top "all"`) {
+		t.Errorf("err = %v, want an invalid $top", err)
+	}
+}
@@ -0,0 +1,74 @@
+package odata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ItemResult is the outcome of one item of ParallelForEach
+type ItemResult[T any] struct {
+	Index int
+	Item  T
+	Err   error
+}
+
+// ParallelResult holds one ItemResult per input item, in input order
+type ParallelResult[T any] struct {
+	Results []ItemResult[T]
+}
+
+// Failed returns the results of the items whose fn returned an error
+func (r *ParallelResult[T]) Failed() []ItemResult[T] {
+	var failed []ItemResult[T]
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of all failed items, or returns nil when every item succeeded
+func (r *ParallelResult[T]) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("item %d: %w", res.Index, res.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// ParallelForEach calls fn for every item with at most concurrency calls in flight, for mass
+// maintenance jobs (updating or deleting thousands of entities). fn should use a Service bound
+// to the ctx it receives; the client's bulkhead, scheduler and retry policy then apply to every
+// call. A failing item does not stop the others; once ctx is done, the remaining items fail
+// with ctx.Err() without calling fn.
+func ParallelForEach[T any](ctx context.Context, items []T, concurrency int, fn func(ctx context.Context, item T) error) *ParallelResult[T] {
+	result := &ParallelResult[T]{Results: make([]ItemResult[T], len(items))}
+	for i, item := range items {
+		result.Results[i] = ItemResult[T]{Index: i, Item: item}
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					result.Results[i].Err = err
+					continue
+				}
+				result.Results[i].Err = fn(ctx, items[i])
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,127 @@
+package odata
+
+import "iter"
+
+// Map returns fn applied to every item, e.g. to project entities to DTOs:
+//
+//	dtos := odata.Map(resp.D.Result, toProductDTO)
+func Map[T, U any](items []T, fn func(T) U) []U {
+	out := make([]U, len(items))
+	for i, item := range items {
+		out[i] = fn(item)
+	}
+	return out
+}
+
+// Filter returns the items for which keep returns true
+func Filter[T any](items []T, keep func(T) bool) []T {
+	var out []T
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Reduce folds items into an accumulator starting at init
+func Reduce[T, A any](items []T, init A, fn func(A, T) A) A {
+	acc := init
+	for _, item := range items {
+		acc = fn(acc, item)
+	}
+	return acc
+}
+
+// GroupBy groups items by key, keeping their order within each group
+func GroupBy[T any, K comparable](items []T, key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for _, item := range items {
+		k := key(item)
+		groups[k] = append(groups[k], item)
+	}
+	return groups
+}
+
+// Distinct returns the first item for every key, e.g. to drop entities repeated across pages
+func Distinct[T any, K comparable](items []T, key func(T) K) []T {
+	seen := make(map[K]bool, len(items))
+	var out []T
+	for _, item := range items {
+		if k := key(item); !seen[k] {
+			seen[k] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// The Seq variants work on iterators yielding an entity or the error that ended the
+// iteration, as produced by the paging APIs. They are lazy: nothing is read until the
+// result is ranged over, and stopping early stops the source.
+
+// MapSeq returns an iterator applying fn to every entity of seq
+func MapSeq[T, U any](seq iter.Seq2[T, error], fn func(T) U) iter.Seq2[U, error] {
+	return func(yield func(U, error) bool) {
+		for item, err := range seq {
+			var u U
+			if err == nil {
+				u = fn(item)
+			}
+			if !yield(u, err) {
+				return
+			}
+		}
+	}
+}
+
+// FilterSeq returns an iterator over the entities of seq for which keep returns true.
+// Errors are always passed on.
+func FilterSeq[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range seq {
+			if err == nil && !keep(item) {
+				continue
+			}
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// DistinctSeq returns an iterator over the first entity of seq for every key
+func DistinctSeq[T any, K comparable](seq iter.Seq2[T, error], key func(T) K) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		seen := make(map[K]bool)
+		for item, err := range seq {
+			if err == nil {
+				k := key(item)
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+			}
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// ReduceSeq folds the entities of seq into an accumulator, stopping at the first error
+func ReduceSeq[T, A any](seq iter.Seq2[T, error], init A, fn func(A, T) A) (A, error) {
+	acc := init
+	for item, err := range seq {
+		if err != nil {
+			return acc, err
+		}
+		acc = fn(acc, item)
+	}
+	return acc, nil
+}
+
+// CollectSeq reads seq into a slice, stopping at the first error
+func CollectSeq[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	return ReduceSeq(seq, []T(nil), func(out []T, item T) []T { return append(out, item) })
+}
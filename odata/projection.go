@@ -0,0 +1,119 @@
+package odata
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+// ProjectionError reports a $select that leaves fields of the target struct unset
+type ProjectionError struct {
+	EntitySet string
+	Type      string   // Go type the entities decode into
+	Missing   []string // wire names of the fields not selected
+}
+
+func (e *ProjectionError) Error() string {
+	return fmt.Sprintf("$select of %s does not cover %s fields %s", e.EntitySet, e.Type, strings.Join(e.Missing, ", "))
+}
+
+// WithProjectionValidation checks reads with a $select decoding into a struct before they
+// are sent. A struct field without omitempty that is not selected would silently stay at
+// its zero value, so the read fails with a *ProjectionError instead. Selected properties
+// no field decodes are logged as a warning to logger (slog.Default() if nil), once per
+// type and property. Batch reads are not checked.
+func WithProjectionValidation(logger *slog.Logger) ServiceOption {
+	return func(s *Service) {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		s.projection = &projectionLog{logger: logger}
+	}
+}
+
+// projectionLog warns once per type and property; it is shared by copies of the Service
+type projectionLog struct {
+	logger *slog.Logger
+	warned sync.Map
+}
+
+func (l *projectionLog) warn(entitySet string, t reflect.Type, property string) {
+	if _, seen := l.warned.LoadOrStore(t.String()+"\x00"+property, true); seen {
+		return
+	}
+	l.logger.Warn("OData property selected but not mapped", "entitySet", entitySet, "type", t.String(), "property", property)
+}
+
+// checkProjection compares the $select of query with the struct out decodes entities into
+func (s *Service) checkProjection(c *call, query map[string]string, out interface{}) error {
+	selected := query[OptionSelect]
+	if s.projection == nil || selected == "" {
+		return nil
+	}
+	t := entityStruct(out)
+	if t == nil {
+		return nil
+	}
+	props := make(map[string]bool)
+	for _, path := range strings.Split(selected, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(path), "/")
+		if name == "*" {
+			return nil
+		}
+		props[name] = true
+	}
+
+	names := s.wireNames().structNames(t)
+	mapped := make(map[string]bool)
+	var missing []string
+	for name, f := range metadata.StructFields(t) {
+		wire := name
+		if w, ok := names.toWire[name]; ok {
+			wire = w
+		}
+		mapped[wire] = true
+		_, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !props[wire] && !strings.HasPrefix(wire, "__") && !slices.Contains(strings.Split(opts, ","), "omitempty") {
+			missing = append(missing, wire)
+		}
+	}
+	for name := range props {
+		if !mapped[name] {
+			s.projection.warn(c.entitySet, t, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return &ProjectionError{EntitySet: c.entitySet, Type: t.String(), Missing: missing}
+	}
+	return nil
+}
+
+// entityStruct returns the struct type of the entities a *models.ODataResponse decodes,
+// nil for other targets and for types that decode themselves
+func entityStruct(out interface{}) reflect.Type {
+	t := reflect.TypeOf(out)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	d, ok := t.Elem().FieldByName("D")
+	if !ok {
+		return nil
+	}
+	result, ok := wrappedType(d.Type)
+	if !ok {
+		return nil
+	}
+	for result.Kind() == reflect.Pointer || result.Kind() == reflect.Slice {
+		result = result.Elem()
+	}
+	if result.Kind() != reflect.Struct || customJSON(result) {
+		return nil
+	}
+	return result
+}
@@ -3,12 +3,19 @@ package odata
 import (
 	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // QueryOptions builder for OData v2 parameters
 type QueryOptions struct {
-	params url.Values
+	params    url.Values
+	unbounded bool
+
+	mu    sync.Mutex        // guards built, since options are shared by concurrent requests
+	built map[string]string // cached result of Build, reset by every setter
 }
 
 func NewQueryOptions() *QueryOptions {
@@ -19,25 +26,43 @@ func NewQueryOptions() *QueryOptions {
 
 // Format adds $format parameter (e.g., "json")
 func (q *QueryOptions) Format(format string) *QueryOptions {
-	q.params.Set("$format", format)
+	q.set(OptionFormat, format)
 	return q
 }
 
-// Filter adds $filter parameter
+// Filter adds a $filter condition. Conditions of successive calls are and'ed, so separate
+// layers of code can each restrict the query; an empty filter adds nothing.
 func (q *QueryOptions) Filter(filter string) *QueryOptions {
-	q.params.Set("$filter", filter)
+	if current := q.params.Get(OptionFilter); current != "" {
+		filter = Combine(And, current, filter)
+	}
+	if filter != "" {
+		q.set(OptionFilter, filter)
+	}
+	return q
+}
+
+// ReplaceFilter sets $filter to filter, dropping the conditions added before; an empty
+// filter removes it
+func (q *QueryOptions) ReplaceFilter(filter string) *QueryOptions {
+	if filter == "" {
+		q.params.Del(OptionFilter)
+		q.invalidate()
+		return q
+	}
+	q.set(OptionFilter, filter)
 	return q
 }
 
 // Select adds $select parameter
 func (q *QueryOptions) Select(fields []string) *QueryOptions {
-	q.params.Set("$select", strings.Join(fields, ","))
+	q.set(OptionSelect, strings.Join(fields, ","))
 	return q
 }
 
 // Expand adds $expand parameter
 func (q *QueryOptions) Expand(entities []string) *QueryOptions {
-	q.params.Set("$expand", strings.Join(entities, ","))
+	q.set(OptionExpand, strings.Join(entities, ","))
 	return q
 }
 
@@ -50,25 +75,32 @@ func (q *QueryOptions) OrderBy(field string, asc bool) *QueryOptions {
 	// Append if multiple orderby? V2 usually supports one string like "Name asc, Date desc"
 	// For simplicity, this helper sets one. Users can pass the full string if needed or we can append.
 	// Let's check if it exists to append
-	current := q.params.Get("$orderby")
+	current := q.params.Get(OptionOrderBy)
 	clause := fmt.Sprintf("%s %s", field, direction)
 	if current != "" {
-		q.params.Set("$orderby", current+","+clause)
+		q.set(OptionOrderBy, current+","+clause)
 	} else {
-		q.params.Set("$orderby", clause)
+		q.set(OptionOrderBy, clause)
 	}
 	return q
 }
 
 // Top adds $top parameter (pagination)
 func (q *QueryOptions) Top(n int) *QueryOptions {
-	q.params.Set("$top", fmt.Sprintf("%d", n))
+	q.set(OptionTop, strconv.Itoa(n))
 	return q
 }
 
 // Skip adds $skip parameter (pagination)
 func (q *QueryOptions) Skip(n int) *QueryOptions {
-	q.params.Set("$skip", fmt.Sprintf("%d", n))
+	q.set(OptionSkip, strconv.Itoa(n))
+	return q
+}
+
+// SkipToken adds $skiptoken, to continue server-side paging where a page ended, e.g. with
+// the token of a checkpoint saved by an earlier run (see NextSkipToken)
+func (q *QueryOptions) SkipToken(token string) *QueryOptions {
+	q.set(OptionSkipToken, token)
 	return q
 }
 
@@ -78,17 +110,78 @@ func (q *QueryOptions) InlineCount(allPages bool) *QueryOptions {
 	if allPages {
 		val = "allpages"
 	}
-	q.params.Set("$inlinecount", val)
+	q.set(OptionInlineCount, val)
 	return q
 }
 
-// Build returns the map of query parameters for Resty
+// Unbounded allows the query to read more than the maximum page size of the service
+// (see WithMaxPageSize), including reads without $top
+func (q *QueryOptions) Unbounded() *QueryOptions {
+	q.unbounded = true
+	return q
+}
+
+func (q *QueryOptions) unboundedAllowed() bool {
+	return q != nil && q.unbounded
+}
+
+// set stores a parameter and invalidates the cached Build result
+func (q *QueryOptions) set(key, value string) {
+	q.params.Set(key, value)
+	q.invalidate()
+}
+
+func (q *QueryOptions) invalidate() {
+	q.mu.Lock()
+	q.built = nil
+	q.mu.Unlock()
+}
+
+// Build returns the map of query parameters for Resty. The map is cached until the
+// options change, so options reused across many requests are built once; callers must
+// not modify it. Build may be called concurrently, e.g. on the defaults of a shared
+// Service (see WithDefaultQuery), but not while the options are being changed.
 func (q *QueryOptions) Build() map[string]string {
-	m := make(map[string]string)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.built != nil {
+		return q.built
+	}
+	m := make(map[string]string, len(q.params))
 	for k, v := range q.params {
 		if len(v) > 0 {
 			m[k] = v[0]
 		}
 	}
+	q.built = m
 	return m
 }
+
+// encodeQuery renders params as a query string with sorted keys, leaving the
+// "$" of system query options unescaped and encoding spaces as %20
+func encodeQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(strings.ReplaceAll(url.QueryEscape(k), "%24", "$"))
+		b.WriteByte('=')
+		b.WriteString(strings.ReplaceAll(url.QueryEscape(params[k]), "+", "%20"))
+	}
+	return b.String()
+}
+
+// queryParams builds opts, tolerating nil for requests without options
+func queryParams(opts *QueryOptions) map[string]string {
+	if opts == nil {
+		return nil
+	}
+	return opts.Build()
+}
@@ -0,0 +1,53 @@
+package odata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+)
+
+func TestBuildCache(t *testing.T) {
+	q := NewQueryOptions().Filter("Price gt 10").Top(5)
+	first := q.Build()
+	if got := q.Build(); len(got) != 2 || got[OptionTop] != "5" {
+		t.Fatalf("Build() = %v", got)
+	}
+	q.Top(7)
+	if got := q.Build(); got[OptionTop] != "7" || first[OptionTop] != "5" {
+		t.Errorf("after Top(7) Build() = %v, and the earlier map became %v", got, first)
+	}
+	q.ReplaceFilter("")
+	if _, ok := q.Build()[OptionFilter]; ok {
+		t.Error("ReplaceFilter(\"\") kept $filter in the cached map")
+	}
+}
+
+// TestDefaultQueryConcurrent reads through a shared service whose default options are
+// built by every request; run with -race
+func TestDefaultQueryConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(OptionSelect) != "ProductID" {
+			t.Errorf("request without the default $select: %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"d":{"results":[]}}`))
+	}))
+	defer srv.Close()
+
+	service := NewService(client.NewSAPClient(srv.URL, "", ""), "/svc/",
+		WithDefaultQuery(NewQueryOptions().Select([]string{"ProductID"})))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := GetEntitySet[map[string]interface{}](service, "ProductSet", NewQueryOptions().Top(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,40 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RawEntities holds the undecoded entities of a collection response. Consumers that
+// discard most rows can decode only the ones they keep.
+type RawEntities struct {
+	Items []json.RawMessage
+
+	names *nameMapping
+	codec JSONCodec
+}
+
+// Len returns the number of entities
+func (r *RawEntities) Len() int {
+	return len(r.Items)
+}
+
+// Decode unmarshals entity i into target
+func (r *RawEntities) Decode(i int, target interface{}) error {
+	if i < 0 || i >= len(r.Items) {
+		return fmt.Errorf("entity index %d out of range [0,%d)", i, len(r.Items))
+	}
+	if err := r.names.unmarshal(r.codec, r.Items[i], target); err != nil {
+		return fmt.Errorf("decoding entity %d: %w", i, err)
+	}
+	return nil
+}
+
+// GetEntitySetRaw is GetEntitySet without decoding the entities
+func GetEntitySetRaw(s *Service, entitySet string, opts *QueryOptions) (*RawEntities, error) {
+	resp, err := GetEntitySet[json.RawMessage](s, entitySet, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &RawEntities{Items: resp.D.Result, names: s.wireNames(), codec: s.jsonCodec()}, nil
+}
@@ -0,0 +1,60 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+// CreateOption customizes CreateEntity
+type CreateOption func(*createConfig)
+
+type createConfig struct {
+	refetch bool
+	query   *QueryOptions
+}
+
+// WithRefetch makes CreateEntity read the created entity back, with opts (e.g. $expand) if not
+// nil, and return that instead of the create response. It saves the second GET for backends
+// that draw keys from number ranges and fill properties on save, but answer the POST with a
+// partial entity or 204 No Content. The entity is found through the __metadata uri of the
+// response or its Location header.
+func WithRefetch(opts *QueryOptions) CreateOption {
+	return func(c *createConfig) {
+		c.refetch = true
+		c.query = opts
+	}
+}
+
+// refetch reads the entity created by c
+func refetch[T any](s *Service, c *call, opts *QueryOptions) (*models.ODataResponse[T], error) {
+	var created struct {
+		Metadata struct {
+			URI string `json:"uri"`
+		} `json:"__metadata"`
+	}
+	_ = json.Unmarshal(c.created, &created)
+	location := created.Metadata.URI
+	if location == "" {
+		location = c.header.Get("Location")
+	}
+	u, err := url.Parse(location)
+	if err != nil || u.Path == "" {
+		return nil, fmt.Errorf("refetching created %s: response has no entity URI", c.entitySet)
+	}
+	path := u.EscapedPath()
+	if !u.IsAbs() && path[0] != '/' {
+		path = s.servicePath + path
+	}
+
+	var result models.ODataResponse[T]
+	get := &call{operation: OpGet, entitySet: c.entitySet, method: http.MethodGet, url: path, query: queryParams(opts)}
+	if err := s.execute(get, &result); err != nil {
+		return nil, fmt.Errorf("refetching created %s: %w", c.entitySet, err)
+	}
+	result.Execution = c.execution.Add(get.execution)
+	return &result, nil
+}
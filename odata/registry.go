@@ -0,0 +1,46 @@
+package odata
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/config"
+)
+
+// Services creates the services named in a config.Config on one client, so service paths
+// live in configuration instead of string literals across the code
+//
+//	services := odata.NewServices(sapClient, cfg)
+//	material, err := services.Service("material")
+type Services struct {
+	client *client.SAPClient
+	cfg    *config.Config
+	opts   []ServiceOption
+
+	mu       sync.Mutex
+	services map[string]*Service
+}
+
+// NewServices creates a registry for the services of cfg; opts apply to every service
+func NewServices(c *client.SAPClient, cfg *config.Config, opts ...ServiceOption) *Services {
+	return &Services{client: c, cfg: cfg, opts: opts, services: make(map[string]*Service)}
+}
+
+// Service returns the named service, creating it on first use
+func (r *Services) Service(name string) (*Service, error) {
+	name = strings.ToLower(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.services[name]; ok {
+		return s, nil
+	}
+	path, err := r.cfg.ServicePath(name)
+	if err != nil {
+		return nil, err
+	}
+	s := NewService(r.client, path, r.opts...)
+	r.services[name] = s
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package odata
+
+// Repository is the typed CRUD surface of one entity set. Business logic that depends on
+// Repository instead of calling the package functions directly can be unit tested against
+// an in-memory implementation such as odatatest.FakeRepository.
+//
+// Keys use the same predicate syntax as GetEntityByKey, e.g. "'HT-1000'" or "(Id='1',Type='A')".
+type Repository[T any] interface {
+	List(opts *QueryOptions) ([]T, error)
+	Get(key string, opts *QueryOptions) (T, error)
+	Create(entity T) (T, error)
+	Update(key string, entity T) error
+	Patch(key string, fields interface{}) error
+	Delete(key string) error
+}
+
+// serviceRepository implements Repository on top of the HTTP backed Service
+type serviceRepository[T any] struct {
+	service   *Service
+	entitySet string
+}
+
+// NewRepository returns a Repository for entitySet served by s
+func NewRepository[T any](s *Service, entitySet string) Repository[T] {
+	return &serviceRepository[T]{service: s, entitySet: entitySet}
+}
+
+func (r *serviceRepository[T]) List(opts *QueryOptions) ([]T, error) {
+	resp, err := GetEntitySet[T](r.service, r.entitySet, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.D.Result, nil
+}
+
+func (r *serviceRepository[T]) Get(key string, opts *QueryOptions) (T, error) {
+	resp, err := GetEntityByKey[T](r.service, r.entitySet, key, opts)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return resp.D.Result, nil
+}
+
+func (r *serviceRepository[T]) Create(entity T) (T, error) {
+	resp, err := CreateEntity[T](r.service, r.entitySet, entity)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return resp.D.Result, nil
+}
+
+func (r *serviceRepository[T]) Update(key string, entity T) error {
+	return UpdateEntity(r.service, r.entitySet, key, entity)
+}
+
+func (r *serviceRepository[T]) Patch(key string, fields interface{}) error {
+	return PatchEntity(r.service, r.entitySet, key, fields)
+}
+
+func (r *serviceRepository[T]) Delete(key string) error {
+	return DeleteEntity(r.service, r.entitySet, key)
+}
@@ -0,0 +1,20 @@
+package odata
+
+import "github.com/Willias7788/go-odata-v2-sdk/client"
+
+// SandboxHost is the sandbox of the SAP Business Accelerator Hub (api.sap.com)
+const SandboxHost = "https://sandbox.api.sap.com"
+
+// NewSandboxService returns a service of the api.sap.com sandbox, for prototyping against
+// public SAP APIs. servicePath is the path the hub lists for the API, e.g.
+// "/s4hanacloud/sap/opu/odata/sap/API_PRODUCT_SRV"; apiKey is shown on the hub after logging
+// on. The sandbox answers reads only and rate limits them, so reads rejected with 429 or a
+// gateway error are retried, and $format=json is sent since not every sandbox API honours
+// the Accept header.
+func NewSandboxService(apiKey, servicePath string, opts ...ServiceOption) *Service {
+	c := client.New(SandboxHost,
+		client.WithHeader("APIKey", apiKey),
+		client.WithRetry(&client.RetryPolicy{MaxRetries: 3}),
+	)
+	return NewService(c, servicePath, append([]ServiceOption{WithJSONFormat()}, opts...)...)
+}
@@ -0,0 +1,311 @@
+package odata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+)
+
+// Address is a postal address assembled from properties annotated with the vCard adr
+// semantics (street, city, zip, region, country, pobox)
+type Address struct {
+	Street     string
+	Extended   string // e.g. building or floor
+	POBox      string
+	City       string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+// Contact is a person or organization assembled from properties annotated with vCard
+// semantics, as business partner style services annotate them
+type Contact struct {
+	FullName     string
+	GivenName    string
+	FamilyName   string
+	MiddleName   string
+	Honorific    string
+	Suffix       string
+	Nickname     string
+	Title        string
+	Role         string
+	Organization string
+	OrgUnit      string
+	Birthday     time.Time
+	Email        string
+	Phone        string
+	Mobile       string
+	Fax          string
+	URL          string
+	Note         string
+	Address      Address
+}
+
+// CalendarEvent is an appointment or task assembled from properties annotated with
+// iCalendar semantics
+type CalendarEvent struct {
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Due         time.Time
+	Completed   time.Time
+	Status      string
+	Class       string
+	Priority    string
+}
+
+// SemanticMapper assembles entities of one entity type into Address, Contact and
+// CalendarEvent values, following the sap:semantics annotations of its properties,
+// including those of complex properties
+type SemanticMapper struct {
+	fields []semanticField
+}
+
+// semanticField is an annotated property, by its path from the entity
+type semanticField struct {
+	path  []string
+	name  string          // semantics without type parameters, e.g. "tel"
+	types map[string]bool // type parameters, e.g. work and pref
+}
+
+// NewSemanticMapper returns a mapper for entities of t, resolving complex types in doc
+func NewSemanticMapper(doc *metadata.Document, t *metadata.EntityType) *SemanticMapper {
+	m := &SemanticMapper{}
+	m.collect(doc, t.Properties, nil, 0)
+	return m
+}
+
+// SemanticMapper returns a mapper for the entities of entitySet, read from the service's metadata
+func (s *Service) SemanticMapper(entitySet string) (*SemanticMapper, error) {
+	doc, err := GetMetadata(s)
+	if err != nil {
+		return nil, err
+	}
+	_, et, err := doc.EntitySet(entitySet)
+	if err != nil {
+		return nil, err
+	}
+	return NewSemanticMapper(doc, et), nil
+}
+
+func (m *SemanticMapper) collect(doc *metadata.Document, props []metadata.Property, prefix []string, depth int) {
+	for i := range props {
+		p := &props[i]
+		path := append(append([]string(nil), prefix...), p.Name)
+		if sem := p.Semantics(); sem != "" {
+			name, params, _ := strings.Cut(sem, ";")
+			f := semanticField{path: path, name: strings.ToLower(name), types: make(map[string]bool)}
+			if t, ok := strings.CutPrefix(params, "type="); ok {
+				for _, v := range strings.Split(t, ",") {
+					f.types[strings.ToLower(v)] = true
+				}
+			}
+			m.fields = append(m.fields, f)
+		}
+		if ct, ok := doc.ComplexType(p.Type); ok && depth < 8 {
+			m.collect(doc, ct.Properties, path, depth+1)
+		}
+	}
+}
+
+// Address returns the address of entity, a map or a struct with the property names as JSON
+// names
+func (m *SemanticMapper) Address(entity interface{}) (Address, error) {
+	e, err := entityMap(entity)
+	if err != nil {
+		return Address{}, err
+	}
+	return m.address(e), nil
+}
+
+func (m *SemanticMapper) address(e map[string]interface{}) Address {
+	var a Address
+	for _, f := range m.fields {
+		v := f.text(e)
+		switch f.name {
+		case "street":
+			a.Street = first(a.Street, v)
+		case "extended", "extadr":
+			a.Extended = first(a.Extended, v)
+		case "pobox":
+			a.POBox = first(a.POBox, v)
+		case "city":
+			a.City = first(a.City, v)
+		case "region":
+			a.Region = first(a.Region, v)
+		case "zip":
+			a.PostalCode = first(a.PostalCode, v)
+		case "country":
+			a.Country = first(a.Country, v)
+		}
+	}
+	return a
+}
+
+// Contact returns the contact data of entity, a map or a struct with the property names as
+// JSON names. Of several phone numbers, cell and fax numbers go to Mobile and Fax, and
+// preferred ones win.
+func (m *SemanticMapper) Contact(entity interface{}) (Contact, error) {
+	e, err := entityMap(entity)
+	if err != nil {
+		return Contact{}, err
+	}
+	c := Contact{Address: m.address(e)}
+	for _, f := range m.fields {
+		v := f.text(e)
+		if v == "" {
+			continue
+		}
+		switch f.name {
+		case "name":
+			c.FullName = first(c.FullName, v)
+		case "givenname":
+			c.GivenName = first(c.GivenName, v)
+		case "familyname":
+			c.FamilyName = first(c.FamilyName, v)
+		case "middlename":
+			c.MiddleName = first(c.MiddleName, v)
+		case "honorific":
+			c.Honorific = first(c.Honorific, v)
+		case "suffix":
+			c.Suffix = first(c.Suffix, v)
+		case "nickname":
+			c.Nickname = first(c.Nickname, v)
+		case "title":
+			c.Title = first(c.Title, v)
+		case "role":
+			c.Role = first(c.Role, v)
+		case "org":
+			c.Organization = first(c.Organization, v)
+		case "org-unit":
+			c.OrgUnit = first(c.OrgUnit, v)
+		case "bday":
+			if c.Birthday.IsZero() {
+				c.Birthday = f.time(e)
+			}
+		case "email":
+			c.Email = preferred(c.Email, v, f)
+		case "tel":
+			switch {
+			case f.types["cell"]:
+				c.Mobile = preferred(c.Mobile, v, f)
+			case f.types["fax"]:
+				c.Fax = preferred(c.Fax, v, f)
+			default:
+				c.Phone = preferred(c.Phone, v, f)
+			}
+		case "url":
+			c.URL = first(c.URL, v)
+		case "note":
+			c.Note = first(c.Note, v)
+		}
+	}
+	return c, nil
+}
+
+// Event returns the calendar data of entity, a map or a struct with the property names as
+// JSON names
+func (m *SemanticMapper) Event(entity interface{}) (CalendarEvent, error) {
+	e, err := entityMap(entity)
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	var ev CalendarEvent
+	for _, f := range m.fields {
+		switch f.name {
+		case "summary":
+			ev.Summary = first(ev.Summary, f.text(e))
+		case "description":
+			ev.Description = first(ev.Description, f.text(e))
+		case "location":
+			ev.Location = first(ev.Location, f.text(e))
+		case "dtstart":
+			ev.Start = f.time(e)
+		case "dtend":
+			ev.End = f.time(e)
+		case "due":
+			ev.Due = f.time(e)
+		case "completed":
+			ev.Completed = f.time(e)
+		case "status":
+			ev.Status = first(ev.Status, f.text(e))
+		case "class":
+			ev.Class = first(ev.Class, f.text(e))
+		case "priority":
+			ev.Priority = first(ev.Priority, f.text(e))
+		}
+	}
+	return ev, nil
+}
+
+// value returns the property of f in e, nil if absent
+func (f semanticField) value(e map[string]interface{}) interface{} {
+	var v interface{} = e
+	for _, name := range f.path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// text returns the property of f as a string, "" if absent or null
+func (f semanticField) text(e map[string]interface{}) string {
+	switch v := f.value(e).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// time returns an Edm.DateTime property of f, the zero time if absent or invalid
+func (f semanticField) time(e map[string]interface{}) time.Time {
+	t, _ := models.ParseDateTime(f.text(e))
+	return t
+}
+
+func first(current, v string) string {
+	if current != "" {
+		return current
+	}
+	return v
+}
+
+// preferred keeps current unless it is empty or v is marked pref
+func preferred(current, v string, f semanticField) string {
+	if current == "" || f.types["pref"] {
+		return v
+	}
+	return current
+}
+
+// entityMap returns entity as a generic map, decoding it through JSON unless it is one
+func entityMap(entity interface{}) (map[string]interface{}, error) {
+	if m, ok := entity.(map[string]interface{}); ok {
+		return m, nil
+	}
+	raw, ok := entity.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(entity); err != nil {
+			return nil, fmt.Errorf("encoding entity: %w", err)
+		}
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("decoding entity: %w", err)
+	}
+	return m, nil
+}
@@ -1,23 +1,56 @@
 package odata
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/Willias7788/go-odata-v2-sdk/client"
 	"github.com/Willias7788/go-odata-v2-sdk/models"
 )
 
-// Service represents a specific OData service endpoint
+// Service represents a specific OData service endpoint. It is not changed after NewService
+// and is safe for concurrent use; variations such as per-tenant headers are derived copies
+// (see WithOptions), which leave the original and other copies untouched.
 type Service struct {
 	client      *client.SAPClient
 	servicePath string // e.g. "/sap/opu/odata/IWBEP/GWSAMPLE_BASIC/"
+	ctx         context.Context
+	limits      DecodeLimits
+	urls        *urlCache
+	metrics     MetricsHook
+	bulkhead    *client.Bulkhead
+
+	headers  map[string]string // sent with every request
+	query    map[string]string // sent with every request
+	defaults *QueryOptions     // applied to reads
+	flavor   Flavor
+	meta     *metadataCache
+	preload  bool
+	hooks    entityHooks
+	validate Validator
+	fallback *fallbackLog
+	// maxPageSize is the default and maximum $top of entity set reads, 0 for unlimited
+	maxPageSize    int
+	validateFilter bool
+	jsonFormat     bool
+	location       *time.Location // backend time zone of Edm.DateTime values, nil for UTC
+	names          *nameMapping   // nil maps by odata tags only
+	pin            *metadataPin
+	etags          ETagStore
+	sinks          []EventSink
+	audit          *auditLog
+	batchLimits    *batchLimits
+	codec          JSONCodec // nil for encoding/json
+	projection     *projectionLog
 }
 
 // NewService creates a new OData service handler
-func NewService(client *client.SAPClient, servicePath string) *Service {
+func NewService(client *client.SAPClient, servicePath string, opts ...ServiceOption) *Service {
 	// Ensure service path has trailing slash
 	if !strings.HasSuffix(servicePath, "/") {
 		servicePath += "/"
@@ -26,206 +59,209 @@ func NewService(client *client.SAPClient, servicePath string) *Service {
 	if !strings.HasPrefix(servicePath, "/") {
 		servicePath = "/" + servicePath
 	}
-	return &Service{
+	s := &Service{
 		client:      client,
 		servicePath: servicePath,
+		urls:        newURLCache(),
+		batchLimits: newBatchLimits(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.preload {
+		_, _ = GetMetadata(s) // failures are retried by the first GetMetadata call
+	}
+	return s
+}
+
+// WithContext returns a shallow copy of the service whose requests are bound to ctx.
+// Use it for cancellation/deadlines and to carry per-request data such as the
+// user token for principal propagation (see client.ContextWithUserToken).
+func (s *Service) WithContext(ctx context.Context) *Service {
+	s2 := *s
+	s2.ctx = ctx
+	return &s2
+}
+
+// WithBulkhead returns a shallow copy of the service whose requests also take a slot of b,
+// isolating this consumer's concurrency from others sharing the client
+func (s *Service) WithBulkhead(b *client.Bulkhead) *Service {
+	s2 := *s
+	s2.bulkhead = b
+	return &s2
+}
+
+// WithOptions returns a copy of the service with opts applied on top of its configuration,
+// e.g. for one tenant or request. WithMetadataPreload fetches $metadata for the copy.
+func (s *Service) WithOptions(opts ...ServiceOption) *Service {
+	s2 := *s
+	s2.preload = false
+	for _, opt := range opts {
+		opt(&s2)
+	}
+	if s2.preload {
+		_, _ = GetMetadata(&s2)
+	}
+	return &s2
+}
+
+// WithHeaders returns a copy of the service that also sends headers with every request
+func (s *Service) WithHeaders(headers map[string]string) *Service {
+	return s.WithOptions(WithHeaders(headers))
+}
+
+// WithFlavor returns a copy of the service speaking the server dialect f
+func (s *Service) WithFlavor(f Flavor) *Service {
+	return s.WithOptions(WithFlavor(f))
+}
+
+func (s *Service) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
 }
 
 func (s *Service) buildURL(entitySet string) string {
-	return s.servicePath + entitySet
+	return s.urls.prefix(s.servicePath, entitySet)
 }
 
+// buildKeyURL returns the URL of an entity. key is an unencoded predicate like "('123')" or
+// "(Id='123',Type='A')", see Key and CompositeKey; without parentheses it is wrapped in them.
 func (s *Service) buildKeyURL(entitySet, key string) string {
-	// Simple check: if key doesn't start with (, wrap it? 
-	// OData keys can be complicated (prop=val vs 'val'). 
-	// We assume user passes valid key predicate like "('123')" or "(Id='123',Type='A')"
-	// If the user just passes "123", we might want to be smart, but generic SDKs should prioritize predictability.
-	// We'll trust the user passed the predicate.
+	return s.buildURL(entitySet) + keyPredicate(key)
+}
+
+// keyPredicate returns key in parentheses, percent-encoded for the resource path
+func keyPredicate(key string) string {
 	if !strings.HasPrefix(key, "(") {
 		key = "(" + key + ")"
 	}
-	return s.servicePath + entitySet + key
+	return escapeKey(key)
+}
+
+// keyPath returns the path of an entity relative to the service root, as used in $batch
+func (s *Service) keyPath(entitySet, key string) string {
+	return strings.TrimPrefix(s.buildKeyURL(entitySet, key), s.servicePath)
 }
 
 func (s *Service) buildNavigationURL(entitySet, key, navProperty string) string {
-	if !strings.HasPrefix(key, "(") {
-		key = "(" + key + ")"
-	}
-	return s.servicePath + entitySet + key + "/" + navProperty
+	return s.buildKeyURL(entitySet, key) + "/" + navProperty
 }
 
 // GetEntitySet fetches a collection of entities
 func GetEntitySet[T any](s *Service, entitySet string, opts *QueryOptions) (*models.ODataResponse[[]T], error) {
-	url := s.buildURL(entitySet)
-	var qParams map[string]string
-	if opts != nil {
-		qParams = opts.Build()
-	}
-
-	resp, err := s.client.ExecuteRequest(http.MethodGet, url, nil, qParams)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, parseError(resp.Body())
-	}
-
 	var result models.ODataResponse[[]T]
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	c := &call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: queryParams(opts), unbounded: opts.unboundedAllowed()}
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
 	}
-
+	result.Execution = c.execution
 	return &result, nil
 }
 
 // GetEntityByKey fetches a single entity
 func GetEntityByKey[T any](s *Service, entitySet, key string, opts *QueryOptions) (*models.ODataResponse[T], error) {
-	url := s.buildKeyURL(entitySet, key)
-	var qParams map[string]string
-	if opts != nil {
-		qParams = opts.Build()
-	}
-
-	resp, err := s.client.ExecuteRequest(http.MethodGet, url, nil, qParams)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, parseError(resp.Body())
-	}
-
 	var result models.ODataResponse[T]
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	c := &call{operation: OpGet, entitySet: entitySet, method: http.MethodGet, url: s.buildKeyURL(entitySet, key), query: queryParams(opts)}
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
 	}
-
+	result.Execution = c.execution
 	return &result, nil
 }
 
 // GetNavigationSet fetches a collection of related entities via a navigation property.
 // Example URL: EntitySet('key')/NavigationProperty
 func GetNavigationSet[T any](s *Service, entitySet, key, navProperty string, opts *QueryOptions) (*models.ODataResponse[[]T], error) {
-	url := s.buildNavigationURL(entitySet, key, navProperty)
-	var qParams map[string]string
-	if opts != nil {
-		qParams = opts.Build()
-	}
-
-	resp, err := s.client.ExecuteRequest(http.MethodGet, url, nil, qParams)
-	if err != nil {
+	var result models.ODataResponse[[]T]
+	c := &call{operation: OpNavigation, entitySet: entitySet, method: http.MethodGet, url: s.buildNavigationURL(entitySet, key, navProperty), query: queryParams(opts), unbounded: opts.unboundedAllowed()}
+	if err := s.execute(c, &result); err != nil {
 		return nil, err
 	}
+	result.Execution = c.execution
+	return &result, nil
+}
 
-	if resp.IsError() {
-		return nil, parseError(resp.Body())
+// GetNavigation fetches what a navigation property of an entity leads to, e.g.
+// ProductSet('HT-1000')/ToSupplier. T is the entity type for a to-one property, with
+// ErrNotFound if there is no related entity, and a slice for a to-many property, which
+// is read like GetNavigationSet.
+func GetNavigation[T any](s *Service, entitySet, key, navProperty string, opts *QueryOptions) (*models.ODataResponse[T], error) {
+	var result models.ODataResponse[T]
+	c := &call{operation: OpGet, entitySet: entitySet, method: http.MethodGet, url: s.buildNavigationURL(entitySet, key, navProperty), query: queryParams(opts)}
+	if k := reflect.TypeFor[T]().Kind(); k == reflect.Slice || k == reflect.Array {
+		c.operation, c.unbounded = OpNavigation, opts.unboundedAllowed()
 	}
-
-	var result models.ODataResponse[[]T]
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
 	}
-
+	result.Execution = c.execution
 	return &result, nil
 }
 
 // CreateNavigationEntity creates a new related entity via a navigation property (POST).
 // Example URL: POST EntitySet('key')/NavigationProperty
 func CreateNavigationEntity[T any](s *Service, entitySet, key, navProperty string, payload interface{}) (*models.ODataResponse[T], error) {
-	url := s.buildNavigationURL(entitySet, key, navProperty)
-	
-	resp, err := s.client.ExecuteRequest(http.MethodPost, url, payload, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, parseError(resp.Body())
-	}
-
 	var result models.ODataResponse[T]
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	c := &call{operation: OpCreate, entitySet: entitySet, method: http.MethodPost, url: s.buildNavigationURL(entitySet, key, navProperty), payload: payload}
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
 	}
-
+	result.Execution = c.execution
 	return &result, nil
 }
 
 // CreateEntity creates a new entity
-func CreateEntity[T any](s *Service, entitySet string, payload interface{}) (*models.ODataResponse[T], error) {
-	url := s.buildURL(entitySet)
-	
-	resp, err := s.client.ExecuteRequest(http.MethodPost, url, payload, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.IsError() {
-		return nil, parseError(resp.Body())
+func CreateEntity[T any](s *Service, entitySet string, payload interface{}, opts ...CreateOption) (*models.ODataResponse[T], error) {
+	cfg := &createConfig{}
+	for _, opt := range opts {
+		opt(cfg)
 	}
-
 	var result models.ODataResponse[T]
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	c := &call{operation: OpCreate, entitySet: entitySet, method: http.MethodPost, url: s.buildURL(entitySet), payload: payload, refetch: cfg.refetch}
+	if err := s.execute(c, &result); err != nil {
+		return nil, err
 	}
-
+	if cfg.refetch {
+		return refetch[T](s, c, cfg.query)
+	}
+	result.Execution = c.execution
 	return &result, nil
 }
 
 // UpdateEntity updates an existing entity (PUT)
 func UpdateEntity(s *Service, entitySet, key string, payload interface{}) error {
-	url := s.buildKeyURL(entitySet, key)
-	
-	resp, err := s.client.ExecuteRequest(http.MethodPut, url, payload, nil)
-	if err != nil {
-		return err
-	}
-
-	if resp.IsError() {
-		return parseError(resp.Body())
-	}
-
-	return nil
+	return s.execute(&call{operation: OpUpdate, entitySet: entitySet, method: http.MethodPut, url: s.buildKeyURL(entitySet, key), payload: payload}, nil)
 }
 
 // PatchEntity updates an existing entity (PATCH/MERGE)
 func PatchEntity(s *Service, entitySet, key string, payload interface{}) error {
-	url := s.buildKeyURL(entitySet, key)
-	
-	resp, err := s.client.ExecuteRequest(http.MethodPatch, url, payload, nil)
-	if err != nil {
-		return err
-	}
-
-	if resp.IsError() {
-		return parseError(resp.Body())
-	}
-
-	return nil
+	return s.execute(&call{operation: OpPatch, entitySet: entitySet, method: s.patchMethod(), url: s.buildKeyURL(entitySet, key), payload: payload}, nil)
 }
 
 // DeleteEntity deletes an entity
 func DeleteEntity(s *Service, entitySet, key string) error {
-	url := s.buildKeyURL(entitySet, key)
-	
-	resp, err := s.client.ExecuteRequest(http.MethodDelete, url, nil, nil)
-	if err != nil {
-		return err
-	}
-
-	if resp.IsError() {
-		return parseError(resp.Body())
-	}
-
-	return nil
+	return s.execute(&call{operation: OpDelete, entitySet: entitySet, method: http.MethodDelete, url: s.buildKeyURL(entitySet, key)}, nil)
 }
 
-func parseError(body []byte) error {
+// parseError decodes an OData error payload, selecting its message in language
+func parseError(body []byte, language string) error {
 	var errResp models.ODataErrorResponse
 	if err := json.Unmarshal(body, &errResp); err != nil {
 		return fmt.Errorf("http error and failed to parse odata error: %s", string(body))
 	}
+	if language != "" {
+		errResp.Err.SelectLanguage(language)
+	}
 	return &errResp
 }
+
+// language returns the logon language the service's requests are sent with, which error
+// messages are selected in
+func (s *Service) language() string {
+	if l := s.query["sap-language"]; l != "" {
+		return l
+	}
+	return s.client.Language()
+}
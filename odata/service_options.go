@@ -0,0 +1,141 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+// ServiceOption configures a Service created with NewService or derived with WithOptions
+type ServiceOption func(*Service)
+
+// ErrPageSizeExceeded is returned for reads asking for more entities than WithMaxPageSize allows
+var ErrPageSizeExceeded = errors.New("odata: page size exceeds the maximum of the service")
+
+// Flavor selects between dialects of OData V2 servers
+type Flavor int
+
+const (
+	// FlavorSAPGateway sends partial updates as PATCH (the default)
+	FlavorSAPGateway Flavor = iota
+	// FlavorStandard follows the OData V2 specification and sends partial updates as MERGE
+	FlavorStandard
+)
+
+// WithHeaders sends headers with every request of the service, e.g. sap-language.
+// Headers set by an individual call take precedence.
+func WithHeaders(headers map[string]string) ServiceOption {
+	return func(s *Service) {
+		s.headers = merge(merge(nil, s.headers), headers)
+	}
+}
+
+// WithSAPClient selects the SAP client (mandant) for this service only, for services of
+// different clients on one system sharing a SAPClient
+func WithSAPClient(sapClient string) ServiceOption {
+	return func(s *Service) {
+		s.query = merge(merge(nil, s.query), map[string]string{"sap-client": sapClient})
+	}
+}
+
+// WithFlavor selects the server dialect
+func WithFlavor(f Flavor) ServiceOption {
+	return func(s *Service) {
+		s.flavor = f
+	}
+}
+
+// WithDefaultQuery applies opts to every entity read (list, get, navigation) of the service. Options passed to an
+// individual call override the defaults parameter by parameter.
+func WithDefaultQuery(opts *QueryOptions) ServiceOption {
+	return func(s *Service) {
+		s.defaults = opts
+	}
+}
+
+// WithMetadataPreload fetches $metadata when the service is created and keeps it for
+// GetMetadata. If the fetch fails, GetMetadata retries it on first use.
+func WithMetadataPreload() ServiceOption {
+	return func(s *Service) {
+		s.meta = &metadataCache{}
+		s.preload = true
+	}
+}
+
+// metadataCache holds a preloaded document, shared by copies of the Service
+type metadataCache struct {
+	mu  sync.Mutex
+	doc *metadata.Document
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// patchMethod returns the method for partial updates
+func (s *Service) patchMethod() string {
+	if s.flavor == FlavorStandard {
+		return "MERGE"
+	}
+	return http.MethodPatch
+}
+
+// WithJSONFormat appends $format=json to every entity and function import request, for
+// gateways that ignore the Accept header and answer in Atom XML otherwise
+func WithJSONFormat() ServiceOption {
+	return func(s *Service) {
+		s.jsonFormat = true
+	}
+}
+
+// formatQuery adds $format=json to the query of c if the service asks for it
+func (s *Service) formatQuery(c *call, query map[string]string) map[string]string {
+	switch {
+	case !s.jsonFormat, query[OptionFormat] != "":
+		return query
+	case c.operation == OpMetadata, c.operation == OpBatch, c.operation == OpMedia, c.operation == OpCount:
+		return query
+	}
+	return merge(merge(nil, query), map[string]string{OptionFormat: "json"})
+}
+
+// WithMaxPageSize bounds the entity set reads of the service, so a forgotten $top cannot
+// scan a whole entity set in production: reads without $top get $top=n and reads asking
+// for more, or with a $top that is not a number, fail. GetEntitySetAll and Pages read the
+// set n entities at a time. QueryOptions.Unbounded lifts the limit for a single query, e.g.
+// for a StreamEntitySet export.
+func WithMaxPageSize(n int) ServiceOption {
+	return func(s *Service) {
+		s.maxPageSize = n
+	}
+}
+
+// limitPage applies the maximum page size to the query of c
+func (s *Service) limitPage(c *call, query map[string]string) (map[string]string, error) {
+	if s.maxPageSize <= 0 || c.unbounded || (c.operation != OpList && c.operation != OpNavigation) {
+		return query, nil
+	}
+	raw, ok := query[OptionTop]
+	if !ok {
+		query = merge(merge(nil, query), map[string]string{OptionTop: strconv.Itoa(s.maxPageSize)})
+		return query, nil
+	}
+	top, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", OptionTop, raw, err)
+	}
+	if top > s.maxPageSize {
+		return nil, fmt.Errorf("%w: $top=%d, maximum %d (use QueryOptions.Unbounded to allow it)", ErrPageSizeExceeded, top, s.maxPageSize)
+	}
+	return query, nil
+}
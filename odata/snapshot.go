@@ -0,0 +1,199 @@
+package odata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Snapshot keeps copies of entities so they can be put back later, e.g. by integration tests
+// or maintenance runs against a shared QA system that must leave its data as they found it
+type Snapshot struct {
+	service  *Service
+	entities []snapshotEntity
+	queries  []snapshotQuery
+}
+
+type snapshotEntity struct {
+	entitySet string
+	key       string
+	data      map[string]json.RawMessage
+}
+
+// snapshotQuery is an entity set read by Where, whose new matches Restore deletes
+type snapshotQuery struct {
+	entitySet string
+	opts      *QueryOptions
+	keys      map[string]bool
+}
+
+// NewSnapshot returns an empty snapshot of entities of the service
+func (s *Service) NewSnapshot() *Snapshot {
+	return &Snapshot{service: s}
+}
+
+// Entity adds the current state of an entity to the snapshot
+func (sn *Snapshot) Entity(entitySet, key string) error {
+	c := &call{operation: OpGet, entitySet: entitySet, method: http.MethodGet, url: sn.service.buildKeyURL(entitySet, key)}
+	var resp struct {
+		D map[string]json.RawMessage `json:"d"`
+	}
+	if err := sn.service.execute(c, &resp); err != nil {
+		return fmt.Errorf("snapshot of %s: %w", sn.service.keyPath(entitySet, key), err)
+	}
+	sn.entities = append(sn.entities, snapshotEntity{entitySet: entitySet, key: sn.service.entityKey(c), data: resp.D})
+	return nil
+}
+
+// Where adds every entity of entitySet matching opts to the snapshot, reading all pages.
+// Entities that match opts at restore time but were not part of the snapshot, e.g. those a
+// test created, are deleted by Restore.
+func (sn *Snapshot) Where(entitySet string, opts *QueryOptions) error {
+	q := snapshotQuery{entitySet: entitySet, opts: opts, keys: make(map[string]bool)}
+	err := sn.each(entitySet, opts, func(key string, data map[string]json.RawMessage) {
+		q.keys[key] = true
+		sn.entities = append(sn.entities, snapshotEntity{entitySet: entitySet, key: key, data: data})
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot of %s: %w", entitySet, err)
+	}
+	sn.queries = append(sn.queries, q)
+	return nil
+}
+
+// each passes the key and properties of every entity of entitySet matching opts to fn
+func (sn *Snapshot) each(entitySet string, opts *QueryOptions, fn func(key string, data map[string]json.RawMessage)) error {
+	var link string
+	for {
+		page, err := GetDeltaPage(sn.service, entitySet, opts, link)
+		if err != nil {
+			return err
+		}
+		for _, raw := range page.Entities {
+			var data map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return fmt.Errorf("decoding entity: %w", err)
+			}
+			var meta struct {
+				URI string `json:"uri"`
+			}
+			_ = json.Unmarshal(data["__metadata"], &meta)
+			key := uriKey(meta.URI)
+			if key == "" {
+				return fmt.Errorf("entity has no __metadata uri")
+			}
+			fn(key, data)
+		}
+		if page.Next == "" {
+			return nil
+		}
+		link = page.Next
+	}
+}
+
+// Restore puts every entity of the snapshot back as it was: changed entities are updated,
+// deleted ones created again, and entities created since within the scope of Where deleted.
+// The writes are sent in $batch requests, each in its own changeset, so one failure does not
+// stop the others; the returned error joins all failures.
+func (sn *Snapshot) Restore() error {
+	s := sn.service
+	var errs []error
+
+	var deletes []*BatchOperation
+	for _, q := range sn.queries {
+		err := sn.each(q.entitySet, q.opts, func(key string, _ map[string]json.RawMessage) {
+			if !q.keys[key] {
+				deletes = append(deletes, &BatchOperation{Method: http.MethodDelete, Path: s.keyPath(q.entitySet, key)})
+			}
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading %s: %w", q.entitySet, err))
+		}
+	}
+
+	updates := make([]*BatchOperation, len(sn.entities))
+	for i, e := range sn.entities {
+		updates[i] = &BatchOperation{Method: http.MethodPut, Path: s.keyPath(e.entitySet, e.key), Body: restorable(e.data)}
+	}
+	results, err := sn.send(append(deletes, updates...))
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	// Updates of entities deleted in the meantime fail with 404; they are created again
+	var creates []*BatchOperation
+	for _, r := range results {
+		switch err := r.Err(); {
+		case err == nil:
+		case r.Operation.Method == http.MethodPut && r.StatusCode == http.StatusNotFound:
+			entitySet, _, _ := strings.Cut(r.Operation.Path, "(")
+			creates = append(creates, &BatchOperation{Method: http.MethodPost, Path: entitySet, Body: r.Operation.Body})
+		default:
+			errs = append(errs, fmt.Errorf("restoring %s: %w", r.Operation.Path, err))
+		}
+	}
+	if len(creates) > 0 {
+		results, err = sn.send(creates)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for _, r := range results {
+			if err := r.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("restoring %s: %w", r.Operation.Path, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send executes ops and returns the results of the $batch requests that succeeded
+func (sn *Snapshot) send(ops []*BatchOperation) ([]*BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	resp, err := sn.service.NewBatchExecutor().Execute(sn.service.context(), ops)
+	results := make([]*BatchResult, 0, len(ops))
+	for _, r := range resp.Results {
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	return results, err
+}
+
+// restorable returns the properties of a read entity that can be written back: metadata,
+// deferred and expanded navigation properties are left out
+func restorable(data map[string]json.RawMessage) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage, len(data))
+	for name, value := range data {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		var nested map[string]json.RawMessage
+		if json.Unmarshal(value, &nested) == nil {
+			_, deferred := nested["__deferred"]
+			_, results := nested["results"]
+			_, entity := nested["__metadata"]
+			if deferred || results || entity && !isComplex(nested) {
+				continue
+			}
+		}
+		if len(value) > 0 && value[0] == '[' {
+			continue
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// isComplex reports whether a nested object carrying __metadata is a complex type value
+// rather than an expanded entity, which has a uri
+func isComplex(v map[string]json.RawMessage) bool {
+	var meta struct {
+		URI string `json:"uri"`
+	}
+	_ = json.Unmarshal(v["__metadata"], &meta)
+	return meta.URI == ""
+}
@@ -0,0 +1,179 @@
+package odata
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// ErrStopStream can be returned by a StreamEntitySet callback to stop reading without an error
+var ErrStopStream = errors.New("odata: stop stream")
+
+// StreamEntitySet is GetEntitySet for very large results: entities are decoded one at a time
+// from the response body and passed to fn, so neither the raw body nor the full slice is held
+// in memory. Returning an error from fn aborts the read; ErrStopStream aborts it silently.
+// DecodeLimits apply to the entities read so far. Options are sent as they are, without
+// WithClientSideFallback.
+func StreamEntitySet[T any](s *Service, entitySet string, opts *QueryOptions, fn func(T) error) (err error) {
+	c := &call{operation: OpList, entitySet: entitySet, method: http.MethodGet, url: s.buildURL(entitySet), query: queryParams(opts), unbounded: opts.unboundedAllowed()}
+	start := time.Now()
+	status := 0
+	body := &countingReader{}
+	defer func() {
+		s.observe(c, start, status, body.n, err)
+		err = s.fail(c, start, status, err)
+	}()
+
+	resp, err := s.send(c)
+	if err != nil {
+		return err
+	}
+	body.r = resp.RawBody()
+	defer resp.RawBody().Close()
+	status = resp.StatusCode()
+
+	if resp.IsError() {
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("reading response: %w", err)
+		}
+		return parseError(raw, s.language())
+	}
+
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(64)
+	if err := checkJSON(resp.Header().Get("Content-Type"), head); err != nil {
+		return err
+	}
+
+	limits := &limitCounter{limits: s.limits}
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	plain := !s.wireNames().renames(entityType) && !s.wireNames().nests(entityType)
+	err = streamResults(json.NewDecoder(br), func(dec *json.Decoder) error {
+		var v T
+		if s.codec == nil && !s.limits.enabled() && s.location == nil && plain {
+			if err := dec.Decode(&v); err != nil {
+				return fmt.Errorf("decoding response: %w", err)
+			}
+			return fn(v)
+		}
+
+		// entities sit at depth 3: {"d":{"results":[...]}}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		if err := limits.check(raw, 3, true); err != nil {
+			return err
+		}
+		if err := s.wireNames().unmarshal(s.codec, s.datesToUTC(raw), &v); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		return fn(v)
+	})
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	return err
+}
+
+// countingReader counts the bytes read through it, for RequestMetric.ResponseSize
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// streamResults walks {"d":{"results":[...]}} (or {"d":[...]}) and calls next with the
+// decoder positioned at each array element. Other members such as __count are skipped.
+func streamResults(dec *json.Decoder, next func(*json.Decoder) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		if key != "d" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		switch tok {
+		case json.Delim('['):
+			return streamArray(dec, next)
+		case json.Delim('{'):
+		default:
+			return fmt.Errorf("decoding response: unexpected %v in d", tok)
+		}
+		for dec.More() {
+			member, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("decoding response: %w", err)
+			}
+			if member != "results" {
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			if err := streamArray(dec, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("decoding response: no d member")
+}
+
+// streamArray calls next for each remaining element and consumes the closing bracket
+func streamArray(dec *json.Decoder, next func(*json.Decoder) error) error {
+	for dec.More() {
+		if err := next(dec); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if tok != want {
+		return fmt.Errorf("decoding response: expected %v, got %v", want, tok)
+	}
+	return nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var skip json.RawMessage
+	if err := dec.Decode(&skip); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package odata
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// WithTimeZone declares the time zone the backend writes Edm.DateTime values in. Many SAP
+// systems serialize timestamps in system time without an offset, so "/Date(ms)/" holds the
+// local wall clock as if it were UTC. With this option such values are converted to UTC in
+// responses and back to loc in payloads and in datetime'...' literals of query options, so
+// callers only ever see UTC. Values carrying an offset (Edm.DateTimeOffset) are left alone.
+func WithTimeZone(loc *time.Location) ServiceOption {
+	return func(s *Service) {
+		if loc == time.UTC {
+			loc = nil
+		}
+		s.location = loc
+	}
+}
+
+var (
+	// jsonDate matches "/Date(ms)/" in JSON text, where the slashes may be escaped
+	jsonDate = regexp.MustCompile(`(\\?/Date\()(-?\d+)(\)\\?/)`)
+	// literalDate matches the datetime'...' literals of a query option
+	literalDate = regexp.MustCompile(`datetime'([0-9T:.\-]+)'`)
+)
+
+// datesToUTC rewrites the backend local dates of a response body to UTC
+func (s *Service) datesToUTC(body []byte) []byte {
+	if s.location == nil {
+		return body
+	}
+	return shiftJSONDates(body, func(wall time.Time) time.Time {
+		return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), s.location)
+	})
+}
+
+// datesToLocal rewrites the UTC dates of an encoded payload to the backend's wall clock
+func (s *Service) datesToLocal(body []byte) []byte {
+	return shiftJSONDates(body, s.wallClock)
+}
+
+// wallClock returns the backend's wall clock for t, expressed as UTC
+func (s *Service) wallClock(t time.Time) time.Time {
+	l := t.In(s.location)
+	return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), l.Nanosecond(), time.UTC)
+}
+
+func shiftJSONDates(body []byte, shift func(time.Time) time.Time) []byte {
+	return jsonDate.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := jsonDate.FindSubmatch(m)
+		ms, err := strconv.ParseInt(string(parts[2]), 10, 64)
+		if err != nil {
+			return m
+		}
+		shifted := shift(time.UnixMilli(ms).UTC()).UnixMilli()
+		out := append([]byte(nil), parts[1]...)
+		out = strconv.AppendInt(out, shifted, 10)
+		return append(out, parts[3]...)
+	})
+}
+
+// localPayload encodes payload with its dates in the backend's time zone. Payloads that
+// are already encoded or not JSON are sent as they are.
+func (s *Service) localPayload(payload interface{}) interface{} {
+	if s.location == nil || payload == nil {
+		return payload
+	}
+	var body []byte
+	switch p := payload.(type) {
+	case []byte:
+		body = p
+	case string:
+		body = []byte(p)
+	case json.RawMessage:
+		body = p
+	default:
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return payload // let the client report it
+		}
+	}
+	if !json.Valid(body) {
+		return payload
+	}
+	return s.datesToLocal(body)
+}
+
+// localQuery converts the datetime'...' literals of query to the backend's time zone
+func (s *Service) localQuery(query map[string]string) map[string]string {
+	if s.location == nil {
+		return query
+	}
+	var out map[string]string
+	for k, v := range query {
+		converted := literalDate.ReplaceAllStringFunc(v, func(m string) string {
+			raw := literalDate.FindStringSubmatch(m)[1]
+			for _, layout := range []string{"2006-01-02T15:04:05.9999999", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+				if t, err := time.Parse(layout, raw); err == nil {
+					return "datetime'" + s.wallClock(t).Format(layout) + "'"
+				}
+			}
+			return m
+		})
+		if converted != v {
+			if out == nil {
+				out = merge(nil, query)
+			}
+			out[k] = converted
+		}
+	}
+	if out == nil {
+		return query
+	}
+	return out
+}
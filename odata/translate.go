@@ -0,0 +1,563 @@
+package odata
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// TranslateToV2 converts a query string written for an OData V4 service, e.g.
+// "$filter=contains(Name,'Bolt')&$count=true", into the closest V2 equivalent, to ease moving
+// callers between gateway versions. It maps $count=true to $inlinecount=allpages, $search to
+// SAP's search option, contains() to substringof(), "in" lists to or'ed comparisons, bare date,
+// time and guid literals to typed ones, and $select/$expand options nested in $expand to
+// paths. Constructs V2 has no counterpart for, such as lambda operators, $apply or nested
+// $filter, are reported as errors.
+func TranslateToV2(query string) (string, error) {
+	params, err := parseQueryString(query)
+	if err != nil {
+		return "", err
+	}
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		switch k {
+		case "$count":
+			if strings.EqualFold(v, "true") {
+				out[OptionInlineCount] = "allpages"
+			}
+		case "$search":
+			out["search"] = v
+		case OptionFilter:
+			if out[k], err = translateFilter(v, true); err != nil {
+				return "", err
+			}
+		case OptionExpand, OptionSelect:
+		case "$apply", "$compute", "$levels", "$index", "$schemaversion", "$deltatoken":
+			return "", fmt.Errorf("translating %s: not supported by OData V2", k)
+		default:
+			out[k] = v
+		}
+	}
+
+	if expand := params[OptionExpand]; expand != "" {
+		var exp expansion
+		if err := exp.toV2(expand, ""); err != nil {
+			return "", err
+		}
+		out[OptionExpand] = strings.Join(exp.paths, ",")
+		if sel := params[OptionSelect]; sel != "" || len(exp.fields) > 0 {
+			if sel == "" {
+				sel = "*"
+			}
+			// V2 only returns expanded navigation properties that are selected too
+			out[OptionSelect] = strings.Join(append(append([]string{sel}, exp.navs...), exp.fields...), ",")
+		}
+	} else if sel := params[OptionSelect]; sel != "" {
+		out[OptionSelect] = sel
+	}
+	return encodeQuery(out), nil
+}
+
+// TranslateToV4 converts a query string written for an OData V2 service into the closest V4
+// equivalent, the inverse of TranslateToV2: $inlinecount=allpages becomes $count=true, SAP's
+// search option $search, substringof() contains(), typed literals bare ones and selected
+// paths into expanded navigation properties nested $select options. V2 functions V4 lacks,
+// such as replace(), are reported as errors.
+func TranslateToV4(query string) (string, error) {
+	params, err := parseQueryString(query)
+	if err != nil {
+		return "", err
+	}
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		switch k {
+		case OptionInlineCount:
+			if strings.EqualFold(v, "allpages") {
+				out["$count"] = "true"
+			}
+		case "search":
+			out["$search"] = v
+		case OptionFilter:
+			if out[k], err = translateFilter(v, false); err != nil {
+				return "", err
+			}
+		case OptionExpand, OptionSelect:
+		default:
+			out[k] = v
+		}
+	}
+
+	root := &expandNode{}
+	for _, path := range splitTop(params[OptionExpand], ',') {
+		if path = strings.TrimSpace(path); path != "" {
+			root.child(strings.Split(path, "/"))
+		}
+	}
+	for _, path := range splitTop(params[OptionSelect], ',') {
+		if path = strings.TrimSpace(path); path != "" {
+			root.selectPath(strings.Split(path, "/"))
+		}
+	}
+	if len(root.selects) > 0 {
+		out[OptionSelect] = strings.Join(root.selects, ",")
+	}
+	if len(root.children) > 0 {
+		out[OptionExpand] = root.renderChildren()
+	}
+	return encodeQuery(out), nil
+}
+
+// parseQueryString parses a query string with or without the leading "?". Unlike
+// url.ParseQuery it accepts the semicolons separating V4 options nested in $expand.
+func parseQueryString(query string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(strings.TrimPrefix(query, "?"), "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: %w", err)
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing query: %w", err)
+		}
+		if _, dup := params[key]; dup {
+			return nil, fmt.Errorf("parsing query: %s given more than once", key)
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// expansion collects the V2 form of a V4 $expand
+type expansion struct {
+	paths  []string // $expand paths
+	navs   []string // expanded navigation properties to select
+	fields []string // properties selected inside expanded entities
+}
+
+func (e *expansion) toV2(expand, prefix string) error {
+	for _, item := range splitTop(expand, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		path, opts := item, ""
+		if i := strings.IndexByte(item, '('); i >= 0 && strings.HasSuffix(item, ")") {
+			path, opts = item[:i], item[i+1:len(item)-1]
+		}
+		path = prefix + strings.TrimSpace(path)
+		e.paths = append(e.paths, path)
+
+		selected := false
+		for _, opt := range splitTop(opts, ';') {
+			name, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch name {
+			case "":
+			case OptionSelect:
+				for _, p := range splitTop(value, ',') {
+					e.fields = append(e.fields, path+"/"+strings.TrimSpace(p))
+				}
+				selected = true
+			case OptionExpand:
+				if err := e.toV2(value, path+"/"); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("translating $expand: %s inside $expand is not supported by OData V2", name)
+			}
+		}
+		if !selected {
+			e.navs = append(e.navs, path)
+		}
+	}
+	return nil
+}
+
+// expandNode is a navigation property of a V4 $expand tree
+type expandNode struct {
+	name     string
+	selects  []string
+	children []*expandNode
+}
+
+// child returns the node at path below n, adding the missing nodes
+func (n *expandNode) child(path []string) *expandNode {
+	if len(path) == 0 {
+		return n
+	}
+	for _, c := range n.children {
+		if c.name == path[0] {
+			return c.child(path[1:])
+		}
+	}
+	c := &expandNode{name: path[0]}
+	n.children = append(n.children, c)
+	return c.child(path[1:])
+}
+
+// selectPath adds a V2 select path to the deepest expanded node it passes through. Selecting
+// an expanded navigation property as a whole needs no V4 counterpart.
+func (n *expandNode) selectPath(path []string) {
+	for _, c := range n.children {
+		if c.name == path[0] {
+			if len(path) > 1 {
+				c.selectPath(path[1:])
+			}
+			return
+		}
+	}
+	n.selects = append(n.selects, strings.Join(path, "/"))
+}
+
+func (n *expandNode) renderChildren() string {
+	items := make([]string, len(n.children))
+	for i, c := range n.children {
+		var opts []string
+		if len(c.selects) > 0 {
+			opts = append(opts, OptionSelect+"="+strings.Join(c.selects, ","))
+		}
+		if len(c.children) > 0 {
+			opts = append(opts, OptionExpand+"="+c.renderChildren())
+		}
+		items[i] = c.name
+		if len(opts) > 0 {
+			items[i] += "(" + strings.Join(opts, ";") + ")"
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// splitTop splits s at sep outside of parentheses and quotes
+func splitTop(s string, sep byte) []string {
+	if s == "" {
+		return nil
+	}
+	var parts []string
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// filter tokens
+const (
+	tokSpace = iota
+	tokWord
+	tokString
+	tokPunct
+)
+
+type filterToken struct {
+	kind   int
+	text   string // a string literal includes its quotes
+	prefix string // type prefix of a string literal, e.g. datetime
+}
+
+func (t filterToken) String() string { return t.prefix + t.text }
+
+var (
+	v4DateTime   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2})?$`)
+	v4Date       = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	v4Guid       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	v2TypedNum   = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?[mMdDfFlL]$`)
+	v2OnlyFuncs  = map[string]bool{"replace": true}
+	v4OnlyFuncs  = map[string]bool{"matchespattern": true, "now": true, "maxdatetime": true, "mindatetime": true, "totalseconds": true, "fractionalseconds": true, "totaloffsetminutes": true, "date": true, "time": true, "cast": true}
+	lambdaSuffix = regexp.MustCompile(`/(any|all)$`)
+)
+
+// translateFilter rewrites a $filter expression to V2 (toV2) or to V4
+func translateFilter(expr string, toV2 bool) (string, error) {
+	toks, err := tokenizeFilter(expr)
+	if err != nil {
+		return "", err
+	}
+	if toks, err = rewriteFilter(toks, toV2); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, t := range toks {
+		b.WriteString(t.String())
+	}
+	return b.String(), nil
+}
+
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			j := i
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+			toks = append(toks, filterToken{kind: tokSpace, text: s[i:j]})
+			i = j
+		case c == '(' || c == ')' || c == ',':
+			toks = append(toks, filterToken{kind: tokPunct, text: s[i : i+1]})
+			i++
+		case c == '\'':
+			end, err := quotedEnd(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, filterToken{kind: tokString, text: s[i:end]})
+			i = end
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t(),'", rune(s[j])) {
+				j++
+			}
+			if j < len(s) && s[j] == '\'' {
+				end, err := quotedEnd(s, j)
+				if err != nil {
+					return nil, err
+				}
+				toks = append(toks, filterToken{kind: tokString, prefix: s[i:j], text: s[j:end]})
+				i = end
+				continue
+			}
+			toks = append(toks, filterToken{kind: tokWord, text: s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// quotedEnd returns the index after the string literal starting at s[i]
+func quotedEnd(s string, i int) (int, error) {
+	for j := i + 1; j < len(s); j++ {
+		if s[j] != '\'' {
+			continue
+		}
+		if j+1 < len(s) && s[j+1] == '\'' {
+			j++
+			continue
+		}
+		return j + 1, nil
+	}
+	return 0, fmt.Errorf("translating $filter: unterminated string at position %d", i)
+}
+
+// nextToken returns the index of the first non-space token at or after i, len(toks) if none
+func nextToken(toks []filterToken, i int) int {
+	for i < len(toks) && toks[i].kind == tokSpace {
+		i++
+	}
+	return i
+}
+
+// closing returns the index of the parenthesis closing the one at toks[open]
+func closing(toks []filterToken, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(toks); i++ {
+		if toks[i].kind != tokPunct {
+			continue
+		}
+		switch toks[i].text {
+		case "(":
+			depth++
+		case ")":
+			if depth--; depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("translating $filter: unbalanced parentheses")
+}
+
+// arguments splits the tokens between parentheses at their top-level commas
+func arguments(toks []filterToken) [][]filterToken {
+	var args [][]filterToken
+	depth, start := 0, 0
+	for i, t := range toks {
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				args = append(args, toks[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, toks[start:])
+}
+
+func rewriteFilter(toks []filterToken, toV2 bool) ([]filterToken, error) {
+	var out []filterToken
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch t.kind {
+		case tokString:
+			out = append(out, translateLiteral(t, toV2))
+			continue
+		case tokWord:
+		default:
+			out = append(out, t)
+			continue
+		}
+
+		name := strings.ToLower(t.text)
+		if toV2 && name == "in" {
+			rewritten, next, err := rewriteIn(out, toks, i)
+			if err != nil {
+				return nil, err
+			}
+			out, i = rewritten, next
+			continue
+		}
+		if open := nextToken(toks, i+1); open < len(toks) && toks[open].text == "(" && toks[open].kind == tokPunct {
+			end, err := closing(toks, open)
+			if err != nil {
+				return nil, err
+			}
+			call, err := rewriteCall(t.text, toks[open+1:end], toV2)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, call...)
+			i = end
+			continue
+		}
+
+		switch {
+		case toV2 && name == "has":
+			return nil, fmt.Errorf("translating $filter: the has operator is not supported by OData V2")
+		default:
+			out = append(out, translateWord(t, toV2))
+		}
+	}
+	return out, nil
+}
+
+// rewriteCall translates a function call with the tokens of its arguments
+func rewriteCall(name string, inner []filterToken, toV2 bool) ([]filterToken, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case toV2 && (v4OnlyFuncs[lower] || lambdaSuffix.MatchString(lower) || strings.HasPrefix(lower, "geo.")):
+		return nil, fmt.Errorf("translating $filter: %s is not supported by OData V2", name)
+	case !toV2 && v2OnlyFuncs[lower]:
+		return nil, fmt.Errorf("translating $filter: %s is not supported by OData V4", name)
+	}
+
+	var args [][]filterToken
+	for _, arg := range arguments(inner) {
+		rewritten, err := rewriteFilter(arg, toV2)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, rewritten)
+	}
+	switch {
+	case toV2 && lower == "contains" && len(args) == 2:
+		name, args[0], args[1] = "substringof", args[1], args[0]
+	case !toV2 && lower == "substringof" && len(args) == 2:
+		name, args[0], args[1] = "contains", args[1], args[0]
+	}
+
+	out := []filterToken{{kind: tokWord, text: name}, {kind: tokPunct, text: "("}}
+	for i, arg := range args {
+		if i > 0 {
+			out = append(out, filterToken{kind: tokPunct, text: ","})
+		}
+		out = append(out, arg...)
+	}
+	return append(out, filterToken{kind: tokPunct, text: ")"}), nil
+}
+
+// rewriteIn turns "Prop in (a,b)" at toks[i] into "(Prop eq a or Prop eq b)", taking the
+// operand already written to out. It returns out and the index of the closing parenthesis.
+func rewriteIn(out, toks []filterToken, i int) ([]filterToken, int, error) {
+	last := len(out) - 1
+	for last >= 0 && out[last].kind == tokSpace {
+		last--
+	}
+	open := nextToken(toks, i+1)
+	if last < 0 || out[last].kind != tokWord || open == len(toks) || toks[open].text != "(" {
+		return nil, 0, fmt.Errorf("translating $filter: unsupported use of in")
+	}
+	end, err := closing(toks, open)
+	if err != nil {
+		return nil, 0, err
+	}
+	operand := out[last].text
+	var terms []string
+	for _, item := range arguments(toks[open+1 : end]) {
+		rewritten, err := rewriteFilter(item, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		var b strings.Builder
+		for _, t := range rewritten {
+			b.WriteString(t.String())
+		}
+		terms = append(terms, operand+" eq "+strings.TrimSpace(b.String()))
+	}
+	out = append(out[:last], filterToken{kind: tokWord, text: "(" + strings.Join(terms, " or ") + ")"})
+	return out, end, nil
+}
+
+// translateLiteral converts a typed string literal
+func translateLiteral(t filterToken, toV2 bool) filterToken {
+	prefix := strings.ToLower(t.prefix)
+	value := t.text[1 : len(t.text)-1]
+	switch {
+	case toV2 && prefix == "duration":
+		t.prefix = "time"
+	case toV2:
+	case prefix == "datetime":
+		if m := v4DateTime.FindStringSubmatch(value); m == nil || m[3] == "" {
+			value += "Z"
+		}
+		return filterToken{kind: tokWord, text: value}
+	case prefix == "datetimeoffset", prefix == "guid":
+		return filterToken{kind: tokWord, text: value}
+	case prefix == "time":
+		t.prefix = "duration"
+	}
+	return t
+}
+
+// translateWord converts bare literals: V4 dates and guids to typed V2 literals, and V2
+// numbers with a type suffix to plain numbers
+func translateWord(t filterToken, toV2 bool) filterToken {
+	switch {
+	case toV2 && v4DateTime.MatchString(t.text):
+		if strings.HasSuffix(t.text, "Z") {
+			return filterToken{kind: tokString, prefix: "datetime", text: "'" + strings.TrimSuffix(t.text, "Z") + "'"}
+		}
+		if v4DateTime.FindStringSubmatch(t.text)[3] != "" {
+			return filterToken{kind: tokString, prefix: "datetimeoffset", text: "'" + t.text + "'"}
+		}
+		return filterToken{kind: tokString, prefix: "datetime", text: "'" + t.text + "'"}
+	case toV2 && v4Date.MatchString(t.text):
+		return filterToken{kind: tokString, prefix: "datetime", text: "'" + t.text + "T00:00:00'"}
+	case toV2 && v4Guid.MatchString(t.text):
+		return filterToken{kind: tokString, prefix: "guid", text: "'" + t.text + "'"}
+	case !toV2 && v2TypedNum.MatchString(t.text):
+		return filterToken{kind: tokWord, text: t.text[:len(t.text)-1]}
+	}
+	return t
+}
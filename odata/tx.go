@@ -0,0 +1,155 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrTxDone is returned when a transaction is used after Commit or Rollback
+var ErrTxDone = errors.New("odata: transaction already committed or rolled back")
+
+// Tx queues writes and sends them on Commit as a single changeset of one $batch request, which
+// the gateway applies completely or not at all. Nothing is sent before Commit, so a Tx is not a
+// database transaction: reads in between do not see its writes, and nothing is locked.
+type Tx struct {
+	service   *Service
+	batch     *Batch
+	changeset *Changeset
+	done      bool
+}
+
+// Begin starts a transaction against the service
+func (s *Service) Begin() *Tx {
+	b := s.NewBatch()
+	return &Tx{service: s, batch: b, changeset: b.Changeset()}
+}
+
+func (tx *Tx) add(method, path string, payload interface{}) *BatchOperation {
+	if tx.done {
+		return &BatchOperation{Method: method, Path: path, Body: payload} // not sent; Commit returns ErrTxDone
+	}
+	return tx.changeset.Add(method, path, payload)
+}
+
+// Create queues the creation of an entity. Later operations of the transaction can refer to
+// it as "$<ContentID>" of the returned operation, e.g. to create items below it.
+func (tx *Tx) Create(entitySet string, payload interface{}) *BatchOperation {
+	return tx.add(http.MethodPost, entitySet, payload)
+}
+
+// Update queues a full update (PUT) of an entity
+func (tx *Tx) Update(entitySet, key string, payload interface{}) *BatchOperation {
+	return tx.add(http.MethodPut, tx.service.keyPath(entitySet, key), payload)
+}
+
+// Patch queues a partial update (PATCH/MERGE) of an entity
+func (tx *Tx) Patch(entitySet, key string, payload interface{}) *BatchOperation {
+	return tx.add(tx.service.patchMethod(), tx.service.keyPath(entitySet, key), payload)
+}
+
+// Delete queues the deletion of an entity
+func (tx *Tx) Delete(entitySet, key string) *BatchOperation {
+	return tx.add(http.MethodDelete, tx.service.keyPath(entitySet, key), nil)
+}
+
+// Operations returns the queued operations in order
+func (tx *Tx) Operations() []*BatchOperation {
+	return tx.changeset.operations
+}
+
+// Commit sends the queued operations. If the gateway rejects any of them, none is applied and
+// the error is a *TxError naming the failed operations. Other errors mean the $batch request
+// itself failed, e.g. because the gateway was unreachable; then nothing was applied either,
+// unless the connection broke after the gateway received the request.
+func (tx *Tx) Commit() (*BatchResponse, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	tx.done = true
+	if len(tx.changeset.operations) == 0 {
+		return &BatchResponse{}, nil
+	}
+	resp, err := tx.batch.Execute()
+	if err != nil {
+		return nil, err
+	}
+	if err := txError(tx.changeset.operations, resp.Results); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// Rollback discards the queued operations. It is a no-op after Commit, so it can be deferred.
+func (tx *Tx) Rollback() {
+	tx.done = true
+}
+
+// TxError reports a transaction the gateway rejected; none of its operations was applied
+type TxError struct {
+	// Failures lists the failed operations in order. SAP gateways answer a failed changeset
+	// with a single error response for the whole set; it is reported once, for the operation
+	// named by its Content-ID header, or with a nil Operation if it names none.
+	Failures []TxFailure
+}
+
+// TxFailure is the error response to one operation of a transaction
+type TxFailure struct {
+	// Index is the position of Operation in the transaction, -1 if it is unknown
+	Index      int
+	Operation  *BatchOperation
+	StatusCode int
+	Err        error
+}
+
+func (e *TxError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		if f.Operation == nil {
+			msgs[i] = fmt.Sprintf("status %d: %v", f.StatusCode, f.Err)
+			continue
+		}
+		msgs[i] = fmt.Sprintf("operation %d (%s %s): status %d: %v", f.Index+1, f.Operation.Method, f.Operation.Path, f.StatusCode, f.Err)
+	}
+	return "odata: transaction failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the failed operations
+func (e *TxError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// txError returns the *TxError for the results of a transaction's operations, nil if all
+// of them succeeded
+func txError(ops []*BatchOperation, results []*BatchResult) error {
+	var failures []TxFailure
+	for i, r := range results {
+		err := r.Err()
+		if err == nil {
+			continue
+		}
+		if !r.shared {
+			failures = append(failures, TxFailure{Index: i, Operation: ops[i], StatusCode: r.StatusCode, Err: err})
+			continue
+		}
+		f := TxFailure{Index: -1, StatusCode: r.StatusCode, Err: err}
+		if id := r.Header.Get("Content-ID"); id != "" {
+			for j, op := range ops {
+				if op.ContentID == id {
+					f.Index, f.Operation = j, op
+					break
+				}
+			}
+		}
+		return &TxError{Failures: []TxFailure{f}}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &TxError{Failures: failures}
+}
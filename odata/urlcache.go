@@ -0,0 +1,37 @@
+package odata
+
+import "sync"
+
+// maxCachedPrefixes bounds the cache for callers that pass keys or paths as entity set names
+const maxCachedPrefixes = 1024
+
+// urlCache memoizes servicePath+entitySet so hot paths concatenate once per request
+// instead of rebuilding the prefix. Copies of a Service share it.
+type urlCache struct {
+	mu       sync.RWMutex
+	prefixes map[string]string
+}
+
+func newURLCache() *urlCache {
+	return &urlCache{prefixes: make(map[string]string)}
+}
+
+func (c *urlCache) prefix(servicePath, entitySet string) string {
+	if c == nil {
+		return servicePath + entitySet
+	}
+	c.mu.RLock()
+	p, ok := c.prefixes[entitySet]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	p = servicePath + entitySet
+	c.mu.Lock()
+	if len(c.prefixes) < maxCachedPrefixes {
+		c.prefixes[entitySet] = p
+	}
+	c.mu.Unlock()
+	return p
+}
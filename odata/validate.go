@@ -0,0 +1,233 @@
+package odata
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator checks a payload before it is sent, see WithValidation. Adapt another library
+// such as go-playground/validator with a func calling its Struct method.
+type Validator func(payload interface{}) error
+
+// WithValidation checks the payload of every create and full update (PUT) of the service
+// with v before sending it, after the before hooks ran. A nil v uses ValidateStruct.
+// Partial updates are not validated since their payloads leave required fields out.
+func WithValidation(v Validator) ServiceOption {
+	if v == nil {
+		v = ValidateStruct
+	}
+	return func(s *Service) {
+		s.validate = v
+	}
+}
+
+// FieldError is a single failed rule. Field is the JSON path of the property,
+// e.g. to_Items[1].Material.
+type FieldError struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+func (e *FieldError) Error() string {
+	switch e.Rule {
+	case "required":
+		return e.Field + " is required"
+	case "pattern":
+		return fmt.Sprintf("%s does not match %s", e.Field, e.Param)
+	}
+	if e.Param == "" {
+		return fmt.Sprintf("%s violates %s", e.Field, e.Rule)
+	}
+	return fmt.Sprintf("%s violates %s=%s", e.Field, e.Rule, e.Param)
+}
+
+// PayloadError lists the field errors found by ValidateStruct
+type PayloadError struct {
+	Fields []*FieldError
+}
+
+func (e *PayloadError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "invalid payload: " + strings.Join(msgs, "; ")
+}
+
+// ValidateStruct checks the validate tags of v's fields, descending into nested structs
+// and slices of structs (deep inserts). Rules are comma separated:
+//
+//	required     not the zero value (a non-nil pointer)
+//	min=n max=n  bounds of a number, or of the length of a string or slice
+//	len=n        exact length of a string or slice
+//	oneof=a b c  one of the space separated values
+//	pattern=re   a regular expression the string must match; write it last, it may contain commas
+//
+// Rules other than required are skipped for zero values, so optional fields may stay empty.
+func ValidateStruct(payload interface{}) error {
+	var errs []*FieldError
+	validateValue(reflect.ValueOf(payload), "", &errs)
+	if len(errs) > 0 {
+		return &PayloadError{Fields: errs}
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, errs *[]*FieldError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		return
+	default:
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fieldPath := name
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			fieldPath = path // embedded fields are flattened like encoding/json does
+		} else if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		fv := v.Field(i)
+		if tag := f.Tag.Get("validate"); tag != "" {
+			checkRules(fv, fieldPath, tag, errs)
+		}
+		validateValue(fv, fieldPath, errs)
+	}
+}
+
+// checkRules applies the rules of a validate tag to v
+func checkRules(v reflect.Value, path, tag string, errs *[]*FieldError) {
+	rules := parseRules(tag)
+	if v.IsZero() {
+		if _, ok := rules["required"]; ok {
+			*errs = append(*errs, &FieldError{Field: path, Rule: "required"})
+		}
+		return
+	}
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != "required" && !checkRule(v, name, rules[name]) {
+			*errs = append(*errs, &FieldError{Field: path, Rule: name, Param: rules[name]})
+		}
+	}
+}
+
+// parseRules splits a validate tag into rule names and parameters. Unknown rules are kept so
+// checkRule can report them.
+func parseRules(tag string) map[string]string {
+	rules := make(map[string]string)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "" {
+			rules[name] = param
+		}
+	}
+	return rules
+}
+
+func checkRule(v reflect.Value, rule, param string) bool {
+	switch rule {
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false
+		}
+		size, ok := measure(v)
+		if !ok {
+			return false
+		}
+		switch rule {
+		case "min":
+			return size >= n
+		case "max":
+			return size <= n
+		}
+		return size == n
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return true
+			}
+		}
+		return false
+	case "pattern":
+		re, err := compilePattern(param)
+		return err == nil && v.Kind() == reflect.String && re.MatchString(v.String())
+	}
+	return false // unknown rules fail rather than pass silently
+}
+
+// measure returns the value of a number or the length of a string or slice
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+var patterns sync.Map // string -> *regexp.Regexp
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
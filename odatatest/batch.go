@@ -0,0 +1,223 @@
+package odatatest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// batchRequest is one application/http part of a $batch payload
+type batchRequest struct {
+	method    string
+	target    string
+	contentID string
+	body      []byte
+}
+
+// batch executes a multipart/mixed $batch request. Changesets are atomic: when one
+// operation fails, the changes of the others are rolled back and a single error
+// response is returned for the whole changeset, as SAP Gateway does.
+func (s *Server) batch(contentType string, body []byte) *response {
+	boundary, err := boundaryOf(contentType)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+
+	var out bytes.Buffer
+	respBoundary := "batchresponse_" + randomToken()
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid batch payload: %v", err))
+		}
+
+		fmt.Fprintf(&out, "--%s\r\n", respBoundary)
+		ct := part.Header.Get("Content-Type")
+		if strings.HasPrefix(ct, "multipart/mixed") {
+			if err := s.changeset(&out, part, ct); err != nil {
+				return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+			}
+			continue
+		}
+
+		req, err := readBatchRequest(part)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+		}
+		if req.method != http.MethodGet {
+			return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("%s %s must be placed inside a changeset", req.method, req.target))
+		}
+		writeBatchResponse(&out, "", s.serveBatchRequest(req, nil))
+	}
+	fmt.Fprintf(&out, "--%s--\r\n", respBoundary)
+
+	return &response{
+		status: http.StatusAccepted,
+		header: http.Header{"Content-Type": {"multipart/mixed; boundary=" + respBoundary}},
+		body:   out.Bytes(),
+	}
+}
+
+func (s *Server) changeset(out *bytes.Buffer, part io.Reader, contentType string) error {
+	boundary, err := boundaryOf(contentType)
+	if err != nil {
+		return err
+	}
+
+	snapshot := s.snapshot()
+	locations := make(map[string]string) // Content-ID -> service relative entity path
+
+	type result struct {
+		contentID string
+		resp      *response
+	}
+	var results []result
+
+	mr := multipart.NewReader(part, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid changeset: %w", err)
+		}
+		req, err := readBatchRequest(p)
+		if err != nil {
+			return err
+		}
+		if req.method == http.MethodGet {
+			return fmt.Errorf("GET %s is not allowed inside a changeset", req.target)
+		}
+
+		resp := s.serveBatchRequest(req, locations)
+		if resp.status >= 400 {
+			s.restore(snapshot)
+			writeBatchResponse(out, "", resp)
+			return nil
+		}
+		if loc := resp.header.Get("Location"); loc != "" && req.contentID != "" {
+			if i := strings.Index(loc, s.ServicePath); i >= 0 {
+				locations[req.contentID] = loc[i+len(s.ServicePath):]
+			}
+		}
+		results = append(results, result{contentID: req.contentID, resp: resp})
+	}
+
+	csBoundary := "changesetresponse_" + randomToken()
+	fmt.Fprintf(out, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", csBoundary)
+	for _, r := range results {
+		fmt.Fprintf(out, "--%s\r\n", csBoundary)
+		writeBatchResponse(out, r.contentID, r.resp)
+	}
+	fmt.Fprintf(out, "--%s--\r\n", csBoundary)
+	return nil
+}
+
+// serveBatchRequest resolves the target (absolute path, relative path or $<Content-ID>
+// reference) and executes it
+func (s *Server) serveBatchRequest(req *batchRequest, locations map[string]string) *response {
+	target := req.target
+	if strings.HasPrefix(target, "$") {
+		id, rest, _ := strings.Cut(target[1:], "/")
+		loc, ok := locations[id]
+		if !ok {
+			return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("unknown Content-ID reference $%s", id))
+		}
+		target = loc
+		if rest != "" {
+			target += "/" + rest
+		}
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid request target %q", req.target))
+	}
+	rel := u.Path
+	if u.IsAbs() || strings.HasPrefix(rel, "/") {
+		if !strings.HasPrefix(rel+"/", s.ServicePath) {
+			return errorResponse(http.StatusNotFound, "NotFound", fmt.Sprintf("%s is outside of the service", rel))
+		}
+		rel = strings.TrimPrefix(rel+"/", s.ServicePath)
+	}
+	rel = strings.TrimSuffix(rel, "/")
+	return s.serve(req.method, rel, u.Query(), req.body)
+}
+
+// readBatchRequest parses an embedded HTTP request. http.ReadRequest cannot be used because
+// batch request lines usually carry service relative targets like "Products('1')".
+func readBatchRequest(part *multipart.Part) (*batchRequest, error) {
+	req := &batchRequest{contentID: part.Header.Get("Content-ID")}
+
+	tp := textproto.NewReader(bufio.NewReader(part))
+	line, err := tp.ReadLine()
+	for err == nil && strings.TrimSpace(line) == "" {
+		line, err = tp.ReadLine()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading batch request line: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid batch request line %q", line)
+	}
+	req.method, req.target = strings.ToUpper(fields[0]), fields[1]
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading batch request headers: %w", err)
+	}
+	if m := header.Get("X-HTTP-Method"); m != "" && req.method == http.MethodPost {
+		req.method = strings.ToUpper(m)
+	}
+	if req.contentID == "" {
+		req.contentID = header.Get("Content-ID")
+	}
+
+	body, err := io.ReadAll(tp.R)
+	if err != nil {
+		return nil, fmt.Errorf("reading batch request body: %w", err)
+	}
+	req.body = bytes.TrimSpace(body)
+	return req, nil
+}
+
+func writeBatchResponse(out *bytes.Buffer, contentID string, resp *response) {
+	out.WriteString("Content-Type: application/http\r\n")
+	out.WriteString("Content-Transfer-Encoding: binary\r\n")
+	if contentID != "" {
+		fmt.Fprintf(out, "Content-ID: %s\r\n", contentID)
+	}
+	out.WriteString("\r\n")
+
+	fmt.Fprintf(out, "HTTP/1.1 %d %s\r\n", resp.status, http.StatusText(resp.status))
+	for k, v := range resp.header {
+		for _, vv := range v {
+			fmt.Fprintf(out, "%s: %s\r\n", k, vv)
+		}
+	}
+	fmt.Fprintf(out, "Content-Length: %s\r\n\r\n", strconv.Itoa(len(resp.body)))
+	out.Write(resp.body)
+	out.WriteString("\r\n")
+}
+
+func boundaryOf(contentType string) (string, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return "", fmt.Errorf("batch content type %q has no boundary", contentType)
+	}
+	return params["boundary"], nil
+}
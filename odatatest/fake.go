@@ -0,0 +1,215 @@
+package odatatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+// Fake is an in-memory stand-in for an OData service. Unlike Server it involves no
+// HTTP at all: repositories obtained from FakeRepository operate directly on maps,
+// with $filter, $orderby, $top, $skip and $select evaluated in process.
+// Errors have the same type (*models.ODataErrorResponse) as those of a real service.
+type Fake struct {
+	mu sync.Mutex
+	store
+}
+
+// NewFake creates an empty fake service
+func NewFake() *Fake {
+	return &Fake{store: newStore()}
+}
+
+// AddEntitySet registers an empty entity set with the given key properties
+func (f *Fake) AddEntitySet(name string, keys ...string) *EntitySet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addEntitySet(name, keys)
+}
+
+// Seed inserts entities (structs, maps or raw JSON) into an entity set
+func (f *Fake) Seed(set string, entities ...interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seed(set, entities)
+}
+
+// Entities returns a copy of the current content of an entity set
+func (f *Fake) Entities(set string) []Entity {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entities(set)
+}
+
+// FakeRepository returns an odata.Repository for entitySet backed by f.
+// The entity set must have been registered with AddEntitySet.
+func FakeRepository[T any](f *Fake, entitySet string) odata.Repository[T] {
+	return &fakeRepository[T]{fake: f, entitySet: entitySet}
+}
+
+type fakeRepository[T any] struct {
+	fake      *Fake
+	entitySet string
+}
+
+// set returns the entity set; callers hold the lock
+func (r *fakeRepository[T]) set() (*EntitySet, error) {
+	es, ok := r.fake.sets[r.entitySet]
+	if !ok {
+		return nil, odataError(&storeError{
+			code:    "/IWBEP/CM_MGW_RT/021",
+			message: fmt.Sprintf("Resource not found for segment '%s'", r.entitySet),
+		})
+	}
+	return es, nil
+}
+
+func (r *fakeRepository[T]) List(opts *odata.QueryOptions) ([]T, error) {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	es, err := r.set()
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQueryOptions(optionGetter(opts))
+	if err != nil {
+		return nil, odataError(badRequest("%v", err))
+	}
+	results, _, err := es.query(q)
+	if err != nil {
+		return nil, odataError(badRequest("%v", err))
+	}
+
+	out := make([]T, len(results))
+	for i, e := range results {
+		if err := fromEntity(e, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepository[T]) Get(key string, opts *odata.QueryOptions) (T, error) {
+	var result T
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	es, err := r.set()
+	if err != nil {
+		return result, err
+	}
+	idx, err := es.lookup(trimKey(key))
+	if err != nil {
+		return result, odataError(err)
+	}
+	q, err := parseQueryOptions(optionGetter(opts))
+	if err != nil {
+		return result, odataError(badRequest("%v", err))
+	}
+	err = fromEntity(project(es.entities[idx], q.selects), &result)
+	return result, err
+}
+
+func (r *fakeRepository[T]) Create(entity T) (T, error) {
+	var result T
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	es, err := r.set()
+	if err != nil {
+		return result, err
+	}
+	e, err := toEntity(entity)
+	if err != nil {
+		return result, fmt.Errorf("encoding entity: %w", err)
+	}
+	if err := es.insert(e); err != nil {
+		return result, odataError(err)
+	}
+	err = fromEntity(e, &result)
+	return result, err
+}
+
+func (r *fakeRepository[T]) Update(key string, entity T) error {
+	return r.modify(key, entity, false)
+}
+
+func (r *fakeRepository[T]) Patch(key string, fields interface{}) error {
+	return r.modify(key, fields, true)
+}
+
+func (r *fakeRepository[T]) modify(key string, payload interface{}, merge bool) error {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	es, err := r.set()
+	if err != nil {
+		return err
+	}
+	patch, err := toEntity(payload)
+	if err != nil {
+		return fmt.Errorf("encoding entity: %w", err)
+	}
+	if err := es.update(trimKey(key), patch, merge); err != nil {
+		return odataError(err)
+	}
+	return nil
+}
+
+func (r *fakeRepository[T]) Delete(key string) error {
+	r.fake.mu.Lock()
+	defer r.fake.mu.Unlock()
+
+	es, err := r.set()
+	if err != nil {
+		return err
+	}
+	if err := es.remove(trimKey(key)); err != nil {
+		return odataError(err)
+	}
+	return nil
+}
+
+// trimKey accepts keys with or without the surrounding parentheses, like the odata package
+func trimKey(key string) string {
+	if strings.HasPrefix(key, "(") && strings.HasSuffix(key, ")") {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+func optionGetter(opts *odata.QueryOptions) func(string) string {
+	var params map[string]string
+	if opts != nil {
+		params = opts.Build()
+	}
+	return func(name string) string { return params[name] }
+}
+
+func fromEntity(e Entity, v interface{}) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("decoding entity: %w", err)
+	}
+	return nil
+}
+
+// odataError converts a store error into the error type returned by the odata package
+func odataError(err error) error {
+	se, ok := err.(*storeError)
+	if !ok {
+		return err
+	}
+	return &models.ODataErrorResponse{Err: models.ODataError{
+		Code:    se.code,
+		Message: models.ODataMessage{Lang: "en", Value: se.message},
+	}}
+}
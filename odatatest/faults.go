@@ -0,0 +1,168 @@
+package odatatest
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault injects latency and errors into the requests matching a route, for exercising
+// retries, timeouts and circuit breakers against the mock server
+type Fault struct {
+	// Method restricts the fault to one HTTP method; empty matches all
+	Method string
+	// Path is a path.Match pattern on the service relative path, e.g. "Products",
+	// "Products(*)" or "$batch"; empty matches all
+	Path string
+
+	// Latency delays every matching request
+	Latency time.Duration
+	// ErrorRate is the fraction (0..1) of matching requests that fail. The draw uses the
+	// server's seeded generator (see SetSeed), so a run is reproducible.
+	ErrorRate float64
+	// Times makes the first Times matching requests fail, regardless of ErrorRate
+	Times int
+	// Status of injected errors, default 503
+	Status int
+	// RetryAfter, when set, is sent as the Retry-After header of injected errors
+	RetryAfter string
+}
+
+// faultState is the part of the server configured through this file
+type faultState struct {
+	faults      []*fault
+	rng         *rand.Rand
+	csrfUses    int
+	csrfMaxUses int
+	pageSize    int
+}
+
+func newFaultState() faultState {
+	return faultState{rng: rand.New(rand.NewPCG(1, 1))}
+}
+
+// fault is a registered Fault with its match counter
+type fault struct {
+	Fault
+	seen int
+}
+
+func (f *fault) matches(method, rel string) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, method) {
+		return false
+	}
+	if f.Path == "" {
+		return true
+	}
+	ok, _ := path.Match(f.Path, rel)
+	return ok
+}
+
+// AddFault registers a fault. All matching faults apply: latencies add up and the
+// request fails if any of them fails it.
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all registered faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetSeed reseeds the generator used for Fault.ErrorRate
+func (s *Server) SetSeed(seed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// ExpireCSRFToken invalidates the current CSRF token, as a session timeout on the
+// gateway would: the next modifying request gets 403 with X-CSRF-Token: Required
+func (s *Server) ExpireCSRFToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.csrfToken = randomToken()
+	s.csrfUses = 0
+}
+
+// SetCSRFTokenUses expires the CSRF token after n accepted modifying requests; 0 disables expiry
+func (s *Server) SetCSRFTokenUses(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.csrfMaxUses = n
+	s.csrfUses = 0
+}
+
+// SetPageSize enables server-driven paging: entity set reads return at most n entities
+// and a __next link with a $skiptoken for the rest; 0 disables paging
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// injectFault applies the faults matching the request. The caller holds s.mu;
+// it is released while sleeping so slow routes do not block other requests.
+func (s *Server) injectFault(method, rel string) *response {
+	var delay time.Duration
+	var failed *fault
+	for _, f := range s.faults {
+		if !f.matches(method, rel) {
+			continue
+		}
+		f.seen++
+		delay += f.Latency
+		if failed == nil && (f.seen <= f.Times || (f.ErrorRate > 0 && s.rng.Float64() < f.ErrorRate)) {
+			failed = f
+		}
+	}
+
+	if delay > 0 {
+		s.mu.Unlock()
+		time.Sleep(delay)
+		s.mu.Lock()
+	}
+	if failed == nil {
+		return nil
+	}
+
+	status := failed.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	resp := errorResponse(status, "FaultInjected", "Injected fault: "+http.StatusText(status))
+	if failed.RetryAfter != "" {
+		resp.header.Set("Retry-After", failed.RetryAfter)
+	}
+	return resp
+}
+
+// useCSRFToken counts an accepted modifying request against the token's lifetime
+func (s *Server) useCSRFToken() {
+	if s.csrfMaxUses <= 0 {
+		return
+	}
+	s.csrfUses++
+	if s.csrfUses >= s.csrfMaxUses {
+		s.csrfToken = randomToken()
+		s.csrfUses = 0
+	}
+}
+
+// nextLink builds the __next URL of the page after skiptoken
+func (s *Server) nextLink(set string, query url.Values, skiptoken int) string {
+	q := make(url.Values, len(query)+1)
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("$skiptoken", strconv.Itoa(skiptoken))
+	return s.URL + s.ServicePath + set + "?" + q.Encode()
+}
@@ -0,0 +1,295 @@
+package odatatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden (re)write golden
+// files instead of comparing against them: UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// RecordedRequest is an outgoing request captured by Server or Recorder
+type RecordedRequest struct {
+	Method string
+	// Path is the unescaped URL path, e.g. /sap/opu/odata/sap/Z_SRV/Products('1')
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+func recordRequest(r *http.Request, body []byte) RecordedRequest {
+	return RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   append([]byte(nil), body...),
+	}
+}
+
+// Requests returns the requests received so far, including CSRF token fetches
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// ResetRequests clears the recorded requests
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// Recorder is an http.RoundTripper that captures requests before passing them on,
+// for golden tests of clients that talk to something other than Server:
+//
+//	rec := odatatest.NewRecorder(nil)
+//	sapClient.GetClient().SetTransport(rec)
+type Recorder struct {
+	next     http.RoundTripper
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// NewRecorder wraps next; nil means http.DefaultTransport
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, recordRequest(r, body))
+	rec.mu.Unlock()
+
+	return rec.next.RoundTrip(r)
+}
+
+// Requests returns the requests captured so far
+func (rec *Recorder) Requests() []RecordedRequest {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]RecordedRequest(nil), rec.requests...)
+}
+
+// GoldenOptions controls how requests are serialized for golden files
+type GoldenOptions struct {
+	// Headers lists the headers to include; nil includes all except transport noise
+	// (User-Agent, Accept-Encoding, Content-Length, Connection)
+	Headers []string
+	// RedactHeaders are replaced by <redacted>. Authorization, Proxy-Authorization,
+	// Cookie, X-CSRF-Token and SAP-Connectivity-Authentication are always redacted.
+	RedactHeaders []string
+	// RedactFields are JSON body fields (at any depth) whose values are replaced by <redacted>,
+	// e.g. generated IDs or timestamps
+	RedactFields []string
+}
+
+var (
+	noiseHeaders   = []string{"User-Agent", "Accept-Encoding", "Content-Length", "Connection"}
+	secretHeaders  = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Csrf-Token", "Sap-Connectivity-Authentication"}
+	boundaryRegexp = regexp.MustCompile(`boundary=([^\s;"]+)`)
+)
+
+const redacted = "<redacted>"
+
+// FormatRequest renders a request in a stable, human readable form: request line,
+// sorted and unescaped query options, sorted headers and an indented JSON body with
+// sorted keys. Multipart boundaries are replaced by stable placeholders.
+func FormatRequest(r RecordedRequest, opts *GoldenOptions) []byte {
+	if opts == nil {
+		opts = &GoldenOptions{}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\n", r.Method, r.Path)
+
+	names := make([]string, 0, len(r.Query))
+	for name := range r.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range r.Query[name] {
+			fmt.Fprintf(&b, "  %s=%s\n", name, v)
+		}
+	}
+
+	redact := make(map[string]bool)
+	for _, h := range append(append([]string(nil), secretHeaders...), opts.RedactHeaders...) {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+	include := func(h string) bool {
+		if opts.Headers == nil {
+			for _, n := range noiseHeaders {
+				if h == n {
+					return false
+				}
+			}
+			return true
+		}
+		for _, n := range opts.Headers {
+			if http.CanonicalHeaderKey(n) == h {
+				return true
+			}
+		}
+		return false
+	}
+
+	headers := make([]string, 0, len(r.Header))
+	for h := range r.Header {
+		if include(http.CanonicalHeaderKey(h)) {
+			headers = append(headers, h)
+		}
+	}
+	sort.Strings(headers)
+
+	body := r.Body
+	var boundaries []string
+	for _, h := range headers {
+		for _, v := range r.Header[h] {
+			switch {
+			case redact[http.CanonicalHeaderKey(h)]:
+				v = redacted
+			case strings.Contains(v, "boundary="):
+				boundaries = append(boundaries, boundaryRegexp.FindStringSubmatch(v)[1])
+			}
+			fmt.Fprintf(&b, "%s: %s\n", h, v)
+		}
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		b.WriteString("\n")
+		b.Write(formatBody(body, opts.RedactFields))
+		b.WriteString("\n")
+	}
+
+	return normalizeBoundaries(b.Bytes(), boundaries)
+}
+
+// FormatRequests renders several requests separated by blank lines
+func FormatRequests(requests []RecordedRequest, opts *GoldenOptions) []byte {
+	var b bytes.Buffer
+	for i, r := range requests {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.Write(FormatRequest(r, opts))
+	}
+	return b.Bytes()
+}
+
+func formatBody(body []byte, redactFields []string) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		// Not JSON ($batch, media): normalize line endings only
+		return bytes.TrimSpace(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")))
+	}
+
+	fields := make(map[string]bool, len(redactFields))
+	for _, f := range redactFields {
+		fields[f] = true
+	}
+	v = redactJSON(v, fields)
+
+	// encoding/json sorts map keys, which gives the stable ordering
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return body
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+func redactJSON(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if fields[k] {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactJSON(val, fields)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactJSON(v[i], fields)
+		}
+	}
+	return v
+}
+
+// normalizeBoundaries replaces random multipart boundaries (from the Content-Type header
+// and nested changesets in the body) with boundary_1, boundary_2, ...
+func normalizeBoundaries(b []byte, boundaries []string) []byte {
+	for _, m := range boundaryRegexp.FindAllSubmatch(b, -1) {
+		boundaries = append(boundaries, string(m[1]))
+	}
+	seen := make(map[string]bool)
+	n := 0
+	for _, boundary := range boundaries {
+		if seen[boundary] {
+			continue
+		}
+		seen[boundary] = true
+		n++
+		b = bytes.ReplaceAll(b, []byte(boundary), []byte(fmt.Sprintf("boundary_%d", n)))
+	}
+	return b
+}
+
+// AssertGolden compares got with testdata/<name>.golden, failing the test with both
+// versions on mismatch. With UPDATE_GOLDEN=1 the golden file is written instead.
+func AssertGolden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("writing golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n")), got) {
+		t.Errorf("%s does not match golden file %s (run with %s=1 to update)\n--- want\n%s\n--- got\n%s",
+			name, path, UpdateGoldenEnv, want, got)
+	}
+}
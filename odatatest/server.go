@@ -0,0 +1,449 @@
+// Package odatatest provides an in-process OData V2 server for integration tests of code
+// built on this SDK. It speaks enough of the protocol for the client and odata packages
+// to run unmodified: the d wrapper, $filter/$orderby/$top/$skip/$select/$inlinecount,
+// the CSRF token handshake, $batch with changesets and SAP style error payloads.
+// AddFault, ExpireCSRFToken and SetPageSize simulate a misbehaving gateway.
+//
+//	srv := odatatest.NewServer("/sap/opu/odata/sap/ZSALES_SRV")
+//	defer srv.Close()
+//	srv.AddEntitySet("Products", "ProductID")
+//	srv.Seed("Products", Product{ProductID: "1", Name: "Pen"})
+//
+//	c := client.NewSAPClient(srv.URL, "user", "pass")
+//	svc := odata.NewService(c, srv.ServicePath)
+package odatatest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Willias7788/go-odata-v2-sdk/metadata"
+)
+
+const csrfHeader = "X-CSRF-Token"
+
+// Server is a mock OData V2 service backed by in-memory entity sets
+type Server struct {
+	*httptest.Server
+	// ServicePath is the path the service is mounted at, with leading and trailing slash
+	ServicePath string
+
+	mu sync.Mutex
+	store
+	metadata    []byte
+	csrfToken   string
+	requireCSRF bool
+	username    string
+	password    string
+	requests    []RecordedRequest
+	faultState
+}
+
+// NewServer starts a mock service mounted at servicePath. Call Close when done.
+func NewServer(servicePath string) *Server {
+	if !strings.HasSuffix(servicePath, "/") {
+		servicePath += "/"
+	}
+	if !strings.HasPrefix(servicePath, "/") {
+		servicePath = "/" + servicePath
+	}
+	s := &Server{
+		ServicePath: servicePath,
+		store:       newStore(),
+		csrfToken:   randomToken(),
+		requireCSRF: true,
+		faultState:  newFaultState(),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// AddEntitySet registers an empty entity set with the given key properties
+func (s *Server) AddEntitySet(name string, keys ...string) *EntitySet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addEntitySet(name, keys)
+}
+
+// Seed inserts entities (structs, maps or raw JSON) into an entity set
+func (s *Server) Seed(set string, entities ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seed(set, entities)
+}
+
+// Entities returns a copy of the current content of an entity set, for asserting on
+// the effect of creates, updates and deletes
+func (s *Server) Entities(set string) []Entity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entities(set)
+}
+
+// SetMetadata serves raw as $metadata and registers every entity set declared in it
+// (with keys taken from its entity type) that is not registered yet
+func (s *Server) SetMetadata(raw []byte) error {
+	doc, err := metadata.Parse(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata = raw
+	for _, set := range doc.EntitySets() {
+		if _, exists := s.sets[set.Name]; exists {
+			continue
+		}
+		_, et, err := doc.EntitySet(set.Name)
+		if err != nil {
+			return err
+		}
+		s.sets[set.Name] = &EntitySet{Name: set.Name, Keys: et.KeyNames(), EntityType: set.EntityType}
+		s.order = append(s.order, set.Name)
+	}
+	return nil
+}
+
+// SetBasicAuth makes the server reject requests without these credentials (401)
+func (s *Server) SetBasicAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// RequireCSRF toggles CSRF token validation of modifying requests (enabled by default)
+func (s *Server) RequireCSRF(required bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requireCSRF = required
+}
+
+// CSRFToken returns the token currently handed out on X-CSRF-Token: Fetch
+func (s *Server) CSRFToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.csrfToken
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeResponse(w, errorResponse(http.StatusBadRequest, "BadRequest", err.Error()))
+		return
+	}
+	s.requests = append(s.requests, recordRequest(r, body))
+
+	method := r.Method
+	if m := r.Header.Get("X-HTTP-Method"); m != "" && method == http.MethodPost {
+		method = strings.ToUpper(m)
+	}
+	rel := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path+"/", s.ServicePath), "/")
+
+	if resp := s.injectFault(method, rel); resp != nil {
+		writeResponse(w, resp)
+		return
+	}
+
+	if s.username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != s.username || pass != s.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="SAP NetWeaver Application Server"`)
+			writeResponse(w, errorResponse(http.StatusUnauthorized, "Unauthorized", "Logon failed"))
+			return
+		}
+	}
+
+	// CSRF handshake: token is handed out on a fetch and required for modifying calls
+	if strings.EqualFold(r.Header.Get(csrfHeader), "Fetch") && (method == http.MethodGet || method == http.MethodHead) {
+		w.Header().Set(csrfHeader, s.csrfToken)
+		http.SetCookie(w, &http.Cookie{Name: "SAP_SESSIONID_MOCK", Value: s.csrfToken, Path: "/"})
+	}
+	if s.requireCSRF && isModifying(method) {
+		if r.Header.Get(csrfHeader) != s.csrfToken {
+			w.Header().Set(csrfHeader, "Required")
+			writeResponse(w, errorResponse(http.StatusForbidden, "CSRF", "CSRF token validation failed"))
+			return
+		}
+		s.useCSRFToken()
+	}
+
+	if !strings.HasPrefix(r.URL.Path+"/", s.ServicePath) {
+		writeResponse(w, errorResponse(http.StatusNotFound, "SY/530", fmt.Sprintf("No service found for namespace, name %s", r.URL.Path)))
+		return
+	}
+
+	if rel == "$batch" && method == http.MethodPost {
+		writeResponse(w, s.batch(r.Header.Get("Content-Type"), body))
+		return
+	}
+
+	resp := s.serve(method, rel, r.URL.Query(), body)
+	if method == http.MethodHead {
+		resp.body = nil
+	}
+	writeResponse(w, resp)
+}
+
+// response is a buffered reply, shared by plain requests and $batch operations
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func writeResponse(w http.ResponseWriter, resp *response) {
+	for k, v := range resp.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// serve handles a single operation on the service-relative path rel
+func (s *Server) serve(method, rel string, query url.Values, body []byte) *response {
+	switch rel {
+	case "":
+		return jsonResponse(http.StatusOK, map[string]interface{}{"d": map[string]interface{}{"EntitySets": s.order}})
+	case "$metadata":
+		if s.metadata == nil {
+			return errorResponse(http.StatusNotFound, "NotFound", "no metadata configured for the mock service")
+		}
+		return &response{status: http.StatusOK, header: http.Header{"Content-Type": {"application/xml"}}, body: s.metadata}
+	}
+
+	setName, predicate, rest, err := splitResourcePath(rel)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	es, ok := s.sets[setName]
+	if !ok {
+		return errorResponse(http.StatusNotFound, "/IWBEP/CM_MGW_RT/021", fmt.Sprintf("Resource not found for segment '%s'", setName))
+	}
+
+	if predicate == "" {
+		switch {
+		case rest == "$count" && method == http.MethodGet:
+			return s.count(es, query)
+		case rest != "":
+			return errorResponse(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("segment %s is not supported by the mock service", rest))
+		case method == http.MethodGet || method == http.MethodHead:
+			return s.list(es, query)
+		case method == http.MethodPost:
+			return s.create(es, body)
+		default:
+			return errorResponse(http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("method %s not allowed on entity set %s", method, setName))
+		}
+	}
+
+	idx, err := es.lookup(predicate)
+	if err != nil {
+		return storeErrorResponse(err)
+	}
+	if rest != "" {
+		return errorResponse(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("segment %s is not supported by the mock service", rest))
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		q, err := parseQueryOptions(query.Get)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+		}
+		return jsonResponse(http.StatusOK, map[string]interface{}{"d": s.decorate(es, project(es.entities[idx], q.selects))})
+	case http.MethodPut, http.MethodPatch, "MERGE":
+		patch, err := toEntity(json.RawMessage(body))
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid payload: %v", err))
+		}
+		if err := es.update(predicate, patch, method != http.MethodPut); err != nil {
+			return storeErrorResponse(err)
+		}
+		return &response{status: http.StatusNoContent, header: http.Header{}}
+	case http.MethodDelete:
+		if err := es.remove(predicate); err != nil {
+			return storeErrorResponse(err)
+		}
+		return &response{status: http.StatusNoContent, header: http.Header{}}
+	default:
+		return errorResponse(http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("method %s not allowed on an entity", method))
+	}
+}
+
+func (s *Server) list(es *EntitySet, query url.Values) *response {
+	q, err := parseQueryOptions(query.Get)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	// A $skiptoken continues after the pages already sent, within the client's $skip/$top window
+	q.skip += q.skiptoken
+	if q.top >= 0 {
+		q.top = max(q.top-q.skiptoken, 0)
+	}
+	results, total, err := es.query(q)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	next := ""
+	if s.pageSize > 0 && len(results) > s.pageSize {
+		results = results[:s.pageSize]
+		next = s.nextLink(es.Name, query, q.skiptoken+s.pageSize)
+	}
+
+	items := make([]interface{}, len(results))
+	for i, e := range results {
+		items[i] = s.decorate(es, e)
+	}
+	d := map[string]interface{}{"results": items}
+	if q.count {
+		d["__count"] = strconv.Itoa(total) // V2 sends the count as a string
+	}
+	if next != "" {
+		d["__next"] = next
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"d": d})
+}
+
+func (s *Server) count(es *EntitySet, query url.Values) *response {
+	q, err := parseQueryOptions(query.Get)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	q.top, q.skip = -1, 0
+	_, total, err := es.query(q)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", err.Error())
+	}
+	return &response{
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {"text/plain"}},
+		body:   []byte(strconv.Itoa(total)),
+	}
+}
+
+func (s *Server) create(es *EntitySet, body []byte) *response {
+	e, err := toEntity(json.RawMessage(body))
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "BadRequest", fmt.Sprintf("invalid payload: %v", err))
+	}
+	if err := es.insert(e); err != nil {
+		return storeErrorResponse(err)
+	}
+
+	resp := jsonResponse(http.StatusCreated, map[string]interface{}{"d": s.decorate(es, clone(e))})
+	resp.header.Set("Location", s.entityURI(es, e))
+	return resp
+}
+
+// decorate adds the __metadata block SAP Gateway sends with every entity
+func (s *Server) decorate(es *EntitySet, e Entity) Entity {
+	uri := s.entityURI(es, e)
+	e["__metadata"] = map[string]interface{}{"id": uri, "uri": uri, "type": es.EntityType}
+	return e
+}
+
+func (s *Server) entityURI(es *EntitySet, e Entity) string {
+	base := ""
+	if s.Server != nil {
+		base = s.URL
+	}
+	return base + s.ServicePath + es.Name + es.keyPredicate(e)
+}
+
+// splitResourcePath splits "Products('1')/ToSupplier" into set, key predicate and remaining path
+func splitResourcePath(rel string) (set, predicate, rest string, err error) {
+	set = rel
+	if i := strings.Index(rel, "/"); i >= 0 && !strings.Contains(rel[:i], "(") {
+		return rel[:i], "", rel[i+1:], nil
+	}
+	open := strings.Index(rel, "(")
+	if open < 0 {
+		return set, "", "", nil
+	}
+	closing := -1
+	inQuote := false
+	for i := open + 1; i < len(rel); i++ {
+		if rel[i] == '\'' {
+			inQuote = !inQuote
+		} else if rel[i] == ')' && !inQuote {
+			closing = i
+			break
+		}
+	}
+	if closing < 0 {
+		return "", "", "", fmt.Errorf("unterminated key predicate in %s", rel)
+	}
+	set, predicate = rel[:open], rel[open+1:closing]
+	rest = strings.TrimPrefix(rel[closing+1:], "/")
+	if predicate == "" {
+		// Products() addresses the collection
+		return set, "", rest, nil
+	}
+	return set, predicate, rest, nil
+}
+
+func jsonResponse(status int, v interface{}) *response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "InternalError", err.Error())
+	}
+	return &response{
+		status: status,
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   body,
+	}
+}
+
+// errorResponse renders the error payload shape of SAP Gateway
+func errorResponse(status int, code, message string) *response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": map[string]string{"lang": "en", "value": message},
+			"innererror": map[string]interface{}{
+				"transactionid": strings.ToUpper(randomToken()),
+				"errordetails":  []interface{}{},
+			},
+		},
+	})
+	return &response{
+		status: status,
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   body,
+	}
+}
+
+func storeErrorResponse(err error) *response {
+	if se, ok := err.(*storeError); ok {
+		return errorResponse(se.status, se.code, se.message)
+	}
+	return errorResponse(http.StatusInternalServerError, "InternalError", err.Error())
+}
+
+func isModifying(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, "MERGE":
+		return true
+	}
+	return false
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
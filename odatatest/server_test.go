@@ -0,0 +1,162 @@
+package odatatest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/models"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+	"github.com/Willias7788/go-odata-v2-sdk/odatatest"
+)
+
+type product struct {
+	ProductID string
+	Name      string
+	Price     float64
+}
+
+func newProductServer(t *testing.T) (*odatatest.Server, *odata.Service) {
+	t.Helper()
+	srv := odatatest.NewServer("/sap/opu/odata/sap/ZTEST_SRV")
+	t.Cleanup(srv.Close)
+	srv.AddEntitySet("ProductSet", "ProductID")
+	err := srv.Seed("ProductSet",
+		product{ProductID: "HT-1000", Name: "Notebook Basic 15", Price: 956},
+		product{ProductID: "HT-1001", Name: "Notebook Basic 17", Price: 1249},
+		product{ProductID: "HT-1002", Name: "Notebook Basic 18", Price: 1570},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, odata.NewService(client.NewSAPClient(srv.URL, "", ""), srv.ServicePath)
+}
+
+func TestServerQueries(t *testing.T) {
+	_, svc := newProductServer(t)
+
+	tests := []struct {
+		name string
+		opts *odata.QueryOptions
+		want []string
+	}{
+		{"all", nil, []string{"HT-1000", "HT-1001", "HT-1002"}},
+		{"filter", odata.NewQueryOptions().Filter("Price gt 1000"), []string{"HT-1001", "HT-1002"}},
+		{"function", odata.NewQueryOptions().Filter("endswith(Name, '17')"), []string{"HT-1001"}},
+		{"orderby", odata.NewQueryOptions().OrderBy("Price", false), []string{"HT-1002", "HT-1001", "HT-1000"}},
+		{"paging", odata.NewQueryOptions().OrderBy("ProductID", true).Skip(1).Top(1), []string{"HT-1001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := odata.GetEntitySet[product](svc, "ProductSet", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range resp.D.Result {
+				got = append(got, p.ProductID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestServerWrites(t *testing.T) {
+	srv, svc := newProductServer(t)
+
+	created, err := odata.CreateEntity[product](svc, "ProductSet", product{ProductID: "HT-2000", Name: "Monitor", Price: 230})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.D.Result.Name != "Monitor" {
+		t.Errorf("created %+v", created.D.Result)
+	}
+	if err := odata.PatchEntity(svc, "ProductSet", "'HT-2000'", map[string]interface{}{"Price": 199}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := odata.GetEntityByKey[product](svc, "ProductSet", "'HT-2000'", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Result.Price != 199 || got.D.Result.Name != "Monitor" {
+		t.Errorf("after MERGE got %+v", got.D.Result)
+	}
+	if err := odata.DeleteEntity(svc, "ProductSet", "'HT-2000'"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Entities("ProductSet")); n != 3 {
+		t.Errorf("%d entities left after the delete, want 3", n)
+	}
+
+	_, err = odata.GetEntityByKey[product](svc, "ProductSet", "'HT-2000'", nil)
+	var odataErr *models.ODataErrorResponse
+	if !errors.As(err, &odataErr) {
+		t.Errorf("reading a deleted entity returned %v, want an OData error", err)
+	}
+}
+
+func TestServerRequiresCSRF(t *testing.T) {
+	srv, _ := newProductServer(t)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+srv.ServicePath+"ProductSet('HT-1000')", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("X-CSRF-Token") != "Required" {
+		t.Errorf("delete without token: %d %q", resp.StatusCode, resp.Header.Get("X-CSRF-Token"))
+	}
+
+	srv.RequireCSRF(false)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete with CSRF disabled: %d", resp.StatusCode)
+	}
+}
+
+func TestServerBatchChangesetRollback(t *testing.T) {
+	srv, svc := newProductServer(t)
+	if err := odata.PatchEntity(svc, "ProductSet", "'HT-1001'", map[string]string{"Name": "Renamed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := svc.NewBatch()
+	cs := b.Changeset()
+	cs.Add(http.MethodPost, "ProductSet", product{ProductID: "HT-3000", Name: "Mouse"})
+	cs.Add(http.MethodPost, "ProductSet", product{ProductID: "HT-1000", Name: "Duplicate"})
+	b.Query("ProductSet('HT-1001')", nil)
+	resp, err := b.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(resp.Results))
+	}
+	for _, r := range resp.Results[:2] {
+		if r.Err() == nil {
+			t.Errorf("%s %s succeeded in a failed changeset", r.Operation.Method, r.Operation.Path)
+		}
+	}
+	var p struct {
+		D product `json:"d"`
+	}
+	if err := resp.Results[2].Decode(&p); err != nil || p.D.Name != "Renamed" {
+		t.Errorf("query after the changeset: %+v, %v", p.D, err)
+	}
+	if n := len(srv.Entities("ProductSet")); n != 3 {
+		t.Errorf("changeset was not rolled back, %d entities", n)
+	}
+}
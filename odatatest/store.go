@@ -0,0 +1,424 @@
+package odatatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Willias7788/go-odata-v2-sdk/internal/filter"
+)
+
+// Entity is the JSON representation of an entity as held by the mock server
+type Entity = map[string]interface{}
+
+// EntitySet is an in-memory entity set served by the mock server
+type EntitySet struct {
+	Name string
+	// Keys lists the key properties in declaration order
+	Keys []string
+	// EntityType is reported in __metadata.type, defaults to the set name
+	EntityType string
+
+	entities []Entity
+}
+
+// store holds the entity sets shared by Server and Fake; callers synchronize access
+type store struct {
+	sets  map[string]*EntitySet
+	order []string // entity set names in registration order for the service document
+}
+
+func newStore() store {
+	return store{sets: make(map[string]*EntitySet)}
+}
+
+func (st *store) addEntitySet(name string, keys []string) *EntitySet {
+	if len(keys) == 0 {
+		panic(fmt.Sprintf("odatatest: entity set %s needs at least one key property", name))
+	}
+	es := &EntitySet{Name: name, Keys: keys, EntityType: name}
+	if _, exists := st.sets[name]; !exists {
+		st.order = append(st.order, name)
+	}
+	st.sets[name] = es
+	return es
+}
+
+func (st *store) seed(set string, entities []interface{}) error {
+	es, ok := st.sets[set]
+	if !ok {
+		return fmt.Errorf("entity set %s not registered", set)
+	}
+	for _, v := range entities {
+		e, err := toEntity(v)
+		if err != nil {
+			return fmt.Errorf("seeding %s: %w", set, err)
+		}
+		if err := es.insert(e); err != nil {
+			return fmt.Errorf("seeding %s: %w", set, err)
+		}
+	}
+	return nil
+}
+
+func (st *store) entities(set string) []Entity {
+	es, ok := st.sets[set]
+	if !ok {
+		return nil
+	}
+	out := make([]Entity, len(es.entities))
+	for i, e := range es.entities {
+		out[i] = clone(e)
+	}
+	return out
+}
+
+// snapshot copies the content of all entity sets so a failed changeset can be rolled back
+func (st *store) snapshot() map[string][]Entity {
+	snap := make(map[string][]Entity, len(st.sets))
+	for name, es := range st.sets {
+		entities := make([]Entity, len(es.entities))
+		for i, e := range es.entities {
+			entities[i] = clone(e)
+		}
+		snap[name] = entities
+	}
+	return snap
+}
+
+func (st *store) restore(snap map[string][]Entity) {
+	for name, entities := range snap {
+		if es, ok := st.sets[name]; ok {
+			es.entities = entities
+		}
+	}
+}
+
+// toEntity normalizes v (struct, map or raw JSON) to the JSON form the server stores.
+// Numbers are kept as json.Number so integers round-trip unchanged.
+func toEntity(v interface{}) (Entity, error) {
+	var raw []byte
+	switch v := v.(type) {
+	case []byte:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var e Entity
+	if err := dec.Decode(&e); err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("entity must be a JSON object")
+	}
+	// Payloads from the SDK may carry the d wrapper or metadata of a previous read
+	if d, ok := e["d"].(map[string]interface{}); ok && len(e) == 1 {
+		e = d
+	}
+	delete(e, "__metadata")
+	return e, nil
+}
+
+// clone returns a deep copy so callers never share maps with the store
+func clone(e Entity) Entity {
+	out := make(Entity, len(e))
+	for k, v := range e {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = clone(m)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// parseKey parses a key predicate body like "'001'", "42" or "SalesOrderID='1',Item=10"
+func (es *EntitySet) parseKey(predicate string) (map[string]interface{}, error) {
+	parts := splitOutsideQuotes(predicate, ',')
+	key := make(map[string]interface{}, len(parts))
+	for _, part := range parts {
+		name, literal := "", part
+		if i := strings.Index(part, "="); i >= 0 && !strings.HasPrefix(strings.TrimSpace(part), "'") {
+			name, literal = strings.TrimSpace(part[:i]), part[i+1:]
+		}
+		if name == "" {
+			if len(parts) != 1 || len(es.Keys) != 1 {
+				return nil, fmt.Errorf("key of %s must name all of its properties %v", es.Name, es.Keys)
+			}
+			name = es.Keys[0]
+		}
+		n, err := filter.Parse(literal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key value %q: %w", literal, err)
+		}
+		lit, ok := n.(*filter.Literal)
+		if !ok {
+			return nil, fmt.Errorf("key value %q is not a literal", literal)
+		}
+		key[name] = lit.Value
+	}
+	for _, k := range es.Keys {
+		if _, ok := key[k]; !ok {
+			return nil, fmt.Errorf("key property %s missing in key predicate (%s)", k, predicate)
+		}
+	}
+	return key, nil
+}
+
+// storeError is a failed store operation; Server renders it as an HTTP error payload,
+// Fake returns it as *models.ODataErrorResponse
+type storeError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *storeError) Error() string { return e.message }
+
+func badRequest(format string, args ...interface{}) *storeError {
+	return &storeError{status: http.StatusBadRequest, code: "BadRequest", message: fmt.Sprintf(format, args...)}
+}
+
+// lookup resolves a key predicate to the index of the entity it addresses
+func (es *EntitySet) lookup(predicate string) (int, error) {
+	key, err := es.parseKey(predicate)
+	if err != nil {
+		return -1, badRequest("%v", err)
+	}
+	idx := es.find(key)
+	if idx < 0 {
+		return -1, &storeError{
+			status:  http.StatusNotFound,
+			code:    "/IWBEP/CM_MGW_RT/020",
+			message: fmt.Sprintf("Resource not found for segment '%s(%s)'", es.Name, predicate),
+		}
+	}
+	return idx, nil
+}
+
+// insert adds a new entity, rejecting missing or duplicate keys
+func (es *EntitySet) insert(e Entity) error {
+	key, ok := es.keyOf(e)
+	if !ok {
+		return badRequest("key properties %v are required", es.Keys)
+	}
+	if es.find(key) >= 0 {
+		return &storeError{
+			status:  http.StatusConflict,
+			code:    "/IWBEP/CM_MGW_RT/022",
+			message: fmt.Sprintf("Entity %s%s already exists", es.Name, es.keyPredicate(e)),
+		}
+	}
+	es.entities = append(es.entities, e)
+	return nil
+}
+
+// update replaces (PUT) or merges (PATCH/MERGE) the entity addressed by predicate.
+// Key properties are immutable.
+func (es *EntitySet) update(predicate string, patch Entity, merge bool) error {
+	idx, err := es.lookup(predicate)
+	if err != nil {
+		return err
+	}
+	current := es.entities[idx]
+	updated := clone(patch)
+	if merge {
+		updated = clone(current)
+		for k, v := range patch {
+			updated[k] = v
+		}
+	}
+	for _, k := range es.Keys {
+		if pv, ok := patch[k]; ok && !filter.Equal(pv, current[k]) {
+			return badRequest("key property %s cannot be changed", k)
+		}
+		updated[k] = current[k]
+	}
+	es.entities[idx] = updated
+	return nil
+}
+
+// remove deletes the entity addressed by predicate
+func (es *EntitySet) remove(predicate string) error {
+	idx, err := es.lookup(predicate)
+	if err != nil {
+		return err
+	}
+	es.entities = append(es.entities[:idx], es.entities[idx+1:]...)
+	return nil
+}
+
+// find returns the index of the entity matching key, or -1
+func (es *EntitySet) find(key map[string]interface{}) int {
+	for i, e := range es.entities {
+		match := true
+		for k, v := range key {
+			if !filter.Equal(e[k], v) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyOf extracts the key values of e; ok is false when one is missing
+func (es *EntitySet) keyOf(e Entity) (map[string]interface{}, bool) {
+	key := make(map[string]interface{}, len(es.Keys))
+	for _, k := range es.Keys {
+		v, ok := e[k]
+		if !ok || v == nil {
+			return nil, false
+		}
+		key[k] = v
+	}
+	return key, true
+}
+
+// keyPredicate renders the canonical key predicate of e, e.g. ('001') or (OrderID='1',Item=10)
+func (es *EntitySet) keyPredicate(e Entity) string {
+	format := func(v interface{}) string {
+		switch v := v.(type) {
+		case string:
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	if len(es.Keys) == 1 {
+		return "(" + format(e[es.Keys[0]]) + ")"
+	}
+	parts := make([]string, len(es.Keys))
+	for i, k := range es.Keys {
+		parts[i] = k + "=" + format(e[k])
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// query applies $filter, $orderby, $skip, $top and $select. total is the match count before paging.
+func (es *EntitySet) query(q queryOptions) (results []Entity, total int, err error) {
+	var where filter.Node
+	if q.filter != "" {
+		if where, err = filter.Parse(q.filter); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for _, e := range es.entities {
+		if where != nil {
+			ok, err := filter.Match(where, e)
+			if err != nil {
+				return nil, 0, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		results = append(results, e)
+	}
+
+	if q.orderby != "" {
+		terms, err := filter.ParseOrderBy(q.orderby)
+		if err != nil {
+			return nil, 0, err
+		}
+		filter.Sort(results, terms)
+	}
+
+	total = len(results)
+	if q.skip > 0 {
+		results = results[min(q.skip, len(results)):]
+	}
+	if q.top >= 0 && q.top < len(results) {
+		results = results[:q.top]
+	}
+
+	out := make([]Entity, len(results))
+	for i, e := range results {
+		out[i] = project(e, q.selects)
+	}
+	return out, total, nil
+}
+
+type queryOptions struct {
+	filter  string
+	orderby string
+	top     int // -1 when absent
+	skip    int
+	// skiptoken is the offset of a server-driven page, as emitted in __next by this server
+	skiptoken int
+	selects   []string
+	count     bool // $inlinecount=allpages
+}
+
+func parseQueryOptions(get func(string) string) (queryOptions, error) {
+	q := queryOptions{
+		filter:  get("$filter"),
+		orderby: get("$orderby"),
+		top:     -1,
+	}
+	for name, dst := range map[string]*int{"$top": &q.top, "$skip": &q.skip, "$skiptoken": &q.skiptoken} {
+		if raw := get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid value %q for %s, expected a non-negative integer", raw, name)
+			}
+			*dst = n
+		}
+	}
+	if sel := get("$select"); sel != "" && sel != "*" {
+		for _, f := range strings.Split(sel, ",") {
+			q.selects = append(q.selects, strings.TrimSpace(f))
+		}
+	}
+	switch get("$inlinecount") {
+	case "", "none":
+	case "allpages":
+		q.count = true
+	default:
+		return q, fmt.Errorf("invalid value %q for $inlinecount", get("$inlinecount"))
+	}
+	return q, nil
+}
+
+func project(e Entity, selects []string) Entity {
+	if len(selects) == 0 {
+		return clone(e)
+	}
+	out := make(Entity, len(selects))
+	for _, f := range selects {
+		if v, ok := e[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+// splitOutsideQuotes splits s at sep, ignoring separators inside '...' literals
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package outbox
+
+// lockFile is a no-op where flock is not available; a FileQueue there must only be used
+// by a single process
+func lockFile(string) (unlock func() error, err error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package outbox
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on path, creating the file if needed, and returns the
+// function releasing it. The lock is held per open file, so it also excludes other
+// FileQueues of the same process.
+func lockFile(path string) (unlock func() error, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f.Close, nil // closing the file releases the lock
+}
@@ -0,0 +1,248 @@
+// Package outbox queues writes durably while the SAP system is unreachable and sends them, in
+// order, once it is back, for edge deployments such as shop-floor terminals that must accept
+// bookings during network outages.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+)
+
+// Policy controls how an outbox retries
+type Policy struct {
+	// Backoff is the delay of Run after a flush failed because the service was unreachable,
+	// doubled per failure with full jitter (default 1s)
+	Backoff time.Duration
+	// MaxBackoff caps the delay (default 5m)
+	MaxBackoff time.Duration
+	// Interval is how often Run flushes while the queue is empty, to send entries appended
+	// by other processes sharing a FileQueue (default 30s)
+	Interval time.Duration
+	// MaxAttempts is how often an entry the service rejects is sent before it is dropped and
+	// passed to OnRejected (default 3). Later entries wait until then, to keep the order.
+	MaxAttempts int
+}
+
+// Outbox queues writes to a service and sends them in order. Entries are only removed once
+// the service accepted them, so a create whose response was lost to a network failure is
+// sent again; give entities client-side keys where duplicates must be avoided.
+type Outbox struct {
+	service *odata.Service
+	queue   Queue
+	Policy  Policy
+	// OnRejected, if set, receives entries dropped after MaxAttempts rejections
+	OnRejected func(ctx context.Context, e Entry, err error)
+
+	flush sync.Mutex // one flush at a time, to keep the order
+	wake  chan struct{}
+}
+
+// New returns an outbox sending the entries of queue to s
+func New(s *odata.Service, queue Queue) *Outbox {
+	return &Outbox{service: s, queue: queue, wake: make(chan struct{}, 1)}
+}
+
+// Create queues the creation of an entity
+func (o *Outbox) Create(ctx context.Context, entitySet string, payload interface{}) error {
+	return o.enqueue(ctx, odata.OpCreate, entitySet, "", payload)
+}
+
+// Update queues a full update (PUT) of an entity
+func (o *Outbox) Update(ctx context.Context, entitySet, key string, payload interface{}) error {
+	return o.enqueue(ctx, odata.OpUpdate, entitySet, key, payload)
+}
+
+// Patch queues a partial update (PATCH/MERGE) of an entity
+func (o *Outbox) Patch(ctx context.Context, entitySet, key string, payload interface{}) error {
+	return o.enqueue(ctx, odata.OpPatch, entitySet, key, payload)
+}
+
+// Delete queues the deletion of an entity
+func (o *Outbox) Delete(ctx context.Context, entitySet, key string) error {
+	return o.enqueue(ctx, odata.OpDelete, entitySet, key, nil)
+}
+
+func (o *Outbox) enqueue(ctx context.Context, operation, entitySet, key string, payload interface{}) error {
+	e := &Entry{Operation: operation, EntitySet: entitySet, Key: key, Enqueued: time.Now()}
+	if payload != nil {
+		var err error
+		if e.Payload, err = o.service.EncodePayload(payload); err != nil {
+			return err
+		}
+	}
+	if err := o.queue.Append(ctx, e); err != nil {
+		return fmt.Errorf("queueing %s of %s: %w", operation, entitySet, err)
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the queued entries in order
+func (o *Outbox) Pending(ctx context.Context) ([]Entry, error) {
+	return o.queue.List(ctx)
+}
+
+// ErrUnreachable is matched (errors.Is) by the error of a flush that stopped because the
+// service could not be reached or was temporarily unavailable
+var ErrUnreachable = errors.New("outbox: service unreachable")
+
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string        { return fmt.Sprintf("%v: %v", ErrUnreachable, e.err) }
+func (e *unreachableError) Unwrap() error        { return e.err }
+func (e *unreachableError) Is(target error) bool { return target == ErrUnreachable }
+
+// Flush sends the queued entries in order and returns how many the service accepted. It stops
+// at the first entry that could not be delivered, which stays queued.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	o.flush.Lock()
+	defer o.flush.Unlock()
+
+	entries, err := o.queue.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s := o.service.WithContext(ctx)
+	sent := 0
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		err := send(s, e)
+		switch {
+		case err == nil:
+			if err := o.queue.Remove(ctx, e.ID); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		case transient(err):
+			return sent, &unreachableError{err: err}
+		}
+
+		e.Attempts++
+		e.LastError = err.Error()
+		if e.Attempts < o.maxAttempts() {
+			if qErr := o.queue.Update(ctx, e); qErr != nil {
+				return sent, qErr
+			}
+			return sent, fmt.Errorf("sending outbox entry %d: %w", e.ID, err)
+		}
+		if qErr := o.queue.Remove(ctx, e.ID); qErr != nil {
+			return sent, qErr
+		}
+		if o.OnRejected != nil {
+			o.OnRejected(ctx, e, err)
+		}
+	}
+	return sent, nil
+}
+
+// Run flushes the outbox until ctx is done: right away, whenever an entry is queued, every
+// Interval, and after a failed flush with growing backoff, which entries queued meanwhile
+// do not cut short. Errors are not returned but left
+// for Pending to show; Run returns ctx.Err().
+func (o *Outbox) Run(ctx context.Context) error {
+	failures := 0
+	for {
+		wait := o.interval()
+		if _, err := o.Flush(ctx); err != nil && ctx.Err() == nil {
+			wait = o.backoff(failures)
+			failures++
+		} else {
+			failures = 0
+		}
+		timer := time.NewTimer(wait)
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-o.wake:
+				// after a failed flush, new entries wait for the backoff like the queued ones
+				if failures == 0 {
+					timer.Stop()
+					waiting = false
+				}
+			case <-timer.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+// send performs the write of e
+func send(s *odata.Service, e Entry) error {
+	var payload interface{}
+	if e.Payload != nil {
+		payload = e.Payload
+	}
+	switch e.Operation {
+	case odata.OpCreate:
+		_, err := odata.CreateEntity[map[string]interface{}](s, e.EntitySet, payload)
+		return err
+	case odata.OpUpdate:
+		return odata.UpdateEntity(s, e.EntitySet, e.Key, payload)
+	case odata.OpPatch:
+		return odata.PatchEntity(s, e.EntitySet, e.Key, payload)
+	case odata.OpDelete:
+		return odata.DeleteEntity(s, e.EntitySet, e.Key)
+	}
+	return fmt.Errorf("unknown operation %q", e.Operation)
+}
+
+// transient reports whether err means the service could not take the request right now,
+// rather than that it rejected it
+func transient(err error) bool {
+	var reqErr *odata.RequestError
+	if !errors.As(err, &reqErr) {
+		return false
+	}
+	switch code := reqErr.StatusCode; {
+	case code == 0, code >= 500, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+func (o *Outbox) maxAttempts() int {
+	if o.Policy.MaxAttempts > 0 {
+		return o.Policy.MaxAttempts
+	}
+	return 3
+}
+
+func (o *Outbox) interval() time.Duration {
+	if o.Policy.Interval > 0 {
+		return o.Policy.Interval
+	}
+	return 30 * time.Second
+}
+
+// backoff returns the delay after the given number of consecutive failed flushes
+func (o *Outbox) backoff(failures int) time.Duration {
+	base, ceiling := o.Policy.Backoff, o.Policy.MaxBackoff
+	if base <= 0 {
+		base = time.Second
+	}
+	if ceiling <= 0 {
+		ceiling = 5 * time.Minute
+	}
+	d := ceiling
+	if failures < 30 { // beyond that the shift overflows
+		d = min(base<<failures, ceiling)
+	}
+	return rand.N(d) + 1
+}
@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Willias7788/go-odata-v2-sdk/client"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+	"github.com/Willias7788/go-odata-v2-sdk/odatatest"
+)
+
+type booking struct {
+	BookingID string
+	Quantity  int
+}
+
+func newBookingServer(t *testing.T) (*odatatest.Server, *odata.Service) {
+	t.Helper()
+	srv := odatatest.NewServer("/sap/opu/odata/sap/ZBOOKING_SRV")
+	t.Cleanup(srv.Close)
+	srv.AddEntitySet("BookingSet", "BookingID")
+	if err := srv.Seed("BookingSet", booking{BookingID: "1", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	return srv, odata.NewService(client.NewSAPClient(srv.URL, "", ""), srv.ServicePath)
+}
+
+func bookings(srv *odatatest.Server) map[string]string {
+	out := make(map[string]string)
+	for _, e := range srv.Entities("BookingSet") {
+		out[e["BookingID"].(string)] = fmt.Sprint(e["Quantity"])
+	}
+	return out
+}
+
+func TestFlushInOrder(t *testing.T) {
+	ctx := context.Background()
+	srv, service := newBookingServer(t)
+	box := New(service, NewMemoryQueue())
+
+	steps := []error{
+		box.Create(ctx, "BookingSet", booking{BookingID: "2", Quantity: 5}),
+		box.Patch(ctx, "BookingSet", "('2')", map[string]interface{}{"Quantity": 6}),
+		box.Update(ctx, "BookingSet", "('1')", booking{BookingID: "1", Quantity: 3}),
+		box.Delete(ctx, "BookingSet", "('1')"),
+	}
+	if err := errors.Join(steps...); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := box.Pending(ctx)
+	if len(pending) != 4 || pending[0].ID != 1 || pending[3].ID != 4 || pending[1].Operation != odata.OpPatch {
+		t.Fatalf("Pending() = %+v", pending)
+	}
+
+	// the gateway is down: nothing is sent and nothing is lost
+	srv.AddFault(odatatest.Fault{Method: http.MethodPost, Path: "BookingSet", Times: 1})
+	sent, err := box.Flush(ctx)
+	if !errors.Is(err, ErrUnreachable) || sent != 0 {
+		t.Fatalf("Flush() while unreachable = %d, %v, want 0 and ErrUnreachable", sent, err)
+	}
+	if pending, _ := box.Pending(ctx); len(pending) != 4 || pending[0].Attempts != 0 {
+		t.Errorf("after an unreachable flush Pending() = %+v, want all 4 entries, unattempted", pending)
+	}
+
+	sent, err = box.Flush(ctx)
+	if err != nil || sent != 4 {
+		t.Fatalf("Flush() = %d, %v, want 4 sent", sent, err)
+	}
+	if got := bookings(srv); len(got) != 1 || got["2"] != "6" {
+		t.Errorf("gateway holds %v, want only booking 2 with quantity 6", got)
+	}
+	if pending, _ := box.Pending(ctx); len(pending) != 0 {
+		t.Errorf("Pending() after the flush = %+v", pending)
+	}
+}
+
+func TestFlushRejected(t *testing.T) {
+	ctx := context.Background()
+	srv, service := newBookingServer(t)
+	box := New(service, NewMemoryQueue())
+	box.Policy.MaxAttempts = 2
+	var rejected []Entry
+	box.OnRejected = func(_ context.Context, e Entry, err error) {
+		rejected = append(rejected, e)
+	}
+
+	_ = box.Create(ctx, "BookingSet", booking{BookingID: "1"}) // exists already
+	_ = box.Create(ctx, "BookingSet", booking{BookingID: "3", Quantity: 2})
+
+	sent, err := box.Flush(ctx)
+	if err == nil || errors.Is(err, ErrUnreachable) || sent != 0 {
+		t.Fatalf("first Flush() = %d, %v, want the rejection", sent, err)
+	}
+	pending, _ := box.Pending(ctx)
+	if len(pending) != 2 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Fatalf("after a rejection Pending() = %+v, want the entry kept with its error", pending)
+	}
+	if _, ok := bookings(srv)["3"]; ok {
+		t.Error("the create was sent before the rejected entry ahead of it")
+	}
+
+	sent, err = box.Flush(ctx)
+	if err != nil || sent != 1 {
+		t.Fatalf("second Flush() = %d, %v, want the rejected entry dropped and 1 sent", sent, err)
+	}
+	if len(rejected) != 1 || rejected[0].Operation != odata.OpCreate || rejected[0].Attempts != 2 {
+		t.Errorf("OnRejected received %+v", rejected)
+	}
+	if got := bookings(srv); got["3"] != "2" {
+		t.Errorf("gateway holds %v, want booking 3", got)
+	}
+}
+
+func TestFileQueue(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox", "queue.json")
+	q, err := NewFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, set := range []string{"A", "B", "C"} {
+		if err := q.Append(ctx, &Entry{Operation: odata.OpCreate, EntitySet: set}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Remove(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Update(ctx, Entry{ID: 2, Operation: odata.OpCreate, EntitySet: "B", Attempts: 1, LastError: "rejected"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := reopened.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != 2 || entries[0].Attempts != 1 || entries[1].EntitySet != "C" {
+		t.Fatalf("reopened queue lists %+v", entries)
+	}
+	e := &Entry{Operation: odata.OpDelete, EntitySet: "D"}
+	if err := reopened.Append(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 4 {
+		t.Errorf("ID after reopening = %d, want 4: IDs must not be reused", e.ID)
+	}
+}
+
+// TestFileQueueShared appends through two queues on the same file, as two processes would
+func TestFileQueueShared(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.json")
+	var queues [2]*FileQueue
+	for i := range queues {
+		q, err := NewFileQueue(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queues[i] = q
+	}
+
+	var wg sync.WaitGroup
+	for _, q := range queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				if err := q.Append(ctx, &Entry{Operation: odata.OpCreate, EntitySet: "BookingSet"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, q := range queues {
+		entries, err := q.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 50 {
+			t.Fatalf("queue %d lists %d entries, want all 50", i, len(entries))
+		}
+		for j, e := range entries {
+			if e.ID != uint64(j+1) {
+				t.Fatalf("queue %d: entry %d has ID %d, want IDs 1 to 50 in order", i, j, e.ID)
+			}
+		}
+	}
+
+	// a removal by the flushing process is seen by the other one
+	if err := queues[0].Remove(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := queues[1].List(ctx); len(entries) != 49 || entries[0].ID != 2 {
+		t.Errorf("other queue lists %d entries starting at %d after a removal", len(entries), entries[0].ID)
+	}
+}
@@ -0,0 +1,237 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Entry is a write waiting in the outbox
+type Entry struct {
+	// ID is assigned by the queue, increasing in enqueue order
+	ID        uint64          `json:"id"`
+	Operation string          `json:"operation"` // odata.OpCreate, OpUpdate, OpPatch or OpDelete
+	EntitySet string          `json:"entitySet"`
+	Key       string          `json:"key,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Enqueued  time.Time       `json:"enqueued"`
+	// Attempts counts the times the service rejected the entry
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Queue persists the entries of an outbox in order. Implement it on top of SQLite or any
+// other local database; MemoryQueue and FileQueue need no dependencies.
+type Queue interface {
+	// Append stores e at the end of the queue and sets its ID
+	Append(ctx context.Context, e *Entry) error
+	// List returns the entries in order
+	List(ctx context.Context) ([]Entry, error)
+	// Update replaces the stored entry with the ID of e
+	Update(ctx context.Context, e Entry) error
+	Remove(ctx context.Context, id uint64) error
+}
+
+// queued is the content of a queue, shared by MemoryQueue and FileQueue
+type queued struct {
+	LastID  uint64  `json:"lastId"`
+	Entries []Entry `json:"entries"`
+}
+
+func (q *queued) append(e *Entry) {
+	q.LastID++
+	e.ID = q.LastID
+	q.Entries = append(q.Entries, *e)
+}
+
+func (q *queued) update(e Entry) {
+	for i := range q.Entries {
+		if q.Entries[i].ID == e.ID {
+			q.Entries[i] = e
+			return
+		}
+	}
+}
+
+func (q *queued) remove(id uint64) {
+	for i := range q.Entries {
+		if q.Entries[i].ID == id {
+			q.Entries = append(q.Entries[:i], q.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (q *queued) list() []Entry {
+	return append([]Entry(nil), q.Entries...)
+}
+
+// MemoryQueue is a Queue that is lost when the process ends, e.g. for tests
+type MemoryQueue struct {
+	mu sync.Mutex
+	q  queued
+}
+
+// NewMemoryQueue returns an empty MemoryQueue
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+func (m *MemoryQueue) Append(_ context.Context, e *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.q.append(e)
+	return nil
+}
+
+func (m *MemoryQueue) List(_ context.Context) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.q.list(), nil
+}
+
+func (m *MemoryQueue) Update(_ context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.q.update(e)
+	return nil
+}
+
+func (m *MemoryQueue) Remove(_ context.Context, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.q.remove(id)
+	return nil
+}
+
+// FileQueue is a Queue kept in a JSON file. Every change rewrites the file atomically, so an
+// entry is durable once Append returns and a crash leaves the previous state. Several
+// processes may share the file: each call rereads it under an advisory lock (see lockFile),
+// so entries appended by one process are seen by the others. Run Flush in one of them
+// only, since two flushing processes would send the same entries.
+type FileQueue struct {
+	path string
+
+	mu sync.Mutex // serializes the calls of this process, which the file lock does not
+}
+
+// NewFileQueue returns a FileQueue stored in path, creating its directory if needed. The
+// lock is taken on path + ".lock" next to it.
+func NewFileQueue(path string) (*FileQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating outbox: %w", err)
+	}
+	return &FileQueue{path: path}, nil
+}
+
+func (f *FileQueue) load() (*queued, error) {
+	q := &queued{}
+	raw, err := os.ReadFile(f.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading outbox: %w", err)
+	default:
+		if err := json.Unmarshal(raw, q); err != nil {
+			return nil, fmt.Errorf("reading outbox %s: %w", f.path, err)
+		}
+	}
+	return q, nil
+}
+
+// locked runs fn with the queue as it is on disk, holding the lock of this process and
+// of the file
+func (f *FileQueue) locked(fn func(*queued) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking outbox: %w", err)
+	}
+	defer unlock()
+	q, err := f.load()
+	if err != nil {
+		return err
+	}
+	return fn(q)
+}
+
+// save writes q through a temporary file renamed over the old one
+func (f *FileQueue) save(q *queued) error {
+	raw, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	if err := syncDir(filepath.Dir(f.path)); err != nil {
+		return fmt.Errorf("writing outbox: %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes the directory entry of a rename to disk. Windows cannot sync directories.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// change applies fn to the queue and saves it
+func (f *FileQueue) change(fn func(*queued)) error {
+	return f.locked(func(q *queued) error {
+		fn(q)
+		return f.save(q)
+	})
+}
+
+func (f *FileQueue) Append(_ context.Context, e *Entry) error {
+	return f.change(func(q *queued) { q.append(e) })
+}
+
+func (f *FileQueue) List(_ context.Context) ([]Entry, error) {
+	var entries []Entry
+	err := f.locked(func(q *queued) error {
+		entries = q.list()
+		return nil
+	})
+	return entries, err
+}
+
+func (f *FileQueue) Update(_ context.Context, e Entry) error {
+	return f.change(func(q *queued) { q.update(e) })
+}
+
+func (f *FileQueue) Remove(_ context.Context, id uint64) error {
+	return f.change(func(q *queued) { q.remove(id) })
+}
@@ -0,0 +1,193 @@
+// Package redisstore implements the SDK's store interfaces on Redis, so the instances of a
+// clustered deployment share ETags and replicated entity sets instead of each one keeping
+// and re-fetching its own.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Willias7788/go-odata-v2-sdk/datasync"
+	"github.com/Willias7788/go-odata-v2-sdk/odata"
+	"github.com/redis/go-redis/v9"
+)
+
+// Options configure the stores
+type Options struct {
+	// Namespace prefixes every key, so several applications or services can share one
+	// Redis; "odata" if empty
+	Namespace string
+	// TTL expires entries that were not written for that long; 0 keeps them
+	TTL time.Duration
+}
+
+func (o Options) key(parts ...string) string {
+	key := o.Namespace
+	if key == "" {
+		key = "odata"
+	}
+	for _, p := range parts {
+		key += ":" + p
+	}
+	return key
+}
+
+// ETagStore is an odata.ETagStore on Redis
+type ETagStore struct {
+	client redis.UniversalClient
+	opts   Options
+}
+
+var _ odata.ETagStore = (*ETagStore)(nil)
+
+// NewETagStore returns an ETagStore using client
+func NewETagStore(client redis.UniversalClient, opts Options) *ETagStore {
+	return &ETagStore{client: client, opts: opts}
+}
+
+func (s *ETagStore) Get(ctx context.Context, key string) (string, bool, error) {
+	etag, err := s.client.Get(ctx, s.opts.key("etag", key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("reading ETag from redis: %w", err)
+	}
+	return etag, true, nil
+}
+
+func (s *ETagStore) Set(ctx context.Context, key, etag string) error {
+	if err := s.client.Set(ctx, s.opts.key("etag", key), etag, s.opts.TTL).Err(); err != nil {
+		return fmt.Errorf("writing ETag to redis: %w", err)
+	}
+	return nil
+}
+
+func (s *ETagStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.opts.key("etag", key)).Err(); err != nil {
+		return fmt.Errorf("deleting ETag from redis: %w", err)
+	}
+	return nil
+}
+
+// SyncStore is a datasync.Store on Redis. Each entity set is a hash of its records next to
+// a key holding the checkpoint; the TTL applies to both and is renewed by every write.
+type SyncStore struct {
+	client redis.UniversalClient
+	opts   Options
+}
+
+var _ datasync.Store = (*SyncStore)(nil)
+
+// NewSyncStore returns a SyncStore using client
+func NewSyncStore(client redis.UniversalClient, opts Options) *SyncStore {
+	return &SyncStore{client: client, opts: opts}
+}
+
+func (s *SyncStore) records(entitySet string) string {
+	return s.opts.key("sync", entitySet, "records")
+}
+
+func (s *SyncStore) checkpoint(entitySet string) string {
+	return s.opts.key("sync", entitySet, "checkpoint")
+}
+
+// expire renews the TTL of the keys of entitySet within pipe
+func (s *SyncStore) expire(ctx context.Context, pipe redis.Pipeliner, entitySet string) {
+	if s.opts.TTL > 0 {
+		pipe.Expire(ctx, s.records(entitySet), s.opts.TTL)
+		pipe.Expire(ctx, s.checkpoint(entitySet), s.opts.TTL)
+	}
+}
+
+func (s *SyncStore) Get(ctx context.Context, entitySet, key string) (datasync.Record, bool, error) {
+	raw, err := s.client.HGet(ctx, s.records(entitySet), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return datasync.Record{}, false, nil
+	}
+	if err != nil {
+		return datasync.Record{}, false, fmt.Errorf("reading %s%s from redis: %w", entitySet, key, err)
+	}
+	var r datasync.Record
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return datasync.Record{}, false, fmt.Errorf("decoding %s%s from redis: %w", entitySet, key, err)
+	}
+	return r, true, nil
+}
+
+func (s *SyncStore) List(ctx context.Context, entitySet string) ([]datasync.Record, error) {
+	all, err := s.client.HGetAll(ctx, s.records(entitySet)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from redis: %w", entitySet, err)
+	}
+	records := make([]datasync.Record, 0, len(all))
+	for key, raw := range all {
+		var r datasync.Record
+		if err := json.Unmarshal([]byte(raw), &r); err != nil {
+			return nil, fmt.Errorf("decoding %s%s from redis: %w", entitySet, key, err)
+		}
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records, nil
+}
+
+func (s *SyncStore) Put(ctx context.Context, entitySet string, records ...datasync.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	values := make([]interface{}, 0, 2*len(records))
+	for _, r := range records {
+		raw, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		values = append(values, r.Key, raw)
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.records(entitySet), values...)
+		s.expire(ctx, pipe, entitySet)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s to redis: %w", entitySet, err)
+	}
+	return nil
+}
+
+func (s *SyncStore) Delete(ctx context.Context, entitySet string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := s.client.HDel(ctx, s.records(entitySet), keys...).Err(); err != nil {
+		return fmt.Errorf("deleting from %s in redis: %w", entitySet, err)
+	}
+	return nil
+}
+
+func (s *SyncStore) Checkpoint(ctx context.Context, entitySet string) (string, error) {
+	checkpoint, err := s.client.Get(ctx, s.checkpoint(entitySet)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading checkpoint of %s from redis: %w", entitySet, err)
+	}
+	return checkpoint, nil
+}
+
+func (s *SyncStore) SetCheckpoint(ctx context.Context, entitySet, checkpoint string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.checkpoint(entitySet), checkpoint, 0)
+		s.expire(ctx, pipe, entitySet)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("writing checkpoint of %s to redis: %w", entitySet, err)
+	}
+	return nil
+}